@@ -0,0 +1,113 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// annotationWatcher triggers a reload whenever the value of a pod annotation changes.
+// The annotations are read from a file projected through the downward API, which the
+// kubelet updates in place when the annotations of the pod change.
+type annotationWatcher struct {
+	logger    log.Logger
+	client    *http.Client
+	file      string
+	key       string
+	reloadURL *url.URL
+	interval  time.Duration
+
+	// The last observed annotation value and whether one was observed yet.
+	last     string
+	observed bool
+}
+
+// run checks the annotation file periodically until the context is cancelled.
+func (w *annotationWatcher) run(ctx context.Context) error {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := w.check(ctx); err != nil {
+				//nolint:errcheck
+				level.Error(w.logger).Log("msg", "checking reload annotation failed", "err", err)
+			}
+		}
+	}
+}
+
+// check reads the annotation and triggers a reload if its value changed since the
+// last check. The first observed value never triggers a reload.
+func (w *annotationWatcher) check(ctx context.Context) error {
+	b, err := os.ReadFile(w.file)
+	if err != nil {
+		return fmt.Errorf("read annotations file: %w", err)
+	}
+	value, err := annotationValue(string(b), w.key)
+	if err != nil {
+		return err
+	}
+	if !w.observed {
+		w.last, w.observed = value, true
+		return nil
+	}
+	if value == w.last {
+		return nil
+	}
+	//nolint:errcheck
+	level.Info(w.logger).Log("msg", "reload annotation changed, triggering reload", "key", w.key, "value", value)
+	if err := w.reload(ctx); err != nil {
+		// Keep the previous value so that the reload is retried on the next check.
+		return err
+	}
+	w.last = value
+	return nil
+}
+
+func (w *annotationWatcher) reload(ctx context.Context) error {
+	return sendReload(ctx, w.client, w.reloadURL, reloadTriggerAnnotation)
+}
+
+// annotationValue returns the value of the annotation with the given key from the
+// downward API annotations file format, which contains one key="value" pair per line.
+// Missing annotations have an empty value.
+func annotationValue(content, key string) (string, error) {
+	for _, line := range strings.Split(content, "\n") {
+		k, v, ok := strings.Cut(line, "=")
+		if !ok || k != key {
+			continue
+		}
+		value, err := strconv.Unquote(v)
+		if err != nil {
+			return "", fmt.Errorf("invalid value for annotation %q: %w", key, err)
+		}
+		return value, nil
+	}
+	return "", nil
+}
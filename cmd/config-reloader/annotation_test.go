@@ -0,0 +1,104 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+)
+
+func TestAnnotationWatcher(t *testing.T) {
+	var reloads int
+	reloadStatus := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/-/reload" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		reloads++
+		w.WriteHeader(reloadStatus)
+	}))
+	defer server.Close()
+
+	reloadURL, err := url.Parse(server.URL + "/-/reload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "annotations")
+	w := &annotationWatcher{
+		logger:    log.NewNopLogger(),
+		client:    server.Client(),
+		file:      file,
+		key:       "example.com/config-checksum",
+		reloadURL: reloadURL,
+		interval:  time.Second,
+	}
+	ctx := context.Background()
+
+	// Simulate the kubelet updating the projected annotations file.
+	check := func(content string, wantReloads int) {
+		t.Helper()
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.check(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if reloads != wantReloads {
+			t.Fatalf("expected %d reloads, got %d", wantReloads, reloads)
+		}
+	}
+
+	// The initial value does not trigger a reload.
+	check("kubectl.kubernetes.io/default-container=\"prometheus\"\nexample.com/config-checksum=\"abc\"\n", 0)
+	// Changes to other annotations are ignored.
+	check("kubectl.kubernetes.io/default-container=\"config-reloader\"\nexample.com/config-checksum=\"abc\"\n", 0)
+	// A changed checksum triggers a reload.
+	check("kubectl.kubernetes.io/default-container=\"config-reloader\"\nexample.com/config-checksum=\"def\"\n", 1)
+	check("kubectl.kubernetes.io/default-container=\"config-reloader\"\nexample.com/config-checksum=\"def\"\n", 1)
+	// Removing the annotation counts as a change.
+	check("kubectl.kubernetes.io/default-container=\"config-reloader\"\n", 2)
+
+	// Failed reloads are retried on the next check.
+	reloadStatus = http.StatusInternalServerError
+	if err := os.WriteFile(file, []byte("example.com/config-checksum=\"ghi\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.check(ctx); err == nil {
+		t.Fatal("expected reload error")
+	}
+	reloadStatus = http.StatusOK
+	if err := w.check(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if reloads != 4 {
+		t.Fatalf("expected 4 reloads, got %d", reloads)
+	}
+
+	// Malformed values are reported.
+	if err := os.WriteFile(file, []byte("example.com/config-checksum=ghi\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.check(ctx); err == nil {
+		t.Fatal("expected error for malformed annotation value")
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// reloadTrigger is the source of a reload request.
+type reloadTrigger string
+
+const (
+	// The reloader detected a change of the config file or watched directories, either
+	// through a file system notification or its periodic check.
+	reloadTriggerFileChange reloadTrigger = "file-change"
+	// The reload annotation of the pod changed.
+	reloadTriggerAnnotation reloadTrigger = "annotation"
+	// The config-reloader received a SIGHUP.
+	reloadTriggerSignal reloadTrigger = "sighup"
+	// The ..data symlink of a watched ConfigMap or Secret volume was swapped.
+	reloadTriggerSymlinkSwap reloadTrigger = "symlink-swap"
+)
+
+type reloadTriggerKey struct{}
+
+// withReloadTrigger returns a context for reload requests sent because of the trigger.
+func withReloadTrigger(ctx context.Context, trigger reloadTrigger) context.Context {
+	return context.WithValue(ctx, reloadTriggerKey{}, trigger)
+}
+
+// reloadTriggerFrom returns the trigger of a reload request. Requests sent by the reloader
+// itself carry no trigger and are caused by file changes.
+func reloadTriggerFrom(ctx context.Context) reloadTrigger {
+	if trigger, ok := ctx.Value(reloadTriggerKey{}).(reloadTrigger); ok {
+		return trigger
+	}
+	return reloadTriggerFileChange
+}
+
+// sendReload sends a reload request for the given trigger.
+func sendReload(ctx context.Context, client *http.Client, reloadURL *url.URL, trigger reloadTrigger) error {
+	req, err := http.NewRequestWithContext(withReloadTrigger(ctx, trigger), http.MethodPost, reloadURL.String(), nil)
+	if err != nil {
+		return fmt.Errorf("create reload request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("reload request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("reload request returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// auditTransport writes an audit log entry for every reload attempt with its trigger,
+// result, and the hashes of the configuration file at the last successful reload and at
+// the attempt. Reloads skipped during the backoff after failed reloads are not attempts.
+type auditTransport struct {
+	next    http.RoundTripper
+	logger  log.Logger
+	cfgFile string
+
+	mtx sync.Mutex
+	// Hash of the configuration file at the last successful reload. It is empty until the
+	// first successful reload as the configuration loaded at startup is not known.
+	lastHash string
+}
+
+func newAuditTransport(logger log.Logger, next http.RoundTripper, cfgFile string) *auditTransport {
+	return &auditTransport{
+		next:    next,
+		logger:  log.With(logger, "audit", "reload"),
+		cfgFile: cfgFile,
+	}
+}
+
+func (t *auditTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	hash, hashErr := fileHash(t.cfgFile)
+	resp, err := t.next.RoundTrip(req)
+	if errors.Is(err, errReloadSkipped) {
+		// The reload was not attempted.
+		return resp, err
+	}
+
+	keyvals := []interface{}{
+		"msg", "reload attempt",
+		"trigger", reloadTriggerFrom(req.Context()),
+		"old_hash", t.lastHash,
+		"new_hash", hash,
+	}
+	if hashErr != nil {
+		keyvals = append(keyvals, "hash_err", hashErr)
+	}
+	switch {
+	case err != nil:
+		keyvals = append(keyvals, "result", "failure", "err", err)
+	case resp.StatusCode != http.StatusOK:
+		keyvals = append(keyvals, "result", "failure", "status", resp.StatusCode)
+	default:
+		keyvals = append(keyvals, "result", "success")
+		t.lastHash = hash
+	}
+	//nolint:errcheck
+	level.Info(t.logger).Log(keyvals...)
+
+	return resp, err
+}
+
+// fileHash returns the hex-encoded SHA-256 hash of the file.
+func fileHash(name string) (string, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:]), nil
+}
@@ -0,0 +1,157 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAuditTransport(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusOK)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer server.Close()
+
+	reloadURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfgFile := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig := func(content string) string {
+		t.Helper()
+		if err := os.WriteFile(cfgFile, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		h := sha256.Sum256([]byte(content))
+		return hex.EncodeToString(h[:])
+	}
+
+	var buf bytes.Buffer
+	client := &http.Client{
+		Transport: newAuditTransport(log.NewJSONLogger(&buf), server.Client().Transport, cfgFile),
+	}
+
+	// Requests sent by the reloader itself carry no trigger.
+	hash1 := writeConfig("scrape_configs: []\n")
+	req, err := http.NewRequest(http.MethodPost, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	hash2 := writeConfig("scrape_configs: [{job_name: example}]\n")
+	if err := sendReload(context.Background(), client, reloadURL, reloadTriggerAnnotation); err != nil {
+		t.Fatal(err)
+	}
+
+	// Failed reloads don't update the last hash.
+	hash3 := writeConfig("invalid")
+	status.Store(http.StatusInternalServerError)
+	if err := sendReload(context.Background(), client, reloadURL, reloadTriggerSignal); err == nil {
+		t.Fatal("expected reload error")
+	}
+	status.Store(http.StatusOK)
+	if err := sendReload(context.Background(), client, reloadURL, reloadTriggerSignal); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, entry)
+	}
+	entry := func(trigger reloadTrigger, oldHash, newHash, result string) map[string]interface{} {
+		return map[string]interface{}{
+			"level":    "info",
+			"audit":    "reload",
+			"msg":      "reload attempt",
+			"trigger":  string(trigger),
+			"old_hash": oldHash,
+			"new_hash": newHash,
+			"result":   result,
+		}
+	}
+	failed := entry(reloadTriggerSignal, hash2, hash3, "failure")
+	failed["status"] = float64(http.StatusInternalServerError)
+
+	want := []map[string]interface{}{
+		entry(reloadTriggerFileChange, "", hash1, "success"),
+		entry(reloadTriggerAnnotation, hash1, hash2, "success"),
+		failed,
+		entry(reloadTriggerSignal, hash2, hash3, "success"),
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected audit log entries (-want, +got): %s", diff)
+	}
+}
+
+func TestAuditTransportSkipped(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	transport := newAuditTransport(log.NewJSONLogger(&buf), newBackoffTransport(server.Client().Transport, backoffConfig{
+		min:        time.Hour,
+		max:        time.Hour,
+		multiplier: 1,
+	}), filepath.Join(t.TempDir(), "config.yaml"))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	// Reloads skipped during the backoff are not logged as attempts.
+	if _, err := transport.RoundTrip(req); !errors.Is(err, errReloadSkipped) {
+		t.Fatalf("expected skipped reload, got %v", err)
+	}
+	if got := strings.Count(buf.String(), "reload attempt"); got != 1 {
+		t.Errorf("expected 1 audit log entry, got %d:\n%s", got, buf.String())
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"k8s.io/utils/clock"
+)
+
+// backoffConfig configures the exponential backoff between failed reload requests.
+type backoffConfig struct {
+	min, max   time.Duration
+	multiplier float64
+	// Fraction by which each delay is randomly increased or decreased.
+	jitter float64
+}
+
+func (c *backoffConfig) validate() error {
+	if c.min <= 0 {
+		return errors.New("minimum backoff must be positive")
+	}
+	if c.max < c.min {
+		return errors.New("maximum backoff must not be less than the minimum backoff")
+	}
+	if c.multiplier < 1 {
+		return errors.New("backoff multiplier must be at least 1")
+	}
+	if c.jitter < 0 || c.jitter > 1 {
+		return errors.New("backoff jitter must be between 0 and 1")
+	}
+	return nil
+}
+
+// errReloadSkipped is returned for reload requests that were not sent because the backoff
+// after failed reloads outlasts the request. They are not counted as failed reloads.
+var errReloadSkipped = errors.New("reload skipped during backoff after failed reloads")
+
+// backoffTransport delays reload requests after failed ones. Each consecutive failure
+// increases the delay before the next request is sent, up to the maximum backoff. A
+// successful request resets the delay.
+//
+// The reloader retries failed reloads in a fixed interval and with a fresh context for
+// each watch interval, so the backoff state is kept across requests rather than
+// retrying within a single request. Requests whose context ends before the delay passed
+// are skipped. Only reloads caused by file changes are delayed, reloads of other triggers
+// are requested explicitly and sent right away.
+type backoffTransport struct {
+	next   http.RoundTripper
+	cfg    backoffConfig
+	clock  clock.Clock
+	random func() float64
+
+	// Admits one delayed request at a time and guards the backoff state, so that
+	// concurrent requests do not all pass once the delay passed.
+	gate chan struct{}
+	// The delay applied after the next failure.
+	delay time.Duration
+	// The earliest time at which the next request may be sent.
+	notBefore time.Time
+}
+
+func newBackoffTransport(next http.RoundTripper, cfg backoffConfig) *backoffTransport {
+	return &backoffTransport{
+		next:   next,
+		cfg:    cfg,
+		clock:  clock.RealClock{},
+		random: rand.Float64,
+		gate:   make(chan struct{}, 1),
+		delay:  cfg.min,
+	}
+}
+
+func (t *backoffTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if reloadTriggerFrom(req.Context()) != reloadTriggerFileChange {
+		return t.next.RoundTrip(req)
+	}
+	ctx := req.Context()
+	select {
+	case t.gate <- struct{}{}:
+	case <-ctx.Done():
+		return nil, errReloadSkipped
+	}
+	defer func() { <-t.gate }()
+
+	if wait := t.notBefore.Sub(t.clock.Now()); wait > 0 {
+		if deadline, ok := ctx.Deadline(); ok && t.notBefore.After(deadline) {
+			return nil, errReloadSkipped
+		}
+		timer := t.clock.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, errReloadSkipped
+		case <-timer.C():
+		}
+	}
+	resp, err := t.next.RoundTrip(req)
+
+	if err == nil && resp.StatusCode == http.StatusOK {
+		t.delay, t.notBefore = t.cfg.min, time.Time{}
+		return resp, nil
+	}
+	t.notBefore = t.clock.Now().Add(t.jittered(t.delay))
+	t.delay = time.Duration(float64(t.delay) * t.cfg.multiplier)
+	if t.delay > t.cfg.max {
+		t.delay = t.cfg.max
+	}
+	return resp, err
+}
+
+// jittered randomly increases or decreases d by up to the configured jitter fraction.
+func (t *backoffTransport) jittered(d time.Duration) time.Duration {
+	return time.Duration(float64(d) * (1 + t.cfg.jitter*(2*t.random()-1)))
+}
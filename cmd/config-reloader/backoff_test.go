@@ -0,0 +1,284 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	tclock "k8s.io/utils/clock/testing"
+)
+
+func TestBackoffTransport(t *testing.T) {
+	start := time.Unix(1000, 0)
+	clock := tclock.NewFakeClock(start)
+
+	var (
+		mtx          sync.Mutex
+		requests     []time.Duration
+		reloadStatus = http.StatusInternalServerError
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		requests = append(requests, clock.Since(start))
+		w.WriteHeader(reloadStatus)
+	}))
+	defer server.Close()
+
+	transport := newBackoffTransport(server.Client().Transport, backoffConfig{
+		min:        time.Second,
+		max:        8 * time.Second,
+		multiplier: 2,
+		jitter:     0.5,
+	})
+	transport.clock = clock
+	// Disable the jitter by always picking the middle of the range.
+	transport.random = func() float64 { return 0.5 }
+
+	// reload sends a reload request and advances the fake clock while it is delayed.
+	reload := func(status int) {
+		t.Helper()
+		mtx.Lock()
+		reloadStatus = status
+		mtx.Unlock()
+
+		req, err := http.NewRequest(http.MethodPost, server.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		done := make(chan error)
+		go func() {
+			resp, err := transport.RoundTrip(req)
+			if err == nil {
+				resp.Body.Close()
+			}
+			done <- err
+		}()
+		for {
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			default:
+			}
+			if clock.HasWaiters() {
+				clock.Step(100 * time.Millisecond)
+			} else {
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}
+
+	for i := 0; i < 6; i++ {
+		reload(http.StatusInternalServerError)
+	}
+	// A successful reload resets the backoff.
+	reload(http.StatusOK)
+	reload(http.StatusInternalServerError)
+	reload(http.StatusInternalServerError)
+
+	want := []time.Duration{
+		0,
+		1 * time.Second,
+		3 * time.Second,
+		7 * time.Second,
+		15 * time.Second,
+		23 * time.Second,
+		31 * time.Second,
+		31 * time.Second,
+		32 * time.Second,
+	}
+	if diff := cmp.Diff(want, requests); diff != "" {
+		t.Errorf("unexpected request times (-want, +got): %s", diff)
+	}
+}
+
+func TestBackoffTransportSkip(t *testing.T) {
+	start := time.Now()
+	clock := tclock.NewFakeClock(start)
+
+	var (
+		mtx      sync.Mutex
+		requests int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		requests++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	transport := newBackoffTransport(server.Client().Transport, backoffConfig{
+		min:        time.Minute,
+		max:        time.Minute,
+		multiplier: 1,
+	})
+	transport.clock = clock
+
+	roundTrip := func(ctx context.Context) error {
+		t.Helper()
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	}
+	expectRequests := func(want int) {
+		t.Helper()
+		mtx.Lock()
+		defer mtx.Unlock()
+		if requests != want {
+			t.Fatalf("expected %d requests, got %d", want, requests)
+		}
+	}
+
+	if err := roundTrip(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	expectRequests(1)
+
+	// Requests that end before the backoff passed are skipped right away.
+	ctx, cancel := context.WithDeadline(context.Background(), start.Add(10*time.Second))
+	defer cancel()
+	if err := roundTrip(ctx); !errors.Is(err, errReloadSkipped) {
+		t.Fatalf("expected skipped reload, got %v", err)
+	}
+	expectRequests(1)
+
+	// Requests cancelled while waiting are skipped.
+	ctx, cancel = context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- roundTrip(ctx) }()
+	for !clock.HasWaiters() {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, errReloadSkipped) {
+		t.Fatalf("expected skipped reload, got %v", err)
+	}
+	expectRequests(1)
+
+	// Explicitly requested reloads are not delayed.
+	for _, trigger := range []reloadTrigger{reloadTriggerSignal, reloadTriggerAnnotation, reloadTriggerSymlinkSwap} {
+		if err := roundTrip(withReloadTrigger(context.Background(), trigger)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectRequests(4)
+}
+
+func TestBackoffTransportConcurrent(t *testing.T) {
+	clock := tclock.NewFakeClock(time.Now())
+
+	var (
+		mtx      sync.Mutex
+		requests int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		requests++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	transport := newBackoffTransport(server.Client().Transport, backoffConfig{
+		min:        time.Second,
+		max:        time.Second,
+		multiplier: 1,
+	})
+	transport.clock = clock
+
+	done := make(chan struct{})
+	roundTrip := func() {
+		req, err := http.NewRequest(http.MethodPost, server.URL, nil)
+		if err != nil {
+			t.Error(err)
+		}
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			t.Error(err)
+		} else {
+			resp.Body.Close()
+		}
+		done <- struct{}{}
+	}
+	expectRequests := func(want int) {
+		t.Helper()
+		mtx.Lock()
+		defer mtx.Unlock()
+		if requests != want {
+			t.Fatalf("expected %d requests, got %d", want, requests)
+		}
+	}
+
+	go roundTrip()
+	<-done
+	expectRequests(1)
+
+	// Of concurrent requests during the backoff, only one is sent once it passed. The
+	// other one waits for the backoff after its failure.
+	go roundTrip()
+	go roundTrip()
+	for !clock.HasWaiters() {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Step(time.Second)
+	<-done
+	expectRequests(2)
+	for !clock.HasWaiters() {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Step(time.Second)
+	<-done
+	expectRequests(3)
+}
+
+func TestBackoffTransportJitter(t *testing.T) {
+	transport := newBackoffTransport(http.DefaultTransport, backoffConfig{
+		min:        time.Second,
+		max:        time.Minute,
+		multiplier: 2,
+		jitter:     0.2,
+	})
+	for _, c := range []struct {
+		random float64
+		want   time.Duration
+	}{
+		{random: 0, want: 8 * time.Second},
+		{random: 0.5, want: 10 * time.Second},
+		{random: 1, want: 12 * time.Second},
+	} {
+		transport.random = func() float64 { return c.random }
+		if got := transport.jittered(10 * time.Second); got != c.want {
+			t.Errorf("expected delay %s for random value %v, got %s", c.want, c.random, got)
+		}
+	}
+}
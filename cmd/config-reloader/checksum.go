@@ -0,0 +1,153 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// checksumTransport suppresses reload requests of the reloader if the checksum of the
+// watched files did not change since the last successful reload. The reloader only reloads
+// if the contents changed as well, but its detection can be fooled by flapping modification
+// times, e.g. on NFS. Reloads of other triggers are always sent as they are requested
+// explicitly.
+type checksumTransport struct {
+	next   http.RoundTripper
+	logger log.Logger
+	// The rendered config file and the directories whose files are checksummed.
+	cfgFile string
+	dirs    []string
+
+	mtx sync.Mutex
+	// Checksum of the watched files at the last successful reload. It is empty until the
+	// first successful reload as the files loaded at startup are not known.
+	lastChecksum string
+
+	checksum   *prometheus.GaugeVec
+	suppressed prometheus.Counter
+}
+
+func newChecksumTransport(logger log.Logger, reg prometheus.Registerer, next http.RoundTripper, cfgFile string, dirs []string) *checksumTransport {
+	t := &checksumTransport{
+		next:    next,
+		logger:  logger,
+		cfgFile: cfgFile,
+		dirs:    dirs,
+		checksum: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "config_reloader_watched_files_checksum_info",
+			Help: "Always 1, with the SHA-256 checksum of the watched files as of the last reload request as label.",
+		}, []string{"checksum"}),
+		suppressed: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "config_reloader_suppressed_reloads_total",
+			Help: "Total number of reload requests that were suppressed because the checksum of the watched files did not change.",
+		}),
+	}
+	if reg != nil {
+		reg.MustRegister(t.checksum, t.suppressed)
+	}
+	return t
+}
+
+func (t *checksumTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	checksum, err := watchedFilesChecksum(t.cfgFile, t.dirs)
+	if err != nil {
+		// Do not hold back reloads if the files cannot be read. The reloader reports the
+		// actual error.
+		//nolint:errcheck
+		level.Warn(t.logger).Log("msg", "computing checksum of watched files failed", "err", err)
+		return t.next.RoundTrip(req)
+	}
+	t.checksum.Reset()
+	t.checksum.WithLabelValues(checksum).Set(1)
+
+	if reloadTriggerFrom(req.Context()) == reloadTriggerFileChange && checksum == t.lastChecksum {
+		t.suppressed.Inc()
+		//nolint:errcheck
+		level.Info(t.logger).Log("msg", "suppressed reload as the checksum of the watched files did not change", "checksum", checksum)
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return okResponse(req), nil
+	}
+	resp, err := t.next.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusOK {
+		t.lastChecksum = checksum
+	}
+	return resp, err
+}
+
+// watchedFilesChecksum returns the hex-encoded SHA-256 checksum of the names and contents
+// of the config file, if set, and the files in the directories. Subdirectories are not
+// included, like the reloader does not watch them. Symlinks are followed, so the files of
+// ConfigMap and Secret volumes are included but not the kubelet's internal directories.
+func watchedFilesChecksum(cfgFile string, dirs []string) (string, error) {
+	var files []string
+	if cfgFile != "" {
+		files = append(files, cfgFile)
+	}
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return "", fmt.Errorf("read directory: %w", err)
+		}
+		// The entries are sorted by name.
+		for _, e := range entries {
+			files = append(files, filepath.Join(dir, e.Name()))
+		}
+	}
+	h := sha256.New()
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			// Files may be removed in the meantime.
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return "", err
+		}
+		fi, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return "", err
+		}
+		if fi.IsDir() {
+			f.Close()
+			continue
+		}
+		// Delimit names and contents so that moving bytes between them changes the checksum.
+		fmt.Fprintf(h, "%s\x00%d\x00", name, fi.Size())
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return "", fmt.Errorf("read file %s: %w", name, err)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
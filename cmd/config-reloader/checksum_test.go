@@ -0,0 +1,170 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestChecksumTransport(t *testing.T) {
+	dir := t.TempDir()
+	cfgFile := filepath.Join(dir, "config.yaml")
+	rulesDir := filepath.Join(dir, "rules")
+	if err := os.Mkdir(rulesDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile := func(name, data string) {
+		t.Helper()
+		if err := os.WriteFile(name, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(cfgFile, "global: {}\n")
+	writeFile(filepath.Join(rulesDir, "rules.yaml"), "groups: []\n")
+
+	var (
+		reloads int
+		status  = http.StatusOK
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		reloads++
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	reloadURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := newChecksumTransport(log.NewNopLogger(), nil, server.Client().Transport, cfgFile, []string{rulesDir})
+	client := &http.Client{Transport: transport}
+
+	reloadExpect := func(trigger reloadTrigger, wantReloads int, wantErr bool) {
+		t.Helper()
+		err := sendReload(context.Background(), client, reloadURL, trigger)
+		if wantErr != (err != nil) {
+			t.Fatalf("expected error %v, got %v", wantErr, err)
+		}
+		if reloads != wantReloads {
+			t.Fatalf("expected %d reloads, got %d", wantReloads, reloads)
+		}
+	}
+
+	// The first reload is always sent.
+	reloadExpect(reloadTriggerFileChange, 1, false)
+	// Unchanged files, even with new modification times, do not cause further reloads.
+	reloadExpect(reloadTriggerFileChange, 1, false)
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(cfgFile, later, later); err != nil {
+		t.Fatal(err)
+	}
+	reloadExpect(reloadTriggerFileChange, 1, false)
+	if got := testutil.ToFloat64(transport.suppressed); got != 2 {
+		t.Errorf("expected 2 suppressed reloads, got %v", got)
+	}
+	// Explicitly requested reloads are always sent.
+	reloadExpect(reloadTriggerSignal, 2, false)
+	reloadExpect(reloadTriggerAnnotation, 3, false)
+
+	// Changes of the config file and of files in the watched directories cause reloads.
+	writeFile(cfgFile, "global:\n  scrape_interval: 10s\n")
+	reloadExpect(reloadTriggerFileChange, 4, false)
+	writeFile(filepath.Join(rulesDir, "more-rules.yaml"), "groups: []\n")
+	reloadExpect(reloadTriggerFileChange, 5, false)
+	reloadExpect(reloadTriggerFileChange, 5, false)
+
+	// Failed reloads are retried even if the files did not change in the meantime.
+	writeFile(cfgFile, "global: {}\n")
+	status = http.StatusInternalServerError
+	reloadExpect(reloadTriggerFileChange, 6, true)
+	status = http.StatusOK
+	reloadExpect(reloadTriggerFileChange, 7, false)
+	reloadExpect(reloadTriggerFileChange, 7, false)
+
+	// The current checksum is exposed as a label.
+	checksum, err := watchedFilesChecksum(cfgFile, []string{rulesDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := testutil.CollectAndCount(transport.checksum); got != 1 {
+		t.Errorf("expected a single checksum series, got %d", got)
+	}
+	if got := testutil.ToFloat64(transport.checksum.WithLabelValues(checksum)); got != 1 {
+		t.Errorf("expected checksum series %q with value 1, got %v", checksum, got)
+	}
+}
+
+func TestWatchedFilesChecksum(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.yaml"), []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	checksum := func() string {
+		t.Helper()
+		s, err := watchedFilesChecksum("", []string{dir})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	initial := checksum()
+
+	// Subdirectories and their files are ignored.
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "b.yaml"), []byte("b"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := checksum(); got != initial {
+		t.Errorf("expected checksum to ignore subdirectories")
+	}
+
+	// Symlinked files are included with the contents of their target.
+	if err := os.Symlink(filepath.Join("sub", "b.yaml"), filepath.Join(dir, "b.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	withLink := checksum()
+	if withLink == initial {
+		t.Errorf("expected checksum to include symlinked file")
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "b.yaml"), []byte("c"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := checksum(); got == withLink {
+		t.Errorf("expected checksum to change with the symlink target")
+	}
+
+	// Renaming a file changes the checksum.
+	if err := os.Rename(filepath.Join(dir, "a.yaml"), filepath.Join(dir, "c.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	renamed := checksum()
+	if err := os.Rename(filepath.Join(dir, "c.yaml"), filepath.Join(dir, "a.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	if renamed == checksum() {
+		t.Errorf("expected checksum to change with file names")
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// coalesceTransport coalesces reloads caused by changes of the watched files within a
+// window into a single reload, e.g. when several mounted Secrets are rotated one after
+// another. The first such reload is deferred by the window and reloads requested in the
+// meantime join it. All of them wait for the deferred reload and receive its result, so
+// that failed reloads are retried by the reloader.
+//
+// The reloader only requests another reload once the previous one returned. If the watched
+// files did not change since the last successful deferred reload, their changes were
+// loaded by it and the reload is answered right away. Reloads of other triggers are
+// always sent immediately as they are requested explicitly.
+type coalesceTransport struct {
+	next   http.RoundTripper
+	logger log.Logger
+	window time.Duration
+	// The rendered config file and the directories whose files are checksummed.
+	cfgFile string
+	dirs    []string
+	// afterFunc schedules the deferred reload, replaced in tests.
+	afterFunc func(time.Duration, func())
+
+	mtx sync.Mutex
+	// The deferred reload that has not been sent yet, nil if none is pending.
+	pending *coalescedReload
+	// Checksum of the watched files at the last successful deferred reload.
+	lastChecksum string
+
+	coalesced prometheus.Counter
+}
+
+// coalescedReload is a deferred reload shared by all requests that joined it.
+type coalescedReload struct {
+	req *http.Request
+	// Closed once the reload was sent and its result is set.
+	done chan struct{}
+	resp *http.Response
+	err  error
+}
+
+func newCoalesceTransport(logger log.Logger, reg prometheus.Registerer, next http.RoundTripper, window time.Duration, cfgFile string, dirs []string) *coalesceTransport {
+	t := &coalesceTransport{
+		next:    next,
+		logger:  logger,
+		window:  window,
+		cfgFile: cfgFile,
+		dirs:    dirs,
+		afterFunc: func(d time.Duration, f func()) {
+			time.AfterFunc(d, f)
+		},
+		coalesced: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "config_reloader_coalesced_changes_total",
+			Help: "Total number of reloads caused by changes of the watched files that were coalesced into another reload.",
+		}),
+	}
+	if reg != nil {
+		reg.MustRegister(t.coalesced)
+	}
+	return t
+}
+
+func (t *coalesceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	switch reloadTriggerFrom(req.Context()) {
+	case reloadTriggerFileChange, reloadTriggerSymlinkSwap:
+	default:
+		return t.next.RoundTrip(req)
+	}
+	// Reload requests carry no body, close it as the request is not passed on.
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	t.mtx.Lock()
+	r := t.pending
+	switch {
+	case r != nil:
+		t.coalesced.Inc()
+		//nolint:errcheck
+		level.Debug(t.logger).Log("msg", "coalescing reload into pending reload")
+	case t.lastChecksum != "" && t.checksum() == t.lastChecksum:
+		t.coalesced.Inc()
+		t.mtx.Unlock()
+		//nolint:errcheck
+		level.Debug(t.logger).Log("msg", "watched files were loaded by previous coalesced reload")
+		return okResponse(req), nil
+	default:
+		// The deferred reload is shared, so it must not be cancelled with the request
+		// that happens to start it.
+		pending := req.Clone(context.WithoutCancel(req.Context()))
+		pending.Body = nil
+		pending.ContentLength = 0
+		r = &coalescedReload{req: pending, done: make(chan struct{})}
+		t.pending = r
+		t.afterFunc(t.window, t.flush)
+		//nolint:errcheck
+		level.Debug(t.logger).Log("msg", "deferring reload to coalesce further changes", "window", t.window)
+	}
+	t.mtx.Unlock()
+
+	select {
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	case <-r.done:
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	// The body of the shared response was consumed when it was received.
+	resp := *r.resp
+	resp.Header = r.resp.Header.Clone()
+	resp.Body = http.NoBody
+	resp.Request = req
+	return &resp, nil
+}
+
+// flush sends the pending reload and passes its result to the requests that joined it.
+func (t *coalesceTransport) flush() {
+	t.mtx.Lock()
+	r := t.pending
+	t.pending = nil
+	// The files are loaded after the checksum is computed, so a successful reload
+	// includes at least the checksummed state.
+	checksum := t.checksum()
+	t.mtx.Unlock()
+
+	if r == nil {
+		return
+	}
+	defer close(r.done)
+
+	r.resp, r.err = t.next.RoundTrip(r.req)
+	if r.err != nil {
+		return
+	}
+	//nolint:errcheck
+	io.Copy(io.Discard, r.resp.Body)
+	r.resp.Body.Close()
+
+	if r.resp.StatusCode == http.StatusOK {
+		t.mtx.Lock()
+		t.lastChecksum = checksum
+		t.mtx.Unlock()
+	}
+}
+
+// checksum returns the checksum of the watched files, or an empty string if they cannot
+// be read so that reloads are not skipped.
+func (t *coalesceTransport) checksum() string {
+	checksum, err := watchedFilesChecksum(t.cfgFile, t.dirs)
+	if err != nil {
+		//nolint:errcheck
+		level.Warn(t.logger).Log("msg", "computing checksum of watched files failed", "err", err)
+		return ""
+	}
+	return checksum
+}
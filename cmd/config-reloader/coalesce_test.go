@@ -0,0 +1,171 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCoalesceTransport(t *testing.T) {
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "password")
+
+	var (
+		mtx     sync.Mutex
+		reloads []string
+		status  = http.StatusOK
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		// Record the file contents at the time of the reload.
+		data, err := os.ReadFile(secretFile)
+		if err != nil {
+			t.Error(err)
+		}
+		mtx.Lock()
+		defer mtx.Unlock()
+		reloads = append(reloads, string(data))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	reloadURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transport := newCoalesceTransport(log.NewNopLogger(), nil, server.Client().Transport, time.Minute, "", []string{dir})
+	scheduled := make(chan func(), 10)
+	transport.afterFunc = func(d time.Duration, f func()) {
+		if d != time.Minute {
+			t.Errorf("expected reload to be deferred by the window, got %v", d)
+		}
+		scheduled <- f
+	}
+	client := &http.Client{Transport: transport}
+
+	reload := func(trigger reloadTrigger) <-chan error {
+		errc := make(chan error, 1)
+		go func() {
+			errc <- sendReload(context.Background(), client, reloadURL, trigger)
+		}()
+		return errc
+	}
+	expectReloads := func(want ...string) {
+		t.Helper()
+		mtx.Lock()
+		defer mtx.Unlock()
+		if diff := cmp.Diff(want, reloads); diff != "" {
+			t.Fatalf("unexpected reloads (-want, +got): %s", diff)
+		}
+	}
+	expectCoalesced := func(want float64) {
+		t.Helper()
+		for start := time.Now(); testutil.ToFloat64(transport.coalesced) != want; {
+			if time.Since(start) > 10*time.Second {
+				t.Fatalf("expected %v coalesced changes, got %v", want, testutil.ToFloat64(transport.coalesced))
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	rotate := func(data string) {
+		t.Helper()
+		if err := os.WriteFile(secretFile, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Several rotations in short succession are coalesced into a single reload, which
+	// loads the latest contents. All requests wait for it.
+	rotate("a")
+	first := reload(reloadTriggerFileChange)
+	flush := <-scheduled
+	rotate("b")
+	second := reload(reloadTriggerSymlinkSwap)
+	expectCoalesced(1)
+	rotate("c")
+	expectReloads()
+
+	// Explicitly requested reloads are sent immediately.
+	if err := <-reload(reloadTriggerSignal); err != nil {
+		t.Fatal(err)
+	}
+	expectReloads("c")
+
+	flush()
+	for _, errc := range []<-chan error{first, second} {
+		if err := <-errc; err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectReloads("c", "c")
+
+	// Further reloads of the reloader for the changes that were already loaded are
+	// answered right away.
+	if err := <-reload(reloadTriggerFileChange); err != nil {
+		t.Fatal(err)
+	}
+	expectCoalesced(2)
+	expectReloads("c", "c")
+
+	// A failed deferred reload is returned to the reloader, whose retry is deferred and
+	// sent again.
+	rotate("d")
+	mtx.Lock()
+	status = http.StatusInternalServerError
+	mtx.Unlock()
+	failed := reload(reloadTriggerFileChange)
+	(<-scheduled)()
+	if err := <-failed; err == nil {
+		t.Fatal("expected failed reload to return an error")
+	}
+	expectReloads("c", "c", "d")
+
+	mtx.Lock()
+	status = http.StatusOK
+	mtx.Unlock()
+	retried := reload(reloadTriggerFileChange)
+	(<-scheduled)()
+	if err := <-retried; err != nil {
+		t.Fatal(err)
+	}
+	expectReloads("c", "c", "d", "d")
+	expectCoalesced(2)
+
+	// A cancelled request does not cancel the deferred reload.
+	rotate("e")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := make(chan error, 1)
+	go func() {
+		cancelled <- sendReload(ctx, client, reloadURL, reloadTriggerFileChange)
+	}()
+	flush = <-scheduled
+	cancel()
+	if err := <-cancelled; err == nil {
+		t.Fatal("expected cancelled reload to return an error")
+	}
+	flush()
+	expectReloads("c", "c", "d", "d", "e")
+}
@@ -0,0 +1,137 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// configMapWriter copies the rendered configuration file into a key of a ConfigMap through
+// the Kubernetes API so that other consumers can read it. The ConfigMap is created if it
+// does not exist and other keys are left unchanged.
+type configMapWriter struct {
+	logger    log.Logger
+	client    kubernetes.Interface
+	namespace string
+	name      string
+	key       string
+	file      string
+	interval  time.Duration
+
+	// Contents last written to the ConfigMap.
+	last []byte
+
+	failures prometheus.Counter
+}
+
+func newConfigMapWriter(logger log.Logger, reg prometheus.Registerer, client kubernetes.Interface, namespace, name, key, file string, interval time.Duration) *configMapWriter {
+	w := &configMapWriter{
+		logger:    logger,
+		client:    client,
+		namespace: namespace,
+		name:      name,
+		key:       key,
+		file:      file,
+		interval:  interval,
+		failures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "config_reloader_config_map_update_failures_total",
+			Help: "Total number of failed updates of the ConfigMap that the rendered configuration is written to.",
+		}),
+	}
+	if reg != nil {
+		reg.MustRegister(w.failures)
+	}
+	return w
+}
+
+// run periodically writes the rendered configuration file to the ConfigMap until the
+// context is cancelled.
+func (w *configMapWriter) run(ctx context.Context) error {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.apply(ctx); err != nil {
+			w.failures.Inc()
+			//nolint:errcheck
+			level.Error(w.logger).Log("msg", "updating ConfigMap failed", "namespace", w.namespace, "name", w.name, "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// apply writes the rendered configuration file to the ConfigMap if it has changed. It does
+// nothing if the file has not been rendered yet.
+func (w *configMapWriter) apply(ctx context.Context) error {
+	b, err := os.ReadFile(w.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+	if w.last != nil && bytes.Equal(w.last, b) {
+		return nil
+	}
+	configMaps := w.client.CoreV1().ConfigMaps(w.namespace)
+
+	cm, err := configMaps.Get(ctx, w.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: w.namespace,
+				Name:      w.name,
+			},
+			Data: map[string]string{w.key: string(b)},
+		}
+		if _, err := configMaps.Create(ctx, cm, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("create ConfigMap: %w", err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("get ConfigMap: %w", err)
+	} else if v, ok := cm.Data[w.key]; ok && v == string(b) {
+		w.last = b
+		return nil
+	} else {
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		cm.Data[w.key] = string(b)
+		if _, err := configMaps.Update(ctx, cm, metav1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("update ConfigMap: %w", err)
+		}
+	}
+	w.last = b
+	//nolint:errcheck
+	level.Info(w.logger).Log("msg", "updated ConfigMap with rendered configuration", "namespace", w.namespace, "name", w.name, "key", w.key)
+	return nil
+}
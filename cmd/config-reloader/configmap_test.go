@@ -0,0 +1,148 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestConfigMapWriter(t *testing.T) {
+	ctx := context.Background()
+	file := filepath.Join(t.TempDir(), "config.yaml")
+
+	client := fake.NewSimpleClientset()
+	w := newConfigMapWriter(log.NewNopLogger(), nil, client, "gmp-system", "rendered", "config.yaml", file, time.Second)
+
+	getData := func() map[string]string {
+		t.Helper()
+		cm, err := client.CoreV1().ConfigMaps("gmp-system").Get(ctx, "rendered", metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cm.Data
+	}
+
+	// Nothing is written before the config file was rendered.
+	if err := w.apply(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(client.Actions()) > 0 {
+		t.Fatalf("unexpected actions before the config file exists: %v", client.Actions())
+	}
+
+	// The ConfigMap is created with the rendered config.
+	if err := os.WriteFile(file, []byte("global: {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.apply(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(map[string]string{"config.yaml": "global: {}\n"}, getData()); diff != "" {
+		t.Errorf("unexpected ConfigMap data (-want, +got): %s", diff)
+	}
+
+	// Other keys are kept when the config changes.
+	cm, err := client.CoreV1().ConfigMaps("gmp-system").Get(ctx, "rendered", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cm.Data["other"] = "value"
+	if _, err := client.CoreV1().ConfigMaps("gmp-system").Update(ctx, cm, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte("global:\n  scrape_interval: 10s\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.apply(ctx); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"config.yaml": "global:\n  scrape_interval: 10s\n",
+		"other":       "value",
+	}
+	if diff := cmp.Diff(want, getData()); diff != "" {
+		t.Errorf("unexpected ConfigMap data (-want, +got): %s", diff)
+	}
+
+	// Unchanged configs do not cause further requests.
+	client.ClearActions()
+	if err := w.apply(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(client.Actions()) > 0 {
+		t.Errorf("unexpected actions for unchanged config file: %v", client.Actions())
+	}
+}
+
+func TestConfigMapWriterRetry(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(file, []byte("global: {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	client := fake.NewSimpleClientset()
+	failures := 2
+	client.PrependReactor("create", "configmaps", func(k8stesting.Action) (bool, runtime.Object, error) {
+		if failures > 0 {
+			failures--
+			return true, nil, errors.New("unavailable")
+		}
+		return false, nil, nil
+	})
+	w := newConfigMapWriter(log.NewNopLogger(), nil, client, "gmp-system", "rendered", "config.yaml", file, 10*time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := w.run(ctx); err != nil {
+			t.Error(err)
+		}
+	}()
+	var cm *corev1.ConfigMap
+	for start := time.Now(); time.Since(start) < 10*time.Second; time.Sleep(10 * time.Millisecond) {
+		var err error
+		if cm, err = client.CoreV1().ConfigMaps("gmp-system").Get(ctx, "rendered", metav1.GetOptions{}); err == nil {
+			break
+		}
+	}
+	cancel()
+	<-done
+
+	if cm == nil {
+		t.Fatal("ConfigMap was not created")
+	}
+	if diff := cmp.Diff(map[string]string{"config.yaml": "global: {}\n"}, cm.Data); diff != "" {
+		t.Errorf("unexpected ConfigMap data (-want, +got): %s", diff)
+	}
+	if got := testutil.ToFloat64(w.failures); got != 2 {
+		t.Errorf("expected 2 failures, got %v", got)
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// checkEnv reads the configuration file like the reloader does and returns an error
+// listing all environment variables that it references but that are unset.
+func checkEnv(name string) error {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+	b, err = decompress(b)
+	if err != nil {
+		return err
+	}
+	if missing := missingEnvVars(b); len(missing) > 0 {
+		return fmt.Errorf("found references to unset environment variables %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// missingEnvVars returns the sorted names of all environment variables that are
+// referenced as $(VAR) but are unset.
+func missingEnvVars(b []byte) []string {
+	seen := map[string]bool{}
+	var missing []string
+	for _, m := range envRe.FindAllSubmatch(b, -1) {
+		name := string(m[1])
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		if _, ok := os.LookupEnv(name); !ok {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
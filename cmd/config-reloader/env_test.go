@@ -0,0 +1,90 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMissingEnvVars(t *testing.T) {
+	t.Setenv("NODE_NAME", "node-1")
+	t.Setenv("EMPTY", "")
+
+	cfg := []byte(`
+global:
+  external_labels:
+    node: $(NODE_NAME)
+    empty: $(EMPTY)
+    zone: $(ZONE)
+    region: $(REGION)
+scrape_configs:
+- job_name: example
+  static_configs:
+  - targets: ["$(NODE_NAME):9090", "$(ZONE):9090"]
+  relabel_configs:
+  - target_label: instance
+    replacement: ${1}
+`)
+	if diff := cmp.Diff([]string{"REGION", "ZONE"}, missingEnvVars(cfg)); diff != "" {
+		t.Errorf("unexpected missing variables (-want, +got): %s", diff)
+	}
+	if _, err := expandEnv(cfg); err == nil || err.Error() != "found references to unset environment variables REGION, ZONE" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestCheckEnv(t *testing.T) {
+	t.Setenv("NODE_NAME", "node-1")
+
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	if _, err := zw.Write([]byte("node: $(NODE_NAME)\nzone: $(ZONE)\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	for _, c := range []struct {
+		doc     string
+		content []byte
+		wantErr bool
+	}{
+		{doc: "all set", content: []byte("node: $(NODE_NAME)\n")},
+		{doc: "unset", content: []byte("node: $(NODE_NAME)\nzone: $(ZONE)\n"), wantErr: true},
+		{doc: "gzipped", content: compressed.Bytes(), wantErr: true},
+	} {
+		t.Run(c.doc, func(t *testing.T) {
+			name := filepath.Join(dir, "config.yaml")
+			if err := os.WriteFile(name, c.content, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			err := checkEnv(name)
+			if c.wantErr && err == nil {
+				t.Fatal("expected error but got none")
+			}
+			if !c.wantErr && err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		})
+	}
+}
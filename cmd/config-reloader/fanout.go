@@ -0,0 +1,104 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// fanoutTransport sends each reload request to all reload URLs, e.g. to reload several
+// processes in the same pod that share the watched files. The URL of the incoming request
+// is ignored. Every URL is notified even if others fail and the request only succeeds if
+// all of them succeed.
+type fanoutTransport struct {
+	next       http.RoundTripper
+	logger     log.Logger
+	reloadURLs []*url.URL
+
+	reloads  *prometheus.CounterVec
+	failures *prometheus.CounterVec
+}
+
+func newFanoutTransport(logger log.Logger, reg prometheus.Registerer, next http.RoundTripper, reloadURLs []*url.URL) *fanoutTransport {
+	t := &fanoutTransport{
+		next:       next,
+		logger:     logger,
+		reloadURLs: reloadURLs,
+		reloads: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "config_reloader_reload_requests_total",
+			Help: "Total number of reload requests sent per reload URL.",
+		}, []string{"url"}),
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "config_reloader_reload_request_failures_total",
+			Help: "Total number of failed reload requests per reload URL.",
+		}, []string{"url"}),
+	}
+	for _, u := range reloadURLs {
+		// Initialize the series so that failures are visible from the first one.
+		t.reloads.WithLabelValues(u.String())
+		t.failures.WithLabelValues(u.String())
+	}
+	if reg != nil {
+		reg.MustRegister(t.reloads, t.failures)
+	}
+	return t
+}
+
+func (t *fanoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var errs []error
+	for _, u := range t.reloadURLs {
+		if err := t.reload(req, u); err != nil {
+			t.failures.WithLabelValues(u.String()).Inc()
+			//nolint:errcheck
+			level.Error(t.logger).Log("msg", "reload request failed", "url", u, "err", err)
+			errs = append(errs, fmt.Errorf("reload %s: %w", u, err))
+		}
+		t.reloads.WithLabelValues(u.String()).Inc()
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return okResponse(req), nil
+}
+
+// reload sends the request to the given URL. Reload requests have no body.
+func (t *fanoutTransport) reload(req *http.Request, u *url.URL) error {
+	r := req.Clone(req.Context())
+	r.URL = u
+	r.Host = u.Host
+	r.Body = nil
+	r.ContentLength = 0
+
+	resp, err := t.next.RoundTrip(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	//nolint:errcheck
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("received status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestFanoutTransport(t *testing.T) {
+	var prometheusReloads, alertmanagerReloads atomic.Int32
+	var alertmanagerFailing atomic.Bool
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("unexpected method %q", r.Method)
+		}
+		switch r.URL.Path {
+		case "/prometheus/-/reload":
+			prometheusReloads.Add(1)
+		case "/alertmanager/-/reload":
+			alertmanagerReloads.Add(1)
+			if alertmanagerFailing.Load() {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+		default:
+			t.Errorf("unexpected request path %q", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	var urls []*url.URL
+	for _, path := range []string{"/prometheus/-/reload", "/alertmanager/-/reload"} {
+		u, err := url.Parse(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		urls = append(urls, u)
+	}
+	transport := newFanoutTransport(log.NewNopLogger(), prometheus.NewRegistry(), server.Client().Transport, urls)
+
+	reload := func() error {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, urls[0].String(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", resp.StatusCode)
+		}
+		return nil
+	}
+	expectCounts := func(u *url.URL, reloads, failures float64) {
+		t.Helper()
+		if got := testutil.ToFloat64(transport.reloads.WithLabelValues(u.String())); got != reloads {
+			t.Errorf("expected %v reloads of %s, got %v", reloads, u, got)
+		}
+		if got := testutil.ToFloat64(transport.failures.WithLabelValues(u.String())); got != failures {
+			t.Errorf("expected %v failures of %s, got %v", failures, u, got)
+		}
+	}
+
+	// All URLs are reloaded.
+	if err := reload(); err != nil {
+		t.Fatalf("unexpected reload error: %s", err)
+	}
+	if prometheusReloads.Load() != 1 || alertmanagerReloads.Load() != 1 {
+		t.Fatalf("expected one reload per URL, got %d and %d", prometheusReloads.Load(), alertmanagerReloads.Load())
+	}
+	expectCounts(urls[0], 1, 0)
+	expectCounts(urls[1], 1, 0)
+
+	// A failing URL fails the request but does not prevent the others from being reloaded.
+	alertmanagerFailing.Store(true)
+	err := reload()
+	if err == nil {
+		t.Fatal("expected reload error")
+	}
+	if !strings.Contains(err.Error(), urls[1].String()) || strings.Contains(err.Error(), urls[0].String()) {
+		t.Errorf("expected error for %s only, got %q", urls[1], err)
+	}
+	if prometheusReloads.Load() != 2 || alertmanagerReloads.Load() != 2 {
+		t.Fatalf("expected two reloads per URL, got %d and %d", prometheusReloads.Load(), alertmanagerReloads.Load())
+	}
+	expectCounts(urls[0], 2, 0)
+	expectCounts(urls[1], 2, 1)
+}
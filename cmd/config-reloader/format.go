@@ -0,0 +1,153 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	yaml "gopkg.in/yaml.v2"
+	k8syaml "sigs.k8s.io/yaml"
+)
+
+// Supported formats of the rendered configuration file.
+const (
+	outputFormatYAML = "yaml"
+	outputFormatJSON = "json"
+)
+
+func validateOutputFormat(format string) error {
+	switch format {
+	case outputFormatYAML, outputFormatJSON:
+		return nil
+	default:
+		return fmt.Errorf("unknown output format %q, must be one of %q or %q", format, outputFormatYAML, outputFormatJSON)
+	}
+}
+
+// convertConfig converts the YAML configuration into the given format. The conversion must
+// round-trip, i.e. the converted configuration must decode to the same values as the input.
+func convertConfig(b []byte, format string) ([]byte, error) {
+	switch format {
+	case outputFormatYAML:
+		return b, nil
+	case outputFormatJSON:
+	default:
+		return nil, validateOutputFormat(format)
+	}
+	j, err := k8syaml.YAMLToJSON(b)
+	if err != nil {
+		return nil, fmt.Errorf("convert to JSON: %w", err)
+	}
+	// JSON is valid YAML, so both can be decoded and compared the same way. This catches
+	// values that change meaning, e.g. non-string keys or floats that are written as
+	// integers.
+	var want, got interface{}
+	if err := yaml.Unmarshal(b, &want); err != nil {
+		return nil, fmt.Errorf("decode configuration: %w", err)
+	}
+	if err := yaml.Unmarshal(j, &got); err != nil {
+		return nil, fmt.Errorf("decode converted configuration: %w", err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		return nil, fmt.Errorf("configuration does not round-trip through %s", format)
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, j, "", "  "); err != nil {
+		return nil, fmt.Errorf("indent JSON: %w", err)
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// configConverter converts the watched configuration file into the configured format and
+// passes the result on to the reloader, which renders it to the output file.
+//
+// Environment variables are expanded by the reloader after the conversion. Their values
+// are inserted verbatim and hence must not contain characters that require escaping.
+type configConverter struct {
+	logger   log.Logger
+	cfgFile  string
+	outFile  string
+	format   string
+	interval time.Duration
+
+	failures prometheus.Counter
+}
+
+func newConfigConverter(logger log.Logger, reg prometheus.Registerer, cfgFile, outFile, format string, interval time.Duration) *configConverter {
+	c := &configConverter{
+		logger:   logger,
+		cfgFile:  cfgFile,
+		outFile:  outFile,
+		format:   format,
+		interval: interval,
+		failures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "config_reloader_conversion_failures_total",
+			Help: "Total number of configurations that failed to convert to the output format and were not applied.",
+		}),
+	}
+	if reg != nil {
+		reg.MustRegister(c.failures)
+	}
+	return c
+}
+
+// run periodically converts the configuration file until the context is cancelled.
+func (c *configConverter) run(ctx context.Context) error {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := c.apply(); err != nil {
+				//nolint:errcheck
+				level.Error(c.logger).Log("msg", "converting configuration failed, keeping last converted configuration", "err", err)
+			}
+		}
+	}
+}
+
+// apply converts the configuration file and writes it to the output file if it has changed.
+func (c *configConverter) apply() error {
+	b, err := os.ReadFile(c.cfgFile)
+	if err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+	b, err = decompress(b)
+	if err != nil {
+		return err
+	}
+	b, err = convertConfig(b, c.format)
+	if err != nil {
+		c.failures.Inc()
+		return err
+	}
+	if prev, err := os.ReadFile(c.outFile); err == nil && bytes.Equal(prev, b) {
+		return nil
+	}
+	return writeFile(c.outFile, b)
+}
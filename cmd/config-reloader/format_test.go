@@ -0,0 +1,172 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	promconfig "github.com/prometheus/prometheus/config"
+)
+
+func TestConvertConfig(t *testing.T) {
+	cases := []struct {
+		desc        string
+		format      string
+		config      string
+		want        string
+		errContains string
+	}{
+		{
+			desc:   "yaml",
+			format: outputFormatYAML,
+			config: validConfig,
+			want:   validConfig,
+		},
+		{
+			desc:   "json",
+			format: outputFormatJSON,
+			config: validConfig,
+			want: `{
+  "scrape_configs": [
+    {
+      "job_name": "example",
+      "static_configs": [
+        {
+          "targets": [
+            "$(NODE_NAME):9090"
+          ]
+        }
+      ]
+    }
+  ]
+}
+`,
+		},
+		{
+			desc:        "json with non-string keys",
+			format:      outputFormatJSON,
+			config:      "global:\n  external_labels:\n    1: foo\n",
+			errContains: "configuration does not round-trip through json",
+		},
+		{
+			desc:        "json with float written as integer",
+			format:      outputFormatJSON,
+			config:      "scrape_configs:\n- job_name: example\n  metric_relabel_configs:\n  - regex: 1.0\n",
+			errContains: "configuration does not round-trip through json",
+		},
+		{
+			desc:        "invalid yaml",
+			format:      outputFormatJSON,
+			config:      "scrape_configs: [",
+			errContains: "convert to JSON",
+		},
+		{
+			desc:        "unknown format",
+			format:      "toml",
+			config:      validConfig,
+			errContains: `unknown output format "toml"`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			got, err := convertConfig([]byte(c.config), c.format)
+			if c.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), c.errContains) {
+					t.Fatalf("expected error containing %q, got %v", c.errContains, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(c.want, string(got)); diff != "" {
+				t.Errorf("unexpected converted config (-want, +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestConfigConverterJSON(t *testing.T) {
+	t.Setenv("NODE_NAME", "node-1")
+
+	dir := t.TempDir()
+	cfgFile := filepath.Join(dir, "config.yaml")
+	outFile := filepath.Join(dir, "config.json.converted")
+	c := newConfigConverter(log.NewNopLogger(), prometheus.NewRegistry(), cfgFile, outFile, outputFormatJSON, time.Second)
+
+	// Compressed configurations are converted like uncompressed ones.
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(validConfig)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cfgFile, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.apply(); err != nil {
+		t.Fatal(err)
+	}
+	converted, err := os.ReadFile(outFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The rendered JSON loads as the same Prometheus configuration as the YAML input.
+	rendered, err := expandEnv(converted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := promconfig.Load(strings.ReplaceAll(validConfig, "$(NODE_NAME)", "node-1"), false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := promconfig.Load(string(rendered), false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want.String(), got.String()); diff != "" {
+		t.Errorf("unexpected loaded config (-want, +got): %s", diff)
+	}
+
+	// Configurations that fail to convert keep the last converted output.
+	if err := os.WriteFile(cfgFile, []byte("global:\n  external_labels:\n    1: foo\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.apply(); err == nil {
+		t.Fatal("expected conversion error")
+	}
+	if got := testutil.ToFloat64(c.failures); got != 1 {
+		t.Errorf("expected 1 conversion failure, got %v", got)
+	}
+	b, err := os.ReadFile(outFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(converted, b) {
+		t.Errorf("expected output %q to be kept, got %q", converted, b)
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+)
+
+// healthHandler reports the reloader as healthy once it is watching for changes, which
+// is only the case after the ready-url reported ready on startup.
+type healthHandler struct {
+	healthy atomic.Bool
+}
+
+func (h *healthHandler) setHealthy(healthy bool) {
+	h.healthy.Store(healthy)
+}
+
+func (h *healthHandler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	if !h.healthy.Load() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, "Config reloader is not watching for changes yet.")
+		return
+	}
+	fmt.Fprintln(w, "Config reloader is Healthy.")
+}
@@ -0,0 +1,42 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHealthHandler(t *testing.T) {
+	h := &healthHandler{}
+	expectStatus := func(want int) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/-/healthy", nil))
+		if rec.Code != want {
+			t.Errorf("expected status %d, got %d", want, rec.Code)
+		}
+	}
+	// Unhealthy until the reloader watches for changes.
+	expectStatus(http.StatusServiceUnavailable)
+
+	h.setHealthy(true)
+	expectStatus(http.StatusOK)
+
+	// Unhealthy again once the reloader stopped.
+	h.setHealthy(false)
+	expectStatus(http.StatusServiceUnavailable)
+}
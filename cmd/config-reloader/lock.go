@@ -0,0 +1,107 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// lockTransport delays reload requests while another process holds a lock on the lock
+// file, e.g. while it mutates rule files. The lock is released if the file does not exist
+// or no process holds an exclusive flock on it. A shared flock is held while the reload
+// request is in flight so that the other process cannot acquire the lock in the meantime.
+type lockTransport struct {
+	next     http.RoundTripper
+	logger   log.Logger
+	lockFile string
+	timeout  time.Duration
+	interval time.Duration
+}
+
+func newLockTransport(logger log.Logger, next http.RoundTripper, lockFile string, timeout time.Duration) *lockTransport {
+	return &lockTransport{
+		next:     next,
+		logger:   logger,
+		lockFile: lockFile,
+		timeout:  timeout,
+		interval: 100 * time.Millisecond,
+	}
+}
+
+func (t *lockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	unlock, err := t.waitUnlocked(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return t.next.RoundTrip(req)
+}
+
+// waitUnlocked waits until the lock is released and acquires a shared lock, which must be
+// released with the returned function.
+func (t *lockTransport) waitUnlocked(ctx context.Context) (func(), error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for logged := false; ; {
+		unlock, err := tryLockShared(t.lockFile)
+		if err == nil {
+			return unlock, nil
+		}
+		if !errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, fmt.Errorf("lock %s: %w", t.lockFile, err)
+		}
+		if !logged {
+			//nolint:errcheck
+			level.Info(t.logger).Log("msg", "waiting for lock file to be released before reloading", "file", t.lockFile)
+			logged = true
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("lock file %s not released within %s", t.lockFile, t.timeout)
+		case <-ticker.C:
+		}
+	}
+}
+
+// tryLockShared acquires a shared flock on the file without blocking. It succeeds without
+// locking if the file does not exist.
+func tryLockShared(name string) (func(), error) {
+	f, err := os.Open(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return func() {}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_SH|syscall.LOCK_NB); err != nil {
+		f.Close()
+		return nil, err
+	}
+	// Closing the file releases the lock.
+	return func() { f.Close() }, nil
+}
@@ -0,0 +1,120 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+)
+
+func TestLockTransport(t *testing.T) {
+	lockFile := filepath.Join(t.TempDir(), "rules.lock")
+
+	var reloads atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		reloads.Add(1)
+		// The other process must not be able to acquire the lock during the reload.
+		f, err := os.Open(lockFile)
+		if errors.Is(err, os.ErrNotExist) {
+			return
+		}
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer f.Close()
+		if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); !errors.Is(err, syscall.EWOULDBLOCK) {
+			t.Errorf("expected lock to be held during reload, got %v", err)
+		}
+	}))
+	defer server.Close()
+
+	transport := newLockTransport(log.NewNopLogger(), server.Client().Transport, lockFile, 200*time.Millisecond)
+	transport.interval = 10 * time.Millisecond
+
+	reload := func() error {
+		req, err := http.NewRequest(http.MethodPost, server.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	}
+	// lock acquires an exclusive lock on the lock file like the other process would.
+	lock := func() *os.File {
+		f, err := os.Create(lockFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+
+	// No lock file.
+	if err := reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := reloads.Load(); got != 1 {
+		t.Fatalf("expected 1 reload, got %d", got)
+	}
+
+	// The lock is never released.
+	f := lock()
+	if err := reload(); err == nil {
+		t.Fatal("expected reload to fail while the lock is held")
+	}
+	if got := reloads.Load(); got != 1 {
+		t.Fatalf("expected no reload while the lock is held, got %d reloads", got)
+	}
+
+	// The lock is released while waiting.
+	transport.timeout = 10 * time.Second
+	done := make(chan error)
+	go func() { done <- reload() }()
+	time.Sleep(50 * time.Millisecond)
+	if got := reloads.Load(); got != 1 {
+		t.Fatalf("expected no reload while the lock is held, got %d reloads", got)
+	}
+	f.Close()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if got := reloads.Load(); got != 2 {
+		t.Fatalf("expected 2 reloads, got %d", got)
+	}
+
+	// The lock file exists but no lock is held.
+	if err := reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := reloads.Load(); got != 3 {
+		t.Fatalf("expected 3 reloads, got %d", got)
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"net/url"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strings"
 	"syscall"
 	"time"
@@ -32,22 +33,78 @@ import (
 	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/thanos-io/thanos/pkg/reloader"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 )
 
 func main() {
 	var (
 		watchedDirs      stringSlice
+		reloadURLStrs    stringSlice
 		configFile       = flag.String("config-file", "", "config file to watch for changes")
 		configFileOutput = flag.String("config-file-output", "", "config file to write with interpolated environment variables")
+		configOutputFmt  = flag.String("config-output-format", outputFormatYAML, "format of the config file output, one of yaml or json")
 		// Ready and reload endpoints should be compatible with Prometheus-style
 		// management APIs, e.g.
 		// https://prometheus.io/docs/prometheus/latest/management_api/
 		// https://prometheus.io/docs/alerting/latest/management_api/
-		reloadURLStr  = flag.String("reload-url", "http://127.0.0.1:19090/-/reload", "reload endpoint triggers a reload of the configuration file")
 		readyURLStr   = flag.String("ready-url", "http://127.0.0.1:19090/-/ready", "ready endpoint returns a 200 when ready to serve traffic")
+		readyTimeout  = flag.Duration("ready-timeout", 0, "maximum time to wait on startup for the ready-url to report ready, waits indefinitely if 0")
 		listenAddress = flag.String("listen-address", ":19091", "address on which to expose metrics")
+		// Optionally, the ready and reload endpoints are requested with (mutual) TLS. The files
+		// are read again when they change, e.g. after certificates were rotated.
+		reloadCAFile   = flag.String("reload-ca-file", "", "CA certificate file to verify the ready and reload endpoints with")
+		reloadCertFile = flag.String("reload-cert-file", "", "client certificate file for requests to the ready and reload endpoints (requires --reload-key-file)")
+		reloadKeyFile  = flag.String("reload-key-file", "", "client key file for requests to the ready and reload endpoints (requires --reload-cert-file)")
+		// Processes without a reload endpoint may be reloaded through a SIGHUP instead.
+		reloadMethod  = flag.String("reload-method", reloadMethodHTTP, "how to trigger reloads, one of http to request the reload-url or signal to send a SIGHUP to the process in --reload-pid-file")
+		reloadPIDFile = flag.String("reload-pid-file", "", "file containing the PID of the process to send a SIGHUP to on reload (requires --reload-method=signal)")
+		// Optionally, a Secret can be watched through the Kubernetes API instead of relying on
+		// mounted volumes. Its keys are written as files into a watched directory.
+		secretNamespace = flag.String("secret-namespace", "", "namespace of the Kubernetes Secret to watch through the API")
+		secretName      = flag.String("secret-name", "", "name of the Kubernetes Secret to watch through the API (requires in-cluster credentials)")
+		secretDir       = flag.String("secret-dir", "", "directory to write the keys of the watched Kubernetes Secret to")
+		strictEnv       = flag.Bool("strict-env", false, "fail on startup instead of warning if the config file references unset environment variables")
+		keepLastValid   = flag.Bool("keep-last-valid", false, "validate the rendered config file as a Prometheus configuration and keep the last valid output instead of applying an invalid one")
+		// Optionally, the rendered config file is additionally written to a ConfigMap through
+		// the API so that other consumers can read it.
+		configMapNamespace = flag.String("config-map-namespace", "", "namespace of the Kubernetes ConfigMap to write the rendered config file to")
+		configMapName      = flag.String("config-map-name", "", "name of the Kubernetes ConfigMap to write the rendered config file to (requires in-cluster credentials and --config-file-output)")
+		configMapKey       = flag.String("config-map-key", "", "key of the Kubernetes ConfigMap to write the rendered config file to, defaults to the base name of --config-file-output")
+		// Optionally, a reload can be triggered by changing an annotation of the pod, e.g. a
+		// checksum of the configuration, independent of when the mounted files are updated.
+		annotationsFile  = flag.String("annotations-file", "", "downward API file containing the pod's annotations")
+		reloadAnnotation = flag.String("reload-annotation", "", "pod annotation whose value changes trigger a reload (requires --annotations-file)")
+		// There are some reliability issues with fsnotify picking up file changes, so watched
+		// files are additionally checked for changes in an interval. The reloader only sends
+		// reload requests if the contents actually changed.
+		watchInterval = flag.Duration("watch-interval", 10*time.Second, "interval in which watched files are checked for changes independent of file system notifications")
+		retryInterval = flag.Duration("retry-interval", 5*time.Second, "interval in which failed reloads are retried, in addition to the reload retry backoff")
+		delayInterval = flag.Duration("delay-interval", 3*time.Second, "delay after a file system notification before changes are applied, to batch changes to multiple files")
+		// Failed reloads are retried with an exponential backoff.
+		retryMinBackoff = flag.Duration("reload-retry-min-backoff", 5*time.Second, "delay before retrying a failed reload")
+		retryMaxBackoff = flag.Duration("reload-retry-max-backoff", 2*time.Minute, "maximum delay between retries of consecutively failed reloads")
+		retryMultiplier = flag.Float64("reload-retry-backoff-multiplier", 2, "factor by which the delay increases after each consecutively failed reload")
+		retryJitter     = flag.Float64("reload-retry-backoff-jitter", 0.2, "fraction between 0 and 1 by which each retry delay is randomly increased or decreased")
+		// Optionally, the ready endpoint is checked again after each reload.
+		reloadReadyTimeout = flag.Duration("reload-ready-timeout", 0, "if set, a reload is considered failed if the ready-url does not report ready within this duration after the reload")
+		reloadReadyRevert  = flag.Bool("reload-ready-revert", false, "revert to the previous valid configuration if the ready-url does not report ready after a reload (requires --keep-last-valid and --reload-ready-timeout)")
+		// Optionally, reloads are delayed while another process holds a lock, e.g. while it
+		// mutates the watched files.
+		reloadLockFile    = flag.String("reload-lock-file", "", "file on which another process holds an exclusive flock while the watched files must not be loaded; reloads wait until the lock is released or the file is removed")
+		reloadLockTimeout = flag.Duration("reload-lock-timeout", time.Minute, "maximum time to wait for the reload lock file to be released before the reload is considered failed")
+		// Optionally, swaps of the ..data symlink of ConfigMap and Secret volumes trigger a
+		// reload immediately, as the watch of the config file is lost after the first swap.
+		watchDataSymlinks = flag.Bool("watch-data-symlinks", false, "trigger a reload when the ..data symlink in the directory of the config file or a watched directory is swapped, as done by the kubelet for ConfigMap and Secret volumes")
+		// Optionally, reloads are suppressed if the contents did not change even though the
+		// reloader detected a change, e.g. because modification times flap on NFS.
+		suppressUnchanged = flag.Bool("suppress-unchanged-reloads", false, "suppress reloads detected by the reloader if the SHA-256 checksum of the config file output and the files in the watched directories did not change since the last successful reload")
+		// Optionally, reloads caused by changes of the watched files are deferred so that
+		// changes of several files in short succession, e.g. rotated Secrets, cause one reload.
+		reloadCoalesce = flag.Duration("reload-coalesce-window", 0, "time by which a reload caused by changes of the watched files is deferred, coalescing further changes within it into the same reload, must be less than --watch-interval, sends reloads immediately if 0")
 	)
 	flag.Var(&watchedDirs, "watched-dir", "directory to watch for file changes (for rule and secret files, may be repeated)")
+	flag.Var(&reloadURLStrs, "reload-url", "reload endpoint triggers a reload of the configuration file (may be repeated to reload multiple processes, defaults to http://127.0.0.1:19090/-/reload)")
 
 	flag.Parse()
 
@@ -61,82 +118,414 @@ func main() {
 		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
 	)
 
-	reloadURL, err := url.Parse(*reloadURLStr)
+	if *secretName != "" && (*secretNamespace == "" || *secretDir == "") {
+		//nolint:errcheck
+		level.Error(logger).Log("msg", "--secret-namespace and --secret-dir must be set when --secret-name is set")
+		os.Exit(1)
+	}
+	if *configMapName != "" {
+		if *configMapNamespace == "" || *configFileOutput == "" {
+			//nolint:errcheck
+			level.Error(logger).Log("msg", "--config-map-namespace and --config-file-output must be set when --config-map-name is set")
+			os.Exit(1)
+		}
+		if *configMapKey == "" {
+			*configMapKey = filepath.Base(*configFileOutput)
+		}
+	}
+	var kubeClient kubernetes.Interface
+	if *secretName != "" || *configMapName != "" {
+		restConfig, err := rest.InClusterConfig()
+		if err != nil {
+			//nolint:errcheck
+			level.Error(logger).Log("msg", "creating in-cluster config failed", "err", err)
+			os.Exit(1)
+		}
+		kubeClient, err = kubernetes.NewForConfig(restConfig)
+		if err != nil {
+			//nolint:errcheck
+			level.Error(logger).Log("msg", "creating Kubernetes client failed", "err", err)
+			os.Exit(1)
+		}
+	}
+	if *secretName != "" {
+		// The directory must exist before the reloader starts watching it.
+		if err := os.MkdirAll(*secretDir, 0o755); err != nil {
+			//nolint:errcheck
+			level.Error(logger).Log("msg", "creating secret directory failed", "err", err)
+			os.Exit(1)
+		}
+		watchedDirs = append(watchedDirs, *secretDir)
+	}
+
+	if (*annotationsFile == "") != (*reloadAnnotation == "") {
+		//nolint:errcheck
+		level.Error(logger).Log("msg", "--annotations-file and --reload-annotation must be set together")
+		os.Exit(1)
+	}
+
+	for _, f := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"--watch-interval", *watchInterval},
+		{"--retry-interval", *retryInterval},
+		{"--delay-interval", *delayInterval},
+	} {
+		if f.value <= 0 {
+			//nolint:errcheck
+			level.Error(logger).Log("msg", "interval must be positive", "flag", f.name, "value", f.value)
+			os.Exit(1)
+		}
+	}
+
+	retryBackoff := backoffConfig{
+		min:        *retryMinBackoff,
+		max:        *retryMaxBackoff,
+		multiplier: *retryMultiplier,
+		jitter:     *retryJitter,
+	}
+	if err := retryBackoff.validate(); err != nil {
+		//nolint:errcheck
+		level.Error(logger).Log("msg", "invalid reload retry backoff", "err", err)
+		os.Exit(1)
+	}
+	if *reloadCoalesce >= *watchInterval {
+		// The reloader cancels reloads after the watch interval, which would always cancel
+		// the deferred reload.
+		//nolint:errcheck
+		level.Error(logger).Log("msg", "--reload-coalesce-window must be less than --watch-interval", "window", *reloadCoalesce, "watch-interval", *watchInterval)
+		os.Exit(1)
+	}
+	if err := validateOutputFormat(*configOutputFmt); err != nil {
+		//nolint:errcheck
+		level.Error(logger).Log("msg", "invalid --config-output-format", "err", err)
+		os.Exit(1)
+	}
+	if err := validateReloadMethod(*reloadMethod); err != nil {
+		//nolint:errcheck
+		level.Error(logger).Log("msg", "invalid --reload-method", "err", err)
+		os.Exit(1)
+	}
+	if *reloadMethod == reloadMethodSignal {
+		if _, err := readPIDFile(*reloadPIDFile); err != nil {
+			//nolint:errcheck
+			level.Error(logger).Log("msg", "--reload-pid-file must be a readable PID file when --reload-method=signal is set", "err", err)
+			os.Exit(1)
+		}
+	}
+	if *reloadReadyRevert && (!*keepLastValid || *reloadReadyTimeout <= 0) {
+		//nolint:errcheck
+		level.Error(logger).Log("msg", "--keep-last-valid and --reload-ready-timeout must be set when --reload-ready-revert is set")
+		os.Exit(1)
+	}
+	// The reloader fails to write the output file on the first reference to an unset
+	// environment variable. Report all of them upfront instead.
+	if *configFile != "" && *configFileOutput != "" {
+		if err := checkEnv(*configFile); err != nil {
+			if *strictEnv {
+				//nolint:errcheck
+				level.Error(logger).Log("msg", "invalid environment for config file", "err", err)
+				os.Exit(1)
+			}
+			//nolint:errcheck
+			level.Warn(logger).Log("msg", "invalid environment for config file", "err", err)
+		}
+	}
+	if len(reloadURLStrs) == 0 {
+		reloadURLStrs = stringSlice{"http://127.0.0.1:19090/-/reload"}
+	}
+	var reloadURLs []*url.URL
+	for _, s := range reloadURLStrs {
+		u, err := url.Parse(s)
+		if err != nil {
+			//nolint:errcheck
+			level.Error(logger).Log("msg", "parsing reloader URL failed", "url", s, "err", err)
+			os.Exit(1)
+		}
+		reloadURLs = append(reloadURLs, u)
+	}
+	// The reloader itself only sends requests to the first URL, which are fanned out to all.
+	reloadURL := reloadURLs[0]
+
+	tlsFiles := tlsFiles{caFile: *reloadCAFile, certFile: *reloadCertFile, keyFile: *reloadKeyFile}
+	baseTransport, err := tlsFiles.newTransport()
 	if err != nil {
 		//nolint:errcheck
-		level.Error(logger).Log("msg", "parsing reloader URL failed", "err", err)
+		level.Error(logger).Log("msg", "invalid reload TLS configuration", "err", err)
 		os.Exit(1)
 	}
+	// The ready endpoint is requested with the same TLS configuration as the reload endpoint.
+	readyClient := &http.Client{Transport: baseTransport}
+
+	var (
+		reloadTransport http.RoundTripper = newFanoutTransport(logger, metrics, baseTransport, reloadURLs)
+		readyCheck      *readyCheckTransport
+	)
+	if *reloadMethod == reloadMethodSignal {
+		// The reload URLs are ignored and the process is signaled instead. Everything else,
+		// including retries and auditing, applies to signals the same way.
+		reloadTransport = newPIDSignalTransport(logger, *reloadPIDFile)
+	}
+	if *reloadReadyTimeout > 0 {
+		readyCheck = newReadyCheckTransport(logger, metrics, reloadTransport, *readyURLStr, *reloadReadyTimeout)
+		readyCheck.client = readyClient
+		reloadTransport = readyCheck
+	}
+	if *reloadLockFile != "" {
+		if *reloadLockTimeout <= 0 {
+			//nolint:errcheck
+			level.Error(logger).Log("msg", "--reload-lock-timeout must be positive")
+			os.Exit(1)
+		}
+		reloadTransport = newLockTransport(logger, reloadTransport, *reloadLockFile, *reloadLockTimeout)
+	}
+	// Every reload attempt is audited with the hash of the configuration that Prometheus loads.
+	auditedFile := *configFileOutput
+	if auditedFile == "" {
+		auditedFile = *configFile
+	}
+	reloadClient := &http.Client{
+		Transport: newAuditTransport(logger, newReloadMetricsTransport(metrics, newBackoffTransport(reloadTransport, retryBackoff)), auditedFile),
+	}
+	if *suppressUnchanged {
+		// Suppressed reloads are neither audited nor counted as they are never attempted.
+		reloadClient.Transport = newChecksumTransport(logger, metrics, reloadClient.Transport, auditedFile, watchedDirs)
+	}
+	if *reloadCoalesce > 0 {
+		// Coalesced reloads are checked for unchanged files once they are sent.
+		reloadClient.Transport = newCoalesceTransport(logger, metrics, reloadClient.Transport, *reloadCoalesce, auditedFile, watchedDirs)
+	}
 
 	// Set up interrupt signal handler.
 	term := make(chan os.Signal, 1)
 	signal.Notify(term, os.Interrupt, syscall.SIGTERM)
 
-	// Poll ready endpoint indefinitely until it's up and running.
-	req, err := http.NewRequest(http.MethodGet, *readyURLStr, nil)
-	if err != nil {
+	// The web server is started before polling the ready endpoint so that the health endpoint
+	// reports the reloader as unhealthy until it watches for changes.
+	health := &healthHandler{}
+	server := &http.Server{Addr: *listenAddress}
+	http.Handle("/metrics", promhttp.HandlerFor(metrics, promhttp.HandlerOpts{Registry: metrics}))
+	http.Handle("/-/healthy", health)
+	serverErr := make(chan error, 1)
+	go func() {
+		//nolint:errcheck
+		level.Info(logger).Log("msg", "Starting web server for metrics", "listen", *listenAddress)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	// Poll ready endpoint until it's up and running. It may not be listening yet, so failed
+	// checks are retried until the ready timeout, if any.
+	if _, err := http.NewRequest(http.MethodGet, *readyURLStr, nil); err != nil {
 		//nolint:errcheck
 		level.Error(logger).Log("msg", "creating request", "err", err)
 		os.Exit(1)
 	}
-	ticker := time.NewTicker(500 * time.Millisecond)
-	done := make(chan bool)
-	go func() {
-		//nolint:errcheck
-		level.Info(logger).Log("msg", "ensure ready-url is healthy")
-		for {
+	{
+		var (
+			ctx    context.Context
+			cancel context.CancelFunc
+		)
+		if *readyTimeout > 0 {
+			ctx, cancel = context.WithTimeout(context.Background(), *readyTimeout)
+		} else {
+			ctx, cancel = context.WithCancel(context.Background())
+		}
+		go func() {
 			select {
 			case <-term:
 				//nolint:errcheck
 				level.Info(logger).Log("msg", "received SIGTERM, exiting gracefully...")
 				os.Exit(0)
-			case <-ticker.C:
-				resp, err := http.DefaultClient.Do(req)
-				defer resp.Body.Close()
-				if err != nil {
-					//nolint:errcheck
-					level.Error(logger).Log("msg", "polling ready-url", "err", err)
-					os.Exit(1)
-				}
-				if resp.StatusCode == http.StatusOK {
-					//nolint:errcheck
-					level.Info(logger).Log("msg", "ready-url is healthy")
-					ticker.Stop()
-					done <- true
-					return
-				}
+			case <-ctx.Done():
 			}
+		}()
+		//nolint:errcheck
+		level.Info(logger).Log("msg", "ensure ready-url is healthy")
+		err := pollReady(ctx, logger, readyClient, *readyURLStr, readyPollBackoff)
+		cancel()
+		if err != nil {
+			//nolint:errcheck
+			level.Error(logger).Log("msg", "polling ready-url", "err", err)
+			os.Exit(1)
 		}
-	}()
-	<-done
+		//nolint:errcheck
+		level.Info(logger).Log("msg", "ready-url is healthy")
+	}
+
+	// In keep-last-valid mode the config file is first validated and copied to an intermediate
+	// file, which is then processed by the reloader. Invalid configurations never reach it
+	// and hence neither overwrite the output file nor trigger a reload.
+	var validator *configValidator
+	cfgFile := *configFile
+	if *keepLastValid {
+		if *configFile == "" || *configFileOutput == "" {
+			//nolint:errcheck
+			level.Error(logger).Log("msg", "--config-file and --config-file-output must be set when --keep-last-valid is set")
+			os.Exit(1)
+		}
+		cfgFile = *configFileOutput + ".last-valid"
+		validator = newConfigValidator(logger, metrics, *configFile, cfgFile, 10*time.Second)
+		if err := validator.apply(); err != nil {
+			//nolint:errcheck
+			level.Error(logger).Log("msg", "initial configuration is invalid", "err", err)
+			os.Exit(1)
+		}
+		if *reloadReadyRevert {
+			readyCheck.onUnready = validator.revert
+		}
+	}
+	// Similarly, the config file is converted to another output format through an
+	// intermediate file before the reloader renders it.
+	var converter *configConverter
+	if *configOutputFmt != outputFormatYAML {
+		if *configFile == "" || *configFileOutput == "" {
+			//nolint:errcheck
+			level.Error(logger).Log("msg", "--config-file and --config-file-output must be set when --config-output-format is set")
+			os.Exit(1)
+		}
+		converter = newConfigConverter(logger, metrics, cfgFile, *configFileOutput+".converted", *configOutputFmt, 10*time.Second)
+		if err := converter.apply(); err != nil {
+			//nolint:errcheck
+			level.Error(logger).Log("msg", "converting initial configuration failed", "err", err)
+			os.Exit(1)
+		}
+		cfgFile = converter.outFile
+	}
 
 	rel := reloader.New(
 		logger,
 		metrics,
 		&reloader.Options{
 			ReloadURL:     reloadURL,
-			CfgFile:       *configFile,
+			CfgFile:       cfgFile,
 			CfgOutputFile: *configFileOutput,
 			WatchedDirs:   watchedDirs,
-			// There are some reliability issues with fsnotify picking up file changes.
-			// Configure a very aggress refresh for now. The reloader will only send reload signals
-			// to Prometheus if the contents actually changed. So this should not have any practical
-			// drawbacks.
-			WatchInterval: 10 * time.Second,
-			RetryInterval: 5 * time.Second,
-			DelayInterval: 3 * time.Second,
+			WatchInterval: *watchInterval,
+			// The reloader retries in a fixed interval. The reload client delays
+			// retries further according to the backoff.
+			RetryInterval: *retryInterval,
+			DelayInterval: *delayInterval,
 		},
 	)
+	rel.SetHttpClient(*reloadClient)
 
 	var g run.Group
 	{
 		ctx, cancel := context.WithCancel(context.Background())
 		g.Add(func() error {
+			health.setHealthy(true)
+			defer health.setHealthy(false)
 			return rel.Watch(ctx)
 		}, func(error) {
 			cancel()
 		})
 	}
+	if validator != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		g.Add(func() error {
+			return validator.run(ctx)
+		}, func(error) {
+			cancel()
+		})
+	}
+	if converter != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		g.Add(func() error {
+			return converter.run(ctx)
+		}, func(error) {
+			cancel()
+		})
+	}
+	if *reloadAnnotation != "" {
+		w := &annotationWatcher{
+			logger:    logger,
+			client:    reloadClient,
+			file:      *annotationsFile,
+			key:       *reloadAnnotation,
+			reloadURL: reloadURL,
+			interval:  5 * time.Second,
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		g.Add(func() error {
+			return w.run(ctx)
+		}, func(error) {
+			cancel()
+		})
+	}
+	// Apply the watched files and trigger a reload on demand, e.g. after files were changed
+	// out of band, without waiting for the reloader to detect the changes.
+	sr := &signalReloader{
+		logger:    logger,
+		client:    reloadClient,
+		reloadURL: reloadURL,
+		validator: validator,
+		converter: converter,
+		cfgFile:   cfgFile,
+		outFile:   *configFileOutput,
+	}
+	{
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		ctx, cancel := context.WithCancel(context.Background())
+		g.Add(func() error {
+			for {
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-hup:
+					//nolint:errcheck
+					level.Info(logger).Log("msg", "manual reload requested by SIGHUP")
+					if err := sr.reload(ctx, reloadTriggerSignal); err != nil {
+						//nolint:errcheck
+						level.Error(logger).Log("msg", "reload triggered by SIGHUP failed", "err", err)
+					}
+				}
+			}
+		}, func(error) {
+			signal.Stop(hup)
+			cancel()
+		})
+	}
+	if *watchDataSymlinks {
+		dirs := append([]string{}, watchedDirs...)
+		if *configFile != "" {
+			dirs = append(dirs, filepath.Dir(*configFile))
+		}
+		w := newSymlinkWatcher(logger, dirs, *watchInterval, func(ctx context.Context) error {
+			return sr.reload(ctx, reloadTriggerSymlinkSwap)
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		g.Add(func() error {
+			return w.run(ctx)
+		}, func(error) {
+			cancel()
+		})
+	}
+	if *secretName != "" {
+		w := &secretWatcher{
+			logger:    logger,
+			client:    kubeClient,
+			namespace: *secretNamespace,
+			name:      *secretName,
+			dir:       *secretDir,
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		g.Add(func() error {
+			return w.run(ctx)
+		}, func(error) {
+			cancel()
+		})
+	}
+	if *configMapName != "" {
+		w := newConfigMapWriter(logger, metrics, kubeClient, *configMapNamespace, *configMapName, *configMapKey, *configFileOutput, *watchInterval)
+		ctx, cancel := context.WithCancel(context.Background())
+		g.Add(func() error {
+			return w.run(ctx)
+		}, func(error) {
+			cancel()
+		})
+	}
 	{
 		cancel := make(chan struct{})
 		g.Add(
@@ -155,13 +544,8 @@ func main() {
 		)
 	}
 	{
-		server := &http.Server{Addr: *listenAddress}
-		http.Handle("/metrics", promhttp.HandlerFor(metrics, promhttp.HandlerOpts{Registry: metrics}))
-
 		g.Add(func() error {
-			//nolint:errcheck
-			level.Info(logger).Log("msg", "Starting web server for metrics", "listen", *listenAddress)
-			return server.ListenAndServe()
+			return <-serverErr
 		}, func(error) {
 			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
 			if err := server.Shutdown(ctx); err != nil {
@@ -0,0 +1,95 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// Supported values of the --reload-method flag.
+const (
+	// Reload requests are sent to the reload URLs.
+	reloadMethodHTTP = "http"
+	// A SIGHUP is sent to the process whose PID is written to the PID file.
+	reloadMethodSignal = "signal"
+)
+
+func validateReloadMethod(method string) error {
+	switch method {
+	case reloadMethodHTTP, reloadMethodSignal:
+		return nil
+	}
+	return fmt.Errorf("unsupported reload method %q, must be one of %s or %s", method, reloadMethodHTTP, reloadMethodSignal)
+}
+
+// pidSignalTransport reloads processes that do not expose a reload endpoint by sending a
+// SIGHUP to the PID in the PID file instead of sending the reload request. The PID file is
+// read for every reload so that restarts of the process are picked up.
+type pidSignalTransport struct {
+	logger  log.Logger
+	pidFile string
+	// Sends the signal to the process, syscall.Kill if unset.
+	kill func(pid int, sig syscall.Signal) error
+}
+
+func newPIDSignalTransport(logger log.Logger, pidFile string) *pidSignalTransport {
+	return &pidSignalTransport{
+		logger:  logger,
+		pidFile: pidFile,
+		kill:    syscall.Kill,
+	}
+}
+
+func (t *pidSignalTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	pid, err := readPIDFile(t.pidFile)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.kill(pid, syscall.SIGHUP); err != nil {
+		return nil, fmt.Errorf("send SIGHUP to process %d: %w", pid, err)
+	}
+	//nolint:errcheck
+	level.Debug(t.logger).Log("msg", "sent SIGHUP to reload process", "pid", pid)
+
+	return okResponse(req), nil
+}
+
+// readPIDFile returns the PID in the file, which must only contain a positive integer
+// and optional surrounding whitespace.
+func readPIDFile(name string) (int, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return 0, fmt.Errorf("read PID file: %w", err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		return 0, fmt.Errorf("parse PID file %s: %w", name, err)
+	}
+	if pid <= 0 {
+		return 0, fmt.Errorf("invalid PID %d in PID file %s", pid, name)
+	}
+	return pid, nil
+}
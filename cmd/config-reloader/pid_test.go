@@ -0,0 +1,130 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/go-kit/log"
+)
+
+func TestReadPIDFile(t *testing.T) {
+	cases := []struct {
+		desc    string
+		data    string
+		want    int
+		wantErr bool
+	}{
+		{desc: "valid", data: "1234", want: 1234},
+		{desc: "trailing newline", data: "1234\n", want: 1234},
+		{desc: "empty", data: "", wantErr: true},
+		{desc: "not a number", data: "prometheus", wantErr: true},
+		{desc: "zero", data: "0", wantErr: true},
+		{desc: "negative", data: "-1", wantErr: true},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "prometheus.pid")
+			if err := os.WriteFile(file, []byte(c.data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := readPIDFile(file)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("expected error but got PID %d", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != c.want {
+				t.Errorf("expected PID %d, got %d", c.want, got)
+			}
+		})
+	}
+	if _, err := readPIDFile(filepath.Join(t.TempDir(), "missing.pid")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected not exist error for missing file, got %v", err)
+	}
+}
+
+func TestValidateReloadMethod(t *testing.T) {
+	for _, method := range []string{reloadMethodHTTP, reloadMethodSignal} {
+		if err := validateReloadMethod(method); err != nil {
+			t.Errorf("unexpected error for %q: %s", method, err)
+		}
+	}
+	if err := validateReloadMethod("exec"); err == nil {
+		t.Error("expected error but got none")
+	}
+}
+
+func TestPIDSignalTransport(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "prometheus.pid")
+	writePID := func(data string) {
+		t.Helper()
+		if err := os.WriteFile(pidFile, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	type signal struct {
+		pid int
+		sig syscall.Signal
+	}
+	var (
+		signals []signal
+		killErr error
+	)
+	transport := newPIDSignalTransport(log.NewNopLogger(), pidFile)
+	transport.kill = func(pid int, sig syscall.Signal) error {
+		signals = append(signals, signal{pid, sig})
+		return killErr
+	}
+	// The reload URL is never requested.
+	client := &http.Client{Transport: transport}
+	reloadURL := &url.URL{Scheme: "http", Host: "127.0.0.1:1", Path: "/-/reload"}
+
+	writePID("100\n")
+	if err := sendReload(context.Background(), client, reloadURL, reloadTriggerFileChange); err != nil {
+		t.Fatal(err)
+	}
+	// The PID file is read again on every reload, e.g. after the process restarted.
+	writePID("200\n")
+	if err := sendReload(context.Background(), client, reloadURL, reloadTriggerFileChange); err != nil {
+		t.Fatal(err)
+	}
+	want := []signal{{100, syscall.SIGHUP}, {200, syscall.SIGHUP}}
+	if len(signals) != len(want) || signals[0] != want[0] || signals[1] != want[1] {
+		t.Errorf("expected signals %v, got %v", want, signals)
+	}
+
+	// Reloads fail if the process cannot be signaled or the PID file is invalid.
+	killErr = syscall.ESRCH
+	if err := sendReload(context.Background(), client, reloadURL, reloadTriggerFileChange); !errors.Is(err, syscall.ESRCH) {
+		t.Errorf("expected ESRCH error, got %v", err)
+	}
+	killErr = nil
+	writePID("")
+	if err := sendReload(context.Background(), client, reloadURL, reloadTriggerFileChange); err == nil {
+		t.Error("expected error but got none")
+	}
+}
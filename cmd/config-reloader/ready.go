@@ -0,0 +1,168 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// readyCheckTransport verifies that the reloaded process is still ready after each
+// successful reload request. A reload after which the ready endpoint does not report
+// ready again within the timeout is treated as failed.
+type readyCheckTransport struct {
+	next     http.RoundTripper
+	logger   log.Logger
+	client   *http.Client
+	readyURL string
+	timeout  time.Duration
+	interval time.Duration
+	// Optional function called when the process does not become ready after a reload,
+	// e.g. to revert to the previous configuration.
+	onUnready func() error
+
+	failures prometheus.Counter
+}
+
+func newReadyCheckTransport(logger log.Logger, reg prometheus.Registerer, next http.RoundTripper, readyURL string, timeout time.Duration) *readyCheckTransport {
+	t := &readyCheckTransport{
+		next:     next,
+		logger:   logger,
+		client:   http.DefaultClient,
+		readyURL: readyURL,
+		timeout:  timeout,
+		interval: 500 * time.Millisecond,
+		failures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "config_reloader_ready_check_failures_total",
+			Help: "Total number of reloads after which the ready endpoint did not report ready within the timeout.",
+		}),
+	}
+	if reg != nil {
+		reg.MustRegister(t.failures)
+	}
+	return t
+}
+
+func (t *readyCheckTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	if err := t.waitReady(req.Context()); err != nil {
+		resp.Body.Close()
+		t.failures.Inc()
+		//nolint:errcheck
+		level.Error(t.logger).Log("msg", "not ready after reload", "err", err)
+
+		if t.onUnready != nil {
+			if err := t.onUnready(); err != nil {
+				//nolint:errcheck
+				level.Error(t.logger).Log("msg", "reverting configuration failed", "err", err)
+			} else {
+				//nolint:errcheck
+				level.Info(t.logger).Log("msg", "reverted to previous configuration")
+			}
+		}
+		return nil, fmt.Errorf("not ready after reload: %w", err)
+	}
+	return resp, nil
+}
+
+// waitReady polls the ready endpoint until it reports ready or the timeout expires. The
+// first check happens after one polling interval to give a failing process time to crash.
+func (t *readyCheckTransport) waitReady(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	var lastErr error
+	for {
+		select {
+		case <-ctx.Done():
+			if lastErr != nil {
+				return fmt.Errorf("ready-url not ready within %s: %w", t.timeout, lastErr)
+			}
+			return fmt.Errorf("ready-url not ready within %s", t.timeout)
+		case <-ticker.C:
+		}
+		if lastErr = t.checkReady(ctx); lastErr == nil {
+			return nil
+		}
+	}
+}
+
+func (t *readyCheckTransport) checkReady(ctx context.Context) error {
+	return checkReady(ctx, t.client, t.readyURL)
+}
+
+// readyPollBackoff is the backoff between failed checks of the ready endpoint on startup.
+var readyPollBackoff = backoffConfig{
+	min:        500 * time.Millisecond,
+	max:        10 * time.Second,
+	multiplier: 2,
+}
+
+// pollReady polls the ready endpoint until it reports ready, e.g. after the process
+// started. Failed checks, including connection errors while the process is not yet
+// listening, are retried with an exponential backoff until the context is done.
+func pollReady(ctx context.Context, logger log.Logger, client *http.Client, readyURL string, backoff backoffConfig) error {
+	delay := backoff.min
+	for {
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		err := checkReady(ctx, client, readyURL)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("%w: %w", ctx.Err(), err)
+		}
+		delay = time.Duration(float64(delay) * backoff.multiplier)
+		if delay > backoff.max {
+			delay = backoff.max
+		}
+		//nolint:errcheck
+		level.Debug(logger).Log("msg", "ready-url not ready, retrying", "err", err, "backoff", delay)
+	}
+}
+
+func checkReady(ctx context.Context, client *http.Client, readyURL string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, readyURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
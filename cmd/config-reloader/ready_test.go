@@ -0,0 +1,198 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestReadyCheckTransport(t *testing.T) {
+	t.Setenv("NODE_NAME", "node-1")
+
+	// The stub becomes unready on reload if the applied configuration is the bad one.
+	const badConfig = validConfig + "# crashes the process\n"
+
+	dir := t.TempDir()
+	cfgFile := filepath.Join(dir, "config.yaml")
+	outFile := filepath.Join(dir, "config.yaml.last-valid")
+	v := newConfigValidator(log.NewNopLogger(), prometheus.NewRegistry(), cfgFile, outFile, time.Second)
+
+	var ready atomic.Bool
+	ready.Store(true)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/-/reload":
+			b, err := os.ReadFile(outFile)
+			if err != nil {
+				t.Error(err)
+			}
+			ready.Store(string(b) != badConfig)
+		case "/-/ready":
+			if !ready.Load() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		default:
+			t.Errorf("unexpected request path %q", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	transport := newReadyCheckTransport(log.NewNopLogger(), prometheus.NewRegistry(), server.Client().Transport, server.URL+"/-/ready", 200*time.Millisecond)
+	transport.client = server.Client()
+	transport.interval = 10 * time.Millisecond
+	transport.onUnready = v.revert
+
+	apply := func(cfg string) {
+		t.Helper()
+		if err := os.WriteFile(cfgFile, []byte(cfg), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := v.apply(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reload := func() error {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/-/reload", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	}
+	expectOutput := func(want string) {
+		t.Helper()
+		got, err := os.ReadFile(outFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal([]byte(want), got) {
+			t.Fatalf("expected output %q, got %q", want, got)
+		}
+	}
+
+	// The process stays ready after reloading a good configuration.
+	apply(validConfig)
+	if err := reload(); err != nil {
+		t.Fatalf("unexpected reload error: %s", err)
+	}
+	if got := testutil.ToFloat64(transport.failures); got != 0 {
+		t.Errorf("expected no ready check failures, got %v", got)
+	}
+
+	// The process becomes unready after reloading the bad configuration, which is
+	// reported as a failed reload and reverted.
+	apply(badConfig)
+	if err := reload(); err == nil {
+		t.Fatal("expected reload error")
+	}
+	if got := testutil.ToFloat64(transport.failures); got != 1 {
+		t.Errorf("expected 1 ready check failure, got %v", got)
+	}
+	expectOutput(validConfig)
+
+	// The reverted configuration is not applied again until it changes.
+	if err := v.apply(); err == nil {
+		t.Error("expected reverted configuration to be rejected")
+	}
+	expectOutput(validConfig)
+
+	// The process becomes ready again after reloading the reverted configuration.
+	if err := reload(); err != nil {
+		t.Fatalf("unexpected reload error: %s", err)
+	}
+	apply(validConfig + "# fixed\n")
+	expectOutput(validConfig + "# fixed\n")
+}
+
+func TestPollReady(t *testing.T) {
+	backoff := backoffConfig{
+		min:        time.Millisecond,
+		max:        4 * time.Millisecond,
+		multiplier: 2,
+	}
+	// Reserve an address on which nothing is listening yet.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+	readyURL := "http://" + addr + "/-/ready"
+
+	t.Run("timeout", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		var buf bytes.Buffer
+		err := pollReady(ctx, log.NewLogfmtLogger(&buf), http.DefaultClient, readyURL, backoff)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected deadline exceeded error, got %v", err)
+		}
+		// Connection errors are retried rather than failing right away.
+		if n := strings.Count(buf.String(), "ready-url not ready, retrying"); n < 2 {
+			t.Errorf("expected multiple retries, got %d", n)
+		}
+	})
+
+	t.Run("ready after connection errors", func(t *testing.T) {
+		var checks atomic.Int32
+		server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			// Not ready during the first check after the process started listening.
+			if checks.Add(1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		})}
+		defer server.Close()
+		// Start listening only after a few failed checks.
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			listener, err := net.Listen("tcp", addr)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			server.Serve(listener) //nolint:errcheck
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := pollReady(ctx, log.NewNopLogger(), http.DefaultClient, readyURL, backoff); err != nil {
+			t.Fatal(err)
+		}
+		if got := checks.Load(); got != 2 {
+			t.Errorf("expected 2 checks to reach the server, got %d", got)
+		}
+	})
+}
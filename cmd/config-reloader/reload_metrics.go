@@ -0,0 +1,68 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// reloadMetricsTransport records the time of the last successful reload and the number of
+// failed reloads. Unlike the reloader's own metrics, it covers reloads of all triggers and
+// only counts reloads as successful once the whole chain of transports succeeded, e.g. the
+// ready check after the reload. Reloads skipped during the backoff after failed reloads
+// are not counted as they were never attempted.
+type reloadMetricsTransport struct {
+	next http.RoundTripper
+	now  func() time.Time
+
+	lastSuccess prometheus.Gauge
+	failures    prometheus.Counter
+}
+
+func newReloadMetricsTransport(reg prometheus.Registerer, next http.RoundTripper) *reloadMetricsTransport {
+	t := &reloadMetricsTransport{
+		next: next,
+		now:  time.Now,
+		lastSuccess: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "config_reloader_last_reload_success_timestamp_seconds",
+			Help: "Timestamp of the last successful reload.",
+		}),
+		failures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "config_reloader_reload_failures_total",
+			Help: "Total number of failed reloads.",
+		}),
+	}
+	if reg != nil {
+		reg.MustRegister(t.lastSuccess, t.failures)
+	}
+	return t
+}
+
+func (t *reloadMetricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if errors.Is(err, errReloadSkipped) {
+		return resp, err
+	}
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.failures.Inc()
+		return resp, err
+	}
+	t.lastSuccess.Set(float64(t.now().UnixNano()) / 1e9)
+	return resp, nil
+}
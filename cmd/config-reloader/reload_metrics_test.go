@@ -0,0 +1,115 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestReloadMetricsTransport(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusOK)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer server.Close()
+
+	transport := newReloadMetricsTransport(prometheus.NewRegistry(), server.Client().Transport)
+	now := time.Unix(1700000000, 500000000)
+	transport.now = func() time.Time { return now }
+
+	reload := func() {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, server.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	expectMetrics := func(lastSuccess, failures float64) {
+		t.Helper()
+		if got := testutil.ToFloat64(transport.lastSuccess); got != lastSuccess {
+			t.Errorf("expected last success timestamp %v, got %v", lastSuccess, got)
+		}
+		if got := testutil.ToFloat64(transport.failures); got != failures {
+			t.Errorf("expected %v failures, got %v", failures, got)
+		}
+	}
+
+	reload()
+	expectMetrics(1700000000.5, 0)
+
+	// Failed reloads keep the timestamp of the last successful one.
+	now = now.Add(time.Minute)
+	status.Store(http.StatusInternalServerError)
+	reload()
+	expectMetrics(1700000000.5, 1)
+
+	// Request errors are counted as failures as well.
+	server.Close()
+	req, err := http.NewRequest(http.MethodPost, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := transport.RoundTrip(req); err == nil {
+		t.Fatal("expected request error")
+	}
+	expectMetrics(1700000000.5, 2)
+}
+
+func TestReloadMetricsTransportSkipped(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	transport := newReloadMetricsTransport(nil, newBackoffTransport(server.Client().Transport, backoffConfig{
+		min:        time.Hour,
+		max:        time.Hour,
+		multiplier: 1,
+	}))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	// Reloads skipped during the backoff are not failures.
+	if _, err := transport.RoundTrip(req); !errors.Is(err, errReloadSkipped) {
+		t.Fatalf("expected skipped reload, got %v", err)
+	}
+	if got := testutil.ToFloat64(transport.failures); got != 1 {
+		t.Errorf("expected 1 failure, got %v", got)
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// secretWatcher mirrors the keys of a single Kubernetes Secret into files of a
+// directory. The directory is watched by the reloader, so any change to the Secret
+// results in a re-render and reload of the configuration.
+type secretWatcher struct {
+	logger    log.Logger
+	client    kubernetes.Interface
+	namespace string
+	name      string
+	dir       string
+}
+
+// run watches the Secret until the context is cancelled.
+func (w *secretWatcher) run(ctx context.Context) error {
+	if err := os.MkdirAll(w.dir, 0o755); err != nil {
+		return fmt.Errorf("create secret directory: %w", err)
+	}
+	selector := fields.OneTermEqualSelector(metav1.ObjectNameField, w.name).String()
+	lw := &cache.ListWatch{
+		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+			opts.FieldSelector = selector
+			return w.client.CoreV1().Secrets(w.namespace).List(ctx, opts)
+		},
+		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+			opts.FieldSelector = selector
+			return w.client.CoreV1().Secrets(w.namespace).Watch(ctx, opts)
+		},
+	}
+	_, controller := cache.NewInformer(lw, &corev1.Secret{}, 0, cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			w.update(obj)
+		},
+		UpdateFunc: func(_, obj interface{}) {
+			w.update(obj)
+		},
+		DeleteFunc: func(_ interface{}) {
+			// Keep the last written files so that the configuration stays loadable.
+			//nolint:errcheck
+			level.Warn(w.logger).Log("msg", "watched secret was deleted, keeping last known contents", "namespace", w.namespace, "name", w.name)
+		},
+	})
+	controller.Run(ctx.Done())
+	return nil
+}
+
+func (w *secretWatcher) update(obj interface{}) {
+	secret, ok := obj.(*corev1.Secret)
+	if !ok || secret.Namespace != w.namespace || secret.Name != w.name {
+		return
+	}
+	if err := w.write(secret); err != nil {
+		//nolint:errcheck
+		level.Error(w.logger).Log("msg", "writing secret files failed", "namespace", w.namespace, "name", w.name, "err", err)
+	}
+}
+
+// write writes every key of the Secret into its own file and removes files of keys
+// that no longer exist. Files are replaced atomically so the reloader never observes
+// partial contents.
+func (w *secretWatcher) write(secret *corev1.Secret) error {
+	data := make(map[string][]byte, len(secret.Data)+len(secret.StringData))
+	for k, v := range secret.StringData {
+		data[k] = []byte(v)
+	}
+	for k, v := range secret.Data {
+		data[k] = v
+	}
+	for k, v := range data {
+		tmp := filepath.Join(w.dir, "."+k+".tmp")
+		if err := os.WriteFile(tmp, v, 0o600); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, filepath.Join(w.dir, k)); err != nil {
+			return err
+		}
+	}
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if _, ok := data[e.Name()]; ok || e.IsDir() {
+			continue
+		}
+		if err := os.Remove(filepath.Join(w.dir, e.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func readDir(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	for _, e := range entries {
+		b, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[e.Name()] = string(b)
+	}
+	return files
+}
+
+func waitForFiles(ctx context.Context, t *testing.T, dir string, want map[string]string) {
+	t.Helper()
+	var got map[string]string
+	err := wait.PollUntilContextTimeout(ctx, 10*time.Millisecond, 10*time.Second, true, func(context.Context) (bool, error) {
+		got = readDir(t, dir)
+		return cmp.Equal(want, got), nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected secret files (-want, +got): %s", cmp.Diff(want, got))
+	}
+}
+
+func TestSecretWatcher(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "gmp-system",
+			Name:      "creds",
+		},
+		Data: map[string][]byte{
+			"password": []byte("hunter2"),
+		},
+		StringData: map[string]string{
+			"username": "admin",
+		},
+	}
+	other := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "gmp-system",
+			Name:      "other",
+		},
+		Data: map[string][]byte{
+			"token": []byte("abc"),
+		},
+	}
+	client := fake.NewSimpleClientset(secret, other)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dir := filepath.Join(t.TempDir(), "secret")
+	w := &secretWatcher{
+		logger:    log.NewNopLogger(),
+		client:    client,
+		namespace: secret.Namespace,
+		name:      secret.Name,
+		dir:       dir,
+	}
+	done := make(chan error)
+	go func() {
+		done <- w.run(ctx)
+	}()
+
+	// Initial contents are written, other secrets are ignored.
+	if err := wait.PollUntilContextTimeout(ctx, 10*time.Millisecond, 10*time.Second, true, func(context.Context) (bool, error) {
+		_, err := os.Stat(dir)
+		return err == nil, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	waitForFiles(ctx, t, dir, map[string]string{
+		"password": "hunter2",
+		"username": "admin",
+	})
+
+	// Updated values are re-rendered and removed keys are deleted.
+	updated := secret.DeepCopy()
+	updated.Data["password"] = []byte("correct-horse")
+	updated.StringData = nil
+	if _, err := client.CoreV1().Secrets(secret.Namespace).Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	waitForFiles(ctx, t, dir, map[string]string{
+		"password": "correct-horse",
+	})
+
+	// Deleting the secret keeps the last known contents.
+	if err := client.CoreV1().Secrets(secret.Namespace).Delete(ctx, secret.Name, metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	// Recreating the secret renders it again.
+	recreated := secret.DeepCopy()
+	recreated.Data["password"] = []byte("rotated")
+	if _, err := client.CoreV1().Secrets(secret.Namespace).Create(ctx, recreated, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	waitForFiles(ctx, t, dir, map[string]string{
+		"password": "rotated",
+		"username": "admin",
+	})
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
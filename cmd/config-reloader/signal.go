@@ -0,0 +1,108 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// signalReloader applies the watched files and triggers a reload on demand, without
+// waiting for the reloader to detect changes.
+type signalReloader struct {
+	logger    log.Logger
+	client    *http.Client
+	reloadURL *url.URL
+	// The validator and converter, if any, through which the config file is processed
+	// before it is rendered.
+	validator *configValidator
+	converter *configConverter
+	// The config file rendered by the reloader and its output file. The config file is
+	// only rendered if both are set.
+	cfgFile string
+	outFile string
+
+	mtx sync.Mutex
+}
+
+// reload re-reads the config file, renders it to the output file like the reloader does,
+// and sends a reload request attributed to the trigger. It is safe to call concurrently with the reloader, which
+// renders the same contents and reloads again if it did not apply them itself yet.
+func (r *signalReloader) reload(ctx context.Context, trigger reloadTrigger) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if r.validator != nil {
+		if err := r.validator.apply(); err != nil {
+			//nolint:errcheck
+			level.Error(r.logger).Log("msg", "invalid configuration, keeping last valid configuration", "err", err)
+		}
+	}
+	if r.converter != nil {
+		if err := r.converter.apply(); err != nil {
+			return fmt.Errorf("convert config file: %w", err)
+		}
+	}
+	if r.cfgFile != "" && r.outFile != "" {
+		if err := r.render(); err != nil {
+			return fmt.Errorf("render config file: %w", err)
+		}
+	}
+	return sendReload(ctx, r.client, r.reloadURL, trigger)
+}
+
+func (r *signalReloader) render() error {
+	b, err := os.ReadFile(r.cfgFile)
+	if err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+	b, err = decompress(b)
+	if err != nil {
+		return err
+	}
+	b, err = expandEnv(b)
+	if err != nil {
+		return fmt.Errorf("expand environment variables: %w", err)
+	}
+	// The reloader writes the output file through a temporary file of its own, so a
+	// different one is used to not interfere with it.
+	f, err := os.CreateTemp(filepath.Dir(r.outFile), filepath.Base(r.outFile)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("create file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return fmt.Errorf("write file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("write file: %w", err)
+	}
+	if err := os.Chmod(f.Name(), 0o644); err != nil {
+		return fmt.Errorf("chmod file: %w", err)
+	}
+	if err := os.Rename(f.Name(), r.outFile); err != nil {
+		return fmt.Errorf("rename file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/go-kit/log"
+)
+
+func TestSignalReloader(t *testing.T) {
+	t.Setenv("NODE_NAME", "node-1")
+
+	var (
+		mtx     sync.Mutex
+		reloads int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/-/reload" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		mtx.Lock()
+		reloads++
+		mtx.Unlock()
+	}))
+	defer server.Close()
+
+	reloadURL, err := url.Parse(server.URL + "/-/reload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	cfgFile := filepath.Join(dir, "config.yaml")
+	outFile := filepath.Join(dir, "config.yaml.out")
+	if err := os.WriteFile(cfgFile, []byte("node: $(NODE_NAME)\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r := &signalReloader{
+		logger:    log.NewNopLogger(),
+		client:    server.Client(),
+		reloadURL: reloadURL,
+		cfgFile:   cfgFile,
+		outFile:   outFile,
+	}
+
+	// Concurrent requests are applied one after another.
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := r.reload(context.Background(), reloadTriggerSignal); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	got, err := os.ReadFile(outFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "node: node-1\n"; string(got) != want {
+		t.Errorf("expected output %q, got %q", want, got)
+	}
+	if reloads != 3 {
+		t.Errorf("expected 3 reloads, got %d", reloads)
+	}
+	// No temporary files are left behind.
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("expected only the config and output file, got %v", entries)
+	}
+
+	// The output file is not overwritten and no reload is sent if the config file cannot
+	// be rendered.
+	if err := os.WriteFile(cfgFile, []byte("node: $(UNSET)\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.reload(context.Background(), reloadTriggerSignal); err == nil {
+		t.Fatal("expected error but got none")
+	}
+	if got, err := os.ReadFile(outFile); err != nil || string(got) != "node: node-1\n" {
+		t.Errorf("unexpected output %q: %v", got, err)
+	}
+	if reloads != 3 {
+		t.Errorf("expected 3 reloads, got %d", reloads)
+	}
+}
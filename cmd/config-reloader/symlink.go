@@ -0,0 +1,141 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// dataSymlink is the symlink through which the kubelet atomically swaps the contents of
+// ConfigMap and Secret volumes. The files in the volume are symlinks into its target.
+const dataSymlink = "..data"
+
+// symlinkWatcher triggers a reload whenever the target of the ..data symlink in one of
+// the directories changes, i.e. the kubelet swapped in new contents of a ConfigMap or
+// Secret volume.
+//
+// The reloader watches the config file itself, whose inotify watch follows the symlink
+// into the previous target directory and is lost once the kubelet deletes it. Without
+// this watcher, changes after the first swap are only picked up by the periodic check.
+type symlinkWatcher struct {
+	logger   log.Logger
+	dirs     []string
+	interval time.Duration
+	reload   func(context.Context) error
+
+	// Last observed symlink target by directory. Directories without the symlink are not
+	// included.
+	targets map[string]string
+}
+
+func newSymlinkWatcher(logger log.Logger, dirs []string, interval time.Duration, reload func(context.Context) error) *symlinkWatcher {
+	return &symlinkWatcher{
+		logger:   logger,
+		dirs:     dirs,
+		interval: interval,
+		reload:   reload,
+		targets:  map[string]string{},
+	}
+}
+
+// run checks the symlinks on file system notifications for the directories and
+// periodically until the context is cancelled.
+func (w *symlinkWatcher) run(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("create watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	// Watching the directories rather than the symlinks reports the swap, which renames a
+	// new symlink onto the existing one.
+	for _, dir := range w.dirs {
+		if err := watcher.Add(dir); err != nil {
+			return fmt.Errorf("watch directory %s: %w", dir, err)
+		}
+	}
+	// Record the initial targets.
+	if _, err := w.check(); err != nil {
+		//nolint:errcheck
+		level.Error(w.logger).Log("msg", "checking ..data symlinks failed", "err", err)
+	}
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event := <-watcher.Events:
+			if filepath.Base(event.Name) != dataSymlink {
+				continue
+			}
+		case err := <-watcher.Errors:
+			//nolint:errcheck
+			level.Error(w.logger).Log("msg", "watching ..data symlinks failed", "err", err)
+			continue
+		case <-ticker.C:
+		}
+		changed, err := w.check()
+		if err != nil {
+			//nolint:errcheck
+			level.Error(w.logger).Log("msg", "checking ..data symlinks failed", "err", err)
+		}
+		if !changed {
+			continue
+		}
+		//nolint:errcheck
+		level.Info(w.logger).Log("msg", "..data symlink swapped, triggering reload")
+		if err := w.reload(ctx); err != nil {
+			//nolint:errcheck
+			level.Error(w.logger).Log("msg", "reload triggered by ..data symlink swap failed", "err", err)
+		}
+	}
+}
+
+// check reads the symlink targets and returns whether any of them changed since the last
+// check. A symlink appearing or disappearing is not considered a change.
+func (w *symlinkWatcher) check() (bool, error) {
+	var (
+		changed bool
+		errs    []error
+	)
+	for _, dir := range w.dirs {
+		target, err := os.Readlink(filepath.Join(dir, dataSymlink))
+		if errors.Is(err, os.ErrNotExist) {
+			delete(w.targets, dir)
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if last, ok := w.targets[dir]; ok && last != target {
+			changed = true
+		}
+		w.targets[dir] = target
+	}
+	return changed, errors.Join(errs...)
+}
@@ -0,0 +1,131 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+)
+
+// swapDataDir writes the files to a new timestamped directory and atomically points the
+// ..data symlink to it, like the kubelet updates ConfigMap and Secret volumes.
+func swapDataDir(t *testing.T, dir, timestamp string, files map[string]string) {
+	t.Helper()
+	tsDir := "..2024_01_01_00_00_" + timestamp
+	if err := os.Mkdir(filepath.Join(dir, tsDir), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, tsDir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		// The user-visible files point into the current directory through ..data.
+		link := filepath.Join(dir, name)
+		if _, err := os.Lstat(link); os.IsNotExist(err) {
+			if err := os.Symlink(filepath.Join(dataSymlink, name), link); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	old, _ := os.Readlink(filepath.Join(dir, dataSymlink))
+
+	tmp := filepath.Join(dir, "..data_tmp")
+	if err := os.Symlink(tsDir, tmp); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, dataSymlink)); err != nil {
+		t.Fatal(err)
+	}
+	if old != "" {
+		if err := os.RemoveAll(filepath.Join(dir, old)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSymlinkWatcherCheck(t *testing.T) {
+	dir := t.TempDir()
+	other := t.TempDir()
+	w := newSymlinkWatcher(log.NewNopLogger(), []string{dir, other}, time.Minute, nil)
+
+	// Directories without the symlink are ignored.
+	if changed, err := w.check(); err != nil || changed {
+		t.Fatalf("expected no change, got %v: %v", changed, err)
+	}
+	// The first observed target is not a change.
+	swapDataDir(t, dir, "00.000", map[string]string{"config.yaml": "global: {}\n"})
+	if changed, err := w.check(); err != nil || changed {
+		t.Fatalf("expected no change, got %v: %v", changed, err)
+	}
+	if changed, err := w.check(); err != nil || changed {
+		t.Fatalf("expected no change, got %v: %v", changed, err)
+	}
+	// A swap is detected even though the contents and modification times of the files
+	// are unchanged.
+	swapDataDir(t, dir, "01.000", map[string]string{"config.yaml": "global: {}\n"})
+	if changed, err := w.check(); err != nil || !changed {
+		t.Fatalf("expected change, got %v: %v", changed, err)
+	}
+	if changed, err := w.check(); err != nil || changed {
+		t.Fatalf("expected no change, got %v: %v", changed, err)
+	}
+	// The config file is still read through the swapped symlinks.
+	if b, err := os.ReadFile(filepath.Join(dir, "config.yaml")); err != nil || string(b) != "global: {}\n" {
+		t.Fatalf("unexpected config file %q: %v", b, err)
+	}
+}
+
+func TestSymlinkWatcherRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dir := t.TempDir()
+	swapDataDir(t, dir, "00.000", map[string]string{"config.yaml": "global: {}\n"})
+
+	var reloads atomic.Int32
+	w := newSymlinkWatcher(log.NewNopLogger(), []string{dir}, time.Hour, func(context.Context) error {
+		reloads.Add(1)
+		return nil
+	})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := w.run(ctx); err != nil {
+			t.Error(err)
+		}
+	}()
+	// Give the watcher time to record the initial target.
+	time.Sleep(100 * time.Millisecond)
+	if got := reloads.Load(); got != 0 {
+		t.Fatalf("expected no reload before a swap, got %d", got)
+	}
+
+	// The swap is detected through the file system notification, long before the next
+	// periodic check.
+	swapDataDir(t, dir, "01.000", map[string]string{"config.yaml": "global: {}\n"})
+	for start := time.Now(); time.Since(start) < 10*time.Second && reloads.Load() == 0; time.Sleep(10 * time.Millisecond) {
+	}
+	cancel()
+	<-done
+
+	if got := reloads.Load(); got != 1 {
+		t.Errorf("expected 1 reload, got %d", got)
+	}
+}
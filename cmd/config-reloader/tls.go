@@ -0,0 +1,60 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/prometheus/common/config"
+)
+
+// tlsFiles are the files of the TLS configuration for requests to the ready and reload
+// endpoints.
+type tlsFiles struct {
+	caFile, certFile, keyFile string
+}
+
+func (f *tlsFiles) enabled() bool {
+	return f.caFile != "" || f.certFile != "" || f.keyFile != ""
+}
+
+func (f *tlsFiles) validate() error {
+	if (f.certFile == "") != (f.keyFile == "") {
+		return errors.New("client certificate and key files must be set together")
+	}
+	return nil
+}
+
+// newTransport returns the transport for requests to the ready and reload endpoints. It
+// returns the default transport if no TLS files are set.
+//
+// The files are read again on each request and the TLS configuration is updated if their
+// contents changed, so that rotated certificates are picked up without a restart.
+func (f *tlsFiles) newTransport() (http.RoundTripper, error) {
+	if !f.enabled() {
+		return http.DefaultTransport, nil
+	}
+	if err := f.validate(); err != nil {
+		return nil, err
+	}
+	return config.NewRoundTripperFromConfig(config.HTTPClientConfig{
+		TLSConfig: config.TLSConfig{
+			CAFile:   f.caFile,
+			CertFile: f.certFile,
+			KeyFile:  f.keyFile,
+		},
+	}, "config-reloader")
+}
@@ -0,0 +1,213 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	promconfig "github.com/prometheus/prometheus/config"
+)
+
+var (
+	firstGzipBytes = []byte{0x1f, 0x8b, 0x08}
+	envRe          = regexp.MustCompile(`\$\(([a-zA-Z_0-9]+)\)`)
+)
+
+// configValidator renders the watched configuration file the same way the reloader does
+// and only passes it on to the reloader if the result is a valid Prometheus configuration.
+// Otherwise the last valid configuration stays in place and no reload is triggered.
+type configValidator struct {
+	logger   log.Logger
+	cfgFile  string
+	outFile  string
+	interval time.Duration
+
+	mtx sync.Mutex
+	// The configuration applied before the current one.
+	previous []byte
+	// A configuration that was reverted and must not be applied again.
+	rejected []byte
+
+	lastValid prometheus.Gauge
+	failures  prometheus.Counter
+}
+
+func newConfigValidator(logger log.Logger, reg prometheus.Registerer, cfgFile, outFile string, interval time.Duration) *configValidator {
+	v := &configValidator{
+		logger:   logger,
+		cfgFile:  cfgFile,
+		outFile:  outFile,
+		interval: interval,
+		lastValid: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "config_reloader_last_validation_successful",
+			Help: "Whether the last validation of the rendered configuration succeeded.",
+		}),
+		failures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "config_reloader_validation_failures_total",
+			Help: "Total number of rendered configurations that failed validation and were not applied.",
+		}),
+	}
+	if reg != nil {
+		reg.MustRegister(v.lastValid, v.failures)
+	}
+	return v
+}
+
+// run periodically validates the configuration file until the context is cancelled.
+func (v *configValidator) run(ctx context.Context) error {
+	ticker := time.NewTicker(v.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := v.apply(); err != nil {
+				//nolint:errcheck
+				level.Error(v.logger).Log("msg", "invalid configuration, keeping last valid configuration", "err", err)
+			}
+		}
+	}
+}
+
+// apply validates the rendered configuration file and writes it to the output file if
+// it is valid and has changed.
+func (v *configValidator) apply() error {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+
+	b, err := os.ReadFile(v.cfgFile)
+	if err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+	if v.rejected != nil && bytes.Equal(v.rejected, b) {
+		return errors.New("configuration was reverted after failed reload")
+	}
+	v.rejected = nil
+
+	if err := v.validate(b); err != nil {
+		v.lastValid.Set(0)
+		v.failures.Inc()
+		return err
+	}
+	v.lastValid.Set(1)
+
+	prev, err := os.ReadFile(v.outFile)
+	if err == nil && bytes.Equal(prev, b) {
+		return nil
+	}
+	if err := v.write(b); err != nil {
+		return err
+	}
+	v.previous = prev
+	return nil
+}
+
+// revert restores the previously applied configuration. The current configuration is
+// not applied again until the configuration file changes.
+func (v *configValidator) revert() error {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+
+	if v.previous == nil {
+		return errors.New("no previous configuration")
+	}
+	cur, err := os.ReadFile(v.outFile)
+	if err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+	if err := v.write(v.previous); err != nil {
+		return err
+	}
+	v.rejected, v.previous = cur, nil
+	return nil
+}
+
+func (v *configValidator) write(b []byte) error {
+	return writeFile(v.outFile, b)
+}
+
+// writeFile atomically replaces the contents of the given file.
+func writeFile(name string, b []byte) error {
+	tmpFile := name + ".tmp"
+	if err := os.WriteFile(tmpFile, b, 0o644); err != nil {
+		return fmt.Errorf("write file: %w", err)
+	}
+	if err := os.Rename(tmpFile, name); err != nil {
+		return fmt.Errorf("rename file: %w", err)
+	}
+	return nil
+}
+
+// validate renders the configuration like the reloader would and checks that the
+// result is a loadable Prometheus configuration.
+func (v *configValidator) validate(b []byte) error {
+	b, err := decompress(b)
+	if err != nil {
+		return err
+	}
+	b, err = expandEnv(b)
+	if err != nil {
+		return fmt.Errorf("expand environment variables: %w", err)
+	}
+	if _, err := promconfig.Load(string(b), false, v.logger); err != nil {
+		return fmt.Errorf("load configuration: %w", err)
+	}
+	return nil
+}
+
+// decompress extracts the configuration if it is gzipped, like the reloader does.
+func decompress(b []byte) ([]byte, error) {
+	if len(b) < len(firstGzipBytes) || !bytes.Equal(b[:len(firstGzipBytes)], firstGzipBytes) {
+		return b, nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("create gzip reader: %w", err)
+	}
+	defer zr.Close()
+
+	b, err = io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("read compressed config file: %w", err)
+	}
+	return b, nil
+}
+
+// expandEnv replaces $(VAR) references with the values of the respective environment
+// variables, matching the substitution done by the reloader. Unlike the reloader, it
+// reports all unset variables at once.
+func expandEnv(b []byte) ([]byte, error) {
+	if missing := missingEnvVars(b); len(missing) > 0 {
+		return nil, fmt.Errorf("found references to unset environment variables %s", strings.Join(missing, ", "))
+	}
+	return envRe.ReplaceAllFunc(b, func(n []byte) []byte {
+		return []byte(os.Getenv(string(n[2 : len(n)-1])))
+	}), nil
+}
@@ -0,0 +1,109 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+const validConfig = `
+scrape_configs:
+- job_name: example
+  static_configs:
+  - targets: ["$(NODE_NAME):9090"]
+`
+
+func TestConfigValidatorKeepLastValid(t *testing.T) {
+	t.Setenv("NODE_NAME", "node-1")
+
+	dir := t.TempDir()
+	cfgFile := filepath.Join(dir, "config.yaml")
+	outFile := filepath.Join(dir, "config.yaml.last-valid")
+	v := newConfigValidator(log.NewNopLogger(), prometheus.NewRegistry(), cfgFile, outFile, time.Second)
+
+	writeConfig := func(b []byte) {
+		t.Helper()
+		if err := os.WriteFile(cfgFile, b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectOutput := func(want []byte) {
+		t.Helper()
+		got, err := os.ReadFile(outFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(want, got) {
+			t.Fatalf("expected output %q, got %q", want, got)
+		}
+	}
+
+	// A valid configuration is passed on unmodified so that the reloader can render it.
+	writeConfig([]byte(validConfig))
+	if err := v.apply(); err != nil {
+		t.Fatal(err)
+	}
+	expectOutput([]byte(validConfig))
+	if got := testutil.ToFloat64(v.lastValid); got != 1 {
+		t.Errorf("expected last validation to be successful, got %v", got)
+	}
+
+	// Structurally invalid configurations and configurations that fail to render keep
+	// the last valid output.
+	for _, invalid := range []string{
+		"scrape_configs:\n- job_name: example\n  scrape_interval: $(NODE_NAME)\n",
+		"scrape_configs:\n- job_name: $(UNSET_VARIABLE)\n",
+		"scrape_configs: [",
+	} {
+		writeConfig([]byte(invalid))
+		if err := v.apply(); err == nil {
+			t.Errorf("expected error for config %q", invalid)
+		}
+		expectOutput([]byte(validConfig))
+		if got := testutil.ToFloat64(v.lastValid); got != 0 {
+			t.Errorf("expected last validation to fail, got %v", got)
+		}
+	}
+	if got := testutil.ToFloat64(v.failures); got != 3 {
+		t.Errorf("expected 3 validation failures, got %v", got)
+	}
+
+	// Compressed configurations are validated after decompression.
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(validConfig)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	writeConfig(buf.Bytes())
+	if err := v.apply(); err != nil {
+		t.Fatal(err)
+	}
+	expectOutput(buf.Bytes())
+	if got := testutil.ToFloat64(v.lastValid); got != 1 {
+		t.Errorf("expected last validation to be successful, got %v", got)
+	}
+}
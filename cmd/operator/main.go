@@ -28,6 +28,7 @@ import (
 	"github.com/oklog/run"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap/zapcore"
+	arv1 "k8s.io/api/admissionregistration/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
@@ -71,6 +72,29 @@ func main() {
 			"Address to listen to for incoming kube admission webhook connections.")
 		metricsAddr = flag.String("metrics-addr", ":18080", "Address to emit metrics on.")
 
+		webhookFailurePolicy = flag.String("webhook-failure-policy", "",
+			"Failure policy (Fail or Ignore) to set on the operator's admission webhooks. If empty, the installed policy is left unchanged.")
+		configRegenerationInterval = flag.Duration("config-regeneration-interval", 0,
+			"Minimum interval between collector configuration regenerations. Changes of the collector configuration, Secret, and DaemonSet within the interval are coalesced. Zero disables coalescing.")
+		namePattern = flag.String("name-pattern", "",
+			"Regular expression that names of new PodMonitorings and ClusterPodMonitorings must fully match. Empty permits any name.")
+		validateExisting = flag.Bool("validate-existing", false,
+			"Validate all existing PodMonitorings and ClusterPodMonitorings at startup and report the ones the admission webhooks would reject, without modifying them.")
+		maxMonitorings = flag.Int("max-monitorings", 0,
+			"Maximum total number of PodMonitorings and ClusterPodMonitorings. Creating further ones is rejected. Zero permits any number.")
+		estimateTargets = flag.Bool("estimate-targets", false,
+			"Warn on creation and update of ClusterPodMonitorings with the approximate number of targets they match. Requires permission to list pods in all namespaces.")
+		emitEvents = flag.Bool("emit-events", false,
+			"Emit Kubernetes Events on monitoring resources when generating their scrape configs fails and once it succeeds again. Requires permission to create and patch events in all namespaces.")
+		reportConfigHash = flag.Bool("report-config-hash", false,
+			"Report the hash of the scrape configs generated for PodMonitorings, ClusterPodMonitorings, and ClusterNodeMonitorings in the generatedConfigHash status field.")
+		resolveScrapeSecrets = flag.Bool("resolve-scrape-secrets", false,
+			"Resolve Secrets referenced by PodMonitorings, such as basic auth usernames, and provide them to the collectors. Requires permission to get, list, and watch Secrets in all namespaces.")
+
+		// Offline mode to debug the scrape configs generated for monitoring resources.
+		printConfig = flag.String("print-config", "",
+			"Print the Prometheus scrape configs generated for the PodMonitorings, ClusterPodMonitorings, and ClusterNodeMonitorings in the given YAML file, or stdin if '-', and exit.")
+
 		// Permit the operator to cleanup previously-managed resources that
 		// are missing the provided annotation. An empty string disables this
 		// feature.
@@ -85,6 +109,14 @@ func main() {
 		logger.Error(err, "unable to fetch Google Cloud metadata")
 	}
 
+	if *printConfig != "" {
+		if err := printScrapeConfigs(*printConfig, *projectID, *location, *cluster); err != nil {
+			logger.Error(err, "printing scrape configs failed")
+			os.Exit(1)
+		}
+		return
+	}
+
 	cfg, err := ctrl.GetConfig()
 	if err != nil {
 		logger.Error(err, "loading kubeconfig failed")
@@ -98,16 +130,25 @@ func main() {
 	metrics := ctrlmetrics.Registry
 
 	op, err := operator.New(logger, cfg, operator.Options{
-		ProjectID:         *projectID,
-		Location:          *location,
-		Cluster:           *cluster,
-		OperatorNamespace: *operatorNamespace,
-		PublicNamespace:   *publicNamespace,
-		TLSCert:           *tlsCert,
-		TLSKey:            *tlsKey,
-		CACert:            *caCert,
-		ListenAddr:        *webhookAddr,
-		CleanupAnnotKey:   *cleanupAnnotKey,
+		ProjectID:                  *projectID,
+		Location:                   *location,
+		Cluster:                    *cluster,
+		OperatorNamespace:          *operatorNamespace,
+		PublicNamespace:            *publicNamespace,
+		TLSCert:                    *tlsCert,
+		TLSKey:                     *tlsKey,
+		CACert:                     *caCert,
+		ListenAddr:                 *webhookAddr,
+		CleanupAnnotKey:            *cleanupAnnotKey,
+		WebhookFailurePolicy:       arv1.FailurePolicyType(*webhookFailurePolicy),
+		ConfigRegenerationInterval: *configRegenerationInterval,
+		NamePattern:                *namePattern,
+		ValidateExisting:           *validateExisting,
+		MaxMonitorings:             *maxMonitorings,
+		EstimateTargets:            *estimateTargets,
+		EmitEvents:                 *emitEvents,
+		ReportConfigHash:           *reportConfigHash,
+		ResolveScrapeSecrets:       *resolveScrapeSecrets,
 	})
 	if err != nil {
 		logger.Error(err, "instantiating operator failed")
@@ -139,6 +180,12 @@ func main() {
 	{
 		server := &http.Server{Addr: *metricsAddr}
 		http.Handle("/metrics", promhttp.HandlerFor(metrics, promhttp.HandlerOpts{Registry: metrics}))
+		// Final target label sets of a monitoring resource, e.g. for export into external inventories.
+		http.Handle("/target-labels", op.TargetLabelsHandler())
+		// Active targets of a monitoring resource for file-based discovery of external Prometheus servers.
+		http.Handle("/file-sd", op.FileSDHandler())
+		// Effective operator configuration, e.g. to debug generated configurations.
+		http.Handle("/config", op.ConfigHandler())
 		g.Add(func() error {
 			return server.ListenAndServe()
 		}, func(error) {
@@ -163,3 +210,18 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// printScrapeConfigs prints the scrape configs generated for the monitoring resources in
+// the file, or stdin if it is "-", to stdout.
+func printScrapeConfigs(file, projectID, location, cluster string) error {
+	r := os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	return operator.PrintScrapeConfigs(os.Stdout, r, projectID, location, cluster)
+}
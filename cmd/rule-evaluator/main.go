@@ -111,6 +111,9 @@ func main() {
 	queryCredentialsFile := a.Flag("query.credentials-file", "Credentials file for OAuth2 authentication with --query.target-url.").
 		Default("").String()
 
+	queryLogMaxSize := a.Flag("query.log-max-size", "Size at which the query log file configured through query_log_file is rotated.").
+		Default("10MiB").Bytes()
+
 	listenAddress := a.Flag("web.listen-address", "The address to listen on for HTTP requests.").
 		Default(":9091").String()
 
@@ -197,8 +200,15 @@ func main() {
 	}
 	v1api := v1.NewAPI(client)
 
+	queryLog := newQueryLog(int64(*queryLogMaxSize))
+
 	queryFunc := func(ctx context.Context, q string, t time.Time) (promql.Vector, error) {
+		start := time.Now()
 		v, warnings, err := QueryFunc(ctx, q, t, v1api)
+		if logErr := queryLog.Log(q, t, time.Since(start), err); logErr != nil {
+			//nolint:errcheck
+			level.Error(logger).Log("msg", "Writing query log failed", "err", logErr)
+		}
 		if len(warnings) > 0 {
 			//nolint:errcheck
 			level.Warn(logger).Log("msg", "Querying Prometheus instance returned warnings", "warn", warnings)
@@ -235,6 +245,9 @@ func main() {
 		{
 			name:     "notify",
 			reloader: notificationManager.ApplyConfig,
+		}, {
+			name:     "query_log",
+			reloader: queryLog.ApplyConfig,
 		}, {
 			name:     "exporter",
 			reloader: destination.ApplyConfig,
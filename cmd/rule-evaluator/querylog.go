@@ -0,0 +1,145 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/prometheus/prometheus/config"
+)
+
+// queryLogEntry is a single line of the query log.
+type queryLogEntry struct {
+	Query    string    `json:"query"`
+	Time     time.Time `json:"time"`
+	Duration float64   `json:"durationSeconds"`
+	Error    string    `json:"error,omitempty"`
+	TS       time.Time `json:"ts"`
+}
+
+// queryLog writes the queries issued for rule evaluation to the file configured
+// through the query_log_file global configuration. Once the file exceeds maxSize
+// it is renamed to a backup file with a ".1" suffix and a new file is started.
+type queryLog struct {
+	maxSize int64
+
+	mtx  sync.Mutex
+	path string
+	f    *os.File
+	size int64
+}
+
+func newQueryLog(maxSize int64) *queryLog {
+	return &queryLog{maxSize: maxSize}
+}
+
+// ApplyConfig opens the query log file of the configuration. The query log is
+// disabled if no file is configured.
+func (l *queryLog) ApplyConfig(cfg *config.Config) error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	path := cfg.GlobalConfig.QueryLogFile
+	if path == l.path {
+		return nil
+	}
+	if err := l.close(); err != nil {
+		return err
+	}
+	l.path = path
+	if path == "" {
+		return nil
+	}
+	return l.open()
+}
+
+func (l *queryLog) open() error {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open query log file: %w", err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("stat query log file: %w", err)
+	}
+	l.f, l.size = f, fi.Size()
+	return nil
+}
+
+func (l *queryLog) close() error {
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f, l.size = nil, 0
+	if err != nil {
+		return fmt.Errorf("close query log file: %w", err)
+	}
+	return nil
+}
+
+// rotate moves the current file to the backup file and opens a new one.
+func (l *queryLog) rotate() error {
+	if err := l.close(); err != nil {
+		return err
+	}
+	if err := os.Rename(l.path, l.path+".1"); err != nil {
+		// Keep logging to the current file rather than disabling the query log.
+		return errors.Join(fmt.Errorf("rotate query log file: %w", err), l.open())
+	}
+	return l.open()
+}
+
+// Log appends an entry for the query to the query log, if enabled.
+func (l *queryLog) Log(query string, t time.Time, d time.Duration, queryErr error) error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if l.f == nil {
+		return nil
+	}
+	entry := queryLogEntry{
+		Query:    query,
+		Time:     t.UTC(),
+		Duration: d.Seconds(),
+		TS:       time.Now().UTC(),
+	}
+	if queryErr != nil {
+		entry.Error = queryErr.Error()
+	}
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("marshal query log entry: %w", err)
+	}
+	b = append(b, '\n')
+
+	if l.size > 0 && l.size+int64(len(b)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := l.f.Write(b)
+	l.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("write query log entry: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/config"
+)
+
+func TestQueryLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "query.log")
+	l := newQueryLog(200)
+
+	// Queries are not logged without a configured file.
+	if err := l.Log("up", time.Now(), time.Second, nil); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{GlobalConfig: config.GlobalConfig{QueryLogFile: path}}
+	if err := l.ApplyConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := time.Unix(1000, 0)
+	if err := l.Log("up", ts, 1500*time.Millisecond, errors.New("timeout")); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry queryLogEntry
+	if err := json.Unmarshal(b, &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Query != "up" || !entry.Time.Equal(ts) || entry.Duration != 1.5 || entry.Error != "timeout" {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+
+	// Exceeding the maximum size rotates the file.
+	if err := l.Log(strings.Repeat("x", 100), ts, time.Second, nil); err != nil {
+		t.Fatal(err)
+	}
+	backup, err := os.ReadFile(path + ".1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(backup) != string(b) {
+		t.Errorf("unexpected backup file content: %s", backup)
+	}
+	b, err = os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), strings.Repeat("x", 100)) {
+		t.Errorf("unexpected query log content: %s", b)
+	}
+
+	// Removing the file from the configuration disables the query log.
+	if err := l.ApplyConfig(&config.Config{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Log("up", ts, time.Second, nil); err != nil {
+		t.Fatal(err)
+	}
+	if b2, err := os.ReadFile(path); err != nil || string(b2) != string(b) {
+		t.Errorf("unexpected query log content after disabling: %s, %v", b2, err)
+	}
+}
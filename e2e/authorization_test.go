@@ -462,6 +462,34 @@ func TestOAuth2PodMonitoring(t *testing.T) {
 		},
 	}
 	t.Run("oauth2-podmonitoring-failure", testEnsurePodMonitoringFailure(ctx, opClient, pmFail, "server returned HTTP status 401 Unauthorized"))
+
+	pmTokenFail := &monitoringv1.PodMonitoring{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "oauth-token-fail",
+			Namespace: "default",
+		},
+		Spec: monitoringv1.PodMonitoringSpec{
+			Selector: metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"app": "go-synthetic",
+				},
+			},
+			Endpoints: []monitoringv1.ScrapeEndpoint{
+				{
+					Port:     intstr.FromString("web"),
+					Interval: "5s",
+					HTTPClientConfig: monitoringv1.HTTPClientConfig{
+						OAuth2: &monitoringv1.OAuth2{
+							ClientID: "wrong-client-id",
+							Scopes:   []string{clientScope},
+							TokenURL: "http://go-synthetic.default.svc.cluster.local:8080/token",
+						},
+					},
+				},
+			},
+		},
+	}
+	t.Run("oauth2-podmonitoring-token-failure", testEnsurePodMonitoringFailure(ctx, opClient, pmTokenFail, `oauth2: "invalid_client"`))
 }
 
 func TestOAuth2ClusterPodMonitoring(t *testing.T) {
@@ -532,6 +560,72 @@ func TestOAuth2ClusterPodMonitoring(t *testing.T) {
 	t.Run("oauth2-clusterpodmonitoring-failure", testEnsureClusterPodMonitoringFailure(ctx, opClient, cpmFail, "server returned HTTP status 401 Unauthorized"))
 }
 
+func TestOAuth2TLSPodMonitoring(t *testing.T) {
+	ctx := context.Background()
+	kubeClient, opClient, err := setupCluster(ctx, t)
+	if err != nil {
+		t.Fatalf("error instantiating clients. err: %s", err)
+	}
+	var (
+		clientID    = "gmp-user-client-id-no-client-secret"
+		clientScope = "read"
+		accessToken = "abc123"
+	)
+
+	t.Run("collector-deployed", testCollectorDeployed(ctx, kubeClient))
+	t.Run("enable-target-status", testEnableTargetStatus(ctx, opClient))
+	// The self-signed certificate is served for both the metrics and the token endpoint.
+	t.Run("patch-example-app-args", testPatchExampleAppArgs(ctx, kubeClient,
+		[]string{
+			"--tls-create-self-signed=true",
+			fmt.Sprintf("--oauth2-client-id=%s", clientID),
+			fmt.Sprintf("--oauth2-scopes=%s", clientScope),
+			fmt.Sprintf("--oauth2-access-token=%s", accessToken)}))
+
+	// newPodMonitoring returns a PodMonitoring scraping over TLS whose tokens are fetched
+	// with the given token TLS config.
+	newPodMonitoring := func(name string, tokenTLS *monitoringv1.TLS) *monitoringv1.PodMonitoring {
+		return &monitoringv1.PodMonitoring{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+			},
+			Spec: monitoringv1.PodMonitoringSpec{
+				Selector: metav1.LabelSelector{
+					MatchLabels: map[string]string{
+						"app": "go-synthetic",
+					},
+				},
+				Endpoints: []monitoringv1.ScrapeEndpoint{
+					{
+						Scheme:   "https",
+						Port:     intstr.FromString("web"),
+						Interval: "5s",
+						HTTPClientConfig: monitoringv1.HTTPClientConfig{
+							TLS: &monitoringv1.TLS{
+								InsecureSkipVerify: true,
+							},
+							OAuth2: &monitoringv1.OAuth2{
+								ClientID: clientID,
+								Scopes:   []string{clientScope},
+								TokenURL: "https://go-synthetic.default.svc.cluster.local:8080/token",
+								TLS:      tokenTLS,
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+	pm := newPodMonitoring("oauth-tls-ready", &monitoringv1.TLS{InsecureSkipVerify: true})
+	t.Run("oauth2-tls-podmonitoring-ready", testEnsurePodMonitoringReady(ctx, opClient, pm))
+
+	// The TLS config of the scrape does not apply to the token request.
+	pmFail := newPodMonitoring("oauth-tls-fail", nil)
+	errMsg := "x509: certificate signed by unknown authority"
+	t.Run("oauth2-tls-podmonitoring-failure", testEnsurePodMonitoringFailure(ctx, opClient, pmFail, errMsg))
+}
+
 func testPatchExampleAppArgs(ctx context.Context, kubeClient kubernetes.Interface, args []string) func(*testing.T) {
 	return func(t *testing.T) {
 		scheme, err := newScheme()
@@ -75,7 +75,15 @@ func TestCollectorPodMonitoring(t *testing.T) {
 			},
 		},
 	}
-	t.Run("self-podmonitoring-ready", testEnsurePodMonitoringReady(ctx, opClient, pm))
+	t.Run("self-podmonitoring-ready", testEnsurePodMonitoringStatus(ctx, opClient, pm,
+		func(status *monitoringv1.ScrapeEndpointStatus) error {
+			if err := isPodMonitoringScrapeEndpointSuccess(status); err != nil {
+				return err
+			}
+			// Targets are scraped every 5 seconds.
+			return isPodMonitoringScrapeEndpointFresh(status, 30*time.Second)
+		}))
+	t.Run("self-podmonitoring-last-scrape-advances", testEnsurePodMonitoringLastScrapeTimeAdvances(ctx, opClient, pm))
 	if !skipGCM {
 		t.Run("self-podmonitoring-gcm", testValidateCollectorUpMetrics(ctx, kubeClient, "collector-podmon"))
 	}
@@ -122,6 +130,88 @@ func TestCollectorClusterPodMonitoring(t *testing.T) {
 	}
 }
 
+func TestScrapeLimitsPodMonitoring(t *testing.T) {
+	ctx := context.Background()
+	kubeClient, opClient, err := setupCluster(ctx, t)
+	if err != nil {
+		t.Fatalf("error instantiating clients. err: %s", err)
+	}
+
+	t.Run("collector-deployed", testCollectorDeployed(ctx, kubeClient))
+	t.Run("enable-target-status", testEnableTargetStatus(ctx, opClient))
+	t.Run("patch-example-app-args", testPatchExampleAppArgs(ctx, kubeClient, nil))
+
+	newPodMonitoring := func(name string, limits *monitoringv1.ScrapeLimits) *monitoringv1.PodMonitoring {
+		return &monitoringv1.PodMonitoring{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+			},
+			Spec: monitoringv1.PodMonitoringSpec{
+				Selector: metav1.LabelSelector{
+					MatchLabels: map[string]string{
+						"app": "go-synthetic",
+					},
+				},
+				Endpoints: []monitoringv1.ScrapeEndpoint{
+					{
+						Port:     intstr.FromString("web"),
+						Interval: "5s",
+					},
+				},
+				Limits: limits,
+			},
+		}
+	}
+	// The synthetic app exposes many samples, each with more than one label once the
+	// target labels are attached.
+	t.Run("samples-limit-podmonitoring-failure", testEnsurePodMonitoringFailure(ctx, opClient,
+		newPodMonitoring("samples-limit", &monitoringv1.ScrapeLimits{Samples: 1}),
+		"sample limit exceeded"))
+	t.Run("labels-limit-podmonitoring-failure", testEnsurePodMonitoringFailure(ctx, opClient,
+		newPodMonitoring("labels-limit", &monitoringv1.ScrapeLimits{Labels: 1}),
+		"label_limit exceeded"))
+	t.Run("label-value-length-limit-podmonitoring-failure", testEnsurePodMonitoringFailure(ctx, opClient,
+		newPodMonitoring("label-value-length-limit", &monitoringv1.ScrapeLimits{LabelValueLength: 1}),
+		"label_value_length_limit exceeded"))
+}
+
+func TestBodySizeLimitPodMonitoring(t *testing.T) {
+	ctx := context.Background()
+	kubeClient, opClient, err := setupCluster(ctx, t)
+	if err != nil {
+		t.Fatalf("error instantiating clients. err: %s", err)
+	}
+
+	t.Run("collector-deployed", testCollectorDeployed(ctx, kubeClient))
+	t.Run("enable-target-status", testEnableTargetStatus(ctx, opClient))
+	t.Run("patch-example-app-args", testPatchExampleAppArgs(ctx, kubeClient, nil))
+
+	// The synthetic app exposes far more than a KiB of metrics.
+	pm := &monitoringv1.PodMonitoring{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "body-size-limit",
+			Namespace: "default",
+		},
+		Spec: monitoringv1.PodMonitoringSpec{
+			Selector: metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"app": "go-synthetic",
+				},
+			},
+			Endpoints: []monitoringv1.ScrapeEndpoint{
+				{
+					Port:          intstr.FromString("web"),
+					Interval:      "5s",
+					BodySizeLimit: "1KB",
+				},
+			},
+		},
+	}
+	t.Run("body-size-limit-podmonitoring-failure", testEnsurePodMonitoringFailure(ctx, opClient, pm,
+		"body size limit exceeded"))
+}
+
 func TestCollectorKubeletScraping(t *testing.T) {
 	ctx := context.Background()
 	kubeClient, opClient, err := setupCluster(ctx, t)
@@ -309,6 +399,53 @@ func testEnsurePodMonitoringStatus(ctx context.Context, opClient versioned.Inter
 	}
 }
 
+// testEnsurePodMonitoringLastScrapeTimeAdvances ensures that the last scrape time of all
+// endpoints of the existing PodMonitoring advances with subsequent status updates.
+func testEnsurePodMonitoringLastScrapeTimeAdvances(ctx context.Context, opClient versioned.Interface, pm *monitoringv1.PodMonitoring) func(*testing.T) {
+	return func(t *testing.T) {
+		t.Log("ensuring PodMonitoring last scrape time advances")
+
+		pm, err := opClient.MonitoringV1().PodMonitorings(pm.Namespace).Get(ctx, pm.Name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("getting PodMonitoring failed: %s", err)
+		}
+		initial := map[string]metav1.Time{}
+		for _, status := range pm.Status.EndpointStatuses {
+			if status.LastScrapeTime == nil {
+				t.Fatalf("missing last scrape time of endpoint %q", status.Name)
+			}
+			initial[status.Name] = *status.LastScrapeTime
+		}
+		if len(initial) == 0 {
+			t.Fatal("no endpoint statuses")
+		}
+
+		err = wait.PollUntilContextCancel(ctx, pollDuration, false, func(ctx context.Context) (bool, error) {
+			pm, err := opClient.MonitoringV1().PodMonitorings(pm.Namespace).Get(ctx, pm.Name, metav1.GetOptions{})
+			if err != nil {
+				return false, fmt.Errorf("getting PodMonitoring failed: %w", err)
+			}
+			for _, status := range pm.Status.EndpointStatuses {
+				last, ok := initial[status.Name]
+				if !ok {
+					continue
+				}
+				if status.LastScrapeTime == nil {
+					return false, fmt.Errorf("missing last scrape time of endpoint %q", status.Name)
+				}
+				if !last.Before(status.LastScrapeTime) {
+					t.Logf("last scrape time of endpoint %q did not advance yet", status.Name)
+					return false, nil
+				}
+			}
+			return true, nil
+		})
+		if err != nil {
+			t.Errorf("unable to validate PodMonitoring last scrape time: %s", err)
+		}
+	}
+}
+
 // testEnsureClusterPodMonitoringReady sets up a ClusterPodMonitoring and
 // ensures its status is successfully scraping targets.
 func testEnsureClusterPodMonitoringReady(ctx context.Context, opClient versioned.Interface, cpm *monitoringv1.ClusterPodMonitoring) func(*testing.T) {
@@ -578,7 +715,29 @@ func isPodMonitoringScrapeEndpointSuccess(status *monitoringv1.ScrapeEndpointSta
 				if target.LastError != nil {
 					lastErr = *target.LastError
 				}
-				return fmt.Errorf("unhealthy target %q at group %d: %s", target.Health, i, lastErr)
+				return fmt.Errorf("unhealthy target %q at group %d (reason %q): %s", target.Health, i, target.FailureReason, lastErr)
+			}
+		}
+	}
+	return nil
+}
+
+// isPodMonitoringScrapeEndpointFresh checks that the endpoint and all sample targets were
+// scraped at most maxAge before the status was updated.
+func isPodMonitoringScrapeEndpointFresh(status *monitoringv1.ScrapeEndpointStatus, maxAge time.Duration) error {
+	if status.LastScrapeTime == nil {
+		return errors.New("missing last scrape time")
+	}
+	if age := status.LastUpdateTime.Sub(status.LastScrapeTime.Time); age > maxAge {
+		return fmt.Errorf("endpoint last scraped %s before the status update, expected at most %s", age, maxAge)
+	}
+	for i, group := range status.SampleGroups {
+		for _, target := range group.SampleTargets {
+			if target.LastScrapeTime == nil {
+				return fmt.Errorf("missing last scrape time for target at group %d", i)
+			}
+			if age := status.LastUpdateTime.Sub(target.LastScrapeTime.Time); age > maxAge {
+				return fmt.Errorf("target at group %d last scraped %s before the status update, expected at most %s", i, age, maxAge)
 			}
 		}
 	}
@@ -138,9 +138,10 @@ const (
 
 	// BatchSizeMax represents maximum number of samples to pack into a batch sent to GCM.
 	BatchSizeMax = 200
-	// Time after an accumulating batch is flushed to GCM. This avoids data being
-	// held indefinititely if not enough new data flows in to fill up the batch.
-	batchDelayMax = 50 * time.Millisecond
+	// DefaultBatchDelay is the time after which an accumulating batch is flushed to GCM.
+	// This avoids data being held indefinititely if not enough new data flows in to fill
+	// up the batch.
+	DefaultBatchDelay = 50 * time.Millisecond
 
 	// Prefix for GCM metric.
 	MetricTypePrefix = "prometheus.googleapis.com"
@@ -226,6 +227,9 @@ type EfficiencyOpts struct {
 	// documentation to learn more about algorithm. Defaults to
 	// DefaultShardBufferSize when 0.
 	ShardBufferSize uint
+	// BatchDelay controls the time after which an accumulating batch is sent
+	// even if it is not full. Defaults to DefaultBatchDelay when 0.
+	BatchDelay time.Duration
 }
 
 // NopExporter returns an inactive exporter.
@@ -346,6 +350,9 @@ func New(logger log.Logger, reg prometheus.Registerer, opts ExporterOpts) (*Expo
 	if opts.Efficiency.ShardBufferSize == 0 {
 		opts.Efficiency.ShardBufferSize = DefaultShardBufferSize
 	}
+	if opts.Efficiency.BatchDelay == 0 {
+		opts.Efficiency.BatchDelay = DefaultBatchDelay
+	}
 
 	if opts.MetricTypePrefix == "" {
 		opts.MetricTypePrefix = MetricTypePrefix
@@ -627,7 +634,7 @@ func (e *Exporter) Run(ctx context.Context) error {
 	go e.seriesCache.run(ctx)
 	go e.opts.Lease.Run(ctx)
 
-	timer := time.NewTimer(batchDelayMax)
+	timer := time.NewTimer(e.opts.Efficiency.BatchDelay)
 	stopTimer := func() {
 		if !timer.Stop() {
 			select {
@@ -655,7 +662,7 @@ func (e *Exporter) Run(ctx context.Context) error {
 
 		// Reset state for new batch.
 		stopTimer()
-		timer.Reset(batchDelayMax)
+		timer.Reset(e.opts.Efficiency.BatchDelay)
 
 		curBatch = newBatch(e.logger, e.opts.Efficiency.ShardCount, e.opts.Efficiency.BatchSize)
 	}
@@ -692,7 +699,7 @@ func (e *Exporter) Run(ctx context.Context) error {
 			if !curBatch.empty() {
 				send()
 			} else {
-				timer.Reset(batchDelayMax)
+				timer.Reset(e.opts.Efficiency.BatchDelay)
 			}
 		}
 	}
@@ -385,7 +385,7 @@ func TestExporter_drainBacklog(t *testing.T) {
 	// As our samples are all for the same series, each batch can only contain a single sample.
 	// The exporter waits for the batch delay duration before sending it.
 	// We sleep for an appropriate multiple of it to allow it to drain the shard.
-	time.Sleep(55 * batchDelayMax)
+	time.Sleep(55 * DefaultBatchDelay)
 
 	// Check that we received all samples that went in.
 	if got, want := len(metricServer.samples), 50; got != want {
@@ -164,6 +164,9 @@ func FromFlags(a *kingpin.Application, userAgentProduct string) func(log.Logger,
 	a.Flag("export.debug.shard-buffer-size", "The buffer size for each individual shard. Each element in buffer (queue) consists of sample and hash.").
 		Default(strconv.Itoa(export.DefaultShardBufferSize)).UintVar(&opts.Efficiency.ShardBufferSize)
 
+	a.Flag("export.debug.batch-delay", "Maximum time to wait for a batch to fill up before sending it to the GCM API.").
+		Default(export.DefaultBatchDelay.String()).DurationVar(&opts.Efficiency.BatchDelay)
+
 	a.Flag("export.token-url", "The request URL to generate token that's needed to ingest metrics to the project").
 		StringVar(&opts.TokenURL)
 
@@ -15,11 +15,16 @@
 package v1
 
 import (
+	"errors"
 	"fmt"
+	"net/url"
 	"regexp"
+	"slices"
 	"sort"
 	"strings"
 
+	"github.com/GoogleCloudPlatform/prometheus-engine/pkg/export"
+	"github.com/alecthomas/units"
 	"github.com/prometheus/common/config"
 	prommodel "github.com/prometheus/common/model"
 	promconfig "github.com/prometheus/prometheus/config"
@@ -33,6 +38,89 @@ import (
 // scrape configurations for a PodMonitoring resource.
 const EnvVarNodeName = "NODE_NAME"
 
+// projectIDRe matches valid Google Cloud project IDs.
+var projectIDRe = regexp.MustCompile(`^[a-z][a-z0-9-]{4,28}[a-z0-9]$`)
+
+// destinationProjectID returns the project ID configured for a monitoring resource or the
+// default project ID if none is configured.
+func destinationProjectID(projectID, defaultProjectID string) (string, error) {
+	if projectID == "" {
+		return defaultProjectID, nil
+	}
+	if !projectIDRe.MatchString(projectID) {
+		return "", fmt.Errorf("invalid project ID %q: must be 6 to 30 lowercase letters, digits, or hyphens, start with a letter, and not end with a hyphen", projectID)
+	}
+	return projectID, nil
+}
+
+// applyLabelOverrides returns the location and cluster labels with the overrides of a
+// monitoring resource applied.
+func applyLabelOverrides(overrides map[string]string, location, cluster string) (string, string, error) {
+	keys := make([]string, 0, len(overrides))
+	for k := range overrides {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v := overrides[k]
+		switch k {
+		case export.KeyLocation:
+			location = v
+		case export.KeyCluster:
+			cluster = v
+		case export.KeyProjectID:
+			return "", "", fmt.Errorf("label %q cannot be overridden, set projectID instead", k)
+		default:
+			return "", "", fmt.Errorf("label %q cannot be overridden, only %q and %q are permitted", k, export.KeyLocation, export.KeyCluster)
+		}
+		if v == "" || !prommodel.LabelValue(v).IsValid() {
+			return "", "", fmt.Errorf("invalid override %q for label %q", v, k)
+		}
+	}
+	return location, cluster, nil
+}
+
+// externalLabelRelabelConfigs generates relabeling rules that set the external labels as
+// static target labels. They must not override protected labels, metadata labels, or labels
+// mapped from pod labels.
+func externalLabelRelabelConfigs(externalLabels map[string]string, metadataLabels map[string]struct{}, podLabels []LabelMapping) ([]*relabel.Config, error) {
+	keys := make([]string, 0, len(externalLabels))
+	for k := range externalLabels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var relabelCfgs []*relabel.Config
+	for _, k := range keys {
+		v := externalLabels[k]
+		if !prommodel.LabelName(k).IsValid() || strings.HasPrefix(k, "__") {
+			return nil, fmt.Errorf("invalid external label name %q", k)
+		}
+		if isProtectedLabel(k) {
+			return nil, fmt.Errorf("external label %q must not override one of the protected labels %s", k, strings.Join(protectedLabels, ", "))
+		}
+		if _, ok := metadataLabels[k]; ok {
+			return nil, fmt.Errorf("external label %q conflicts with metadata label", k)
+		}
+		for _, m := range podLabels {
+			if m.To == k || (m.To == "" && m.From == k) {
+				return nil, fmt.Errorf("external label %q conflicts with pod label mapping", k)
+			}
+		}
+		if v == "" || !prommodel.LabelValue(v).IsValid() {
+			return nil, fmt.Errorf("invalid value %q for external label %q", v, k)
+		}
+		relabelCfgs = append(relabelCfgs, &relabel.Config{
+			Action: relabel.Replace,
+			// Escape the value as the replacement expands references to regex groups.
+			Replacement: strings.ReplaceAll(v, "$", "$$"),
+			TargetLabel: k,
+		})
+	}
+	return relabelCfgs, nil
+}
+
 // relabelingsForSelector generates a sequence of relabeling rules that implement
 // the label selector for the meta labels produced by the Kubernetes service discovery.
 func relabelingsForSelector(selector metav1.LabelSelector, crd interface{}) ([]*relabel.Config, error) {
@@ -48,6 +136,22 @@ func relabelingsForSelector(selector metav1.LabelSelector, crd interface{}) ([]*
 	default:
 		return nil, fmt.Errorf("invalid CRD type %T", crd)
 	}
+	return relabelingsForLabelSelector(selector, objectLabel, objectLabelPresent)
+}
+
+// relabelingsForNodeSelector generates relabeling rules that only keep targets on nodes
+// matching the label selector. The node labels must be attached to the discovered targets.
+func relabelingsForNodeSelector(selector *metav1.LabelSelector) ([]*relabel.Config, error) {
+	// Validates the label keys and values.
+	if _, err := metav1.LabelSelectorAsSelector(selector); err != nil {
+		return nil, fmt.Errorf("invalid node selector: %w", err)
+	}
+	return relabelingsForLabelSelector(*selector, "__meta_kubernetes_node_label_", "__meta_kubernetes_node_labelpresent_")
+}
+
+// relabelingsForLabelSelector generates relabeling rules that implement the label selector
+// for the given label and label presence meta label prefixes.
+func relabelingsForLabelSelector(selector metav1.LabelSelector, objectLabel, objectLabelPresent prommodel.LabelName) ([]*relabel.Config, error) {
 	// Simple equal matchers. Sort by keys first to ensure that generated configs are reproducible.
 	// (Go map iteration is non-deterministic.)
 	var selectorKeys []string
@@ -73,7 +177,7 @@ func relabelingsForSelector(selector metav1.LabelSelector, crd interface{}) ([]*
 	for _, exp := range selector.MatchExpressions {
 		switch exp.Operator {
 		case metav1.LabelSelectorOpIn:
-			re, err := relabel.NewRegexp(strings.Join(exp.Values, "|"))
+			re, err := relabel.NewRegexp(joinSorted(exp.Values, "|"))
 			if err != nil {
 				return nil, err
 			}
@@ -83,7 +187,7 @@ func relabelingsForSelector(selector metav1.LabelSelector, crd interface{}) ([]*
 				Regex:        re,
 			})
 		case metav1.LabelSelectorOpNotIn:
-			re, err := relabel.NewRegexp(strings.Join(exp.Values, "|"))
+			re, err := relabel.NewRegexp(joinSorted(exp.Values, "|"))
 			if err != nil {
 				return nil, err
 			}
@@ -110,6 +214,14 @@ func relabelingsForSelector(selector metav1.LabelSelector, crd interface{}) ([]*
 	return relabelCfgs, nil
 }
 
+// joinSorted joins a sorted copy of the values so that the generated configs do not depend
+// on the order in which the values are listed.
+func joinSorted(values []string, sep string) string {
+	sorted := slices.Clone(values)
+	sort.Strings(sorted)
+	return strings.Join(sorted, sep)
+}
+
 // buildPrometheusScrapConfig builds a Prometheus scrape configuration for a given endpoint.
 func buildPrometheusScrapConfig(jobName string, discoverCfgs discovery.Configs, httpCfg config.HTTPClientConfig, relabelCfgs []*relabel.Config, limits *ScrapeLimits, ep ScrapeEndpoint) (*promconfig.ScrapeConfig, error) {
 	interval, err := prommodel.ParseDuration(ep.Interval)
@@ -126,12 +238,30 @@ func buildPrometheusScrapConfig(jobName string, discoverCfgs discovery.Configs,
 			return nil, fmt.Errorf("scrape timeout %v must not be greater than scrape interval %v", timeout, interval)
 		}
 	}
+	var bodySizeLimit units.Base2Bytes
+	if ep.BodySizeLimit != "" {
+		bodySizeLimit, err = units.ParseBase2Bytes(ep.BodySizeLimit)
+		if err != nil {
+			return nil, fmt.Errorf("invalid body size limit %q: %w", ep.BodySizeLimit, err)
+		}
+		if bodySizeLimit < 0 {
+			return nil, fmt.Errorf("invalid body size limit %q: must not be negative", ep.BodySizeLimit)
+		}
+	}
 	metricsPath := "/metrics"
 	if ep.Path != "" {
 		metricsPath = ep.Path
 	}
 
-	var metricRelabelCfgs []*relabel.Config
+	metricRelabelCfgs, err := resourceAttributeRelabelConfigs(ep.ResourceAttributes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid resource attribute mapping: %w", err)
+	}
+	metricFilterCfgs, err := metricFilterRelabelConfigs(ep.MetricKeep, ep.MetricDrop)
+	if err != nil {
+		return nil, err
+	}
+	metricRelabelCfgs = append(metricRelabelCfgs, metricFilterCfgs...)
 	for _, r := range ep.MetricRelabeling {
 		rcfg, err := convertRelabelingRule(r)
 		if err != nil {
@@ -139,6 +269,27 @@ func buildPrometheusScrapConfig(jobName string, discoverCfgs discovery.Configs,
 		}
 		metricRelabelCfgs = append(metricRelabelCfgs, rcfg)
 	}
+	// The prefix is applied last so that user-provided relabeling rules can match
+	// against the metric names as exposed by the target.
+	if ep.MetricPrefix != "" {
+		if !prommodel.IsValidMetricName(prommodel.LabelValue(ep.MetricPrefix)) {
+			return nil, fmt.Errorf("invalid metric prefix %q", ep.MetricPrefix)
+		}
+		metricRelabelCfgs = append(metricRelabelCfgs, &relabel.Config{
+			Action:       relabel.Replace,
+			SourceLabels: prommodel.LabelNames{"__name__"},
+			TargetLabel:  "__name__",
+			Replacement:  ep.MetricPrefix + "$1",
+		})
+	}
+
+	if ep.MaxMetricNameLength > 0 {
+		cfg, err := maxMetricNameLengthRelabelConfig(ep.MaxMetricNameLength)
+		if err != nil {
+			return nil, err
+		}
+		metricRelabelCfgs = append(metricRelabelCfgs, cfg)
+	}
 
 	scrapeCfg := &promconfig.ScrapeConfig{
 		// Generate a job name to make it easy to track what generated the scrape configuration.
@@ -151,6 +302,7 @@ func buildPrometheusScrapConfig(jobName string, discoverCfgs discovery.Configs,
 		HTTPClientConfig:        httpCfg,
 		ScrapeInterval:          interval,
 		ScrapeTimeout:           timeout,
+		BodySizeLimit:           bodySizeLimit,
 		RelabelConfigs:          relabelCfgs,
 		MetricRelabelConfigs:    metricRelabelCfgs,
 	}
@@ -175,6 +327,155 @@ func buildPrometheusScrapConfig(jobName string, discoverCfgs discovery.Configs,
 	return scrapeCfg, nil
 }
 
+// ProbeJobSuffix separates the endpoint from the probe target index in the job names of
+// scrape configurations generated for probe targets.
+const ProbeJobSuffix = "/probe-"
+
+// monitoringNameLabel is set to the scrape pool of endpoints that enable MonitoringNameLabel.
+const monitoringNameLabel = "monitoring_name"
+
+// probeScrapeConfigs returns the scrape configurations for the endpoint. Endpoints with probe
+// targets get a copy of the scrape configuration for each target, which passes the target
+// as a param and sets it as the instance label.
+func probeScrapeConfigs(cfg *promconfig.ScrapeConfig, ep ScrapeEndpoint) ([]*promconfig.ScrapeConfig, error) {
+	if len(ep.ProbeTargets) == 0 {
+		return []*promconfig.ScrapeConfig{cfg}, nil
+	}
+	if _, ok := ep.Params["target"]; ok {
+		return nil, errors.New("the target param must not be set together with probe targets")
+	}
+	if ep.InstanceLabelFrom != "" {
+		return nil, errors.New("instanceLabelFrom must not be set together with probe targets")
+	}
+	var res []*promconfig.ScrapeConfig
+	for i, target := range ep.ProbeTargets {
+		if target == "" {
+			return nil, fmt.Errorf("probe target with index %d must not be empty", i)
+		}
+		probeCfg := *cfg
+		probeCfg.JobName = fmt.Sprintf("%s%s%d", cfg.JobName, ProbeJobSuffix, i)
+
+		probeCfg.Params = url.Values{}
+		for k, v := range cfg.Params {
+			probeCfg.Params[k] = v
+		}
+		probeCfg.Params.Set("target", target)
+
+		probeCfg.RelabelConfigs = append(slices.Clone(cfg.RelabelConfigs), &relabel.Config{
+			Action: relabel.Replace,
+			// Escape the target so that it is not expanded as a regex replacement.
+			Replacement: strings.ReplaceAll(target, "$", "$$"),
+			TargetLabel: "instance",
+		})
+		res = append(res, &probeCfg)
+	}
+	return res, nil
+}
+
+// maxMetricNameLength is the largest supported metric name length limit. Relabeling regular
+// expressions do not support larger repetition counts.
+const maxMetricNameLength = 1000
+
+// maxMetricNameLengthRelabelConfig generates a metric relabeling rule that drops all metrics
+// with names longer than the given length.
+func maxMetricNameLengthRelabelConfig(length uint) (*relabel.Config, error) {
+	if length > maxMetricNameLength {
+		return nil, fmt.Errorf("max metric name length %d must not exceed %d", length, maxMetricNameLength)
+	}
+	return convertRelabelingRule(RelabelingRule{
+		Action:       "drop",
+		SourceLabels: []string{"__name__"},
+		Regex:        fmt.Sprintf(".{%d}.+", length),
+	})
+}
+
+// metricFilterRelabelConfigs generates metric relabeling rules that keep only the metrics
+// whose names match any of the keep regexes and then drop the ones whose names match any
+// of the drop regexes.
+func metricFilterRelabelConfigs(keep, drop []string) ([]*relabel.Config, error) {
+	keepSet := map[string]bool{}
+	for i, re := range keep {
+		if re == "" {
+			return nil, fmt.Errorf("metric keep regex with index %d must not be empty", i)
+		}
+		keepSet[re] = true
+	}
+	for i, re := range drop {
+		if re == "" {
+			return nil, fmt.Errorf("metric drop regex with index %d must not be empty", i)
+		}
+		if keepSet[re] {
+			return nil, fmt.Errorf("metric regex %q must not be both kept and dropped", re)
+		}
+	}
+	var relabelCfgs []*relabel.Config
+	for _, f := range []struct {
+		action  string
+		regexes []string
+	}{
+		{action: "keep", regexes: keep},
+		{action: "drop", regexes: drop},
+	} {
+		if len(f.regexes) == 0 {
+			continue
+		}
+		alternatives := make([]string, 0, len(f.regexes))
+		for _, re := range f.regexes {
+			if _, err := relabel.NewRegexp(re); err != nil {
+				return nil, fmt.Errorf("invalid metric %s regex %q: %w", f.action, re, err)
+			}
+			alternatives = append(alternatives, "(?:"+re+")")
+		}
+		cfg, err := convertRelabelingRule(RelabelingRule{
+			Action:       f.action,
+			SourceLabels: []string{"__name__"},
+			Regex:        strings.Join(alternatives, "|"),
+		})
+		if err != nil {
+			return nil, err
+		}
+		relabelCfgs = append(relabelCfgs, cfg)
+	}
+	return relabelCfgs, nil
+}
+
+// resourceAttributeRelabelConfigs generates metric relabeling rules that move the labels of
+// OpenTelemetry resource attributes onto the configured target labels.
+func resourceAttributeRelabelConfigs(mappings []LabelMapping) ([]*relabel.Config, error) {
+	var relabelCfgs []*relabel.Config
+	for _, m := range mappings {
+		if m.From == "" {
+			return nil, errors.New("resource attribute must be set")
+		}
+		if m.To == "" {
+			return nil, fmt.Errorf("target label for resource attribute %q must be set", m.From)
+		}
+		attr := string(sanitizeLabelName(m.From))
+		if m.To == attr {
+			return nil, fmt.Errorf("target label for resource attribute %q must differ from %q", m.From, attr)
+		}
+		// Only copy present attributes so that existing values of the target label are not cleared.
+		replace, err := convertRelabelingRule(RelabelingRule{
+			Action:       "replace",
+			SourceLabels: []string{attr},
+			Regex:        "(.+)",
+			TargetLabel:  m.To,
+		})
+		if err != nil {
+			return nil, err
+		}
+		drop, err := convertRelabelingRule(RelabelingRule{
+			Action: "labeldrop",
+			Regex:  attr,
+		})
+		if err != nil {
+			return nil, err
+		}
+		relabelCfgs = append(relabelCfgs, replace, drop)
+	}
+	return relabelCfgs, nil
+}
+
 var invalidLabelCharRE = regexp.MustCompile(`[^a-zA-Z0-9_]`)
 
 // sanitizeLabelName reproduces the label name cleanup Prometheus's service discovery applies.
@@ -18,23 +18,76 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"path"
+	"strings"
 
 	"github.com/prometheus/common/config"
+	corev1 "k8s.io/api/core/v1"
 )
 
+// CollectorSecretsDir is the directory in which the collector mounts the secrets that
+// are referenced by scrape configurations.
+const CollectorSecretsDir = "/etc/secrets"
+
+// CollectorSecretPath returns the path at which the collector finds the given key of a
+// Secret in the given namespace.
+func CollectorSecretPath(namespace string, sel *corev1.SecretKeySelector) string {
+	return path.Join(CollectorSecretsDir, (&SecretOrConfigMap{Secret: sel}).FileName(namespace))
+}
+
+// CollectorServiceAccountTokensDir is the directory in which the collector mounts the
+// service account tokens that are referenced by scrape configurations.
+const CollectorServiceAccountTokensDir = "/etc/service-account-tokens"
+
+// CollectorServiceAccountTokenFile returns the file name of the collector's service account
+// token for the given audience within CollectorServiceAccountTokensDir.
+func CollectorServiceAccountTokenFile(audience string) string {
+	return "token_" + url.PathEscape(audience)
+}
+
 // Auth sets the `Authorization` header on every scrape request.
 //
-// Currently the credentials are not configurable and always empty.
+// Currently the credentials are only configurable through ServiceAccountToken and are
+// empty otherwise.
 type Auth struct {
 	// The authentication type. Defaults to Bearer, Basic will cause an error.
 	Type string `json:"type,omitempty"`
+	// ServiceAccountToken sends a token of the collector's service account as credentials.
+	// The type must be unset or Bearer.
+	ServiceAccountToken *ServiceAccountToken `json:"serviceAccountToken,omitempty"`
 	// TODO: Add credentials: https://github.com/GoogleCloudPlatform/prometheus-engine/issues/450
 }
 
-func (c *Auth) ToPrometheusConfig() *config.Authorization {
-	return &config.Authorization{
+// ServiceAccountToken configures a token of the collector's service account for the given
+// audience, e.g. for targets that authenticate requests through a TokenReview.
+//
+// The token is requested from the Kubernetes TokenRequest API by the kubelet through a
+// projected volume that the operator adds to the collector DaemonSet, which restarts the
+// collectors whenever the set of audiences changes. The kubelet refreshes the token before
+// it expires and the collector reads it again on every scrape.
+//
+// The token identifies the collector, not the scraped pod. Targets should only accept
+// tokens issued for their own audience.
+type ServiceAccountToken struct {
+	// The intended audience of the token, e.g. the URL or name of the target service.
+	// +kubebuilder:validation:MinLength=1
+	Audience string `json:"audience"`
+}
+
+func (c *Auth) ToPrometheusConfig() (*config.Authorization, error) {
+	auth := &config.Authorization{
 		Type: c.Type,
 	}
+	if c.ServiceAccountToken != nil {
+		if c.Type != "" && !strings.EqualFold(c.Type, "Bearer") {
+			return nil, fmt.Errorf("authorization type must be Bearer for service account tokens, got %q", c.Type)
+		}
+		if c.ServiceAccountToken.Audience == "" {
+			return nil, errors.New("service account token audience must be set")
+		}
+		auth.CredentialsFile = path.Join(CollectorServiceAccountTokensDir, CollectorServiceAccountTokenFile(c.ServiceAccountToken.Audience))
+	}
+	return auth, nil
 }
 
 // BasicAuth sets the `Authorization` header on every scrape request with the
@@ -42,31 +95,83 @@ func (c *Auth) ToPrometheusConfig() *config.Authorization {
 //
 // Currently the password is not configurable and always empty.
 type BasicAuth struct {
-	// The username for authentication.
+	// The username for authentication. Mutually exclusive with UsernameSecret.
 	Username string `json:"username,omitempty"`
+	// A key of a Secret containing the username for authentication. The Secret must be in
+	// the same namespace as the PodMonitoring. It is not supported for cluster-scoped resources.
+	// Mutually exclusive with Username.
+	// Requires the operator to run with `--resolve-scrape-secrets`, which grants it access to
+	// the Secrets in all namespaces.
+	UsernameSecret *corev1.SecretKeySelector `json:"usernameSecret,omitempty"`
 	// TODO: Add password: https://github.com/GoogleCloudPlatform/prometheus-engine/issues/450
 }
 
-func (c *BasicAuth) ToPrometheusConfig() *config.BasicAuth {
-	return &config.BasicAuth{
-		Username: c.Username,
+// ToPrometheusConfig converts the basic auth settings. Secrets are resolved relative to the
+// given namespace, which is empty for cluster-scoped resources.
+func (c *BasicAuth) ToPrometheusConfig(namespace string) (*config.BasicAuth, error) {
+	if c.UsernameSecret == nil {
+		return &config.BasicAuth{
+			Username: c.Username,
+		}, nil
+	}
+	if c.Username != "" {
+		return nil, errors.New("basic auth username and usernameSecret are mutually exclusive")
 	}
+	if c.UsernameSecret.Name == "" || c.UsernameSecret.Key == "" {
+		return nil, errors.New("basic auth usernameSecret must specify a name and key")
+	}
+	if namespace == "" {
+		return nil, errors.New("basic auth usernameSecret is not supported for cluster-scoped resources")
+	}
+	return &config.BasicAuth{
+		UsernameFile: CollectorSecretPath(namespace, c.UsernameSecret),
+	}, nil
 }
 
 // TLS specifies TLS configuration parameters from Kubernetes resources.
 type TLS struct {
-	// Used to verify the hostname for the targets.
+	// Used to verify the hostname for the targets. Pods are scraped by their IP, so this
+	// must be set to a name in the target certificates unless they contain the pod IP,
+	// e.g. to scrape `https://10.0.0.5` while verifying the certificate against
+	// `metrics.internal`. It is also sent as the server name indication (SNI).
 	ServerName string `json:"serverName,omitempty"`
-	// Disable target certificate validation.
+	// Disable target certificate validation. The serverName is then not verified against
+	// the certificate but still sent as SNI, e.g. to select a certificate on the target.
 	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
 	// Minimum TLS version. Accepted values: TLS10 (TLS 1.0), TLS11 (TLS 1.1), TLS12 (TLS 1.2), TLS13 (TLS 1.3).
 	// If unset, Prometheus will use Go default minimum version, which is TLS 1.2.
 	// See MinVersion in https://pkg.go.dev/crypto/tls#Config.
 	MinVersion string `json:"minVersion,omitempty"`
 	// Maximum TLS version. Accepted values: TLS10 (TLS 1.0), TLS11 (TLS 1.1), TLS12 (TLS 1.2), TLS13 (TLS 1.3).
-	// If unset, Prometheus will use Go default minimum version, which is TLS 1.2.
-	// See MinVersion in https://pkg.go.dev/crypto/tls#Config.
+	// Must not be lower than the minimum version.
+	// If unset, Prometheus will use Go default maximum version, which is TLS 1.3.
+	// See MaxVersion in https://pkg.go.dev/crypto/tls#Config.
 	MaxVersion string `json:"maxVersion,omitempty"`
+	// Istio configures the scrape to authenticate with the workload certificates of an
+	// Istio sidecar, which is required to scrape pods in a mesh with strict mTLS.
+	Istio *IstioTLS `json:"istio,omitempty"`
+}
+
+// DefaultIstioCertsDir is the default directory from which the collector reads the
+// certificates of its Istio sidecar.
+const DefaultIstioCertsDir = "/etc/istio-certs"
+
+// IstioTLS configures scrapes to use the certificates of the collector's Istio sidecar.
+//
+// The collector pods must run with an Istio sidecar that writes its certificates to a
+// volume shared with the prometheus container, e.g. by setting `OUTPUT_CERTS` through the
+// `proxy.istio.io/config` annotation and mounting the volume through the
+// `sidecar.istio.io/userVolumeMount` annotation. Scrape traffic must bypass the sidecar,
+// e.g. with the `traffic.sidecar.istio.io/includeOutboundIPRanges: ""` annotation.
+//
+// Istio certificates identify workloads by SPIFFE URIs rather than host names, which
+// Prometheus cannot verify. Target certificate verification is hence disabled while the
+// collector still authenticates to the target's sidecar with its client certificate.
+// The serverName, if set, is still sent as SNI.
+type IstioTLS struct {
+	// Absolute path of the directory containing the root-cert.pem, cert-chain.pem, and
+	// key.pem files written by the sidecar. Defaults to "/etc/istio-certs".
+	CertsDir string `json:"certsDir,omitempty"`
 }
 
 func TLSVersionFromString(s string) (config.TLSVersion, error) {
@@ -87,17 +192,34 @@ func (c *TLS) ToPrometheusConfig() (*config.TLSConfig, error) {
 	}
 	maxVersion, err := TLSVersionFromString(c.MaxVersion)
 	if err != nil {
-		errs = append(errs, fmt.Errorf("unable to convert TLS min version: %w", err))
+		errs = append(errs, fmt.Errorf("unable to convert TLS max version: %w", err))
+	}
+	if minVersion != 0 && maxVersion != 0 && minVersion > maxVersion {
+		errs = append(errs, fmt.Errorf("TLS min version %s must not be greater than max version %s", c.MinVersion, c.MaxVersion))
+	}
+	if c.Istio != nil && c.Istio.CertsDir != "" && !path.IsAbs(c.Istio.CertsDir) {
+		errs = append(errs, fmt.Errorf("istio certsDir must be an absolute path, got %q", c.Istio.CertsDir))
 	}
 	if err := errors.Join(errs...); err != nil {
 		return nil, err
 	}
-	return &config.TLSConfig{
+	tlsConfig := &config.TLSConfig{
 		InsecureSkipVerify: c.InsecureSkipVerify,
 		ServerName:         c.ServerName,
 		MinVersion:         minVersion,
 		MaxVersion:         maxVersion,
-	}, nil
+	}
+	if c.Istio != nil {
+		dir := c.Istio.CertsDir
+		if dir == "" {
+			dir = DefaultIstioCertsDir
+		}
+		tlsConfig.CAFile = path.Join(dir, "root-cert.pem")
+		tlsConfig.CertFile = path.Join(dir, "cert-chain.pem")
+		tlsConfig.KeyFile = path.Join(dir, "key.pem")
+		tlsConfig.InsecureSkipVerify = true
+	}
+	return tlsConfig, nil
 }
 
 // OAuth2 is the OAuth2 client configuration.
@@ -110,7 +232,8 @@ type OAuth2 struct {
 	Scopes []string `json:"scopes,omitempty"`
 	// The URL to fetch the token from.
 	TokenURL string `json:"tokenURL"`
-	// Optional parameters to append to the token URL.
+	// Optional form parameters to send with the token request, e.g. an audience
+	// required by the token endpoint.
 	EndpointParams map[string]string `json:"endpointParams,omitempty"`
 	// Configures the token request's TLS settings.
 	TLS         *TLS `json:"tlsConfig,omitempty"`
@@ -132,12 +255,12 @@ func (c *OAuth2) ToPrometheusConfig() (*config.OAuth2, error) {
 		}
 		oauth2.TLSConfig = *tlsConfig
 	}
-	if c.ProxyConfig.ProxyURL != "" {
+	if c.ProxyConfig.isSet() {
 		proxyConfig, err := c.ProxyConfig.ToPrometheusConfig()
 		if err != nil {
 			return nil, fmt.Errorf("OAuth2 proxy config: %w", err)
 		}
-		oauth2.ProxyURL = proxyConfig
+		oauth2.ProxyConfig = proxyConfig
 	}
 	return oauth2, nil
 }
@@ -145,22 +268,35 @@ func (c *OAuth2) ToPrometheusConfig() (*config.OAuth2, error) {
 type ProxyConfig struct {
 	// HTTP proxy server to use to connect to the targets. Encoded passwords are not supported.
 	ProxyURL string `json:"proxyUrl,omitempty"`
+	// Comma-separated list of IP addresses, CIDR ranges, and domain names that are
+	// connected to directly rather than through the proxy. Requires proxyUrl to be set.
+	NoProxy string `json:"noProxy,omitempty"`
 	// TODO(TheSpiritXIII): https://prometheus.io/docs/prometheus/latest/configuration/configuration/#oauth2
 }
 
-func (c *ProxyConfig) ToPrometheusConfig() (config.URL, error) {
+func (c *ProxyConfig) isSet() bool {
+	return c.ProxyURL != "" || c.NoProxy != ""
+}
+
+func (c *ProxyConfig) ToPrometheusConfig() (config.ProxyConfig, error) {
+	if c.ProxyURL == "" {
+		return config.ProxyConfig{}, errors.New("noProxy requires proxyUrl to be set")
+	}
 	proxyURL, err := url.Parse(c.ProxyURL)
 	if err != nil {
-		return config.URL{}, fmt.Errorf("invalid proxy URL: %w", err)
+		return config.ProxyConfig{}, fmt.Errorf("invalid proxy URL: %w", err)
 	}
 	// Marshalling the config will redact the password, so we don't support those.
 	// It's not a good idea anyway and we will later support basic auth based on secrets to
 	// cover the general use case.
 	if _, ok := proxyURL.User.Password(); ok {
-		return config.URL{}, errors.New("passwords encoded in URLs are not supported")
+		return config.ProxyConfig{}, errors.New("passwords encoded in URLs are not supported")
 	}
 	// Initialize from default as encode/decode does not work correctly with the type definition.
-	return config.URL{URL: proxyURL}, nil
+	return config.ProxyConfig{
+		ProxyURL: config.URL{URL: proxyURL},
+		NoProxy:  c.NoProxy,
+	}, nil
 }
 
 // HTTPClientConfig stores HTTP-client configurations.
@@ -175,17 +311,33 @@ type HTTPClientConfig struct {
 	OAuth2 *OAuth2 `json:"oauth2,omitempty"`
 	// Proxy configuration.
 	ProxyConfig `json:",inline"`
+	// Whether scrape requests follow HTTP 3xx redirects. Disable it to fail scrapes of
+	// targets that redirect, e.g. to a login page, instead of scraping the redirect target.
+	// Defaults to true.
+	FollowRedirects *bool `json:"followRedirects,omitempty"`
 }
 
-func (c *HTTPClientConfig) ToPrometheusConfig() (config.HTTPClientConfig, error) {
+// ToPrometheusConfig converts the HTTP client settings. Secrets are resolved relative to the
+// given namespace, which is empty for cluster-scoped resources.
+func (c *HTTPClientConfig) ToPrometheusConfig(namespace string) (config.HTTPClientConfig, error) {
 	var errs []error
 	// Copy default config.
 	clientConfig := config.DefaultHTTPClientConfig
 	if c.Authorization != nil {
-		clientConfig.Authorization = c.Authorization.ToPrometheusConfig()
+		auth, err := c.Authorization.ToPrometheusConfig()
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			clientConfig.Authorization = auth
+		}
 	}
 	if c.BasicAuth != nil {
-		clientConfig.BasicAuth = c.BasicAuth.ToPrometheusConfig()
+		basicAuth, err := c.BasicAuth.ToPrometheusConfig(namespace)
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			clientConfig.BasicAuth = basicAuth
+		}
 	}
 	if c.TLS != nil {
 		tlsConfig, err := c.TLS.ToPrometheusConfig()
@@ -203,13 +355,16 @@ func (c *HTTPClientConfig) ToPrometheusConfig() (config.HTTPClientConfig, error)
 			clientConfig.OAuth2 = oauth2
 		}
 	}
-	if c.ProxyConfig.ProxyURL != "" {
+	if c.ProxyConfig.isSet() {
 		proxyConfig, err := c.ProxyConfig.ToPrometheusConfig()
 		if err != nil {
 			errs = append(errs, err)
 		} else {
-			clientConfig.ProxyURL = proxyConfig
+			clientConfig.ProxyConfig = proxyConfig
 		}
 	}
+	if c.FollowRedirects != nil {
+		clientConfig.FollowRedirects = *c.FollowRedirects
+	}
 	return clientConfig, errors.Join(errs...)
 }
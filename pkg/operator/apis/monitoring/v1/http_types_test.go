@@ -0,0 +1,495 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	yaml "gopkg.in/yaml.v2"
+	"k8s.io/utils/ptr"
+)
+
+func TestHTTPClientConfig_IstioTLS(t *testing.T) {
+	cases := []struct {
+		desc        string
+		tls         *TLS
+		want        string
+		errContains string
+	}{
+		{
+			desc: "default certs directory",
+			tls: &TLS{
+				ServerName: "example.ns1.svc",
+				Istio:      &IstioTLS{},
+			},
+			want: `tls_config:
+  ca_file: /etc/istio-certs/root-cert.pem
+  cert_file: /etc/istio-certs/cert-chain.pem
+  key_file: /etc/istio-certs/key.pem
+  server_name: example.ns1.svc
+  insecure_skip_verify: true
+follow_redirects: true
+enable_http2: true
+`,
+		},
+		{
+			desc: "custom certs directory",
+			tls: &TLS{
+				MinVersion: "TLS12",
+				Istio: &IstioTLS{
+					CertsDir: "/etc/prom-certs/",
+				},
+			},
+			want: `tls_config:
+  ca_file: /etc/prom-certs/root-cert.pem
+  cert_file: /etc/prom-certs/cert-chain.pem
+  key_file: /etc/prom-certs/key.pem
+  insecure_skip_verify: true
+  min_version: TLS12
+follow_redirects: true
+enable_http2: true
+`,
+		},
+		{
+			desc: "relative certs directory",
+			tls: &TLS{
+				Istio: &IstioTLS{
+					CertsDir: "istio-certs",
+				},
+			},
+			errContains: `istio certsDir must be an absolute path, got "istio-certs"`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			httpCfg := HTTPClientConfig{TLS: c.tls}
+			cfg, err := httpCfg.ToPrometheusConfig("ns1")
+			if c.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), c.errContains) {
+					t.Fatalf("expected error containing %q, got %v", c.errContains, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := yaml.Marshal(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(c.want, string(b)); diff != "" {
+				t.Errorf("unexpected HTTP client config YAML (-want, +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestHTTPClientConfig_TLSServerName(t *testing.T) {
+	cases := []struct {
+		desc string
+		tls  *TLS
+		want string
+	}{
+		{
+			desc: "server name",
+			tls: &TLS{
+				ServerName: "metrics.internal",
+			},
+			want: `tls_config:
+  server_name: metrics.internal
+  insecure_skip_verify: false
+follow_redirects: true
+enable_http2: true
+`,
+		},
+		{
+			desc: "server name without verification",
+			tls: &TLS{
+				ServerName:         "metrics.internal",
+				InsecureSkipVerify: true,
+			},
+			want: `tls_config:
+  server_name: metrics.internal
+  insecure_skip_verify: true
+follow_redirects: true
+enable_http2: true
+`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			httpCfg := HTTPClientConfig{TLS: c.tls}
+			cfg, err := httpCfg.ToPrometheusConfig("ns1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := yaml.Marshal(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(c.want, string(b)); diff != "" {
+				t.Errorf("unexpected HTTP client config YAML (-want, +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestHTTPClientConfig_TLSVersions(t *testing.T) {
+	cases := []struct {
+		desc        string
+		tls         *TLS
+		want        string
+		errContains string
+	}{
+		{
+			desc: "min and max version",
+			tls: &TLS{
+				MinVersion: "TLS12",
+				MaxVersion: "TLS13",
+			},
+			want: `tls_config:
+  insecure_skip_verify: false
+  min_version: TLS12
+  max_version: TLS13
+follow_redirects: true
+enable_http2: true
+`,
+		},
+		{
+			desc: "equal min and max version",
+			tls: &TLS{
+				MinVersion: "TLS13",
+				MaxVersion: "TLS13",
+			},
+			want: `tls_config:
+  insecure_skip_verify: false
+  min_version: TLS13
+  max_version: TLS13
+follow_redirects: true
+enable_http2: true
+`,
+		},
+		{
+			desc: "min version greater than max version",
+			tls: &TLS{
+				MinVersion: "TLS13",
+				MaxVersion: "TLS12",
+			},
+			errContains: "TLS min version TLS13 must not be greater than max version TLS12",
+		},
+		{
+			desc: "unknown min version",
+			tls: &TLS{
+				MinVersion: "SSL3",
+			},
+			errContains: "unable to convert TLS min version: unknown TLS version: SSL3",
+		},
+		{
+			desc: "unknown max version",
+			tls: &TLS{
+				MaxVersion: "TLS14",
+			},
+			errContains: "unable to convert TLS max version: unknown TLS version: TLS14",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			httpCfg := HTTPClientConfig{TLS: c.tls}
+			cfg, err := httpCfg.ToPrometheusConfig("ns1")
+			if c.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), c.errContains) {
+					t.Fatalf("expected error containing %q, got %v", c.errContains, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := yaml.Marshal(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(c.want, string(b)); diff != "" {
+				t.Errorf("unexpected HTTP client config YAML (-want, +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestHTTPClientConfig_ServiceAccountToken(t *testing.T) {
+	cases := []struct {
+		desc        string
+		auth        *Auth
+		want        string
+		errContains string
+	}{
+		{
+			desc: "default type",
+			auth: &Auth{
+				ServiceAccountToken: &ServiceAccountToken{Audience: "example"},
+			},
+			want: `authorization:
+  credentials_file: /etc/service-account-tokens/token_example
+follow_redirects: true
+enable_http2: true
+`,
+		},
+		{
+			desc: "bearer type with URL audience",
+			auth: &Auth{
+				Type:                "Bearer",
+				ServiceAccountToken: &ServiceAccountToken{Audience: "https://example.com/api"},
+			},
+			want: `authorization:
+  type: Bearer
+  credentials_file: /etc/service-account-tokens/token_https:%2F%2Fexample.com%2Fapi
+follow_redirects: true
+enable_http2: true
+`,
+		},
+		{
+			desc: "basic type",
+			auth: &Auth{
+				Type:                "Basic",
+				ServiceAccountToken: &ServiceAccountToken{Audience: "example"},
+			},
+			errContains: `authorization type must be Bearer for service account tokens, got "Basic"`,
+		},
+		{
+			desc: "empty audience",
+			auth: &Auth{
+				ServiceAccountToken: &ServiceAccountToken{},
+			},
+			errContains: "service account token audience must be set",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			httpCfg := HTTPClientConfig{Authorization: c.auth}
+			cfg, err := httpCfg.ToPrometheusConfig("ns1")
+			if c.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), c.errContains) {
+					t.Fatalf("expected error containing %q, got %v", c.errContains, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := yaml.Marshal(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(c.want, string(b)); diff != "" {
+				t.Errorf("unexpected HTTP client config YAML (-want, +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestHTTPClientConfig_Proxy(t *testing.T) {
+	cases := []struct {
+		desc        string
+		proxy       ProxyConfig
+		oauth2      *OAuth2
+		want        string
+		errContains string
+	}{
+		{
+			desc: "proxy URL",
+			proxy: ProxyConfig{
+				ProxyURL: "http://proxy.example.com:3128",
+			},
+			want: `follow_redirects: true
+enable_http2: true
+proxy_url: http://proxy.example.com:3128
+`,
+		},
+		{
+			desc: "no proxy",
+			proxy: ProxyConfig{
+				ProxyURL: "http://proxy.example.com:3128",
+				NoProxy:  "10.0.0.0/8,.svc.cluster.local",
+			},
+			want: `follow_redirects: true
+enable_http2: true
+proxy_url: http://proxy.example.com:3128
+no_proxy: 10.0.0.0/8,.svc.cluster.local
+`,
+		},
+		{
+			desc: "OAuth2 no proxy",
+			oauth2: &OAuth2{
+				ClientID: "client",
+				TokenURL: "https://auth.example.com/token",
+				ProxyConfig: ProxyConfig{
+					ProxyURL: "http://proxy.example.com:3128",
+					NoProxy:  "localhost",
+				},
+			},
+			want: `oauth2:
+  client_id: client
+  client_secret: null
+  client_secret_file: ""
+  token_url: https://auth.example.com/token
+  proxy_url: http://proxy.example.com:3128
+  no_proxy: localhost
+follow_redirects: true
+enable_http2: true
+`,
+		},
+		{
+			desc: "no proxy without proxy URL",
+			proxy: ProxyConfig{
+				NoProxy: "localhost",
+			},
+			errContains: "noProxy requires proxyUrl to be set",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			httpCfg := HTTPClientConfig{ProxyConfig: c.proxy, OAuth2: c.oauth2}
+			cfg, err := httpCfg.ToPrometheusConfig("ns1")
+			if c.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), c.errContains) {
+					t.Fatalf("expected error containing %q, got %v", c.errContains, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := cfg.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			b, err := yaml.Marshal(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(c.want, string(b)); diff != "" {
+				t.Errorf("unexpected HTTP client config YAML (-want, +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestHTTPClientConfig_FollowRedirects(t *testing.T) {
+	cases := []struct {
+		desc            string
+		followRedirects *bool
+		want            bool
+	}{
+		{desc: "default", want: true},
+		{desc: "enabled", followRedirects: ptr.To(true), want: true},
+		{desc: "disabled", followRedirects: ptr.To(false), want: false},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			httpCfg := HTTPClientConfig{FollowRedirects: c.followRedirects}
+			cfg, err := httpCfg.ToPrometheusConfig("ns1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.FollowRedirects != c.want {
+				t.Errorf("expected follow_redirects %v, got %v", c.want, cfg.FollowRedirects)
+			}
+		})
+	}
+}
+
+func TestHTTPClientConfig_OAuth2EndpointParams(t *testing.T) {
+	httpCfg := HTTPClientConfig{
+		OAuth2: &OAuth2{
+			ClientID: "client",
+			TokenURL: "https://auth.example.com/token",
+			EndpointParams: map[string]string{
+				"resource": "https://api.example.com",
+				"audience": "example",
+				"grant":    "client_credentials",
+			},
+		},
+	}
+	// Parameters are rendered in sorted order so that the generated config does not
+	// change between reconciliations.
+	want := `oauth2:
+  client_id: client
+  client_secret: null
+  client_secret_file: ""
+  token_url: https://auth.example.com/token
+  endpoint_params:
+    audience: example
+    grant: client_credentials
+    resource: https://api.example.com
+follow_redirects: true
+enable_http2: true
+`
+	for i := 0; i < 10; i++ {
+		cfg, err := httpCfg.ToPrometheusConfig("ns1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := yaml.Marshal(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(want, string(b)); diff != "" {
+			t.Fatalf("unexpected HTTP client config YAML (-want, +got): %s", diff)
+		}
+	}
+}
+
+func TestHTTPClientConfig_OAuth2TLS(t *testing.T) {
+	httpCfg := HTTPClientConfig{
+		TLS: &TLS{
+			ServerName: "target.example.com",
+		},
+		OAuth2: &OAuth2{
+			ClientID: "client",
+			TokenURL: "https://auth.example.com/token",
+			TLS: &TLS{
+				ServerName:         "auth.example.com",
+				InsecureSkipVerify: true,
+			},
+		},
+	}
+	// The TLS config of the token request is independent of the one of the scrape.
+	want := `oauth2:
+  client_id: client
+  client_secret: null
+  client_secret_file: ""
+  token_url: https://auth.example.com/token
+  tls_config:
+    server_name: auth.example.com
+    insecure_skip_verify: true
+tls_config:
+  server_name: target.example.com
+  insecure_skip_verify: false
+follow_redirects: true
+enable_http2: true
+`
+	cfg, err := httpCfg.ToPrometheusConfig("ns1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := yaml.Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, string(b)); diff != "" {
+		t.Errorf("unexpected HTTP client config YAML (-want, +got): %s", diff)
+	}
+}
@@ -15,6 +15,8 @@
 package v1
 
 import (
+	"sort"
+
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -35,6 +37,19 @@ const (
 	// ConfigurationCreateSuccess indicates that the config generated from the
 	// monitoring resource was created successfully.
 	ConfigurationCreateSuccess MonitoringConditionType = "ConfigurationCreateSuccess"
+	// ScrapeTargetOverlap indicates that endpoints of the monitoring resource may select the
+	// same targets as another monitoring resource, which are then scraped more than once.
+	ScrapeTargetOverlap MonitoringConditionType = "ScrapeTargetOverlap"
+	// ReconciliationPaused indicates that the operator does not regenerate the scrape
+	// configuration of the monitoring resource and keeps the previously applied one.
+	ReconciliationPaused MonitoringConditionType = "ReconciliationPaused"
+	// MonitoringLimitReached indicates on the OperatorConfig that the maximum total number of
+	// PodMonitorings and ClusterPodMonitorings is reached and creating further ones is rejected.
+	MonitoringLimitReached MonitoringConditionType = "MonitoringLimitReached"
+	// TargetsReady indicates that at least the configured percentage of the active targets
+	// of the monitoring resource is up. It is only reported if a ready threshold is set in
+	// the target status configuration of the OperatorConfig.
+	TargetsReady MonitoringConditionType = "TargetsReady"
 )
 
 // MonitoringCondition describes the condition of a PodMonitoring.
@@ -75,6 +90,11 @@ type MonitoringStatus struct {
 	ObservedGeneration int64 `json:"observedGeneration"`
 	// Represents the latest available observations of a podmonitor's current state.
 	Conditions []MonitoringCondition `json:"conditions,omitempty"`
+	// SHA-256 hash of the scrape configurations last generated for the resource. It changes
+	// whenever the generated scrape configurations do, e.g. after a spec change was
+	// processed. Only set for PodMonitorings, ClusterPodMonitorings, and
+	// ClusterNodeMonitorings if the operator runs with --report-config-hash.
+	GeneratedConfigHash string `json:"generatedConfigHash,omitempty"`
 }
 
 // SetMonitoringCondition merges the provided condition if the resource generation changed or there is
@@ -92,10 +112,12 @@ func (status *MonitoringStatus) SetMonitoringCondition(gen int64, now metav1.Tim
 
 	// Set up defaults.
 	for _, mc := range NewDefaultConditions(now) {
+		mc := mc
 		conds[mc.Type] = &mc
 	}
 	// Overwrite with any previous state.
 	for _, mc := range status.Conditions {
+		mc := mc
 		conds[mc.Type] = &mc
 	}
 
@@ -103,7 +125,7 @@ func (status *MonitoringStatus) SetMonitoringCondition(gen int64, now metav1.Tim
 	cond.LastUpdateTime = now
 
 	// Check if the condition results in a transition of status state.
-	if old := conds[cond.Type]; old.Status == cond.Status {
+	if old, ok := conds[cond.Type]; ok && old.Status == cond.Status {
 		cond.LastTransitionTime = old.LastTransitionTime
 	} else {
 		cond.LastTransitionTime = cond.LastUpdateTime
@@ -122,6 +144,10 @@ func (status *MonitoringStatus) SetMonitoringCondition(gen int64, now metav1.Tim
 		for _, c := range conds {
 			status.Conditions = append(status.Conditions, *c)
 		}
+		// Sort to keep the status stable across updates.
+		sort.Slice(status.Conditions, func(i, j int) bool {
+			return status.Conditions[i].Type < status.Conditions[j].Type
+		})
 	}
 
 	return update, nil
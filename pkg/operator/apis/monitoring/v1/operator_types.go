@@ -15,7 +15,11 @@
 package v1
 
 import (
+	"fmt"
+
+	"github.com/prometheus/prometheus/model/relabel"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 )
@@ -24,6 +28,7 @@ import (
 // +genclient
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 // +kubebuilder:storageversion
+// +kubebuilder:subresource:status
 type OperatorConfig struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
@@ -36,6 +41,36 @@ type OperatorConfig struct {
 	ManagedAlertmanager *ManagedAlertmanagerSpec `json:"managedAlertmanager,omitempty"`
 	// Features holds configuration for optional managed-collection features.
 	Features OperatorFeatures `json:"features,omitempty"`
+	// Most recently observed status of the operator.
+	Status OperatorConfigStatus `json:"status,omitempty"`
+}
+
+// OperatorConfigStatus holds status information of the operator.
+type OperatorConfigStatus struct {
+	// Represents the latest available observations of the operator's state.
+	Conditions []MonitoringCondition `json:"conditions,omitempty"`
+}
+
+// SetCondition sets the condition of the same type and returns whether the status changed.
+// Conditions are only updated if their status, reason, or message changed.
+func (status *OperatorConfigStatus) SetCondition(now metav1.Time, cond MonitoringCondition) bool {
+	cond.LastUpdateTime = now
+	cond.LastTransitionTime = now
+	for i, old := range status.Conditions {
+		if old.Type != cond.Type {
+			continue
+		}
+		if old.Status == cond.Status && old.Reason == cond.Reason && old.Message == cond.Message {
+			return false
+		}
+		if old.Status == cond.Status {
+			cond.LastTransitionTime = old.LastTransitionTime
+		}
+		status.Conditions[i] = cond
+		return true
+	}
+	status.Conditions = append(status.Conditions, cond)
+	return true
 }
 
 // OperatorConfigList is a list of OperatorConfigs.
@@ -68,6 +103,22 @@ type RuleEvaluatorSpec struct {
 	// Within GKE, this can typically be left empty if the compute default
 	// service account has the required permissions.
 	Credentials *corev1.SecretKeySelector `json:"credentials,omitempty"`
+	// QueryLog enables logging of all queries issued by the rule-evaluator to a file.
+	QueryLog *QueryLogSpec `json:"queryLog,omitempty"`
+}
+
+// QueryLogSpec configures the query log of the rule-evaluator. Each query is written as a
+// JSON line with its evaluation timestamp, duration, and error, if any.
+// The file is written to the filesystem of the evaluator container. It does not survive
+// container restarts and counts toward the ephemeral storage usage of the pod, which may
+// lead to eviction on nodes with little disk space. Once the file exceeds maxSize it is
+// rotated into a single backup file with a ".1" suffix, so the query log may use up to
+// twice maxSize of disk space.
+type QueryLogSpec struct {
+	// Absolute path of the query log file in the evaluator container, e.g. "/tmp/query.log".
+	Path string `json:"path"`
+	// Size at which the query log file is rotated. Defaults to 10Mi.
+	MaxSize *resource.Quantity `json:"maxSize,omitempty"`
 }
 
 // CollectionSpec specifies how the operator configures collection of metric data.
@@ -78,6 +129,11 @@ type CollectionSpec struct {
 	ExternalLabels map[string]string `json:"externalLabels,omitempty"`
 	// Filter limits which metric data is sent to Cloud Monitoring.
 	Filter ExportFilters `json:"filter,omitempty"`
+	// Relabeling rules applied to all scraped series before they are exported, e.g. to
+	// drop or rewrite series across all scrape configurations. They are applied after the
+	// metric relabeling rules of the individual endpoints. The same restrictions as for
+	// endpoint metric relabeling apply.
+	ExportRelabeling []RelabelingRule `json:"exportRelabeling,omitempty"`
 	// A reference to GCP service account credentials with which Prometheus collectors
 	// are run. It needs to have metric write permissions for all project IDs to which
 	// data is written.
@@ -86,8 +142,87 @@ type CollectionSpec struct {
 	Credentials *corev1.SecretKeySelector `json:"credentials,omitempty"`
 	// Configuration to scrape the metric endpoints of the Kubelets.
 	KubeletScraping *KubeletScraping `json:"kubeletScraping,omitempty"`
-	// Compression enables compression of metrics collection data
+	// Compression of the data exported to Cloud Monitoring. Collectors export via gRPC
+	// rather than Prometheus remote-write, so only gzip is supported besides none.
+	// Gzip reduces network egress at the cost of additional collector CPU usage.
 	Compression CompressionType `json:"compression,omitempty"`
+	// Configuration to scrape the metric endpoints of the managed collectors themselves.
+	SelfMonitoring *SelfMonitoring `json:"selfMonitoring,omitempty"`
+	// PriorityClassName overrides the priority class of the collector pods. If unset,
+	// the collector pods use the gmp-critical priority class.
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+	// PodDisruptionBudget configures a PodDisruptionBudget for the collector pods.
+	// No PodDisruptionBudget is managed if unset.
+	PodDisruptionBudget *CollectorPodDisruptionBudget `json:"podDisruptionBudget,omitempty"`
+	// ExportQueue tunes the queueing and batching of samples exported to Cloud Monitoring.
+	// Defaults of the collector are used for unset fields.
+	ExportQueue *ExportQueueConfig `json:"exportQueue,omitempty"`
+	// CollectorLabel attaches the name of the collector pod that scraped a target to
+	// all of the target's series. Disabled if unset.
+	CollectorLabel *CollectorLabel `json:"collectorLabel,omitempty"`
+	// OutOfOrderTimeWindow is how far back in time the collectors accept samples that are
+	// older than the latest sample of their series, e.g. "5m". Samples outside of the window,
+	// such as after a target's clock was reset, are rejected and counted by the collectors.
+	// Out-of-order samples are rejected if unset or zero.
+	// A larger window tolerates more clock skew but increases the memory used by collectors
+	// to buffer out-of-order samples. Accepted out-of-order samples may still be rejected
+	// by Cloud Monitoring if a newer sample of the series has already been exported. The
+	// window must not exceed the collectors' local retention of 30 minutes.
+	OutOfOrderTimeWindow string `json:"outOfOrderTimeWindow,omitempty"`
+	// BaseScrapeConfig is a Prometheus scrape config fragment in YAML that provides
+	// defaults for all generated scrape configs, e.g. `sample_limit` or
+	// `metric_relabel_configs`. It is a Go template that is rendered for every scrape
+	// config with its job name available as `{{ .JobName }}`.
+	// Fields set by a generated scrape config take precedence over the base, even if they
+	// are set to their default value such as `honor_timestamps: false`, and nested fields
+	// are merged individually. The base's metric relabeling rules are applied
+	// before those of the generated scrape config and the export relabeling rules, and
+	// must not modify protected labels. The fragment must not set the job name, target
+	// relabeling, or target discovery.
+	BaseScrapeConfig string `json:"baseScrapeConfig,omitempty"`
+}
+
+// ExportRelabelConfigs converts the export relabeling rules into Prometheus relabeling
+// configurations.
+func (c *CollectionSpec) ExportRelabelConfigs() ([]*relabel.Config, error) {
+	var relabelCfgs []*relabel.Config
+	for i, r := range c.ExportRelabeling {
+		rcfg, err := convertRelabelingRule(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid export relabeling rule %d: %w", i, err)
+		}
+		relabelCfgs = append(relabelCfgs, rcfg)
+	}
+	return relabelCfgs, nil
+}
+
+// CollectorPodDisruptionBudget configures the PodDisruptionBudget of the collector pods.
+// Exactly one of MinAvailable and MaxUnavailable must be set.
+type CollectorPodDisruptionBudget struct {
+	// The number or percentage of collector pods that must remain available during
+	// voluntary disruptions.
+	MinAvailable *intstr.IntOrString `json:"minAvailable,omitempty"`
+	// The number or percentage of collector pods that may be unavailable during
+	// voluntary disruptions.
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+}
+
+// ExportQueueConfig configures how collectors queue and batch samples before sending them
+// to Cloud Monitoring. It is the equivalent of the Prometheus remote-write queue_config.
+type ExportQueueConfig struct {
+	// Number of samples to buffer per shard before collection blocks.
+	// +kubebuilder:validation:Minimum=1
+	Capacity uint `json:"capacity,omitempty"`
+	// Maximum number of samples per request sent to Cloud Monitoring. Must not exceed 200
+	// and must not be greater than the capacity.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=200
+	MaxSamplesPerSend uint `json:"maxSamplesPerSend,omitempty"`
+	// Number of shards across which series are distributed for sending.
+	// +kubebuilder:validation:Minimum=1
+	MaxShards uint `json:"maxShards,omitempty"`
+	// Maximum time a sample waits in a batch before the batch is sent, e.g. "100ms".
+	BatchSendDeadline string `json:"batchSendDeadline,omitempty"`
 }
 
 // OperatorFeatures holds configuration for optional managed-collection features.
@@ -110,6 +245,19 @@ type ConfigSpec struct {
 type TargetStatusSpec struct {
 	// Enable target status reporting.
 	Enabled bool `json:"enabled,omitempty"`
+	// DegradedOnTimeout reports targets whose last scrape timed out with the `degraded`
+	// health and counts them as degraded rather than unhealthy targets, e.g. for targets
+	// that are consistently slow but still reachable. Timed out scrapes still produce no
+	// samples.
+	DegradedOnTimeout bool `json:"degradedOnTimeout,omitempty"`
+	// ReadyThreshold is the percentage of active targets of a PodMonitoring or
+	// ClusterPodMonitoring that must be up for its TargetsReady condition to be true, e.g.
+	// 90 for large fleets in which a few targets are down at any time. If unset, the
+	// TargetsReady condition is not reported.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	ReadyThreshold *int32 `json:"readyThreshold,omitempty"`
 }
 
 // +kubebuilder:validation:Enum=none;gzip
@@ -127,6 +275,24 @@ type KubeletScraping struct {
 	TLSInsecureSkipVerify bool `json:"tlsInsecureSkipVerify,omitempty"`
 }
 
+// SelfMonitoring allows enabling scraping of the managed collectors' own metrics.
+type SelfMonitoring struct {
+	// The interval at which the metric endpoints are scraped.
+	Interval string `json:"interval"`
+	// ExternalLabels specifies labels that are attached to the collectors' own metrics,
+	// e.g. to identify the cluster or project across multiple clusters. They must not
+	// override labels already set by the managed collection.
+	ExternalLabels map[string]string `json:"externalLabels,omitempty"`
+}
+
+// CollectorLabel configures a target label recording the collector that scraped the target.
+// Collector pods are recreated on every rollout of the collectors, so the label causes
+// series churn across rollouts.
+type CollectorLabel struct {
+	// Name of the label. Defaults to "collector".
+	Name string `json:"name,omitempty"`
+}
+
 // ExportFilters provides mechanisms to filter the scraped data that's sent to GMP.
 type ExportFilters struct {
 	// A list of Prometheus time series matchers. Every time series must match at least one
@@ -221,3 +387,19 @@ type SecretOrConfigMap struct {
 	// ConfigMap containing data to use for the targets.
 	ConfigMap *corev1.ConfigMapKeySelector `json:"configMap,omitempty"`
 }
+
+// FileName returns the name of the file in which the data of the Secret or ConfigMap in the
+// given namespace is provided to the collectors and rule evaluators. It includes the kind,
+// namespace, and name to avoid collisions of like keys across resources.
+func (s *SecretOrConfigMap) FileName(namespace string) string {
+	if s == nil {
+		return ""
+	}
+	if s.ConfigMap != nil {
+		return fmt.Sprintf("%s_%s_%s_%s", "configmap", namespace, s.ConfigMap.Name, s.ConfigMap.Key)
+	}
+	if s.Secret != nil {
+		return fmt.Sprintf("%s_%s_%s_%s", "secret", namespace, s.Secret.Name, s.Secret.Key)
+	}
+	return ""
+}
@@ -17,6 +17,9 @@ package v1
 import (
 	"errors"
 	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/GoogleCloudPlatform/prometheus-engine/pkg/export"
@@ -27,8 +30,11 @@ import (
 	discoverykube "github.com/prometheus/prometheus/discovery/kubernetes"
 	"github.com/prometheus/prometheus/model/relabel"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
@@ -141,8 +147,10 @@ func (c *ClusterPodMonitoring) ValidateCreate() (admission.Warnings, error) {
 	}
 	// TODO(freinartz): extract validator into dedicated object (like defaulter). For now using
 	// example values has no adverse effects.
-	_, err := c.ScrapeConfigs("test_project", "test_location", "test_cluster")
-	return nil, err
+	if _, err := c.ScrapeConfigs("test_project", "test_location", "test_cluster"); err != nil {
+		return nil, err
+	}
+	return targetLabelCollisionWarnings(c.metadataLabels(), true), nil
 }
 
 func (c *ClusterPodMonitoring) ValidateUpdate(runtime.Object) (admission.Warnings, error) {
@@ -155,13 +163,40 @@ func (*ClusterPodMonitoring) ValidateDelete() (admission.Warnings, error) {
 	return nil, nil
 }
 
-func (c *ClusterPodMonitoring) ScrapeConfigs(projectID, location, cluster string) (res []*promconfig.ScrapeConfig, err error) {
+// ScrapeConfigs generates Prometheus scrape configs for the ClusterPodMonitoring. If it
+// has a namespace selector, no pods are scraped. Use NamespacedScrapeConfigs instead.
+func (c *ClusterPodMonitoring) ScrapeConfigs(projectID, location, cluster string) ([]*promconfig.ScrapeConfig, error) {
+	return c.NamespacedScrapeConfigs(projectID, location, cluster, nil)
+}
+
+// NamespacedScrapeConfigs generates Prometheus scrape configs for the ClusterPodMonitoring
+// that only scrape pods in the given namespaces, which must be the ones matching its
+// namespace selector. The namespaces are ignored if it has no namespace selector.
+func (c *ClusterPodMonitoring) NamespacedScrapeConfigs(projectID, location, cluster string, namespaces []string) (res []*promconfig.ScrapeConfig, err error) {
+	projectID, err = destinationProjectID(c.Spec.ProjectID, projectID)
+	if err != nil {
+		return nil, err
+	}
+	location, cluster, err = applyLabelOverrides(c.Spec.LabelOverrides, location, cluster)
+	if err != nil {
+		return nil, err
+	}
 	for i := range c.Spec.Endpoints {
-		c, err := c.endpointScrapeConfig(i, projectID, location, cluster)
+		eps, err := c.Spec.Endpoints[i].ExpandPorts()
 		if err != nil {
 			return nil, fmt.Errorf("invalid definition for endpoint with index %d: %w", i, err)
 		}
-		res = append(res, c)
+		for _, ep := range eps {
+			cfg, err := c.endpointScrapeConfig(ep, projectID, location, cluster, namespaces)
+			if err != nil {
+				return nil, fmt.Errorf("invalid definition for endpoint with index %d: %w", i, err)
+			}
+			cfgs, err := probeScrapeConfigs(cfg, ep)
+			if err != nil {
+				return nil, fmt.Errorf("invalid definition for endpoint with index %d: %w", i, err)
+			}
+			res = append(res, cfgs...)
+		}
 	}
 	return res, nil
 }
@@ -172,8 +207,10 @@ func (p *PodMonitoring) ValidateCreate() (admission.Warnings, error) {
 	}
 	// TODO(freinartz): extract validator into dedicated object (like defaulter). For now using
 	// example values has no adverse effects.
-	_, err := p.ScrapeConfigs("test_project", "test_location", "test_cluster")
-	return nil, err
+	if _, err := p.ScrapeConfigs("test_project", "test_location", "test_cluster"); err != nil {
+		return nil, err
+	}
+	return targetLabelCollisionWarnings(p.metadataLabels(), false), nil
 }
 
 func (p *PodMonitoring) ValidateUpdate(runtime.Object) (admission.Warnings, error) {
@@ -188,17 +225,35 @@ func (p *PodMonitoring) ValidateDelete() (admission.Warnings, error) {
 
 // ScrapeConfigs generated Prometheus scrape configs for the PodMonitoring.
 func (p *PodMonitoring) ScrapeConfigs(projectID, location, cluster string) (res []*promconfig.ScrapeConfig, err error) {
+	projectID, err = destinationProjectID(p.Spec.ProjectID, projectID)
+	if err != nil {
+		return nil, err
+	}
+	location, cluster, err = applyLabelOverrides(p.Spec.LabelOverrides, location, cluster)
+	if err != nil {
+		return nil, err
+	}
 	for i := range p.Spec.Endpoints {
-		c, err := p.endpointScrapeConfig(i, projectID, location, cluster)
+		eps, err := p.Spec.Endpoints[i].ExpandPorts()
 		if err != nil {
 			return nil, fmt.Errorf("invalid definition for endpoint with index %d: %w", i, err)
 		}
-		res = append(res, c)
+		for _, ep := range eps {
+			c, err := p.endpointScrapeConfig(ep, projectID, location, cluster)
+			if err != nil {
+				return nil, fmt.Errorf("invalid definition for endpoint with index %d: %w", i, err)
+			}
+			cfgs, err := probeScrapeConfigs(c, ep)
+			if err != nil {
+				return nil, fmt.Errorf("invalid definition for endpoint with index %d: %w", i, err)
+			}
+			res = append(res, cfgs...)
+		}
 	}
 	return res, nil
 }
 
-func (p *PodMonitoring) endpointScrapeConfig(index int, projectID, location, cluster string) (*promconfig.ScrapeConfig, error) {
+func (p *PodMonitoring) endpointScrapeConfig(ep ScrapeEndpoint, projectID, location, cluster string) (*promconfig.ScrapeConfig, error) {
 	relabelCfgs := []*relabel.Config{
 		// Filter targets by namespace of the PodMonitoring configuration.
 		{
@@ -215,18 +270,14 @@ func (p *PodMonitoring) endpointScrapeConfig(index int, projectID, location, clu
 	}
 	relabelCfgs = append(relabelCfgs, selectors...)
 
-	metadataLabels := map[string]struct{}{}
-	// The metadata list must be always set in general but we allow the null case
-	// for backwards compatibility and won't add any labels in that case.
 	if p.Spec.TargetLabels.Metadata != nil {
 		for _, l := range *p.Spec.TargetLabels.Metadata {
-			if allowed := []string{"pod", "container", "node"}; !containsString(allowed, l) {
+			if allowed := []string{"pod", "pod_uid", "container", "node"}; !containsString(allowed, l) {
 				return nil, fmt.Errorf("metadata label %q not allowed, must be one of %v", l, allowed)
 			}
-			metadataLabels[l] = struct{}{}
 		}
 	}
-	relabelCfgs = append(relabelCfgs, relabelingsForMetadata(metadataLabels)...)
+	relabelCfgs = append(relabelCfgs, relabelingsForMetadata(p.metadataLabels())...)
 
 	// The namespace label is always set for PodMonitorings.
 	relabelCfgs = append(relabelCfgs, &relabel.Config{
@@ -239,6 +290,11 @@ func (p *PodMonitoring) endpointScrapeConfig(index int, projectID, location, clu
 		Replacement: p.Name,
 		TargetLabel: "job",
 	})
+	externalLabelCfgs, err := externalLabelRelabelConfigs(p.Spec.ExternalLabels, p.metadataLabels(), p.Spec.TargetLabels.FromPod)
+	if err != nil {
+		return nil, err
+	}
+	relabelCfgs = append(relabelCfgs, externalLabelCfgs...)
 
 	// Drop any non-running pods if left unspecified or explicitly enabled.
 	if p.Spec.FilterRunning == nil || *p.Spec.FilterRunning {
@@ -248,38 +304,105 @@ func (p *PodMonitoring) endpointScrapeConfig(index int, projectID, location, clu
 			Regex:        relabel.MustNewRegexp("(Failed|Succeeded)"),
 		})
 	}
+	if p.Spec.RequireReady {
+		relabelCfgs = append(relabelCfgs, relabelingForReadiness())
+	}
 
 	return endpointScrapeConfig(
 		p.GetKey(),
+		p.Namespace,
 		projectID, location, cluster,
-		p.Spec.Endpoints[index],
+		ep,
 		relabelCfgs,
 		p.Spec.TargetLabels.FromPod,
 		p.Spec.Limits,
+		p.Spec.NodeSelector,
+		p.Spec.FieldSelector,
 	)
 }
 
-func endpointScrapeConfig(id, projectID, location, cluster string, ep ScrapeEndpoint, relabelCfgs []*relabel.Config, podLabels []LabelMapping, limits *ScrapeLimits) (*promconfig.ScrapeConfig, error) {
+// podFieldSelectorKeys are the fields by which the Kubernetes API supports selecting pods.
+// Selectors on other fields are rejected by the API server and fail the discovery.
+var podFieldSelectorKeys = []string{
+	"metadata.name",
+	"metadata.namespace",
+	"spec.hostNetwork",
+	"spec.nodeName",
+	"spec.restartPolicy",
+	"spec.schedulerName",
+	"spec.serviceAccountName",
+	"status.nominatedNodeName",
+	"status.phase",
+	"status.podIP",
+	"status.podIPs",
+}
+
+// podSelectorField returns the field selector with which the collectors discover pods,
+// which only selects pods on their own node and additionally matches the given field
+// selector, if any.
+func podSelectorField(fieldSelector string) (string, error) {
+	field := fmt.Sprintf("spec.nodeName=$(%s)", EnvVarNodeName)
+	if fieldSelector == "" {
+		return field, nil
+	}
+	selector, err := fields.ParseSelector(fieldSelector)
+	if err != nil {
+		return "", fmt.Errorf("invalid field selector: %w", err)
+	}
+	for _, r := range selector.Requirements() {
+		if !containsString(podFieldSelectorKeys, r.Field) {
+			return "", fmt.Errorf("invalid field selector: field %q not supported for pods, must be one of %v", r.Field, podFieldSelectorKeys)
+		}
+	}
+	if selector.Empty() {
+		return field, nil
+	}
+	return field + "," + selector.String(), nil
+}
+
+// instanceLabelSources maps the permitted values of ScrapeEndpoint.InstanceLabelFrom to the
+// meta labels from which the instance label is populated.
+var instanceLabelSources = map[string]prommodel.LabelName{
+	"pod":    "__meta_kubernetes_pod_name",
+	"node":   "__meta_kubernetes_pod_node_name",
+	"pod_ip": "__meta_kubernetes_pod_ip",
+}
+
+func endpointScrapeConfig(id, namespace, projectID, location, cluster string, ep ScrapeEndpoint, relabelCfgs []*relabel.Config, podLabels []LabelMapping, limits *ScrapeLimits, nodeSelector *metav1.LabelSelector, fieldSelector string) (*promconfig.ScrapeConfig, error) {
+	// Drop all potential targets not the same node as the collector. The $(NODE_NAME) variable
+	// is interpolated by the config reloader sidecar before the config reaches the Prometheus collector.
+	// Doing it through selectors rather than relabeling should substantially reduce the client and
+	// server side load.
+	field, err := podSelectorField(fieldSelector)
+	if err != nil {
+		return nil, err
+	}
 	// Configure how Prometheus talks to the Kubernetes API server to discover targets.
-	// This configuration is the same for all scrape jobs (esp. selectors).
+	// This configuration is the same for all scrape jobs (esp. selectors) unless they
+	// select pods by node labels or fields.
 	// This ensures that Prometheus can reuse the underlying client and caches, which reduces
 	// load on the Kubernetes API server.
-	discoveryCfgs := discovery.Configs{
-		&discoverykube.SDConfig{
-			HTTPClientConfig: config.DefaultHTTPClientConfig,
-			Role:             discoverykube.RolePod,
-			// Drop all potential targets not the same node as the collector. The $(NODE_NAME) variable
-			// is interpolated by the config reloader sidecar before the config reaches the Prometheus collector.
-			// Doing it through selectors rather than relabeling should substantially reduce the client and
-			// server side load.
-			Selectors: []discoverykube.SelectorConfig{
-				{
-					Role:  discoverykube.RolePod,
-					Field: fmt.Sprintf("spec.nodeName=$(%s)", EnvVarNodeName),
-				},
+	sdCfg := &discoverykube.SDConfig{
+		HTTPClientConfig: config.DefaultHTTPClientConfig,
+		Role:             discoverykube.RolePod,
+		Selectors: []discoverykube.SelectorConfig{
+			{
+				Role:  discoverykube.RolePod,
+				Field: field,
 			},
 		},
 	}
+	// Filter targets by the labels of their node. Attaching the node metadata makes the
+	// discovery differ from the one of other scrape jobs and additionally watch nodes.
+	if nodeSelector != nil {
+		nodeCfgs, err := relabelingsForNodeSelector(nodeSelector)
+		if err != nil {
+			return nil, err
+		}
+		relabelCfgs = append(relabelCfgs, nodeCfgs...)
+		sdCfg.AttachMetadata.Node = true
+	}
+	discoveryCfgs := discovery.Configs{sdCfg}
 
 	relabelCfgs = append(relabelCfgs,
 		// Force target labels so they cannot be overwritten by metric labels.
@@ -317,6 +440,17 @@ func endpointScrapeConfig(id, projectID, location, cluster string, ep ScrapeEndp
 			Replacement:  "$1",
 		},
 	)
+	if ep.InstanceLabelFrom != "" {
+		source, ok := instanceLabelSources[ep.InstanceLabelFrom]
+		if !ok {
+			return nil, fmt.Errorf("invalid instanceLabelFrom %q, must be one of \"pod\", \"node\", or \"pod_ip\"", ep.InstanceLabelFrom)
+		}
+		relabelCfgs = append(relabelCfgs, &relabel.Config{
+			Action:       relabel.Replace,
+			SourceLabels: prommodel.LabelNames{source},
+			TargetLabel:  "__tmp_instance",
+		})
+	}
 
 	// Filter targets by the configured port.
 	if ep.Port.StrVal != "" {
@@ -340,6 +474,9 @@ func endpointScrapeConfig(id, projectID, location, cluster string, ep ScrapeEndp
 			TargetLabel:  "instance",
 		})
 	} else if ep.Port.IntVal != 0 {
+		if errs := validation.IsValidPortNum(int(ep.Port.IntVal)); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid port number %d: %s", ep.Port.IntVal, strings.Join(errs, ", "))
+		}
 		// Prometheus generates a target candidate for each declared port in a pod.
 		// If a container in a pod has no declared port, a single target candidate is generated for
 		// that container.
@@ -383,15 +520,94 @@ func endpointScrapeConfig(id, projectID, location, cluster string, ep ScrapeEndp
 	}
 	relabelCfgs = append(relabelCfgs, pCfgs...)
 
-	httpCfg, err := ep.HTTPClientConfig.ToPrometheusConfig()
+	jobName := fmt.Sprintf("%s/%s", id, &ep.Port)
+	if ep.MonitoringNameLabel {
+		for _, m := range podLabels {
+			if m.To == monitoringNameLabel || (m.To == "" && m.From == monitoringNameLabel) {
+				return nil, fmt.Errorf("pod label mapping onto %q conflicts with monitoringNameLabel", monitoringNameLabel)
+			}
+		}
+		relabelCfgs = append(relabelCfgs, &relabel.Config{
+			Action:      relabel.Replace,
+			Replacement: jobName,
+			TargetLabel: monitoringNameLabel,
+		})
+	}
+
+	for _, r := range ep.Relabeling {
+		rcfg, err := convertRelabelingRule(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid target relabeling: %w", err)
+		}
+		relabelCfgs = append(relabelCfgs, rcfg)
+	}
+
+	httpCfg, err := ep.HTTPClientConfig.ToPrometheusConfig(namespace)
 	if err != nil {
 		return nil, fmt.Errorf("unable to parse HTTP client config: %w", err)
 	}
+	if ep.APIServerProxy {
+		if ep.Authorization != nil || ep.BasicAuth != nil || ep.OAuth2 != nil || ep.TLS != nil || ep.ProxyConfig.isSet() {
+			return nil, errors.New("authorization, basic auth, OAuth2, TLS, and proxy settings cannot be used with apiServerProxy")
+		}
+		relabelCfgs = append(relabelCfgs, relabelingsForAPIServerProxy(ep)...)
+		followRedirects := httpCfg.FollowRedirects
+		httpCfg = apiServerProxyHTTPClientConfig()
+		httpCfg.FollowRedirects = followRedirects
+		// The scheme of the target itself is encoded in the proxy path.
+		ep.Scheme = "https"
+	}
 
 	if err := httpCfg.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid Prometheus HTTP client config: %w", err)
 	}
-	return buildPrometheusScrapConfig(fmt.Sprintf("%s/%s", id, &ep.Port), discoveryCfgs, httpCfg, relabelCfgs, limits, ep)
+	return buildPrometheusScrapConfig(jobName, discoveryCfgs, httpCfg, relabelCfgs, limits, ep)
+}
+
+// metadataLabels returns the set of metadata labels added to the targets.
+func (p *PodMonitoring) metadataLabels() map[string]struct{} {
+	keys := map[string]struct{}{}
+	// The metadata list must be always set in general but we allow the null case
+	// for backwards compatibility and won't add any labels in that case.
+	if p.Spec.TargetLabels.Metadata != nil {
+		for _, l := range *p.Spec.TargetLabels.Metadata {
+			keys[l] = struct{}{}
+		}
+	}
+	return keys
+}
+
+// metadataLabels returns the set of metadata labels added to the targets.
+func (c *ClusterPodMonitoring) metadataLabels() map[string]struct{} {
+	// The metadata list must be always set in general but we allow the null case
+	// for backwards compatibility. In that case we must always add the namespace label.
+	if c.Spec.TargetLabels.Metadata == nil {
+		return map[string]struct{}{"namespace": {}}
+	}
+	keys := map[string]struct{}{}
+	for _, l := range *c.Spec.TargetLabels.Metadata {
+		keys[l] = struct{}{}
+	}
+	return keys
+}
+
+// targetLabelCollisionWarnings returns warnings about targets of different pods that end up
+// with identical label sets given the metadata labels, which causes their series to collide.
+// Labels mapped from pod labels may still disambiguate them.
+func targetLabelCollisionWarnings(keys map[string]struct{}, clusterScoped bool) admission.Warnings {
+	var warnings admission.Warnings
+	_, hasPod := keys["pod"]
+	_, hasUID := keys["pod_uid"]
+	_, hasNamespace := keys["namespace"]
+
+	// The instance label of DaemonSet pods is based on the node name rather than the pod name.
+	if !hasPod && !hasUID {
+		warnings = append(warnings, `targets of DaemonSet pods on the same node, e.g. during rolling updates, have identical labels unless the "pod" or "pod_uid" metadata label is set`)
+	}
+	if clusterScoped && !hasNamespace && !hasUID {
+		warnings = append(warnings, `targets of pods with the same name in different namespaces have identical labels unless the "namespace" or "pod_uid" metadata label is set`)
+	}
+	return warnings
 }
 
 func relabelingsForMetadata(keys map[string]struct{}) (res []*relabel.Config) {
@@ -409,6 +625,13 @@ func relabelingsForMetadata(keys map[string]struct{}) (res []*relabel.Config) {
 			TargetLabel:  "pod",
 		})
 	}
+	if _, ok := keys["pod_uid"]; ok {
+		res = append(res, &relabel.Config{
+			Action:       relabel.Replace,
+			SourceLabels: prommodel.LabelNames{"__meta_kubernetes_pod_uid"},
+			TargetLabel:  "pod_uid",
+		})
+	}
 	if _, ok := keys["container"]; ok {
 		res = append(res, &relabel.Config{
 			Action:       relabel.Replace,
@@ -426,35 +649,95 @@ func relabelingsForMetadata(keys map[string]struct{}) (res []*relabel.Config) {
 	return res
 }
 
-func (c *ClusterPodMonitoring) endpointScrapeConfig(index int, projectID, location, cluster string) (*promconfig.ScrapeConfig, error) {
+// defaultExcludedNamespaces are the namespaces excluded from ClusterPodMonitorings that
+// don't explicitly configure any.
+var defaultExcludedNamespaces = []string{"kube-system"}
+
+// ExcludedNamespaces returns the namespaces in which pods are never scraped.
+func (c *ClusterPodMonitoring) ExcludedNamespaces() []string {
+	if c.Spec.ExcludeNamespaces != nil {
+		return *c.Spec.ExcludeNamespaces
+	}
+	return defaultExcludedNamespaces
+}
+
+// NamespaceLabelSelector returns the selector for the namespaces in which pods are scraped
+// or nil if pods in all namespaces are scraped.
+func (c *ClusterPodMonitoring) NamespaceLabelSelector() (labels.Selector, error) {
+	if c.Spec.NamespaceSelector == nil {
+		return nil, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(c.Spec.NamespaceSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid namespace selector: %w", err)
+	}
+	if selector.Empty() {
+		return nil, nil
+	}
+	return selector, nil
+}
+
+func (c *ClusterPodMonitoring) endpointScrapeConfig(ep ScrapeEndpoint, projectID, location, cluster string, namespaces []string) (*promconfig.ScrapeConfig, error) {
 	// Filter targets that belong to selected pods.
 	relabelCfgs, err := relabelingsForSelector(c.Spec.Selector, c)
 	if err != nil {
 		return nil, err
 	}
 
-	metadataLabels := map[string]struct{}{}
-	// The metadata list must be always set in general but we allow the null case
-	// for backwards compatibility. In that case we must always add the namespace label.
-	if c.Spec.TargetLabels.Metadata == nil {
-		metadataLabels = map[string]struct{}{
-			"namespace": {},
+	// Drop targets in excluded namespaces.
+	excludeNamespaces := c.ExcludedNamespaces()
+	if len(excludeNamespaces) > 0 {
+		for _, ns := range excludeNamespaces {
+			if errs := validation.IsDNS1123Label(ns); len(errs) > 0 {
+				return nil, fmt.Errorf("invalid excluded namespace %q: %s", ns, strings.Join(errs, ", "))
+			}
 		}
-	} else {
+		relabelCfgs = append(relabelCfgs, &relabel.Config{
+			Action:       relabel.Drop,
+			SourceLabels: prommodel.LabelNames{"__meta_kubernetes_namespace"},
+			Regex:        relabel.MustNewRegexp(strings.Join(excludeNamespaces, "|")),
+		})
+	}
+	// Keep targets in the namespaces matching the namespace selector. If no namespace
+	// matches, the regex only matches the empty namespace, i.e. no target.
+	namespaceSelector, err := c.NamespaceLabelSelector()
+	if err != nil {
+		return nil, err
+	}
+	if namespaceSelector != nil {
+		// Sort to keep the scrape config stable across regenerations.
+		sorted := append([]string(nil), namespaces...)
+		sort.Strings(sorted)
+		quoted := make([]string, 0, len(sorted))
+		for _, ns := range sorted {
+			quoted = append(quoted, regexp.QuoteMeta(ns))
+		}
+		relabelCfgs = append(relabelCfgs, &relabel.Config{
+			Action:       relabel.Keep,
+			SourceLabels: prommodel.LabelNames{"__meta_kubernetes_namespace"},
+			Regex:        relabel.MustNewRegexp(strings.Join(quoted, "|")),
+		})
+	}
+
+	if c.Spec.TargetLabels.Metadata != nil {
 		for _, l := range *c.Spec.TargetLabels.Metadata {
-			if allowed := []string{"namespace", "pod", "container", "node"}; !containsString(allowed, l) {
+			if allowed := []string{"namespace", "pod", "pod_uid", "container", "node"}; !containsString(allowed, l) {
 				return nil, fmt.Errorf("metadata label %q not allowed, must be one of %v", l, allowed)
 			}
-			metadataLabels[l] = struct{}{}
 		}
 	}
-	relabelCfgs = append(relabelCfgs, relabelingsForMetadata(metadataLabels)...)
+	relabelCfgs = append(relabelCfgs, relabelingsForMetadata(c.metadataLabels())...)
 
 	relabelCfgs = append(relabelCfgs, &relabel.Config{
 		Action:      relabel.Replace,
 		Replacement: c.Name,
 		TargetLabel: "job",
 	})
+	externalLabelCfgs, err := externalLabelRelabelConfigs(c.Spec.ExternalLabels, c.metadataLabels(), c.Spec.TargetLabels.FromPod)
+	if err != nil {
+		return nil, err
+	}
+	relabelCfgs = append(relabelCfgs, externalLabelCfgs...)
 
 	// Drop any non-running pods if left unspecified or explicitly enabled.
 	if c.Spec.FilterRunning == nil || *c.Spec.FilterRunning {
@@ -464,17 +747,149 @@ func (c *ClusterPodMonitoring) endpointScrapeConfig(index int, projectID, locati
 			Regex:        relabel.MustNewRegexp("(Failed|Succeeded)"),
 		})
 	}
+	if c.Spec.RequireReady {
+		relabelCfgs = append(relabelCfgs, relabelingForReadiness())
+	}
 
 	return endpointScrapeConfig(
 		c.GetKey(),
+		"",
 		projectID, location, cluster,
-		c.Spec.Endpoints[index],
+		ep,
 		relabelCfgs,
 		c.Spec.TargetLabels.FromPod,
 		c.Spec.Limits,
+		c.Spec.NodeSelector,
+		"",
 	)
 }
 
+// portListSeparator separates the ports of an endpoint that scrapes multiple ports.
+const portListSeparator = ","
+
+// portNameGlobRe matches port name globs in port lists, which consist of the characters
+// allowed in port names and at least one `*` wildcard.
+var portNameGlobRe = regexp.MustCompile(`^[a-z0-9*-]{1,15}$`)
+
+func isPortNameGlob(s string) bool {
+	return strings.Contains(s, "*") && portNameGlobRe.MatchString(s)
+}
+
+// portNameGlobRegex returns the regular expression matching the port names matched by the glob.
+func portNameGlobRegex(glob string) string {
+	parts := strings.Split(glob, "*")
+	for i, p := range parts {
+		parts[i] = regexp.QuoteMeta(p)
+	}
+	return strings.Join(parts, ".*")
+}
+
+// ExpandPorts returns a copy of the endpoint for each port it scrapes. The port may be a
+// comma-separated list of port names, port name globs, and port numbers, in which case
+// each copy scrapes one of them with the otherwise same settings. Globs are converted into
+// the regular expression matching the same port names. A single port name, which is not a
+// list, is matched as a regular expression as before, even if it contains a `*`.
+func (e *ScrapeEndpoint) ExpandPorts() ([]ScrapeEndpoint, error) {
+	if e.Port.Type == intstr.Int || !strings.Contains(e.Port.StrVal, portListSeparator) {
+		return []ScrapeEndpoint{*e}, nil
+	}
+	var res []ScrapeEndpoint
+	seen := map[string]struct{}{}
+	for _, s := range strings.Split(e.Port.StrVal, portListSeparator) {
+		port, err := parsePortListEntry(strings.TrimSpace(s))
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := seen[port.String()]; ok {
+			return nil, fmt.Errorf("duplicate port %q in port list", port.String())
+		}
+		seen[port.String()] = struct{}{}
+
+		ep := *e
+		ep.Port = port
+		res = append(res, ep)
+	}
+	return res, nil
+}
+
+// parsePortListEntry parses a port of a port list, which must be a port number, a port
+// name, or a port name glob.
+func parsePortListEntry(s string) (intstr.IntOrString, error) {
+	if s == "" {
+		return intstr.IntOrString{}, errors.New("empty port in port list")
+	}
+	if n, err := strconv.Atoi(s); err == nil {
+		if errs := validation.IsValidPortNum(n); len(errs) > 0 {
+			return intstr.IntOrString{}, fmt.Errorf("invalid port number %d: %s", n, strings.Join(errs, ", "))
+		}
+		return intstr.FromInt32(int32(n)), nil
+	}
+	if isPortNameGlob(s) {
+		return intstr.FromString(portNameGlobRegex(s)), nil
+	}
+	if errs := validation.IsValidPortName(s); len(errs) > 0 {
+		return intstr.IntOrString{}, fmt.Errorf("invalid port name %q: %s", s, strings.Join(errs, ", "))
+	}
+	return intstr.FromString(s), nil
+}
+
+// relabelingsForAPIServerProxy rewrites the target address and metrics path so that the
+// endpoint is scraped through the `pods/proxy` subresource of the Kubernetes API server.
+func relabelingsForAPIServerProxy(ep ScrapeEndpoint) []*relabel.Config {
+	metricsPath := "/metrics"
+	if ep.Path != "" {
+		metricsPath = ep.Path
+	}
+	// The API server connects to the pod via HTTP unless the pod name is prefixed with the scheme.
+	podPrefix := ""
+	if ep.Scheme == "https" {
+		podPrefix = "https:"
+	}
+	pathCfg := &relabel.Config{
+		Action:       relabel.Replace,
+		SourceLabels: prommodel.LabelNames{"__meta_kubernetes_namespace", "__meta_kubernetes_pod_name", "__meta_kubernetes_pod_container_port_number"},
+		Regex:        relabel.MustNewRegexp("(.+);(.+);(.+)"),
+		Replacement:  fmt.Sprintf("/api/v1/namespaces/$1/pods/%s$2:$3/proxy%s", podPrefix, metricsPath),
+		TargetLabel:  "__metrics_path__",
+	}
+	if ep.Port.IntVal != 0 {
+		pathCfg.SourceLabels = prommodel.LabelNames{"__meta_kubernetes_namespace", "__meta_kubernetes_pod_name"}
+		pathCfg.Regex = relabel.MustNewRegexp("(.+);(.+)")
+		pathCfg.Replacement = fmt.Sprintf("/api/v1/namespaces/$1/pods/%s$2:%d/proxy%s", podPrefix, ep.Port.IntVal, metricsPath)
+	}
+	return []*relabel.Config{
+		pathCfg,
+		{
+			Action:      relabel.Replace,
+			Replacement: "kubernetes.default.svc:443",
+			TargetLabel: "__address__",
+		},
+	}
+}
+
+// apiServerProxyHTTPClientConfig authenticates to the Kubernetes API server with the
+// collector's service account.
+func apiServerProxyHTTPClientConfig() config.HTTPClientConfig {
+	httpCfg := config.DefaultHTTPClientConfig
+	httpCfg.Authorization = &config.Authorization{
+		Type:            "Bearer",
+		CredentialsFile: "/var/run/secrets/kubernetes.io/serviceaccount/token",
+	}
+	httpCfg.TLSConfig = config.TLSConfig{
+		CAFile: "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt",
+	}
+	return httpCfg
+}
+
+// relabelingForReadiness drops targets of pods that are not ready yet.
+func relabelingForReadiness() *relabel.Config {
+	return &relabel.Config{
+		Action:       relabel.Keep,
+		SourceLabels: prommodel.LabelNames{"__meta_kubernetes_pod_ready"},
+		Regex:        relabel.MustNewRegexp("true"),
+	}
+}
+
 // convertRelabelingRule converts the rule to a relabel configuration. An error is returned
 // if the rule would modify one of the protected labels.
 func convertRelabelingRule(r RelabelingRule) (*relabel.Config, error) {
@@ -605,6 +1020,19 @@ type PodMonitoringSpec struct {
 	// Label selector that specifies which pods are selected for this monitoring
 	// configuration.
 	Selector metav1.LabelSelector `json:"selector"`
+	// NodeSelector only scrapes pods that are scheduled on nodes matching the label
+	// selector, e.g. nodes with GPUs. The node labels are attached to the discovered pods
+	// through the `attach_metadata` setting of Prometheus' Kubernetes service discovery,
+	// which requires the collectors to be permitted to watch nodes and uses a separate
+	// discovery from the scrape jobs without a node selector.
+	NodeSelector *metav1.LabelSelector `json:"nodeSelector,omitempty"`
+	// FieldSelector only discovers pods matching the Kubernetes field selector, e.g.
+	// `spec.nodeName=node-1`. It is merged into the selector of Prometheus' Kubernetes
+	// service discovery, which uses a separate discovery from the scrape jobs without a
+	// field selector. Only the fields that Kubernetes supports for selecting pods are
+	// allowed.
+	// See: https://kubernetes.io/docs/concepts/overview/working-with-objects/field-selectors/
+	FieldSelector string `json:"fieldSelector,omitempty"`
 	// The endpoints to scrape on the selected pods.
 	Endpoints []ScrapeEndpoint `json:"endpoints"`
 	// Labels to add to the Prometheus target for discovered endpoints.
@@ -613,10 +1041,34 @@ type PodMonitoringSpec struct {
 	TargetLabels TargetLabels `json:"targetLabels,omitempty"`
 	// Limits to apply at scrape time.
 	Limits *ScrapeLimits `json:"limits,omitempty"`
+	// The Google Cloud project to write the scraped metrics to, set as their project_id
+	// label. Defaults to the project of the cluster. The credentials of the collectors
+	// must be permitted to write metrics to the project.
+	// +kubebuilder:validation:Pattern=`^[a-z][a-z0-9-]{4,28}[a-z0-9]$`
+	ProjectID string `json:"projectID,omitempty"`
+	// Values that override the location and cluster labels, which the operator sets on
+	// all targets, keyed by label name, e.g. for clusters that span multiple logical
+	// locations. Only the `location` and `cluster` labels can be overridden, the project
+	// is set through projectID. The location must be a Google Cloud region or zone.
+	LabelOverrides map[string]string `json:"labelOverrides,omitempty"`
+	// Static labels to add to all targets, keyed by label name, e.g. to tell apart the
+	// series of logical clusters that share a project. They must not set the protected
+	// labels (project_id, location, cluster, namespace, job, instance), which are
+	// changed through projectID and labelOverrides, nor labels set through targetLabels.
+	ExternalLabels map[string]string `json:"externalLabels,omitempty"`
 	// FilterRunning will drop any pods that are in the "Failed" or "Succeeded"
 	// pod lifecycle.
 	// See: https://kubernetes.io/docs/concepts/workloads/pods/pod-lifecycle/#pod-phase
 	FilterRunning *bool `json:"filterRunning,omitempty"`
+	// RequireReady only scrapes pods once they report the Ready condition.
+	// Prometheus does not support delaying the first scrape of a new target, so
+	// use this together with a readiness probe's `initialDelaySeconds` to avoid
+	// scraping freshly started pods.
+	// A pod is only Ready once all of its readiness gates are True, so this also
+	// waits for custom readiness gates. Prometheus' service discovery only exposes
+	// the Ready condition, so scraping cannot depend on an individual condition.
+	// See: https://kubernetes.io/docs/concepts/workloads/pods/pod-lifecycle/#pod-conditions
+	RequireReady bool `json:"requireReady,omitempty"`
 }
 
 // ScrapeLimits limits applied to scraped targets.
@@ -640,6 +1092,12 @@ type ClusterPodMonitoringSpec struct {
 	// Label selector that specifies which pods are selected for this monitoring
 	// configuration.
 	Selector metav1.LabelSelector `json:"selector"`
+	// NodeSelector only scrapes pods that are scheduled on nodes matching the label
+	// selector, e.g. nodes with GPUs. The node labels are attached to the discovered pods
+	// through the `attach_metadata` setting of Prometheus' Kubernetes service discovery,
+	// which requires the collectors to be permitted to watch nodes and uses a separate
+	// discovery from the scrape jobs without a node selector.
+	NodeSelector *metav1.LabelSelector `json:"nodeSelector,omitempty"`
 	// The endpoints to scrape on the selected pods.
 	Endpoints []ScrapeEndpoint `json:"endpoints"`
 	// Labels to add to the Prometheus target for discovered endpoints.
@@ -648,6 +1106,21 @@ type ClusterPodMonitoringSpec struct {
 	TargetLabels TargetLabels `json:"targetLabels,omitempty"`
 	// Limits to apply at scrape time.
 	Limits *ScrapeLimits `json:"limits,omitempty"`
+	// The Google Cloud project to write the scraped metrics to, set as their project_id
+	// label. Defaults to the project of the cluster. The credentials of the collectors
+	// must be permitted to write metrics to the project.
+	// +kubebuilder:validation:Pattern=`^[a-z][a-z0-9-]{4,28}[a-z0-9]$`
+	ProjectID string `json:"projectID,omitempty"`
+	// Values that override the location and cluster labels, which the operator sets on
+	// all targets, keyed by label name, e.g. for clusters that span multiple logical
+	// locations. Only the `location` and `cluster` labels can be overridden, the project
+	// is set through projectID. The location must be a Google Cloud region or zone.
+	LabelOverrides map[string]string `json:"labelOverrides,omitempty"`
+	// Static labels to add to all targets, keyed by label name, e.g. to tell apart the
+	// series of logical clusters that share a project. They must not set the protected
+	// labels (project_id, location, cluster, namespace, job, instance), which are
+	// changed through projectID and labelOverrides, nor labels set through targetLabels.
+	ExternalLabels map[string]string `json:"externalLabels,omitempty"`
 	// FilterRunning will drop any pods that are in the "Failed" or "Succeeded"
 	// pod lifecycle.
 	// See: https://kubernetes.io/docs/concepts/workloads/pods/pod-lifecycle/#pod-phase
@@ -657,13 +1130,46 @@ type ClusterPodMonitoringSpec struct {
 	// labels in cases where Pod IPs are reused (e.g. spot containers).
 	// See: https://github.com/GoogleCloudPlatform/prometheus-engine/issues/145
 	FilterRunning *bool `json:"filterRunning,omitempty"`
+	// RequireReady only scrapes pods once they report the Ready condition.
+	// Prometheus does not support delaying the first scrape of a new target, so
+	// use this together with a readiness probe's `initialDelaySeconds` to avoid
+	// scraping freshly started pods.
+	// A pod is only Ready once all of its readiness gates are True, so this also
+	// waits for custom readiness gates. Prometheus' service discovery only exposes
+	// the Ready condition, so scraping cannot depend on an individual condition.
+	// See: https://kubernetes.io/docs/concepts/workloads/pods/pod-lifecycle/#pod-conditions
+	RequireReady bool `json:"requireReady,omitempty"`
+	// Namespaces in which pods are never scraped, even if they match the selector.
+	// Defaults to `kube-system` if unset. Set to an empty list to scrape pods in
+	// all namespaces.
+	ExcludeNamespaces *[]string `json:"excludeNamespaces,omitempty"`
+	// NamespaceSelector only scrapes pods in namespaces matching the label selector,
+	// e.g. the namespaces of a team. Namespace labels are not available to the collectors'
+	// service discovery, so the operator resolves the selector to the matching namespaces
+	// and updates the scrape configuration when namespaces or their labels change.
+	// Excluded namespaces are never scraped. If unset or empty, pods in all namespaces
+	// are scraped.
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
 }
 
 // ScrapeEndpoint specifies a Prometheus metrics endpoint to scrape.
+//
+// Metric metadata is always scraped and cannot be disabled per endpoint. The metric type
+// determines how samples are written to Cloud Monitoring. Metrics without a type are
+// written as both a gauge and a counter, which increases rather than reduces cost.
 type ScrapeEndpoint struct {
 	// Name or number of the port to scrape.
+	// Multiple ports may be scraped with the otherwise same settings by a comma-separated
+	// list of port names, port numbers, and port name globs, such as `metrics-*,9090`. Each
+	// port is scraped by a separate job and reported as a separate endpoint in the status,
+	// where globs appear as the equivalent regular expression, e.g. `metrics-.*`.
+	// A single port name that is not part of a list is matched as a regular expression.
 	// The container metadata label is only populated if the port is referenced by name
 	// because port numbers are not unique across containers.
+	// Pods with hostNetwork enabled are scraped at their pod IP like other pods, which is
+	// the IP of their node, and their container ports are host ports. Targets of such pods
+	// on the same node hence differ only by port, and the port must be reachable from the
+	// collector pods on the node's address.
 	Port intstr.IntOrString `json:"port"`
 	// Protocol scheme to use to scrape.
 	Scheme string `json:"scheme,omitempty"`
@@ -671,18 +1177,95 @@ type ScrapeEndpoint struct {
 	Path string `json:"path,omitempty"`
 	// HTTP GET params to use when scraping.
 	Params map[string][]string `json:"params,omitempty"`
+	// Targets to probe through the selected pods, e.g. the addresses or URLs to check with
+	// a blackbox exporter. Each selected pod is scraped once per probe target with the
+	// target passed as the `target` param and set as the `instance` label. The `target`
+	// param must then not be set explicitly. Other params, such as the exporter's `module`,
+	// are passed as configured.
+	// Pods are discovered by the collector on their node as usual. An exporter with multiple
+	// replicas probes each target once per replica, which can be told apart by the `pod`
+	// metadata label.
+	ProbeTargets []string `json:"probeTargets,omitempty"`
 	// Interval at which to scrape metrics. Must be a valid Prometheus duration.
+	// Each target is scraped by a single loop, so at most one scrape per target is in
+	// flight. The collector does not support limiting the number of concurrent scrapes
+	// across the targets of an endpoint or globally.
 	// +kubebuilder:validation:Pattern="^((([0-9]+)y)?(([0-9]+)w)?(([0-9]+)d)?(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?|0)$"
 	// +kubebuilder:default="1m"
 	Interval string `json:"interval,omitempty"`
 	// Timeout for metrics scrapes. Must be a valid Prometheus duration.
 	// Must not be larger than the scrape interval.
+	// The timeout covers the entire scrape including connection setup. The collector's
+	// HTTP client does not support a separate dial timeout and caps TLS handshakes at 10s,
+	// so a short timeout is the way to fail fast on unreachable targets.
 	Timeout string `json:"timeout,omitempty"`
+	// Maximum size of the uncompressed response body of a scrape, e.g. `100MB`. Units are
+	// powers of 1024. Scrapes with larger bodies fail with a `body size limit exceeded`
+	// error, which protects the collectors from targets exposing huge responses.
+	// Disabled if unset or 0.
+	BodySizeLimit string `json:"bodySizeLimit,omitempty"`
 	// Relabeling rules for metrics scraped from this endpoint. Relabeling rules that
 	// override protected target labels (project_id, location, cluster, namespace, job,
 	// instance, or __address__) are not permitted. The labelmap action is not permitted
 	// in general.
 	MetricRelabeling []RelabelingRule `json:"metricRelabeling,omitempty"`
+	// Regular expressions of the names of the metrics to keep. Metrics whose names match
+	// none of them are dropped. Like relabeling regexes, they must match the full name.
+	// They are applied to the names as exposed by the target, before the metric relabeling
+	// rules.
+	MetricKeep []string `json:"metricKeep,omitempty"`
+	// Regular expressions of the names of the metrics to drop. They must match the full
+	// name and are applied after metricKeep and before the metric relabeling rules. The
+	// same expression must not be listed in both metricKeep and metricDrop.
+	MetricDrop []string `json:"metricDrop,omitempty"`
+	// Relabeling rules for the targets discovered for this endpoint, e.g. to drop targets
+	// based on their `__meta_kubernetes_*` labels or to set additional target labels. They
+	// are applied after the target labels were set and are subject to the same restrictions
+	// as the metric relabeling rules.
+	Relabeling []RelabelingRule `json:"relabeling,omitempty"`
+	// Prefix to prepend to the names of all metrics scraped from this endpoint.
+	// It is applied after the metric relabeling rules and must be a valid
+	// metric name itself, e.g. `myexporter_`.
+	MetricPrefix string `json:"metricPrefix,omitempty"`
+	// Maximum length of metric names, including the metricPrefix. Metrics with longer names
+	// are dropped after all other metric relabeling rules were applied. They are not truncated
+	// as this could merge distinct metrics into one. Disabled if unset.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=1000
+	MaxMetricNameLength uint `json:"maxMetricNameLength,omitempty"`
+	// OpenTelemetry resource attributes to promote to other labels. This applies to
+	// targets that expose resource attributes as labels on their series, e.g. via the
+	// OpenTelemetry Collector's `resource_to_telemetry_conversion` setting.
+	// Attributes are matched by their sanitized label name, e.g. `service.name` matches
+	// the `service_name` label. Its value is copied to the `to` label, which must be set
+	// and must not be a protected label, and the original label is dropped.
+	// Promotion is applied before the metric relabeling rules.
+	ResourceAttributes []LabelMapping `json:"resourceAttributes,omitempty"`
+	// APIServerProxy scrapes the endpoint through the `pods/proxy` subresource of the
+	// Kubernetes API server instead of connecting to the pod directly. This allows scraping
+	// pods that are not reachable from the collectors over the network.
+	// Requests are authenticated with the collector's service account token and verified
+	// against the cluster CA, so authorization, basic auth, OAuth2, TLS, and proxy settings
+	// cannot be set. The collector's service account must be granted the `get` verb on the
+	// `pods/proxy` resource, e.g. through a ClusterRole bound to `gmp-system/collector`.
+	APIServerProxy bool `json:"apiServerProxy,omitempty"`
+	// MonitoringNameLabel sets the `monitoring_name` label on all series scraped from
+	// this endpoint to the scrape pool of the endpoint, e.g.
+	// `PodMonitoring/my-namespace/my-app/metrics`, to record which monitoring resource
+	// selected the target. The label must then not be set through `targetLabels.fromPod`.
+	// Its value is the same for all targets of an endpoint, so the number of series does
+	// not grow. However, series whose targets are selected by multiple monitorings are
+	// then no longer deduplicated across them, and renaming a monitoring or its port
+	// starts new series in Cloud Monitoring.
+	MonitoringNameLabel bool `json:"monitoringNameLabel,omitempty"`
+	// InstanceLabelFrom selects the pod field from which the `instance` label is populated,
+	// one of `pod` for the pod name, `node` for the node name, or `pod_ip` for the pod IP.
+	// The port is appended as usual, e.g. `my-pod:metrics`.
+	// By default, the pod name is used unless the pod is controlled by a DaemonSet, in
+	// which case the node name is used. It must not be set together with probeTargets,
+	// which set the `instance` label to the probe target.
+	// +kubebuilder:validation:Enum=pod;node;pod_ip
+	InstanceLabelFrom string `json:"instanceLabelFrom,omitempty"`
 	// Prometheus HTTP client configuration.
 	HTTPClientConfig `json:",inline"`
 }
@@ -690,9 +1273,14 @@ type ScrapeEndpoint struct {
 // TargetLabels configures labels for the discovered Prometheus targets.
 type TargetLabels struct {
 	// Pod metadata labels that are set on all scraped targets.
-	// Permitted keys are `pod`, `container`, and `node` for PodMonitoring and
-	// `pod`, `container`, `node`, and `namespace` for ClusterPodMonitoring. The `container`
-	// label is only populated if the scrape port is referenced by name.
+	// Permitted keys are `pod`, `pod_uid`, `container`, and `node` for PodMonitoring and
+	// `pod`, `pod_uid`, `container`, `node`, and `namespace` for ClusterPodMonitoring. The
+	// `container` label is only populated if the scrape port is referenced by name.
+	// The `pod_uid` label uniquely identifies pods across namespaces and pod restarts.
+	// Without the `pod` or `pod_uid` label, targets of DaemonSet pods on the same node are
+	// indistinguishable, as are targets of pods with the same name in different namespaces
+	// for ClusterPodMonitoring without the `namespace` or `pod_uid` label. Such configurations
+	// are accepted with a warning.
 	// Defaults to [pod, container] for PodMonitoring and [namespace, pod, container]
 	// for ClusterPodMonitoring.
 	// If set to null, it will be interpreted as the empty list for PodMonitoring
@@ -743,8 +1331,16 @@ type ScrapeEndpointStatus struct {
 	ActiveTargets int64 `json:"activeTargets,omitempty"`
 	// Total number of active, unhealthy targets.
 	UnhealthyTargets int64 `json:"unhealthyTargets,omitempty"`
+	// Total number of active targets whose last scrape timed out if timeouts are reported
+	// as degraded. They are not included in the unhealthy targets.
+	DegradedTargets int64 `json:"degradedTargets,omitempty"`
 	// Last time this status was updated.
 	LastUpdateTime metav1.Time `json:"lastUpdateTime,omitempty"`
+	// Time of the most recent successful scrape of any target as reported by the
+	// collectors, including targets that are not sampled. Unset if no target was
+	// scraped successfully, which tells never scraped endpoints apart from ones that
+	// were last scraped long ago.
+	LastScrapeTime *metav1.Time `json:"lastScrapeTime,omitempty"`
 	// A fixed sample of targets grouped by error type.
 	SampleGroups []SampleGroup `json:"sampleGroups,omitempty"`
 	// Fraction of collectors included in status, bounded [0,1].
@@ -759,6 +1355,9 @@ type SampleGroup struct {
 	// Total count of similar errors.
 	// +optional
 	Count *int32 `json:"count,omitempty"`
+	// Longest last scrape duration in seconds across all targets of the group, including
+	// the ones not sampled. Helps to spot slow but healthy targets.
+	MaxScrapeDurationSeconds string `json:"maxScrapeDurationSeconds,omitempty"`
 }
 
 type SampleTarget struct {
@@ -766,15 +1365,55 @@ type SampleTarget struct {
 	Labels prommodel.LabelSet `json:"labels,omitempty"`
 	// Error message.
 	LastError *string `json:"lastError,omitempty"`
+	// Classified reason of the last scrape failure. Only set for unhealthy targets.
+	// LastError holds the full error message.
+	FailureReason ScrapeFailureReason `json:"failureReason,omitempty"`
 	// Scrape duration in seconds.
 	LastScrapeDurationSeconds string `json:"lastScrapeDurationSeconds,omitempty"`
-	// Health status.
+	// Time of the last scrape of the target as reported by the collector. Compare it
+	// with the last update time of the endpoint status to detect targets that are no
+	// longer scraped. Timestamps of the scraped samples themselves are not reported.
+	LastScrapeTime *metav1.Time `json:"lastScrapeTime,omitempty"`
+	// Health status. One of `up`, `down`, or `unknown` as reported by Prometheus, or
+	// `degraded` for timed out scrapes if timeouts are reported as degraded.
 	Health string `json:"health,omitempty"`
 }
 
+// TargetHealthDegraded is the health of targets whose last scrape timed out if timeouts
+// are reported as degraded.
+const TargetHealthDegraded = "degraded"
+
+// ScrapeFailureReason is a machine-readable classification of a scrape error.
+// +kubebuilder:validation:Enum=connection-refused;timeout;dns-lookup;tls-handshake;http-401;http-403;http-404;http-error;limit-exceeded;oauth2-token;unknown
+type ScrapeFailureReason string
+
+const (
+	ScrapeFailureConnectionRefused ScrapeFailureReason = "connection-refused"
+	ScrapeFailureTimeout           ScrapeFailureReason = "timeout"
+	// Resolving the host name of the target failed, e.g. because it does not exist or
+	// the DNS server did not respond in time.
+	ScrapeFailureDNSLookup        ScrapeFailureReason = "dns-lookup"
+	ScrapeFailureTLSHandshake     ScrapeFailureReason = "tls-handshake"
+	ScrapeFailureHTTPUnauthorized ScrapeFailureReason = "http-401"
+	ScrapeFailureHTTPForbidden    ScrapeFailureReason = "http-403"
+	ScrapeFailureHTTPNotFound     ScrapeFailureReason = "http-404"
+	// Any other non-2xx HTTP status.
+	ScrapeFailureHTTPError ScrapeFailureReason = "http-error"
+	// A sample, label, or body size limit of the endpoint was exceeded.
+	ScrapeFailureLimitExceeded ScrapeFailureReason = "limit-exceeded"
+	// Fetching a token from the OAuth2 token URL failed, e.g. because it rejected the
+	// client credentials or its certificate could not be verified. The target itself
+	// was not scraped.
+	ScrapeFailureOAuth2Token ScrapeFailureReason = "oauth2-token"
+	ScrapeFailureUnknown     ScrapeFailureReason = "unknown"
+)
+
 // PodMonitoringStatus holds status information of a PodMonitoring resource.
 type PodMonitoringStatus struct {
 	MonitoringStatus `json:",inline"`
 	// Represents the latest available observations of target state for each ScrapeEndpoint.
 	EndpointStatuses []ScrapeEndpointStatus `json:"endpointStatuses,omitempty"`
+	// Fraction of active targets across all ScrapeEndpoints that are up, bounded [0,1].
+	// Only reported if a ready threshold is set in the target status configuration.
+	HealthyTargetsFraction string `json:"healthyTargetsFraction,omitempty"`
 }
@@ -15,6 +15,7 @@
 package v1
 
 import (
+	"fmt"
 	"regexp"
 	"strings"
 	"testing"
@@ -25,20 +26,27 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	prommodel "github.com/prometheus/common/model"
+	promconfig "github.com/prometheus/prometheus/config"
+	discoverykube "github.com/prometheus/prometheus/discovery/kubernetes"
+	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/model/relabel"
 	yaml "gopkg.in/yaml.v2"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
 func TestValidatePodMonitoringCommon(t *testing.T) {
 	cases := []struct {
-		desc        string
-		pm          PodMonitoringSpec
-		eps         []ScrapeEndpoint
-		tls         TargetLabels
-		fail        bool
-		errContains string
+		desc           string
+		pm             PodMonitoringSpec
+		eps            []ScrapeEndpoint
+		tls            TargetLabels
+		nodeSelector   *metav1.LabelSelector
+		externalLabels map[string]string
+		fail           bool
+		errContains    string
 	}{
 		{
 			desc: "OK",
@@ -67,6 +75,46 @@ func TestValidatePodMonitoringCommon(t *testing.T) {
 			},
 			fail:        true,
 			errContains: "port must be set",
+		}, {
+			desc: "port number out of range",
+			eps: []ScrapeEndpoint{
+				{Port: intstr.FromInt(70000), Interval: "10s"},
+			},
+			fail:        true,
+			errContains: "invalid port number 70000",
+		}, {
+			desc: "port list",
+			eps: []ScrapeEndpoint{
+				{Port: intstr.FromString("web, metrics-*,9090"), Interval: "10s"},
+			},
+		}, {
+			desc: "port list with empty port",
+			eps: []ScrapeEndpoint{
+				{Port: intstr.FromString("web,,9090"), Interval: "10s"},
+			},
+			fail:        true,
+			errContains: "empty port in port list",
+		}, {
+			desc: "port list with invalid port name",
+			eps: []ScrapeEndpoint{
+				{Port: intstr.FromString("web,metrics_1"), Interval: "10s"},
+			},
+			fail:        true,
+			errContains: `invalid port name "metrics_1"`,
+		}, {
+			desc: "port list with port number out of range",
+			eps: []ScrapeEndpoint{
+				{Port: intstr.FromString("web,0"), Interval: "10s"},
+			},
+			fail:        true,
+			errContains: "invalid port number 0",
+		}, {
+			desc: "port list with duplicate port",
+			eps: []ScrapeEndpoint{
+				{Port: intstr.FromString("9090,web, 9090"), Interval: "10s"},
+			},
+			fail:        true,
+			errContains: `duplicate port "9090" in port list`,
 		}, {
 			desc: "scrape interval missing",
 			eps: []ScrapeEndpoint{
@@ -247,6 +295,268 @@ func TestValidatePodMonitoringCommon(t *testing.T) {
 				},
 			},
 			fail: false,
+		}, {
+			desc: "target relabeling: keep by metadata",
+			eps: []ScrapeEndpoint{
+				{
+					Port:     intstr.FromString("web"),
+					Interval: "10s",
+					Relabeling: []RelabelingRule{
+						{
+							SourceLabels: []string{"__meta_kubernetes_pod_annotation_example_com_scrape"},
+							Regex:        "true",
+							Action:       "keep",
+						},
+					},
+				},
+			},
+		}, {
+			desc: "instance label from pod IP",
+			eps: []ScrapeEndpoint{
+				{
+					Port:              intstr.FromString("web"),
+					Interval:          "10s",
+					InstanceLabelFrom: "pod_ip",
+				},
+			},
+		}, {
+			desc: "instance label from unknown field",
+			eps: []ScrapeEndpoint{
+				{
+					Port:              intstr.FromString("web"),
+					Interval:          "10s",
+					InstanceLabelFrom: "container",
+				},
+			},
+			fail:        true,
+			errContains: `invalid instanceLabelFrom "container"`,
+		}, {
+			desc: "instance label with probe targets",
+			eps: []ScrapeEndpoint{
+				{
+					Port:              intstr.FromString("web"),
+					Interval:          "10s",
+					InstanceLabelFrom: "pod",
+					ProbeTargets:      []string{"example.com"},
+				},
+			},
+			fail:        true,
+			errContains: "instanceLabelFrom must not be set together with probe targets",
+		}, {
+			desc: "target relabeling: labelmap forbidden",
+			eps: []ScrapeEndpoint{
+				{
+					Port:     intstr.FromString("web"),
+					Interval: "10s",
+					Relabeling: []RelabelingRule{
+						{
+							Regex:  "__meta_kubernetes_pod_annotation_(.+)",
+							Action: "labelmap",
+						},
+					},
+				},
+			},
+			fail:        true,
+			errContains: `invalid target relabeling: relabeling with action "labelmap" not allowed`,
+		}, {
+			desc: "target relabeling: protected replace label",
+			eps: []ScrapeEndpoint{
+				{
+					Port:     intstr.FromString("web"),
+					Interval: "10s",
+					Relabeling: []RelabelingRule{
+						{
+							SourceLabels: []string{"__meta_kubernetes_pod_ip"},
+							TargetLabel:  "__address__",
+						},
+					},
+				},
+			},
+			fail:        true,
+			errContains: `invalid target relabeling: cannot relabel with action "" onto protected label "__address__"`,
+		}, {
+			desc: "target relabeling: unknown action",
+			eps: []ScrapeEndpoint{
+				{
+					Port:     intstr.FromString("web"),
+					Interval: "10s",
+					Relabeling: []RelabelingRule{
+						{
+							Action: "keepequal",
+						},
+					},
+				},
+			},
+			fail:        true,
+			errContains: `invalid target relabeling: unknown relabeling action "keepequal"`,
+		}, {
+			desc: "metric prefix valid",
+			eps: []ScrapeEndpoint{
+				{
+					Port:         intstr.FromString("web"),
+					Interval:     "10s",
+					MetricPrefix: "foo:bar_",
+				},
+			},
+		}, {
+			desc: "metric prefix invalid",
+			eps: []ScrapeEndpoint{
+				{
+					Port:         intstr.FromString("web"),
+					Interval:     "10s",
+					MetricPrefix: "1foo-",
+				},
+			},
+			fail:        true,
+			errContains: `invalid metric prefix "1foo-"`,
+		}, {
+			desc: "probe targets valid",
+			eps: []ScrapeEndpoint{
+				{
+					Port:         intstr.FromString("http"),
+					Interval:     "10s",
+					Params:       map[string][]string{"module": {"http_2xx"}},
+					ProbeTargets: []string{"https://example.com"},
+				},
+			},
+		}, {
+			desc: "probe targets with target param",
+			eps: []ScrapeEndpoint{
+				{
+					Port:         intstr.FromString("http"),
+					Interval:     "10s",
+					Params:       map[string][]string{"target": {"https://example.org"}},
+					ProbeTargets: []string{"https://example.com"},
+				},
+			},
+			fail:        true,
+			errContains: "the target param must not be set together with probe targets",
+		}, {
+			desc: "empty probe target",
+			eps: []ScrapeEndpoint{
+				{
+					Port:         intstr.FromString("http"),
+					Interval:     "10s",
+					ProbeTargets: []string{"https://example.com", ""},
+				},
+			},
+			fail:        true,
+			errContains: "probe target with index 1 must not be empty",
+		}, {
+			desc: "max metric name length valid",
+			eps: []ScrapeEndpoint{
+				{
+					Port:                intstr.FromString("web"),
+					Interval:            "10s",
+					MaxMetricNameLength: 1000,
+				},
+			},
+		}, {
+			desc: "max metric name length too large",
+			eps: []ScrapeEndpoint{
+				{
+					Port:                intstr.FromString("web"),
+					Interval:            "10s",
+					MaxMetricNameLength: 1001,
+				},
+			},
+			fail:        true,
+			errContains: "max metric name length 1001 must not exceed 1000",
+		}, {
+			desc: "metric keep and drop valid",
+			eps: []ScrapeEndpoint{
+				{
+					Port:       intstr.FromString("web"),
+					Interval:   "10s",
+					MetricKeep: []string{"http_.*", "up"},
+					MetricDrop: []string{"http_.*_bucket"},
+				},
+			},
+		}, {
+			desc: "metric keep invalid regex",
+			eps: []ScrapeEndpoint{
+				{
+					Port:       intstr.FromString("web"),
+					Interval:   "10s",
+					MetricKeep: []string{"http_(.*"},
+				},
+			},
+			fail:        true,
+			errContains: `invalid metric keep regex "http_(.*"`,
+		}, {
+			desc: "metric drop empty regex",
+			eps: []ScrapeEndpoint{
+				{
+					Port:       intstr.FromString("web"),
+					Interval:   "10s",
+					MetricDrop: []string{"go_.*", ""},
+				},
+			},
+			fail:        true,
+			errContains: "metric drop regex with index 1 must not be empty",
+		}, {
+			desc: "metric keep and drop overlap",
+			eps: []ScrapeEndpoint{
+				{
+					Port:       intstr.FromString("web"),
+					Interval:   "10s",
+					MetricKeep: []string{"http_.*", "up"},
+					MetricDrop: []string{"up"},
+				},
+			},
+			fail:        true,
+			errContains: `metric regex "up" must not be both kept and dropped`,
+		}, {
+			desc: "resource attributes valid",
+			eps: []ScrapeEndpoint{
+				{
+					Port:     intstr.FromString("web"),
+					Interval: "10s",
+					ResourceAttributes: []LabelMapping{
+						{From: "service.name", To: "service"},
+						{From: "k8s.deployment.name", To: "deployment"},
+					},
+				},
+			},
+		}, {
+			desc: "resource attribute without target label",
+			eps: []ScrapeEndpoint{
+				{
+					Port:     intstr.FromString("web"),
+					Interval: "10s",
+					ResourceAttributes: []LabelMapping{
+						{From: "service.name"},
+					},
+				},
+			},
+			fail:        true,
+			errContains: `target label for resource attribute "service.name" must be set`,
+		}, {
+			desc: "resource attribute onto protected label",
+			eps: []ScrapeEndpoint{
+				{
+					Port:     intstr.FromString("web"),
+					Interval: "10s",
+					ResourceAttributes: []LabelMapping{
+						{From: "service.name", To: "job"},
+					},
+				},
+			},
+			fail:        true,
+			errContains: `cannot relabel with action "replace" onto protected label "job"`,
+		}, {
+			desc: "resource attribute dropping protected label",
+			eps: []ScrapeEndpoint{
+				{
+					Port:     intstr.FromString("web"),
+					Interval: "10s",
+					ResourceAttributes: []LabelMapping{
+						{From: "instance", To: "otel_instance"},
+					},
+				},
+			},
+			fail:        true,
+			errContains: "would drop at least one of the protected labels",
 		}, {
 			desc: "invalid URL",
 			eps: []ScrapeEndpoint{
@@ -385,76 +695,148 @@ func TestValidatePodMonitoringCommon(t *testing.T) {
 			},
 			fail:        true,
 			errContains: "at most one of basic_auth, oauth2 & authorization must be configured",
-		},
-	}
-
-	for _, c := range cases {
-		t.Run(c.desc+"_podmonitoring", func(t *testing.T) {
-			pm := &PodMonitoring{
-				Spec: PodMonitoringSpec{
-					Endpoints:    c.eps,
-					TargetLabels: c.tls,
-				},
-			}
-			_, perr := pm.ValidateCreate()
-			t.Log(perr)
-
-			if perr == nil && c.fail {
-				t.Fatalf("expected failure but passed")
-			}
-			if perr != nil && !c.fail {
-				t.Fatalf("unexpected failure: %s", perr)
-			}
-			if perr != nil && c.fail && !strings.Contains(perr.Error(), c.errContains) {
-				t.Fatalf("expected error to contain %q but got %q", c.errContains, perr)
-			}
-		})
-
-		t.Run(c.desc+"_clusterpodmonitoring", func(t *testing.T) {
-			cm := &ClusterPodMonitoring{
-				Spec: ClusterPodMonitoringSpec{
-					Endpoints:    c.eps,
-					TargetLabels: c.tls,
-				},
-			}
-			_, cerr := cm.ValidateCreate()
-			t.Log(cerr)
-
-			if cerr == nil && c.fail {
-				t.Fatalf("expected failure but passed")
-			}
-			if cerr != nil && !c.fail {
-				t.Fatalf("unexpected failure: %s", cerr)
-			}
-			if cerr != nil && c.fail && !strings.Contains(cerr.Error(), c.errContains) {
-				t.Fatalf("expected error to contain %q but got %q", c.errContains, cerr)
-			}
-		})
-	}
-}
-
-func TestValidatePodMonitoring(t *testing.T) {
-	cases := []struct {
-		desc        string
-		pm          PodMonitoringSpec
-		eps         []ScrapeEndpoint
-		tls         TargetLabels
-		fail        bool
-		errContains string
-	}{
-		{
-			desc: "OK metadata labels",
+		}, {
+			desc: "BasicAuth username and usernameSecret",
 			eps: []ScrapeEndpoint{
 				{
 					Port:     intstr.FromString("web"),
 					Interval: "10s",
+					HTTPClientConfig: HTTPClientConfig{
+						BasicAuth: &BasicAuth{
+							Username: "xyz",
+							UsernameSecret: &corev1.SecretKeySelector{
+								LocalObjectReference: corev1.LocalObjectReference{
+									Name: "auth",
+								},
+								Key: "username",
+							},
+						},
+					},
 				},
 			},
-			tls: TargetLabels{
-				Metadata: stringSlicePtr("pod", "node", "container"),
-			},
+			fail:        true,
+			errContains: "basic auth username and usernameSecret are mutually exclusive",
 		}, {
-			desc: "bad metadata label",
+			desc: "BasicAuth usernameSecret without key",
+			eps: []ScrapeEndpoint{
+				{
+					Port:     intstr.FromString("web"),
+					Interval: "10s",
+					HTTPClientConfig: HTTPClientConfig{
+						BasicAuth: &BasicAuth{
+							UsernameSecret: &corev1.SecretKeySelector{
+								LocalObjectReference: corev1.LocalObjectReference{
+									Name: "auth",
+								},
+							},
+						},
+					},
+				},
+			},
+			fail:        true,
+			errContains: "basic auth usernameSecret must specify a name and key",
+		}, {
+			desc: "APIServerProxy with TLS",
+			eps: []ScrapeEndpoint{
+				{
+					Port:           intstr.FromString("web"),
+					Interval:       "10s",
+					APIServerProxy: true,
+					HTTPClientConfig: HTTPClientConfig{
+						TLS: &TLS{
+							InsecureSkipVerify: true,
+						},
+					},
+				},
+			},
+			fail:        true,
+			errContains: "authorization, basic auth, OAuth2, TLS, and proxy settings cannot be used with apiServerProxy",
+		}, {
+			desc: "body size limit valid",
+			eps: []ScrapeEndpoint{
+				{
+					Port:          intstr.FromString("web"),
+					Interval:      "10s",
+					BodySizeLimit: "100MB",
+				},
+			},
+		}, {
+			desc: "body size limit invalid",
+			eps: []ScrapeEndpoint{
+				{
+					Port:          intstr.FromString("web"),
+					Interval:      "10s",
+					BodySizeLimit: "100 megabytes",
+				},
+			},
+			fail:        true,
+			errContains: `invalid body size limit "100 megabytes"`,
+		}, {
+			desc: "body size limit negative",
+			eps: []ScrapeEndpoint{
+				{
+					Port:          intstr.FromString("web"),
+					Interval:      "10s",
+					BodySizeLimit: "-1MB",
+				},
+			},
+			fail:        true,
+			errContains: `invalid body size limit "-1MB"`,
+		}, {
+			desc: "external labels valid",
+			eps: []ScrapeEndpoint{
+				{
+					Port:     intstr.FromString("web"),
+					Interval: "10s",
+				},
+			},
+			externalLabels: map[string]string{"logical_cluster": "blue", "tier": "$1"},
+		}, {
+			desc: "external label protected",
+			eps: []ScrapeEndpoint{
+				{
+					Port:     intstr.FromString("web"),
+					Interval: "10s",
+				},
+			},
+			externalLabels: map[string]string{"cluster": "blue"},
+			fail:           true,
+			errContains:    `external label "cluster" must not override one of the protected labels`,
+		}, {
+			desc: "external label invalid name",
+			eps: []ScrapeEndpoint{
+				{
+					Port:     intstr.FromString("web"),
+					Interval: "10s",
+				},
+			},
+			externalLabels: map[string]string{"logical-cluster": "blue"},
+			fail:           true,
+			errContains:    `invalid external label name "logical-cluster"`,
+		}, {
+			desc: "external label reserved name",
+			eps: []ScrapeEndpoint{
+				{
+					Port:     intstr.FromString("web"),
+					Interval: "10s",
+				},
+			},
+			externalLabels: map[string]string{"__address__": "blue"},
+			fail:           true,
+			errContains:    `invalid external label name "__address__"`,
+		}, {
+			desc: "external label empty value",
+			eps: []ScrapeEndpoint{
+				{
+					Port:     intstr.FromString("web"),
+					Interval: "10s",
+				},
+			},
+			externalLabels: map[string]string{"logical_cluster": ""},
+			fail:           true,
+			errContains:    `invalid value "" for external label "logical_cluster"`,
+		}, {
+			desc: "external label conflicts with metadata label",
 			eps: []ScrapeEndpoint{
 				{
 					Port:     intstr.FromString("web"),
@@ -462,19 +844,99 @@ func TestValidatePodMonitoring(t *testing.T) {
 				},
 			},
 			tls: TargetLabels{
-				Metadata: stringSlicePtr("foo", "pod", "node", "container"),
+				Metadata: stringSlicePtr("pod"),
+			},
+			externalLabels: map[string]string{"pod": "blue"},
+			fail:           true,
+			errContains:    `external label "pod" conflicts with metadata label`,
+		}, {
+			desc: "external label conflicts with pod label mapping",
+			eps: []ScrapeEndpoint{
+				{
+					Port:     intstr.FromString("web"),
+					Interval: "10s",
+				},
+			},
+			tls: TargetLabels{
+				FromPod: []LabelMapping{{From: "app.kubernetes.io/name", To: "app"}},
+			},
+			externalLabels: map[string]string{"app": "blue"},
+			fail:           true,
+			errContains:    `external label "app" conflicts with pod label mapping`,
+		}, {
+			desc: "node selector valid",
+			eps: []ScrapeEndpoint{
+				{
+					Port:     intstr.FromString("web"),
+					Interval: "10s",
+				},
+			},
+			nodeSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"gpu": "true"},
+			},
+		}, {
+			desc: "node selector invalid key",
+			eps: []ScrapeEndpoint{
+				{
+					Port:     intstr.FromString("web"),
+					Interval: "10s",
+				},
+			},
+			nodeSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"gpu/": "true"},
 			},
 			fail:        true,
-			errContains: `label "foo" not allowed, must be one of [pod container node]`,
+			errContains: `invalid node selector: key: Invalid value: "gpu/"`,
+		}, {
+			desc: "node selector invalid value",
+			eps: []ScrapeEndpoint{
+				{
+					Port:     intstr.FromString("web"),
+					Interval: "10s",
+				},
+			},
+			nodeSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"gpu": "yes please"},
+			},
+			fail:        true,
+			errContains: `invalid node selector: values[0][gpu]: Invalid value: "yes please"`,
+		}, {
+			desc: "monitoring name label",
+			eps: []ScrapeEndpoint{
+				{
+					Port:                intstr.FromString("web"),
+					Interval:            "10s",
+					MonitoringNameLabel: true,
+				},
+			},
+			tls: TargetLabels{
+				FromPod: []LabelMapping{{From: "monitoring_name", To: "app_monitoring_name"}},
+			},
+		}, {
+			desc: "monitoring name label conflicts with pod label",
+			eps: []ScrapeEndpoint{
+				{
+					Port:                intstr.FromString("web"),
+					Interval:            "10s",
+					MonitoringNameLabel: true,
+				},
+			},
+			tls: TargetLabels{
+				FromPod: []LabelMapping{{From: "monitoring_name"}},
+			},
+			fail:        true,
+			errContains: `pod label mapping onto "monitoring_name" conflicts with monitoringNameLabel`,
 		},
 	}
 
 	for _, c := range cases {
-		t.Run(c.desc, func(t *testing.T) {
+		t.Run(c.desc+"_podmonitoring", func(t *testing.T) {
 			pm := &PodMonitoring{
 				Spec: PodMonitoringSpec{
-					Endpoints:    c.eps,
-					TargetLabels: c.tls,
+					Endpoints:      c.eps,
+					TargetLabels:   c.tls,
+					NodeSelector:   c.nodeSelector,
+					ExternalLabels: c.externalLabels,
 				},
 			}
 			_, perr := pm.ValidateCreate()
@@ -490,10 +952,33 @@ func TestValidatePodMonitoring(t *testing.T) {
 				t.Fatalf("expected error to contain %q but got %q", c.errContains, perr)
 			}
 		})
+
+		t.Run(c.desc+"_clusterpodmonitoring", func(t *testing.T) {
+			cm := &ClusterPodMonitoring{
+				Spec: ClusterPodMonitoringSpec{
+					Endpoints:      c.eps,
+					TargetLabels:   c.tls,
+					NodeSelector:   c.nodeSelector,
+					ExternalLabels: c.externalLabels,
+				},
+			}
+			_, cerr := cm.ValidateCreate()
+			t.Log(cerr)
+
+			if cerr == nil && c.fail {
+				t.Fatalf("expected failure but passed")
+			}
+			if cerr != nil && !c.fail {
+				t.Fatalf("unexpected failure: %s", cerr)
+			}
+			if cerr != nil && c.fail && !strings.Contains(cerr.Error(), c.errContains) {
+				t.Fatalf("expected error to contain %q but got %q", c.errContains, cerr)
+			}
+		})
 	}
 }
 
-func TestValidateClusterPodMonitoring(t *testing.T) {
+func TestValidatePodMonitoring(t *testing.T) {
 	cases := []struct {
 		desc        string
 		pm          PodMonitoringSpec
@@ -501,6 +986,105 @@ func TestValidateClusterPodMonitoring(t *testing.T) {
 		tls         TargetLabels
 		fail        bool
 		errContains string
+	}{
+		{
+			desc: "OK metadata labels",
+			eps: []ScrapeEndpoint{
+				{
+					Port:     intstr.FromString("web"),
+					Interval: "10s",
+				},
+			},
+			tls: TargetLabels{
+				Metadata: stringSlicePtr("pod", "node", "container"),
+			},
+		}, {
+			desc: "bad metadata label",
+			eps: []ScrapeEndpoint{
+				{
+					Port:     intstr.FromString("web"),
+					Interval: "10s",
+				},
+			},
+			tls: TargetLabels{
+				Metadata: stringSlicePtr("foo", "pod", "node", "container"),
+			},
+			fail:        true,
+			errContains: `label "foo" not allowed, must be one of [pod pod_uid container node]`,
+		}, {
+			desc: "field selector valid",
+			pm: PodMonitoringSpec{
+				FieldSelector: "spec.nodeName=node-1,status.phase!=Pending",
+			},
+			eps: []ScrapeEndpoint{
+				{
+					Port:     intstr.FromString("web"),
+					Interval: "10s",
+				},
+			},
+		}, {
+			desc: "field selector unsupported field",
+			pm: PodMonitoringSpec{
+				FieldSelector: "spec.priority=1",
+			},
+			eps: []ScrapeEndpoint{
+				{
+					Port:     intstr.FromString("web"),
+					Interval: "10s",
+				},
+			},
+			fail:        true,
+			errContains: `invalid field selector: field "spec.priority" not supported for pods`,
+		}, {
+			desc: "field selector invalid syntax",
+			pm: PodMonitoringSpec{
+				FieldSelector: "spec.nodeName",
+			},
+			eps: []ScrapeEndpoint{
+				{
+					Port:     intstr.FromString("web"),
+					Interval: "10s",
+				},
+			},
+			fail:        true,
+			errContains: "invalid field selector",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			pm := &PodMonitoring{
+				Spec: PodMonitoringSpec{
+					Endpoints:     c.eps,
+					TargetLabels:  c.tls,
+					FieldSelector: c.pm.FieldSelector,
+				},
+			}
+			_, perr := pm.ValidateCreate()
+			t.Log(perr)
+
+			if perr == nil && c.fail {
+				t.Fatalf("expected failure but passed")
+			}
+			if perr != nil && !c.fail {
+				t.Fatalf("unexpected failure: %s", perr)
+			}
+			if perr != nil && c.fail && !strings.Contains(perr.Error(), c.errContains) {
+				t.Fatalf("expected error to contain %q but got %q", c.errContains, perr)
+			}
+		})
+	}
+}
+
+func TestValidateClusterPodMonitoring(t *testing.T) {
+	cases := []struct {
+		desc              string
+		pm                PodMonitoringSpec
+		eps               []ScrapeEndpoint
+		tls               TargetLabels
+		excludeNamespaces *[]string
+		fail              bool
+		errContains       string
 	}{
 		{
 			desc: "OK metadata labels",
@@ -513,6 +1097,26 @@ func TestValidateClusterPodMonitoring(t *testing.T) {
 			tls: TargetLabels{
 				Metadata: stringSlicePtr("namespace", "pod", "node", "container"),
 			},
+		}, {
+			desc: "OK excluded namespaces",
+			eps: []ScrapeEndpoint{
+				{
+					Port:     intstr.FromString("web"),
+					Interval: "10s",
+				},
+			},
+			excludeNamespaces: stringSlicePtr("kube-system", "gmp-system"),
+		}, {
+			desc: "bad excluded namespace",
+			eps: []ScrapeEndpoint{
+				{
+					Port:     intstr.FromString("web"),
+					Interval: "10s",
+				},
+			},
+			excludeNamespaces: stringSlicePtr("kube-system", "Foo_Bar"),
+			fail:              true,
+			errContains:       `invalid excluded namespace "Foo_Bar"`,
 		}, {
 			desc: "bad metadata label",
 			eps: []ScrapeEndpoint{
@@ -525,7 +1129,27 @@ func TestValidateClusterPodMonitoring(t *testing.T) {
 				Metadata: stringSlicePtr("namespace", "foo", "pod", "node", "container"),
 			},
 			fail:        true,
-			errContains: `label "foo" not allowed, must be one of [namespace pod container node]`,
+			errContains: `label "foo" not allowed, must be one of [namespace pod pod_uid container node]`,
+		}, {
+			desc: "BasicAuth usernameSecret",
+			eps: []ScrapeEndpoint{
+				{
+					Port:     intstr.FromString("web"),
+					Interval: "10s",
+					HTTPClientConfig: HTTPClientConfig{
+						BasicAuth: &BasicAuth{
+							UsernameSecret: &corev1.SecretKeySelector{
+								LocalObjectReference: corev1.LocalObjectReference{
+									Name: "auth",
+								},
+								Key: "username",
+							},
+						},
+					},
+				},
+			},
+			fail:        true,
+			errContains: "basic auth usernameSecret is not supported for cluster-scoped resources",
 		},
 	}
 
@@ -533,8 +1157,9 @@ func TestValidateClusterPodMonitoring(t *testing.T) {
 		t.Run(c.desc+"", func(t *testing.T) {
 			pm := &ClusterPodMonitoring{
 				Spec: ClusterPodMonitoringSpec{
-					Endpoints:    c.eps,
-					TargetLabels: c.tls,
+					Endpoints:         c.eps,
+					TargetLabels:      c.tls,
+					ExcludeNamespaces: c.excludeNamespaces,
 				},
 			}
 			_, perr := pm.ValidateCreate()
@@ -553,19 +1178,335 @@ func TestValidateClusterPodMonitoring(t *testing.T) {
 	}
 }
 
-func stringSlicePtr(s ...string) *[]string {
-	return &s
-}
-
-func TestLabelMappingRelabelConfigs(t *testing.T) {
+func TestClusterPodMonitoring_ExcludeNamespaces(t *testing.T) {
 	cases := []struct {
-		doc      string
-		mappings []LabelMapping
-		expected []*relabel.Config
-		expErr   bool
+		desc              string
+		excludeNamespaces *[]string
+		// Regex of the namespace drop rule, empty if none is expected.
+		want string
 	}{
 		{
-			doc:      "good podmonitoring relabel",
+			desc: "default",
+			want: "kube-system",
+		}, {
+			desc:              "custom",
+			excludeNamespaces: stringSlicePtr("kube-system", "gmp-system"),
+			want:              "kube-system|gmp-system",
+		}, {
+			desc:              "disabled",
+			excludeNamespaces: stringSlicePtr(),
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			cmon := &ClusterPodMonitoring{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name1",
+				},
+				Spec: ClusterPodMonitoringSpec{
+					Endpoints: []ScrapeEndpoint{
+						{
+							Port:     intstr.FromString("web"),
+							Interval: "10s",
+						},
+					},
+					ExcludeNamespaces: c.excludeNamespaces,
+				},
+			}
+			cfgs, err := cmon.ScrapeConfigs("test_project", "test_location", "test_cluster")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got string
+			for _, rcfg := range cfgs[0].RelabelConfigs {
+				if rcfg.Action == relabel.Drop && len(rcfg.SourceLabels) == 1 && rcfg.SourceLabels[0] == "__meta_kubernetes_namespace" {
+					got = rcfg.Regex.String()
+				}
+			}
+			if got != c.want {
+				t.Errorf("expected namespace drop regex %q, got %q", c.want, got)
+			}
+		})
+	}
+}
+
+func TestClusterPodMonitoring_NamespaceSelector(t *testing.T) {
+	cases := []struct {
+		desc       string
+		selector   *metav1.LabelSelector
+		namespaces []string
+		// Regex of the namespace keep rule, nil if none is expected.
+		want *string
+		fail bool
+	}{
+		{
+			desc:       "unset",
+			namespaces: []string{"team-a"},
+		}, {
+			desc:       "empty",
+			selector:   &metav1.LabelSelector{},
+			namespaces: []string{"team-a"},
+		}, {
+			desc:       "matching namespaces",
+			selector:   &metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}},
+			namespaces: []string{"team-a2", "team-a.1"},
+			want:       ptr.To(`team-a\.1|team-a2`),
+		}, {
+			desc:     "no matching namespace",
+			selector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}},
+			want:     ptr.To(""),
+		}, {
+			desc: "invalid",
+			selector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "team", Operator: "Unknown"},
+			}},
+			fail: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			cmon := &ClusterPodMonitoring{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name1",
+				},
+				Spec: ClusterPodMonitoringSpec{
+					Endpoints: []ScrapeEndpoint{
+						{
+							Port:     intstr.FromString("web"),
+							Interval: "10s",
+						},
+					},
+					NamespaceSelector: c.selector,
+				},
+			}
+			cfgs, err := cmon.NamespacedScrapeConfigs("test_project", "test_location", "test_cluster", c.namespaces)
+			if c.fail {
+				if err == nil {
+					t.Fatal("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got *string
+			for _, rcfg := range cfgs[0].RelabelConfigs {
+				if rcfg.Action == relabel.Keep && len(rcfg.SourceLabels) == 1 && rcfg.SourceLabels[0] == "__meta_kubernetes_namespace" {
+					got = ptr.To(rcfg.Regex.String())
+				}
+			}
+			if diff := cmp.Diff(c.want, got); diff != "" {
+				t.Errorf("unexpected namespace keep regex (-want, +got): %s", diff)
+			}
+			if got == nil {
+				return
+			}
+			// The keep rule only keeps targets in the given namespaces.
+			for _, ns := range []string{"team-a2", "team-a.1", "team-ax1", "other"} {
+				re := relabel.MustNewRegexp(*got)
+				if want := containsString(c.namespaces, ns); re.MatchString(ns) != want {
+					t.Errorf("expected namespace %q to match %v", ns, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMonitoring_ProjectID(t *testing.T) {
+	cases := []struct {
+		desc      string
+		projectID string
+		// Value of the project_id target label, empty if validation is expected to fail.
+		want string
+	}{
+		{
+			desc: "default",
+			want: "test-project",
+		}, {
+			desc:      "custom",
+			projectID: "other-project-123",
+			want:      "other-project-123",
+		}, {
+			desc:      "uppercase",
+			projectID: "Other-Project",
+		}, {
+			desc:      "too short",
+			projectID: "proj",
+		}, {
+			desc:      "trailing hyphen",
+			projectID: "other-project-",
+		}, {
+			desc:      "leading digit",
+			projectID: "1-other-project",
+		},
+	}
+	endpoints := []ScrapeEndpoint{
+		{
+			Port:     intstr.FromString("web"),
+			Interval: "10s",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			pmon := &PodMonitoring{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "ns1",
+					Name:      "name1",
+				},
+				Spec: PodMonitoringSpec{
+					Endpoints: endpoints,
+					ProjectID: c.projectID,
+				},
+			}
+			cmon := &ClusterPodMonitoring{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name1",
+				},
+				Spec: ClusterPodMonitoringSpec{
+					Endpoints: endpoints,
+					ProjectID: c.projectID,
+				},
+			}
+			for _, m := range []interface {
+				ScrapeConfigs(projectID, location, cluster string) ([]*promconfig.ScrapeConfig, error)
+			}{pmon, cmon} {
+				cfgs, err := m.ScrapeConfigs("test-project", "test_location", "test_cluster")
+				if c.want == "" {
+					if err == nil {
+						t.Fatalf("expected error for project ID %q", c.projectID)
+					}
+					if !strings.Contains(err.Error(), "invalid project ID") {
+						t.Fatalf("unexpected error: %s", err)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				var got []string
+				for _, rcfg := range cfgs[0].RelabelConfigs {
+					if rcfg.TargetLabel == "project_id" {
+						got = append(got, rcfg.Replacement)
+					}
+				}
+				if diff := cmp.Diff([]string{c.want}, got); diff != "" {
+					t.Errorf("unexpected project_id relabeling for %T (-want, +got): %s", m, diff)
+				}
+			}
+		})
+	}
+}
+
+func stringSlicePtr(s ...string) *[]string {
+	return &s
+}
+
+func TestMonitoring_LabelOverrides(t *testing.T) {
+	cases := []struct {
+		desc      string
+		overrides map[string]string
+		// Values of the location and cluster target labels, empty if validation is expected
+		// to fail.
+		wantLocation, wantCluster string
+		errContains               string
+	}{
+		{
+			desc:         "default",
+			wantLocation: "test_location",
+			wantCluster:  "test_cluster",
+		}, {
+			desc:         "location",
+			overrides:    map[string]string{"location": "us-east1-b"},
+			wantLocation: "us-east1-b",
+			wantCluster:  "test_cluster",
+		}, {
+			desc:         "location and cluster",
+			overrides:    map[string]string{"location": "us-east1-b", "cluster": "edge-1"},
+			wantLocation: "us-east1-b",
+			wantCluster:  "edge-1",
+		}, {
+			desc:        "project_id",
+			overrides:   map[string]string{"project_id": "other-project"},
+			errContains: `label "project_id" cannot be overridden, set projectID instead`,
+		}, {
+			desc:        "other label",
+			overrides:   map[string]string{"namespace": "other"},
+			errContains: `label "namespace" cannot be overridden, only "location" and "cluster" are permitted`,
+		}, {
+			desc:        "empty value",
+			overrides:   map[string]string{"cluster": ""},
+			errContains: `invalid override "" for label "cluster"`,
+		},
+	}
+	endpoints := []ScrapeEndpoint{
+		{
+			Port:     intstr.FromString("web"),
+			Interval: "10s",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			pmon := &PodMonitoring{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "ns1",
+					Name:      "name1",
+				},
+				Spec: PodMonitoringSpec{
+					Endpoints:      endpoints,
+					LabelOverrides: c.overrides,
+				},
+			}
+			cmon := &ClusterPodMonitoring{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name1",
+				},
+				Spec: ClusterPodMonitoringSpec{
+					Endpoints:      endpoints,
+					LabelOverrides: c.overrides,
+				},
+			}
+			for _, m := range []interface {
+				ScrapeConfigs(projectID, location, cluster string) ([]*promconfig.ScrapeConfig, error)
+			}{pmon, cmon} {
+				cfgs, err := m.ScrapeConfigs("test-project", "test_location", "test_cluster")
+				if c.errContains != "" {
+					if err == nil || !strings.Contains(err.Error(), c.errContains) {
+						t.Fatalf("expected error containing %q, got %v", c.errContains, err)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				got := map[string]string{}
+				for _, rcfg := range cfgs[0].RelabelConfigs {
+					switch rcfg.TargetLabel {
+					case "project_id", "location", "cluster":
+						got[rcfg.TargetLabel] = rcfg.Replacement
+					}
+				}
+				want := map[string]string{
+					"project_id": "test-project",
+					"location":   c.wantLocation,
+					"cluster":    c.wantCluster,
+				}
+				if diff := cmp.Diff(want, got); diff != "" {
+					t.Errorf("unexpected injected labels for %T (-want, +got): %s", m, diff)
+				}
+			}
+		})
+	}
+}
+
+func TestLabelMappingRelabelConfigs(t *testing.T) {
+	cases := []struct {
+		doc      string
+		mappings []LabelMapping
+		expected []*relabel.Config
+		expErr   bool
+	}{
+		{
+			doc:      "good podmonitoring relabel",
 			mappings: []LabelMapping{{From: "from", To: "to"}},
 			expected: []*relabel.Config{{
 				Action:       relabel.Replace,
@@ -599,30 +1540,1147 @@ func TestLabelMappingRelabelConfigs(t *testing.T) {
 			expErr: false,
 		},
 	}
-
-	for _, c := range cases {
-		t.Run(c.doc, func(t *testing.T) {
-			// If we get an error when we don't expect, fail test.
-			actual, err := labelMappingRelabelConfigs(c.mappings, "__meta_kubernetes_pod_label_")
-			if err != nil && !c.expErr {
-				t.Errorf("returned unexpected error: %s", err)
-			}
-			if err == nil && c.expErr {
-				t.Errorf("should have returned an error")
-			}
-			if diff := cmp.Diff(c.expected, actual, cmpopts.IgnoreUnexported(relabel.Regexp{}, regexp.Regexp{})); diff != "" {
-				t.Errorf("returned unexpected config (-want, +got): %s", diff)
-			}
-		})
+
+	for _, c := range cases {
+		t.Run(c.doc, func(t *testing.T) {
+			// If we get an error when we don't expect, fail test.
+			actual, err := labelMappingRelabelConfigs(c.mappings, "__meta_kubernetes_pod_label_")
+			if err != nil && !c.expErr {
+				t.Errorf("returned unexpected error: %s", err)
+			}
+			if err == nil && c.expErr {
+				t.Errorf("should have returned an error")
+			}
+			if diff := cmp.Diff(c.expected, actual, cmpopts.IgnoreUnexported(relabel.Regexp{}, regexp.Regexp{})); diff != "" {
+				t.Errorf("returned unexpected config (-want, +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestPodMonitoring_ScrapeConfig(t *testing.T) {
+	// Generate YAML for one complex scrape config and make sure everything
+	// adds up. This primarily verifies that everything is included and marshalling
+	// the generated config to YAML does not produce any bad configurations due to
+	// defaulting as the Prometheus structs are misconfigured in this regard in
+	// several places.
+	pmon := &PodMonitoring{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns1",
+			Name:      "name1",
+		},
+		Spec: PodMonitoringSpec{
+			Endpoints: []ScrapeEndpoint{
+				{
+					Port:     intstr.FromString("web"),
+					Interval: "10s",
+					MetricRelabeling: []RelabelingRule{
+						{
+							Action:       "replace",
+							SourceLabels: []string{"mlabel_1", "mlabel_2"},
+							TargetLabel:  "mlabel_3",
+						}, {
+							Action:       "hashmod",
+							SourceLabels: []string{"mlabel_1"},
+							Modulus:      3,
+							TargetLabel:  "__tmp_mod",
+						}, {
+							SourceLabels: []string{"mlabel_4"},
+							TargetLabel:  "mlabel_5",
+						}, {
+							Action:  "keep",
+							Regex:   "foo_.+",
+							Modulus: 3,
+						},
+					},
+				},
+				{
+					Port:                intstr.FromInt(8080),
+					Interval:            "10000ms",
+					Timeout:             "5s",
+					Path:                "/prometheus",
+					MetricPrefix:        "foo_",
+					MaxMetricNameLength: 20,
+					ResourceAttributes: []LabelMapping{
+						{From: "service.name", To: "otel_service"},
+					},
+					HTTPClientConfig: HTTPClientConfig{
+						BasicAuth: &BasicAuth{
+							UsernameSecret: &corev1.SecretKeySelector{
+								LocalObjectReference: corev1.LocalObjectReference{
+									Name: "auth",
+								},
+								Key: "username",
+							},
+						},
+						ProxyConfig: ProxyConfig{
+							ProxyURL: "http://foo.bar/test",
+						},
+					},
+				},
+			},
+			TargetLabels: TargetLabels{
+				FromPod: []LabelMapping{
+					{From: "key1", To: "key2"},
+					{From: "key3"},
+				},
+			},
+			Limits: &ScrapeLimits{
+				Samples:          1,
+				Labels:           2,
+				LabelNameLength:  3,
+				LabelValueLength: 4,
+			},
+			RequireReady: true,
+		},
+	}
+	scrapeCfgs, err := pmon.ScrapeConfigs("test_project", "test_location", "test_cluster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+
+	for _, sc := range scrapeCfgs {
+		b, err := yaml.Marshal(sc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, string(b))
+	}
+	want := []string{
+		`job_name: PodMonitoring/ns1/name1/web
+honor_timestamps: false
+scrape_interval: 10s
+scrape_timeout: 10s
+metrics_path: /metrics
+sample_limit: 1
+label_limit: 2
+label_name_length_limit: 3
+label_value_length_limit: 4
+follow_redirects: true
+enable_http2: true
+relabel_configs:
+- source_labels: [__meta_kubernetes_namespace]
+  regex: ns1
+  action: keep
+- source_labels: [__meta_kubernetes_namespace]
+  target_label: namespace
+  action: replace
+- target_label: job
+  replacement: name1
+  action: replace
+- source_labels: [__meta_kubernetes_pod_phase]
+  regex: (Failed|Succeeded)
+  action: drop
+- source_labels: [__meta_kubernetes_pod_ready]
+  regex: "true"
+  action: keep
+- target_label: project_id
+  replacement: test_project
+  action: replace
+- target_label: location
+  replacement: test_location
+  action: replace
+- target_label: cluster
+  replacement: test_cluster
+  action: replace
+- source_labels: [__meta_kubernetes_pod_name]
+  target_label: __tmp_instance
+  action: replace
+- source_labels: [__meta_kubernetes_pod_controller_kind, __meta_kubernetes_pod_node_name]
+  regex: DaemonSet;(.*)
+  target_label: __tmp_instance
+  replacement: $1
+  action: replace
+- source_labels: [__meta_kubernetes_pod_container_port_name]
+  regex: web
+  action: keep
+- source_labels: [__tmp_instance, __meta_kubernetes_pod_container_port_name]
+  regex: (.+);(.+)
+  target_label: instance
+  replacement: $1:$2
+  action: replace
+- source_labels: [__meta_kubernetes_pod_label_key1]
+  target_label: key2
+  action: replace
+- source_labels: [__meta_kubernetes_pod_label_key3]
+  target_label: key3
+  action: replace
+metric_relabel_configs:
+- source_labels: [mlabel_1, mlabel_2]
+  target_label: mlabel_3
+  action: replace
+- source_labels: [mlabel_1]
+  modulus: 3
+  target_label: __tmp_mod
+  action: hashmod
+- source_labels: [mlabel_4]
+  target_label: mlabel_5
+- regex: foo_.+
+  modulus: 3
+  action: keep
+kubernetes_sd_configs:
+- role: pod
+  kubeconfig_file: ""
+  follow_redirects: true
+  enable_http2: true
+  selectors:
+  - role: pod
+    field: spec.nodeName=$(NODE_NAME)
+`,
+		`job_name: PodMonitoring/ns1/name1/8080
+honor_timestamps: false
+scrape_interval: 10s
+scrape_timeout: 5s
+metrics_path: /prometheus
+sample_limit: 1
+label_limit: 2
+label_name_length_limit: 3
+label_value_length_limit: 4
+basic_auth:
+  username: ""
+  username_file: /etc/secrets/secret_ns1_auth_username
+follow_redirects: true
+enable_http2: true
+proxy_url: http://foo.bar/test
+relabel_configs:
+- source_labels: [__meta_kubernetes_namespace]
+  regex: ns1
+  action: keep
+- source_labels: [__meta_kubernetes_namespace]
+  target_label: namespace
+  action: replace
+- target_label: job
+  replacement: name1
+  action: replace
+- source_labels: [__meta_kubernetes_pod_phase]
+  regex: (Failed|Succeeded)
+  action: drop
+- source_labels: [__meta_kubernetes_pod_ready]
+  regex: "true"
+  action: keep
+- target_label: project_id
+  replacement: test_project
+  action: replace
+- target_label: location
+  replacement: test_location
+  action: replace
+- target_label: cluster
+  replacement: test_cluster
+  action: replace
+- source_labels: [__meta_kubernetes_pod_name]
+  target_label: __tmp_instance
+  action: replace
+- source_labels: [__meta_kubernetes_pod_controller_kind, __meta_kubernetes_pod_node_name]
+  regex: DaemonSet;(.*)
+  target_label: __tmp_instance
+  replacement: $1
+  action: replace
+- regex: container
+  action: labeldrop
+- source_labels: [__tmp_instance]
+  target_label: instance
+  replacement: $1:8080
+  action: replace
+- source_labels: [__meta_kubernetes_pod_ip]
+  target_label: __address__
+  replacement: $1:8080
+  action: replace
+- source_labels: [__meta_kubernetes_pod_label_key1]
+  target_label: key2
+  action: replace
+- source_labels: [__meta_kubernetes_pod_label_key3]
+  target_label: key3
+  action: replace
+metric_relabel_configs:
+- source_labels: [service_name]
+  regex: (.+)
+  target_label: otel_service
+  action: replace
+- regex: service_name
+  action: labeldrop
+- source_labels: [__name__]
+  target_label: __name__
+  replacement: foo_$1
+  action: replace
+- source_labels: [__name__]
+  regex: .{20}.+
+  action: drop
+kubernetes_sd_configs:
+- role: pod
+  kubeconfig_file: ""
+  follow_redirects: true
+  enable_http2: true
+  selectors:
+  - role: pod
+    field: spec.nodeName=$(NODE_NAME)
+`,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("unexpected scrape config YAML (-want, +got): %s", diff)
+	}
+}
+
+func TestPodMonitoring_ProbeScrapeConfig(t *testing.T) {
+	pmon := &PodMonitoring{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns1",
+			Name:      "blackbox",
+		},
+		Spec: PodMonitoringSpec{
+			Selector: metav1.LabelSelector{
+				MatchLabels: map[string]string{"app": "blackbox-exporter"},
+			},
+			Endpoints: []ScrapeEndpoint{
+				{
+					Port:         intstr.FromString("http"),
+					Interval:     "30s",
+					Path:         "/probe",
+					Params:       map[string][]string{"module": {"http_2xx"}},
+					ProbeTargets: []string{"https://example.com", "10.0.0.1:443"},
+				},
+			},
+		},
+	}
+	scrapeCfgs, err := pmon.ScrapeConfigs("test_project", "test_location", "test_cluster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+
+	for _, sc := range scrapeCfgs {
+		b, err := yaml.Marshal(sc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, string(b))
+	}
+	probeConfig := func(index int, target string) string {
+		return fmt.Sprintf(`job_name: PodMonitoring/ns1/blackbox/http/probe-%d
+honor_timestamps: false
+params:
+  module:
+  - http_2xx
+  target:
+  - %s
+scrape_interval: 30s
+scrape_timeout: 30s
+metrics_path: /probe
+follow_redirects: true
+enable_http2: true
+relabel_configs:
+- source_labels: [__meta_kubernetes_namespace]
+  regex: ns1
+  action: keep
+- source_labels: [__meta_kubernetes_pod_label_app]
+  regex: blackbox-exporter
+  action: keep
+- source_labels: [__meta_kubernetes_namespace]
+  target_label: namespace
+  action: replace
+- target_label: job
+  replacement: blackbox
+  action: replace
+- source_labels: [__meta_kubernetes_pod_phase]
+  regex: (Failed|Succeeded)
+  action: drop
+- target_label: project_id
+  replacement: test_project
+  action: replace
+- target_label: location
+  replacement: test_location
+  action: replace
+- target_label: cluster
+  replacement: test_cluster
+  action: replace
+- source_labels: [__meta_kubernetes_pod_name]
+  target_label: __tmp_instance
+  action: replace
+- source_labels: [__meta_kubernetes_pod_controller_kind, __meta_kubernetes_pod_node_name]
+  regex: DaemonSet;(.*)
+  target_label: __tmp_instance
+  replacement: $1
+  action: replace
+- source_labels: [__meta_kubernetes_pod_container_port_name]
+  regex: http
+  action: keep
+- source_labels: [__tmp_instance, __meta_kubernetes_pod_container_port_name]
+  regex: (.+);(.+)
+  target_label: instance
+  replacement: $1:$2
+  action: replace
+- target_label: instance
+  replacement: %s
+  action: replace
+kubernetes_sd_configs:
+- role: pod
+  kubeconfig_file: ""
+  follow_redirects: true
+  enable_http2: true
+  selectors:
+  - role: pod
+    field: spec.nodeName=$(NODE_NAME)
+`, index, target, target)
+	}
+	want := []string{
+		probeConfig(0, "https://example.com"),
+		probeConfig(1, "10.0.0.1:443"),
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("unexpected scrape config YAML (-want, +got): %s", diff)
+	}
+}
+
+func TestPodMonitoring_NodeSelectorScrapeConfig(t *testing.T) {
+	pmon := &PodMonitoring{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns1",
+			Name:      "gpu",
+		},
+		Spec: PodMonitoringSpec{
+			Selector: metav1.LabelSelector{
+				MatchLabels: map[string]string{"app": "trainer"},
+			},
+			NodeSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"gpu": "true"},
+				MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: "cloud.google.com/gke-accelerator", Operator: metav1.LabelSelectorOpExists},
+				},
+			},
+			Endpoints: []ScrapeEndpoint{
+				{
+					Port:     intstr.FromString("metrics"),
+					Interval: "10s",
+				},
+			},
+		},
+	}
+	scrapeCfgs, err := pmon.ScrapeConfigs("test_project", "test_location", "test_cluster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+
+	for _, sc := range scrapeCfgs {
+		b, err := yaml.Marshal(sc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, string(b))
+	}
+	want := []string{
+		`job_name: PodMonitoring/ns1/gpu/metrics
+honor_timestamps: false
+scrape_interval: 10s
+scrape_timeout: 10s
+metrics_path: /metrics
+follow_redirects: true
+enable_http2: true
+relabel_configs:
+- source_labels: [__meta_kubernetes_namespace]
+  regex: ns1
+  action: keep
+- source_labels: [__meta_kubernetes_pod_label_app]
+  regex: trainer
+  action: keep
+- source_labels: [__meta_kubernetes_namespace]
+  target_label: namespace
+  action: replace
+- target_label: job
+  replacement: gpu
+  action: replace
+- source_labels: [__meta_kubernetes_pod_phase]
+  regex: (Failed|Succeeded)
+  action: drop
+- source_labels: [__meta_kubernetes_node_label_gpu]
+  regex: "true"
+  action: keep
+- source_labels: [__meta_kubernetes_node_labelpresent_cloud_google_com_gke_accelerator]
+  regex: "true"
+  action: keep
+- target_label: project_id
+  replacement: test_project
+  action: replace
+- target_label: location
+  replacement: test_location
+  action: replace
+- target_label: cluster
+  replacement: test_cluster
+  action: replace
+- source_labels: [__meta_kubernetes_pod_name]
+  target_label: __tmp_instance
+  action: replace
+- source_labels: [__meta_kubernetes_pod_controller_kind, __meta_kubernetes_pod_node_name]
+  regex: DaemonSet;(.*)
+  target_label: __tmp_instance
+  replacement: $1
+  action: replace
+- source_labels: [__meta_kubernetes_pod_container_port_name]
+  regex: metrics
+  action: keep
+- source_labels: [__tmp_instance, __meta_kubernetes_pod_container_port_name]
+  regex: (.+);(.+)
+  target_label: instance
+  replacement: $1:$2
+  action: replace
+kubernetes_sd_configs:
+- role: pod
+  kubeconfig_file: ""
+  follow_redirects: true
+  enable_http2: true
+  selectors:
+  - role: pod
+    field: spec.nodeName=$(NODE_NAME)
+  attach_metadata:
+    node: true
+`,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("unexpected scrape config YAML (-want, +got): %s", diff)
+	}
+}
+
+func TestPodMonitoring_FieldSelectorScrapeConfig(t *testing.T) {
+	cases := []struct {
+		desc          string
+		fieldSelector string
+		want          string
+	}{
+		{
+			desc: "no field selector",
+			want: "spec.nodeName=$(NODE_NAME)",
+		}, {
+			desc:          "single field",
+			fieldSelector: "spec.nodeName=node-1",
+			want:          "spec.nodeName=$(NODE_NAME),spec.nodeName=node-1",
+		}, {
+			desc:          "multiple fields",
+			fieldSelector: "status.phase!=Pending,spec.serviceAccountName==exporter",
+			want:          "spec.nodeName=$(NODE_NAME),spec.serviceAccountName=exporter,status.phase!=Pending",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			pmon := &PodMonitoring{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "ns1",
+					Name:      "local",
+				},
+				Spec: PodMonitoringSpec{
+					FieldSelector: c.fieldSelector,
+					Endpoints: []ScrapeEndpoint{
+						{
+							Port:     intstr.FromString("metrics"),
+							Interval: "10s",
+						},
+					},
+				},
+			}
+			scrapeCfgs, err := pmon.ScrapeConfigs("test_project", "test_location", "test_cluster")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(scrapeCfgs) != 1 {
+				t.Fatalf("expected 1 scrape config, got %d", len(scrapeCfgs))
+			}
+			sdCfg := scrapeCfgs[0].ServiceDiscoveryConfigs[0].(*discoverykube.SDConfig)
+			want := []discoverykube.SelectorConfig{
+				{Role: discoverykube.RolePod, Field: c.want},
+			}
+			if diff := cmp.Diff(want, sdCfg.Selectors); diff != "" {
+				t.Errorf("unexpected selectors (-want, +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestPodMonitoring_BodySizeLimitScrapeConfig(t *testing.T) {
+	cases := []struct {
+		desc          string
+		bodySizeLimit string
+		want          string
+	}{
+		{
+			desc: "unset",
+		}, {
+			desc:          "megabytes",
+			bodySizeLimit: "100MB",
+			want:          "body_size_limit: 100MiB\n",
+		}, {
+			desc:          "binary units",
+			bodySizeLimit: "1GiB",
+			want:          "body_size_limit: 1GiB\n",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			pmon := &PodMonitoring{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "ns1",
+					Name:      "large",
+				},
+				Spec: PodMonitoringSpec{
+					Endpoints: []ScrapeEndpoint{
+						{
+							Port:          intstr.FromString("metrics"),
+							Interval:      "10s",
+							BodySizeLimit: c.bodySizeLimit,
+						},
+					},
+				},
+			}
+			scrapeCfgs, err := pmon.ScrapeConfigs("test_project", "test_location", "test_cluster")
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := yaml.Marshal(scrapeCfgs[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got string
+			for _, line := range strings.SplitAfter(string(b), "\n") {
+				if strings.HasPrefix(line, "body_size_limit:") {
+					got = line
+				}
+			}
+			if got != c.want {
+				t.Errorf("expected %q, got %q", c.want, got)
+			}
+		})
+	}
+}
+
+func TestPodMonitoring_PortListScrapeConfig(t *testing.T) {
+	pmon := &PodMonitoring{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns1",
+			Name:      "sharded",
+		},
+		Spec: PodMonitoringSpec{
+			Endpoints: []ScrapeEndpoint{
+				{
+					Port:     intstr.FromString("shard-*, web,9090"),
+					Path:     "/stats",
+					Interval: "10s",
+				},
+			},
+		},
+	}
+	scrapeCfgs, err := pmon.ScrapeConfigs("test_project", "test_location", "test_cluster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var jobs []string
+	for _, sc := range scrapeCfgs {
+		jobs = append(jobs, sc.JobName)
+		if sc.MetricsPath != "/stats" {
+			t.Errorf("expected metrics path %q for job %q, got %q", "/stats", sc.JobName, sc.MetricsPath)
+		}
+	}
+	wantJobs := []string{
+		"PodMonitoring/ns1/sharded/shard-.*",
+		"PodMonitoring/ns1/sharded/web",
+		"PodMonitoring/ns1/sharded/9090",
+	}
+	if diff := cmp.Diff(wantJobs, jobs); diff != "" {
+		t.Fatalf("unexpected jobs (-want, +got): %s", diff)
+	}
+
+	// The glob only keeps targets of the matching port names.
+	var portFilter *relabel.Config
+	for _, rc := range scrapeCfgs[0].RelabelConfigs {
+		if rc.Action == relabel.Keep && len(rc.SourceLabels) == 1 && rc.SourceLabels[0] == "__meta_kubernetes_pod_container_port_name" {
+			portFilter = rc
+		}
+	}
+	if portFilter == nil {
+		t.Fatal("expected port name filter")
+	}
+	for port, want := range map[string]bool{
+		"shard-0":  true,
+		"shard-12": true,
+		"shard":    false,
+		"web":      false,
+		"shards-0": false,
+	} {
+		if got := portFilter.Regex.MatchString(port); got != want {
+			t.Errorf("expected match of port %q to be %v, got %v", port, want, got)
+		}
+	}
+}
+
+func TestPodMonitoring_SinglePortRegexScrapeConfig(t *testing.T) {
+	// A single port is matched as a regular expression, even if it looks like a glob.
+	pmon := &PodMonitoring{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns1",
+			Name:      "app",
+		},
+		Spec: PodMonitoringSpec{
+			Endpoints: []ScrapeEndpoint{
+				{
+					Port:     intstr.FromString("web*"),
+					Interval: "10s",
+				},
+			},
+		},
+	}
+	scrapeCfgs, err := pmon.ScrapeConfigs("test_project", "test_location", "test_cluster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scrapeCfgs) != 1 {
+		t.Fatalf("expected 1 scrape config, got %d", len(scrapeCfgs))
+	}
+	if want := "PodMonitoring/ns1/app/web*"; scrapeCfgs[0].JobName != want {
+		t.Errorf("expected job %q, got %q", want, scrapeCfgs[0].JobName)
+	}
+	var portFilter *relabel.Config
+	for _, rc := range scrapeCfgs[0].RelabelConfigs {
+		if rc.Action == relabel.Keep && len(rc.SourceLabels) == 1 && rc.SourceLabels[0] == "__meta_kubernetes_pod_container_port_name" {
+			portFilter = rc
+		}
+	}
+	if portFilter == nil {
+		t.Fatal("expected port name filter")
+	}
+	for port, want := range map[string]bool{
+		"we":    true,
+		"web":   true,
+		"webbb": true,
+		"web-0": false,
+		"webs":  false,
+	} {
+		if got := portFilter.Regex.MatchString(port); got != want {
+			t.Errorf("expected match of port %q to be %v, got %v", port, want, got)
+		}
+	}
+}
+
+func TestPodMonitoring_MonitoringNameLabelScrapeConfig(t *testing.T) {
+	pmon := &PodMonitoring{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns1",
+			Name:      "app",
+		},
+		Spec: PodMonitoringSpec{
+			Selector: metav1.LabelSelector{
+				MatchLabels: map[string]string{"app": "web"},
+			},
+			Endpoints: []ScrapeEndpoint{
+				{
+					Port:                intstr.FromString("metrics"),
+					Interval:            "10s",
+					MonitoringNameLabel: true,
+				},
+			},
+		},
+	}
+	scrapeCfgs, err := pmon.ScrapeConfigs("test_project", "test_location", "test_cluster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+
+	for _, sc := range scrapeCfgs {
+		b, err := yaml.Marshal(sc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, string(b))
+	}
+	want := []string{
+		`job_name: PodMonitoring/ns1/app/metrics
+honor_timestamps: false
+scrape_interval: 10s
+scrape_timeout: 10s
+metrics_path: /metrics
+follow_redirects: true
+enable_http2: true
+relabel_configs:
+- source_labels: [__meta_kubernetes_namespace]
+  regex: ns1
+  action: keep
+- source_labels: [__meta_kubernetes_pod_label_app]
+  regex: web
+  action: keep
+- source_labels: [__meta_kubernetes_namespace]
+  target_label: namespace
+  action: replace
+- target_label: job
+  replacement: app
+  action: replace
+- source_labels: [__meta_kubernetes_pod_phase]
+  regex: (Failed|Succeeded)
+  action: drop
+- target_label: project_id
+  replacement: test_project
+  action: replace
+- target_label: location
+  replacement: test_location
+  action: replace
+- target_label: cluster
+  replacement: test_cluster
+  action: replace
+- source_labels: [__meta_kubernetes_pod_name]
+  target_label: __tmp_instance
+  action: replace
+- source_labels: [__meta_kubernetes_pod_controller_kind, __meta_kubernetes_pod_node_name]
+  regex: DaemonSet;(.*)
+  target_label: __tmp_instance
+  replacement: $1
+  action: replace
+- source_labels: [__meta_kubernetes_pod_container_port_name]
+  regex: metrics
+  action: keep
+- source_labels: [__tmp_instance, __meta_kubernetes_pod_container_port_name]
+  regex: (.+);(.+)
+  target_label: instance
+  replacement: $1:$2
+  action: replace
+- target_label: monitoring_name
+  replacement: PodMonitoring/ns1/app/metrics
+  action: replace
+kubernetes_sd_configs:
+- role: pod
+  kubeconfig_file: ""
+  follow_redirects: true
+  enable_http2: true
+  selectors:
+  - role: pod
+    field: spec.nodeName=$(NODE_NAME)
+`,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("unexpected scrape config YAML (-want, +got): %s", diff)
+	}
+
+	// The label is not set by default.
+	pmon.Spec.Endpoints[0].MonitoringNameLabel = false
+	scrapeCfgs, err = pmon.ScrapeConfigs("test_project", "test_location", "test_cluster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rc := range scrapeCfgs[0].RelabelConfigs {
+		if rc.TargetLabel == "monitoring_name" {
+			t.Errorf("unexpected relabeling rule onto monitoring_name: %v", rc)
+		}
+	}
+}
+
+func TestPodMonitoring_RelabelingScrapeConfig(t *testing.T) {
+	pmon := &PodMonitoring{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns1",
+			Name:      "app",
+		},
+		Spec: PodMonitoringSpec{
+			Selector: metav1.LabelSelector{
+				MatchLabels: map[string]string{"app": "web"},
+			},
+			Endpoints: []ScrapeEndpoint{
+				{
+					Port:     intstr.FromString("metrics"),
+					Interval: "10s",
+					Relabeling: []RelabelingRule{
+						{
+							SourceLabels: []string{"__meta_kubernetes_pod_annotation_example_com_scrape"},
+							Regex:        "false",
+							Action:       "drop",
+						},
+						{
+							SourceLabels: []string{"__meta_kubernetes_pod_label_team"},
+							TargetLabel:  "team",
+						},
+					},
+				},
+			},
+			TargetLabels: TargetLabels{
+				FromPod: []LabelMapping{{From: "version"}},
+			},
+		},
+	}
+	scrapeCfgs, err := pmon.ScrapeConfigs("test_project", "test_location", "test_cluster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scrapeCfgs) != 1 {
+		t.Fatalf("expected 1 scrape config, got %d", len(scrapeCfgs))
+	}
+	// The rules are applied after the target labels.
+	rcfgs := scrapeCfgs[0].RelabelConfigs
+	b, err := yaml.Marshal(rcfgs[len(rcfgs)-3:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `- source_labels: [__meta_kubernetes_pod_label_version]
+  target_label: version
+  action: replace
+- source_labels: [__meta_kubernetes_pod_annotation_example_com_scrape]
+  regex: "false"
+  action: drop
+- source_labels: [__meta_kubernetes_pod_label_team]
+  target_label: team
+`
+	if diff := cmp.Diff(want, string(b)); diff != "" {
+		t.Fatalf("unexpected relabel configs (-want, +got): %s", diff)
+	}
+}
+
+func TestPodMonitoring_InstanceLabelFrom(t *testing.T) {
+	cases := []struct {
+		desc string
+		from string
+		port intstr.IntOrString
+		want string
+	}{
+		{
+			desc: "default",
+			port: intstr.FromString("metrics"),
+			want: `- source_labels: [__meta_kubernetes_pod_name]
+  target_label: __tmp_instance
+  action: replace
+- source_labels: [__meta_kubernetes_pod_controller_kind, __meta_kubernetes_pod_node_name]
+  regex: DaemonSet;(.*)
+  target_label: __tmp_instance
+  replacement: $1
+  action: replace
+- source_labels: [__tmp_instance, __meta_kubernetes_pod_container_port_name]
+  regex: (.+);(.+)
+  target_label: instance
+  replacement: $1:$2
+  action: replace
+`,
+		},
+		{
+			desc: "pod name",
+			from: "pod",
+			port: intstr.FromString("metrics"),
+			want: `- source_labels: [__meta_kubernetes_pod_name]
+  target_label: __tmp_instance
+  action: replace
+- source_labels: [__meta_kubernetes_pod_controller_kind, __meta_kubernetes_pod_node_name]
+  regex: DaemonSet;(.*)
+  target_label: __tmp_instance
+  replacement: $1
+  action: replace
+- source_labels: [__meta_kubernetes_pod_name]
+  target_label: __tmp_instance
+  action: replace
+- source_labels: [__tmp_instance, __meta_kubernetes_pod_container_port_name]
+  regex: (.+);(.+)
+  target_label: instance
+  replacement: $1:$2
+  action: replace
+`,
+		},
+		{
+			desc: "pod IP",
+			from: "pod_ip",
+			port: intstr.FromString("metrics"),
+			want: `- source_labels: [__meta_kubernetes_pod_name]
+  target_label: __tmp_instance
+  action: replace
+- source_labels: [__meta_kubernetes_pod_controller_kind, __meta_kubernetes_pod_node_name]
+  regex: DaemonSet;(.*)
+  target_label: __tmp_instance
+  replacement: $1
+  action: replace
+- source_labels: [__meta_kubernetes_pod_ip]
+  target_label: __tmp_instance
+  action: replace
+- source_labels: [__tmp_instance, __meta_kubernetes_pod_container_port_name]
+  regex: (.+);(.+)
+  target_label: instance
+  replacement: $1:$2
+  action: replace
+`,
+		},
+		{
+			desc: "pod IP with port number",
+			from: "pod_ip",
+			port: intstr.FromInt(8080),
+			want: `- source_labels: [__meta_kubernetes_pod_name]
+  target_label: __tmp_instance
+  action: replace
+- source_labels: [__meta_kubernetes_pod_controller_kind, __meta_kubernetes_pod_node_name]
+  regex: DaemonSet;(.*)
+  target_label: __tmp_instance
+  replacement: $1
+  action: replace
+- source_labels: [__meta_kubernetes_pod_ip]
+  target_label: __tmp_instance
+  action: replace
+- source_labels: [__tmp_instance]
+  target_label: instance
+  replacement: $1:8080
+  action: replace
+`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			pmon := &PodMonitoring{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "ns1",
+					Name:      "app",
+				},
+				Spec: PodMonitoringSpec{
+					Endpoints: []ScrapeEndpoint{{
+						Port:              c.port,
+						Interval:          "10s",
+						InstanceLabelFrom: c.from,
+					}},
+				},
+			}
+			scrapeCfgs, err := pmon.ScrapeConfigs("test_project", "test_location", "test_cluster")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var rcfgs []*relabel.Config
+			for _, rc := range scrapeCfgs[0].RelabelConfigs {
+				if rc.TargetLabel == "__tmp_instance" || rc.TargetLabel == "instance" {
+					rcfgs = append(rcfgs, rc)
+				}
+			}
+			b, err := yaml.Marshal(rcfgs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(c.want, string(b)); diff != "" {
+				t.Errorf("unexpected instance relabel configs (-want, +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestExternalLabelRelabelConfigs(t *testing.T) {
+	cfgs, err := externalLabelRelabelConfigs(map[string]string{
+		"logical_cluster": "blue",
+		"cost_center":     "$1-${2}",
+	}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := yaml.Marshal(cfgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The rules are sorted by label name.
+	want := `- target_label: cost_center
+  replacement: $$1-$${2}
+  action: replace
+- target_label: logical_cluster
+  replacement: blue
+  action: replace
+`
+	if diff := cmp.Diff(want, string(b)); diff != "" {
+		t.Errorf("unexpected relabel configs (-want, +got): %s", diff)
+	}
+
+	// Values are set verbatim once the collector loaded the rules.
+	var loaded []*relabel.Config
+	if err := yaml.Unmarshal(b, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	got, keep := relabel.Process(labels.FromStrings("job", "app"), loaded...)
+	if !keep {
+		t.Fatal("expected target to be kept")
+	}
+	if diff := cmp.Diff(labels.FromStrings("cost_center", "$1-${2}", "job", "app", "logical_cluster", "blue"), got); diff != "" {
+		t.Errorf("unexpected labels (-want, +got): %s", diff)
+	}
+}
+
+func TestClusterPodMonitoring_MonitoringNameLabel(t *testing.T) {
+	cmon := &ClusterPodMonitoring{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "app",
+		},
+		Spec: ClusterPodMonitoringSpec{
+			Endpoints: []ScrapeEndpoint{
+				{
+					Port:                intstr.FromInt(8080),
+					Interval:            "10s",
+					MonitoringNameLabel: true,
+					ProbeTargets:        []string{"example.com"},
+				},
+			},
+		},
+	}
+	scrapeCfgs, err := cmon.ScrapeConfigs("test_project", "test_location", "test_cluster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, rc := range scrapeCfgs[0].RelabelConfigs {
+		if rc.TargetLabel == "monitoring_name" {
+			got = append(got, rc.Replacement)
+		}
+	}
+	// Probe targets share the label of their endpoint.
+	if diff := cmp.Diff([]string{"ClusterPodMonitoring/app/8080"}, got); diff != "" {
+		t.Errorf("unexpected monitoring_name replacements (-want, +got): %s", diff)
+	}
+}
+
+func TestMaxMetricNameLengthRelabelConfig(t *testing.T) {
+	cfg, err := maxMetricNameLengthRelabelConfig(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		name string
+		keep bool
+	}{
+		{name: "up", keep: true},
+		{name: "abcdefghij", keep: true},
+		{name: "abcdefghijk", keep: false},
+		{name: "http_requests_total", keep: false},
+	} {
+		_, keep := relabel.Process(labels.FromStrings("__name__", c.name, "job", "foo"), cfg)
+		if keep != c.keep {
+			t.Errorf("expected keep=%v for metric %q, got %v", c.keep, c.name, keep)
+		}
+	}
+}
+
+func TestMetricFilterRelabelConfigs(t *testing.T) {
+	cfgs, err := metricFilterRelabelConfigs([]string{"http_.*", "up"}, []string{"http_.*_bucket", "process_.*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		name string
+		keep bool
+	}{
+		{name: "up", keep: true},
+		{name: "http_requests_total", keep: true},
+		{name: "http_request_duration_seconds_bucket", keep: false},
+		// Kept metrics are matched in full.
+		{name: "upstream_requests_total", keep: false},
+		{name: "go_goroutines", keep: false},
+	} {
+		_, keep := relabel.Process(labels.FromStrings("__name__", c.name, "job", "foo"), cfgs...)
+		if keep != c.keep {
+			t.Errorf("expected keep=%v for metric %q, got %v", c.keep, c.name, keep)
+		}
+	}
+
+	// Only drop rules keep all other metrics.
+	cfgs, err = metricFilterRelabelConfigs(nil, []string{"go_.*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfgs) != 1 || cfgs[0].Action != relabel.Drop {
+		t.Fatalf("expected a single drop rule, got %v", cfgs)
+	}
+	if _, keep := relabel.Process(labels.FromStrings("__name__", "up"), cfgs...); !keep {
+		t.Errorf("expected metric %q to be kept", "up")
 	}
 }
 
-func TestPodMonitoring_ScrapeConfig(t *testing.T) {
-	// Generate YAML for one complex scrape config and make sure everything
-	// adds up. This primarily verifies that everything is included and marshalling
-	// the generated config to YAML does not produce any bad configurations due to
-	// defaulting as the Prometheus structs are misconfigured in this regard in
-	// several places.
+func TestPodMonitoring_APIServerProxyScrapeConfig(t *testing.T) {
 	pmon := &PodMonitoring{
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace: "ns1",
@@ -631,52 +2689,18 @@ func TestPodMonitoring_ScrapeConfig(t *testing.T) {
 		Spec: PodMonitoringSpec{
 			Endpoints: []ScrapeEndpoint{
 				{
-					Port:     intstr.FromString("web"),
-					Interval: "10s",
-					MetricRelabeling: []RelabelingRule{
-						{
-							Action:       "replace",
-							SourceLabels: []string{"mlabel_1", "mlabel_2"},
-							TargetLabel:  "mlabel_3",
-						}, {
-							Action:       "hashmod",
-							SourceLabels: []string{"mlabel_1"},
-							Modulus:      3,
-							TargetLabel:  "__tmp_mod",
-						}, {
-							SourceLabels: []string{"mlabel_4"},
-							TargetLabel:  "mlabel_5",
-						}, {
-							Action:  "keep",
-							Regex:   "foo_.+",
-							Modulus: 3,
-						},
-					},
+					Port:           intstr.FromString("web"),
+					Scheme:         "https",
+					Interval:       "10s",
+					APIServerProxy: true,
 				},
 				{
-					Port:     intstr.FromInt(8080),
-					Interval: "10000ms",
-					Timeout:  "5s",
-					Path:     "/prometheus",
-					HTTPClientConfig: HTTPClientConfig{
-						ProxyConfig: ProxyConfig{
-							ProxyURL: "http://foo.bar/test",
-						},
-					},
-				},
-			},
-			TargetLabels: TargetLabels{
-				FromPod: []LabelMapping{
-					{From: "key1", To: "key2"},
-					{From: "key3"},
+					Port:           intstr.FromInt(8080),
+					Interval:       "10s",
+					Path:           "/prometheus",
+					APIServerProxy: true,
 				},
 			},
-			Limits: &ScrapeLimits{
-				Samples:          1,
-				Labels:           2,
-				LabelNameLength:  3,
-				LabelValueLength: 4,
-			},
 		},
 	}
 	scrapeCfgs, err := pmon.ScrapeConfigs("test_project", "test_location", "test_cluster")
@@ -698,10 +2722,13 @@ honor_timestamps: false
 scrape_interval: 10s
 scrape_timeout: 10s
 metrics_path: /metrics
-sample_limit: 1
-label_limit: 2
-label_name_length_limit: 3
-label_value_length_limit: 4
+scheme: https
+authorization:
+  type: Bearer
+  credentials_file: /var/run/secrets/kubernetes.io/serviceaccount/token
+tls_config:
+  ca_file: /var/run/secrets/kubernetes.io/serviceaccount/ca.crt
+  insecure_skip_verify: false
 follow_redirects: true
 enable_http2: true
 relabel_configs:
@@ -742,25 +2769,14 @@ relabel_configs:
   target_label: instance
   replacement: $1:$2
   action: replace
-- source_labels: [__meta_kubernetes_pod_label_key1]
-  target_label: key2
-  action: replace
-- source_labels: [__meta_kubernetes_pod_label_key3]
-  target_label: key3
+- source_labels: [__meta_kubernetes_namespace, __meta_kubernetes_pod_name, __meta_kubernetes_pod_container_port_number]
+  regex: (.+);(.+);(.+)
+  target_label: __metrics_path__
+  replacement: /api/v1/namespaces/$1/pods/https:$2:$3/proxy/metrics
   action: replace
-metric_relabel_configs:
-- source_labels: [mlabel_1, mlabel_2]
-  target_label: mlabel_3
+- target_label: __address__
+  replacement: kubernetes.default.svc:443
   action: replace
-- source_labels: [mlabel_1]
-  modulus: 3
-  target_label: __tmp_mod
-  action: hashmod
-- source_labels: [mlabel_4]
-  target_label: mlabel_5
-- regex: foo_.+
-  modulus: 3
-  action: keep
 kubernetes_sd_configs:
 - role: pod
   kubeconfig_file: ""
@@ -773,15 +2789,17 @@ kubernetes_sd_configs:
 		`job_name: PodMonitoring/ns1/name1/8080
 honor_timestamps: false
 scrape_interval: 10s
-scrape_timeout: 5s
+scrape_timeout: 10s
 metrics_path: /prometheus
-sample_limit: 1
-label_limit: 2
-label_name_length_limit: 3
-label_value_length_limit: 4
+scheme: https
+authorization:
+  type: Bearer
+  credentials_file: /var/run/secrets/kubernetes.io/serviceaccount/token
+tls_config:
+  ca_file: /var/run/secrets/kubernetes.io/serviceaccount/ca.crt
+  insecure_skip_verify: false
 follow_redirects: true
 enable_http2: true
-proxy_url: http://foo.bar/test
 relabel_configs:
 - source_labels: [__meta_kubernetes_namespace]
   regex: ns1
@@ -822,11 +2840,13 @@ relabel_configs:
   target_label: __address__
   replacement: $1:8080
   action: replace
-- source_labels: [__meta_kubernetes_pod_label_key1]
-  target_label: key2
+- source_labels: [__meta_kubernetes_namespace, __meta_kubernetes_pod_name]
+  regex: (.+);(.+)
+  target_label: __metrics_path__
+  replacement: /api/v1/namespaces/$1/pods/$2:8080/proxy/prometheus
   action: replace
-- source_labels: [__meta_kubernetes_pod_label_key3]
-  target_label: key3
+- target_label: __address__
+  replacement: kubernetes.default.svc:443
   action: replace
 kubernetes_sd_configs:
 - role: pod
@@ -843,6 +2863,341 @@ kubernetes_sd_configs:
 	}
 }
 
+// Dashboards rely on the exact set of target labels produced for PodMonitorings with default
+// settings. This test documents them by applying the generated relabeling rules to discovered
+// target candidates like the collector does.
+func TestPodMonitoring_TargetLabels(t *testing.T) {
+	discovered := map[string]string{
+		"__address__":                               "10.0.0.1:8080",
+		"__meta_kubernetes_namespace":               "gmp-test",
+		"__meta_kubernetes_pod_name":                "example-7d9c4-x2x7j",
+		"__meta_kubernetes_pod_uid":                 "5c2a8a1e-6f0b-4f5e-9d0c-2f1b7e3a9c41",
+		"__meta_kubernetes_pod_ip":                  "10.0.0.1",
+		"__meta_kubernetes_pod_node_name":           "node-1",
+		"__meta_kubernetes_pod_controller_kind":     "ReplicaSet",
+		"__meta_kubernetes_pod_container_name":      "app",
+		"__meta_kubernetes_pod_container_port_name": "metrics",
+		"__meta_kubernetes_pod_phase":               "Running",
+		"__meta_kubernetes_pod_label_app":           "example",
+		"__meta_kubernetes_pod_labelpresent_app":    "true",
+	}
+	cases := []struct {
+		desc       string
+		port       intstr.IntOrString
+		controller string
+		metadata   []string
+		want       map[string]string
+	}{
+		{
+			desc: "named port",
+			port: intstr.FromString("metrics"),
+			want: map[string]string{
+				"project_id": "test-proj",
+				"location":   "test-loc",
+				"cluster":    "test-cluster",
+				"namespace":  "gmp-test",
+				"job":        "example",
+				"instance":   "example-7d9c4-x2x7j:metrics",
+				"pod":        "example-7d9c4-x2x7j",
+				"container":  "app",
+			},
+		},
+		{
+			// The container is ambiguous for numeric ports and hence not set.
+			desc: "numeric port",
+			port: intstr.FromInt(8080),
+			want: map[string]string{
+				"project_id": "test-proj",
+				"location":   "test-loc",
+				"cluster":    "test-cluster",
+				"namespace":  "gmp-test",
+				"job":        "example",
+				"instance":   "example-7d9c4-x2x7j:8080",
+				"pod":        "example-7d9c4-x2x7j",
+			},
+		},
+		{
+			// Pods of DaemonSets are identified by their node in the instance label.
+			desc:       "DaemonSet pod",
+			port:       intstr.FromString("metrics"),
+			controller: "DaemonSet",
+			want: map[string]string{
+				"project_id": "test-proj",
+				"location":   "test-loc",
+				"cluster":    "test-cluster",
+				"namespace":  "gmp-test",
+				"job":        "example",
+				"instance":   "node-1:metrics",
+				"pod":        "example-7d9c4-x2x7j",
+				"container":  "app",
+			},
+		},
+		{
+			// The pod UID disambiguates DaemonSet pods on the same node.
+			desc:       "pod UID",
+			port:       intstr.FromString("metrics"),
+			controller: "DaemonSet",
+			metadata:   []string{"pod_uid"},
+			want: map[string]string{
+				"project_id": "test-proj",
+				"location":   "test-loc",
+				"cluster":    "test-cluster",
+				"namespace":  "gmp-test",
+				"job":        "example",
+				"instance":   "node-1:metrics",
+				"pod_uid":    "5c2a8a1e-6f0b-4f5e-9d0c-2f1b7e3a9c41",
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			// The default set by the CRD.
+			metadata := []string{"pod", "container"}
+			if c.metadata != nil {
+				metadata = c.metadata
+			}
+			pm := &PodMonitoring{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "gmp-test",
+					Name:      "example",
+				},
+				Spec: PodMonitoringSpec{
+					Selector: metav1.LabelSelector{
+						MatchLabels: map[string]string{"app": "example"},
+					},
+					Endpoints: []ScrapeEndpoint{{
+						Port:     c.port,
+						Interval: "10s",
+					}},
+					TargetLabels: TargetLabels{
+						Metadata: &metadata,
+					},
+				},
+			}
+			cfgs, err := pm.ScrapeConfigs("test-proj", "test-loc", "test-cluster")
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Round-trip the config to apply the defaults of the relabeling rules.
+			b, err := yaml.Marshal(cfgs[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			var cfg promconfig.ScrapeConfig
+			if err := yaml.Unmarshal(b, &cfg); err != nil {
+				t.Fatal(err)
+			}
+			target := labels.FromMap(discovered)
+			if c.controller != "" {
+				target = labels.NewBuilder(target).Set("__meta_kubernetes_pod_controller_kind", c.controller).Labels()
+			}
+			res, keep := relabel.Process(target, cfg.RelabelConfigs...)
+			if !keep {
+				t.Fatal("target unexpectedly dropped")
+			}
+			// Like Prometheus, drop internal labels from the final target labels.
+			got := map[string]string{}
+			res.Range(func(l labels.Label) {
+				if !strings.HasPrefix(l.Name, prommodel.ReservedLabelPrefix) {
+					got[l.Name] = l.Value
+				}
+			})
+			if diff := cmp.Diff(c.want, got); diff != "" {
+				t.Errorf("unexpected target labels (-want, +got): %s", diff)
+			}
+		})
+	}
+}
+
+// Pods with hostNetwork enabled share the network namespace of their node. Their pod IP is
+// the node IP and their container ports are host ports. This test documents that their
+// targets are scraped at the node IP without special handling.
+func TestPodMonitoring_HostNetworkAddress(t *testing.T) {
+	pod := func(ip, address string) map[string]string {
+		return map[string]string{
+			"__address__":                               address,
+			"__meta_kubernetes_namespace":               "gmp-test",
+			"__meta_kubernetes_pod_name":                "example-7d9c4-x2x7j",
+			"__meta_kubernetes_pod_ip":                  ip,
+			"__meta_kubernetes_pod_host_ip":             "192.168.0.10",
+			"__meta_kubernetes_pod_node_name":           "node-1",
+			"__meta_kubernetes_pod_controller_kind":     "ReplicaSet",
+			"__meta_kubernetes_pod_container_name":      "app",
+			"__meta_kubernetes_pod_container_port_name": "metrics",
+			"__meta_kubernetes_pod_phase":               "Running",
+		}
+	}
+	cases := []struct {
+		desc       string
+		port       intstr.IntOrString
+		discovered map[string]string
+		want       map[string]string
+	}{
+		{
+			desc:       "pod network, named port",
+			port:       intstr.FromString("metrics"),
+			discovered: pod("10.0.0.1", "10.0.0.1:8080"),
+			want: map[string]string{
+				"__address__": "10.0.0.1:8080",
+				"instance":    "example-7d9c4-x2x7j:metrics",
+			},
+		},
+		{
+			desc:       "pod network, numeric port",
+			port:       intstr.FromInt(9090),
+			discovered: pod("10.0.0.1", "10.0.0.1:8080"),
+			want: map[string]string{
+				"__address__": "10.0.0.1:9090",
+				"instance":    "example-7d9c4-x2x7j:9090",
+			},
+		},
+		{
+			desc:       "host network, named port",
+			port:       intstr.FromString("metrics"),
+			discovered: pod("192.168.0.10", "192.168.0.10:8080"),
+			want: map[string]string{
+				"__address__": "192.168.0.10:8080",
+				"instance":    "example-7d9c4-x2x7j:metrics",
+			},
+		},
+		{
+			desc:       "host network, numeric port",
+			port:       intstr.FromInt(9090),
+			discovered: pod("192.168.0.10", "192.168.0.10:8080"),
+			want: map[string]string{
+				"__address__": "192.168.0.10:9090",
+				"instance":    "example-7d9c4-x2x7j:9090",
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			pm := &PodMonitoring{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "gmp-test",
+					Name:      "example",
+				},
+				Spec: PodMonitoringSpec{
+					Endpoints: []ScrapeEndpoint{{
+						Port:     c.port,
+						Interval: "10s",
+					}},
+				},
+			}
+			cfgs, err := pm.ScrapeConfigs("test-proj", "test-loc", "test-cluster")
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Round-trip the config to apply the defaults of the relabeling rules.
+			b, err := yaml.Marshal(cfgs[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			var cfg promconfig.ScrapeConfig
+			if err := yaml.Unmarshal(b, &cfg); err != nil {
+				t.Fatal(err)
+			}
+			res, keep := relabel.Process(labels.FromMap(c.discovered), cfg.RelabelConfigs...)
+			if !keep {
+				t.Fatal("target unexpectedly dropped")
+			}
+			got := map[string]string{
+				"__address__": res.Get("__address__"),
+				"instance":    res.Get("instance"),
+			}
+			if diff := cmp.Diff(c.want, got); diff != "" {
+				t.Errorf("unexpected target address (-want, +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestTargetLabelCollisionWarnings(t *testing.T) {
+	const (
+		daemonSetWarning = `targets of DaemonSet pods on the same node, e.g. during rolling updates, have identical labels unless the "pod" or "pod_uid" metadata label is set`
+		namespaceWarning = `targets of pods with the same name in different namespaces have identical labels unless the "namespace" or "pod_uid" metadata label is set`
+	)
+	endpoints := []ScrapeEndpoint{{Port: intstr.FromString("metrics"), Interval: "10s"}}
+	podMonitoring := func(metadata *[]string) *PodMonitoring {
+		return &PodMonitoring{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "gmp-test", Name: "example"},
+			Spec: PodMonitoringSpec{
+				Endpoints:    endpoints,
+				TargetLabels: TargetLabels{Metadata: metadata},
+			},
+		}
+	}
+	clusterPodMonitoring := func(metadata *[]string) *ClusterPodMonitoring {
+		return &ClusterPodMonitoring{
+			ObjectMeta: metav1.ObjectMeta{Name: "example"},
+			Spec: ClusterPodMonitoringSpec{
+				Endpoints:    endpoints,
+				TargetLabels: TargetLabels{Metadata: metadata},
+			},
+		}
+	}
+	cases := []struct {
+		desc string
+		obj  interface {
+			ValidateCreate() (admission.Warnings, error)
+		}
+		want admission.Warnings
+	}{
+		{
+			desc: "PodMonitoring defaults",
+			obj:  podMonitoring(&[]string{"pod", "container"}),
+		},
+		{
+			desc: "PodMonitoring pod UID",
+			obj:  podMonitoring(&[]string{"pod_uid"}),
+		},
+		{
+			desc: "PodMonitoring without pod",
+			obj:  podMonitoring(&[]string{"container"}),
+			want: admission.Warnings{daemonSetWarning},
+		},
+		{
+			desc: "PodMonitoring null metadata",
+			obj:  podMonitoring(nil),
+			want: admission.Warnings{daemonSetWarning},
+		},
+		{
+			desc: "ClusterPodMonitoring defaults",
+			obj:  clusterPodMonitoring(&[]string{"namespace", "pod", "container"}),
+		},
+		{
+			desc: "ClusterPodMonitoring pod UID",
+			obj:  clusterPodMonitoring(&[]string{"pod_uid"}),
+		},
+		{
+			desc: "ClusterPodMonitoring without namespace",
+			obj:  clusterPodMonitoring(&[]string{"pod"}),
+			want: admission.Warnings{namespaceWarning},
+		},
+		{
+			desc: "ClusterPodMonitoring null metadata",
+			obj:  clusterPodMonitoring(nil),
+			want: admission.Warnings{daemonSetWarning},
+		},
+		{
+			desc: "ClusterPodMonitoring empty metadata",
+			obj:  clusterPodMonitoring(&[]string{}),
+			want: admission.Warnings{daemonSetWarning, namespaceWarning},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			got, err := c.obj.ValidateCreate()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(c.want, got); diff != "" {
+				t.Errorf("unexpected warnings (-want, +got): %s", diff)
+			}
+		})
+	}
+}
+
 func TestClusterPodMonitoring_ScrapeConfig(t *testing.T) {
 	// Generate YAML for one complex scrape config and make sure everything
 	// adds up. This primarily verifies that everything is included and marshalling
@@ -876,10 +3231,14 @@ func TestClusterPodMonitoring_ScrapeConfig(t *testing.T) {
 					},
 				},
 				{
-					Port:     intstr.FromInt(8080),
-					Interval: "10000ms",
-					Timeout:  "5s",
-					Path:     "/prometheus",
+					Port:         intstr.FromInt(8080),
+					Interval:     "10000ms",
+					Timeout:      "5s",
+					Path:         "/prometheus",
+					MetricPrefix: "foo_",
+					ResourceAttributes: []LabelMapping{
+						{From: "service.name", To: "otel_service"},
+					},
 					HTTPClientConfig: HTTPClientConfig{
 						ProxyConfig: ProxyConfig{
 							ProxyURL: "http://foo.bar/test",
@@ -899,6 +3258,7 @@ func TestClusterPodMonitoring_ScrapeConfig(t *testing.T) {
 				LabelNameLength:  3,
 				LabelValueLength: 4,
 			},
+			RequireReady: true,
 		},
 	}
 	scrapeCfgs, err := pmon.ScrapeConfigs("test_project", "test_location", "test_cluster")
@@ -927,6 +3287,9 @@ label_value_length_limit: 4
 follow_redirects: true
 enable_http2: true
 relabel_configs:
+- source_labels: [__meta_kubernetes_namespace]
+  regex: kube-system
+  action: drop
 - source_labels: [__meta_kubernetes_namespace]
   target_label: namespace
   action: replace
@@ -936,6 +3299,9 @@ relabel_configs:
 - source_labels: [__meta_kubernetes_pod_phase]
   regex: (Failed|Succeeded)
   action: drop
+- source_labels: [__meta_kubernetes_pod_ready]
+  regex: "true"
+  action: keep
 - target_label: project_id
   replacement: test_project
   action: replace
@@ -1000,6 +3366,9 @@ follow_redirects: true
 enable_http2: true
 proxy_url: http://foo.bar/test
 relabel_configs:
+- source_labels: [__meta_kubernetes_namespace]
+  regex: kube-system
+  action: drop
 - source_labels: [__meta_kubernetes_namespace]
   target_label: namespace
   action: replace
@@ -1009,6 +3378,9 @@ relabel_configs:
 - source_labels: [__meta_kubernetes_pod_phase]
   regex: (Failed|Succeeded)
   action: drop
+- source_labels: [__meta_kubernetes_pod_ready]
+  regex: "true"
+  action: keep
 - target_label: project_id
   replacement: test_project
   action: replace
@@ -1042,6 +3414,17 @@ relabel_configs:
 - source_labels: [__meta_kubernetes_pod_label_key3]
   target_label: key3
   action: replace
+metric_relabel_configs:
+- source_labels: [service_name]
+  regex: (.+)
+  target_label: otel_service
+  action: replace
+- regex: service_name
+  action: labeldrop
+- source_labels: [__name__]
+  target_label: __name__
+  replacement: foo_$1
+  action: replace
 kubernetes_sd_configs:
 - role: pod
   kubeconfig_file: ""
@@ -1220,6 +3603,48 @@ func TestSetMonitoringCondition(t *testing.T) {
 			},
 			change: true,
 		},
+		{
+			doc: "additional condition type",
+			curr: &MonitoringStatus{
+				ObservedGeneration: 1,
+				Conditions: []MonitoringCondition{
+					{
+						Type:               ConfigurationCreateSuccess,
+						Status:             corev1.ConditionTrue,
+						LastUpdateTime:     before,
+						LastTransitionTime: before,
+					},
+				},
+			},
+			cond: &MonitoringCondition{
+				Type:    ScrapeTargetOverlap,
+				Status:  corev1.ConditionTrue,
+				Reason:  "TargetsOverlap",
+				Message: "overlapping targets",
+			},
+			generation: 1,
+			now:        now,
+			want: &MonitoringStatus{
+				ObservedGeneration: 1,
+				Conditions: []MonitoringCondition{
+					{
+						Type:               ConfigurationCreateSuccess,
+						Status:             corev1.ConditionTrue,
+						LastUpdateTime:     before,
+						LastTransitionTime: before,
+					},
+					{
+						Type:               ScrapeTargetOverlap,
+						Status:             corev1.ConditionTrue,
+						LastUpdateTime:     now,
+						LastTransitionTime: now,
+						Reason:             "TargetsOverlap",
+						Message:            "overlapping targets",
+					},
+				},
+			},
+			change: true,
+		},
 	}
 	for _, c := range cases {
 		t.Run(c.doc, func(t *testing.T) {
@@ -36,6 +36,10 @@ type Rules struct {
 	Status RulesStatus `json:"status"`
 }
 
+func (r *Rules) GetMonitoringStatus() *MonitoringStatus {
+	return &r.Status.MonitoringStatus
+}
+
 // RulesList is a list of Rules.
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 type RulesList struct {
@@ -66,6 +70,10 @@ type ClusterRules struct {
 	Status RulesStatus `json:"status"`
 }
 
+func (r *ClusterRules) GetMonitoringStatus() *MonitoringStatus {
+	return &r.Status.MonitoringStatus
+}
+
 // ClusterRulesList is a list of ClusterRules.
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 type ClusterRulesList struct {
@@ -95,6 +103,10 @@ type GlobalRules struct {
 	Status RulesStatus `json:"status"`
 }
 
+func (r *GlobalRules) GetMonitoringStatus() *MonitoringStatus {
+	return &r.Status.MonitoringStatus
+}
+
 // GlobalRulesList is a list of GlobalRules.
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 type GlobalRulesList struct {
@@ -107,6 +119,10 @@ type GlobalRulesList struct {
 type RulesSpec struct {
 	// A list of Prometheus rule groups.
 	Groups []RuleGroup `json:"groups"`
+	// Labels to attach to the results of all recording and alerting rules of the resource,
+	// e.g. to route alerts by team. Labels set on an individual rule take precedence.
+	// The labels that scope the rules to the cluster or namespace cannot be set.
+	Labels map[string]string `json:"labels,omitempty"`
 }
 
 // RuleGroup declares rules in the Prometheus format:
@@ -143,5 +159,5 @@ type Rule struct {
 
 // RulesStatus contains status information for a Rules resource.
 type RulesStatus struct {
-	// TODO: add status information.
+	MonitoringStatus `json:",inline"`
 }
@@ -22,7 +22,9 @@ package v1
 import (
 	model "github.com/prometheus/common/model"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
+	intstr "k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
@@ -78,6 +80,11 @@ func (in *AlertmanagerEndpoints) DeepCopy() *AlertmanagerEndpoints {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Auth) DeepCopyInto(out *Auth) {
 	*out = *in
+	if in.ServiceAccountToken != nil {
+		in, out := &in.ServiceAccountToken, &out.ServiceAccountToken
+		*out = new(ServiceAccountToken)
+		**out = **in
+	}
 	return
 }
 
@@ -115,6 +122,11 @@ func (in *Authorization) DeepCopy() *Authorization {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BasicAuth) DeepCopyInto(out *BasicAuth) {
 	*out = *in
+	if in.UsernameSecret != nil {
+		in, out := &in.UsernameSecret, &out.UsernameSecret
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -193,6 +205,11 @@ func (in *ClusterPodMonitoringList) DeepCopyObject() runtime.Object {
 func (in *ClusterPodMonitoringSpec) DeepCopyInto(out *ClusterPodMonitoringSpec) {
 	*out = *in
 	in.Selector.DeepCopyInto(&out.Selector)
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Endpoints != nil {
 		in, out := &in.Endpoints, &out.Endpoints
 		*out = make([]ScrapeEndpoint, len(*in))
@@ -206,11 +223,39 @@ func (in *ClusterPodMonitoringSpec) DeepCopyInto(out *ClusterPodMonitoringSpec)
 		*out = new(ScrapeLimits)
 		**out = **in
 	}
+	if in.LabelOverrides != nil {
+		in, out := &in.LabelOverrides, &out.LabelOverrides
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ExternalLabels != nil {
+		in, out := &in.ExternalLabels, &out.ExternalLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	if in.FilterRunning != nil {
 		in, out := &in.FilterRunning, &out.FilterRunning
 		*out = new(bool)
 		**out = **in
 	}
+	if in.ExcludeNamespaces != nil {
+		in, out := &in.ExcludeNamespaces, &out.ExcludeNamespaces
+		*out = new([]string)
+		if **in != nil {
+			in, out := *in, *out
+			*out = make([]string, len(*in))
+			copy(*out, *in)
+		}
+	}
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -230,7 +275,7 @@ func (in *ClusterRules) DeepCopyInto(out *ClusterRules) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
@@ -296,6 +341,13 @@ func (in *CollectionSpec) DeepCopyInto(out *CollectionSpec) {
 		}
 	}
 	in.Filter.DeepCopyInto(&out.Filter)
+	if in.ExportRelabeling != nil {
+		in, out := &in.ExportRelabeling, &out.ExportRelabeling
+		*out = make([]RelabelingRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.Credentials != nil {
 		in, out := &in.Credentials, &out.Credentials
 		*out = new(corev1.SecretKeySelector)
@@ -306,6 +358,26 @@ func (in *CollectionSpec) DeepCopyInto(out *CollectionSpec) {
 		*out = new(KubeletScraping)
 		**out = **in
 	}
+	if in.SelfMonitoring != nil {
+		in, out := &in.SelfMonitoring, &out.SelfMonitoring
+		*out = new(SelfMonitoring)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PodDisruptionBudget != nil {
+		in, out := &in.PodDisruptionBudget, &out.PodDisruptionBudget
+		*out = new(CollectorPodDisruptionBudget)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ExportQueue != nil {
+		in, out := &in.ExportQueue, &out.ExportQueue
+		*out = new(ExportQueueConfig)
+		**out = **in
+	}
+	if in.CollectorLabel != nil {
+		in, out := &in.CollectorLabel, &out.CollectorLabel
+		*out = new(CollectorLabel)
+		**out = **in
+	}
 	return
 }
 
@@ -319,6 +391,48 @@ func (in *CollectionSpec) DeepCopy() *CollectionSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CollectorLabel) DeepCopyInto(out *CollectorLabel) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CollectorLabel.
+func (in *CollectorLabel) DeepCopy() *CollectorLabel {
+	if in == nil {
+		return nil
+	}
+	out := new(CollectorLabel)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CollectorPodDisruptionBudget) DeepCopyInto(out *CollectorPodDisruptionBudget) {
+	*out = *in
+	if in.MinAvailable != nil {
+		in, out := &in.MinAvailable, &out.MinAvailable
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	if in.MaxUnavailable != nil {
+		in, out := &in.MaxUnavailable, &out.MaxUnavailable
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CollectorPodDisruptionBudget.
+func (in *CollectorPodDisruptionBudget) DeepCopy() *CollectorPodDisruptionBudget {
+	if in == nil {
+		return nil
+	}
+	out := new(CollectorPodDisruptionBudget)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ConfigSpec) DeepCopyInto(out *ConfigSpec) {
 	*out = *in
@@ -356,13 +470,29 @@ func (in *ExportFilters) DeepCopy() *ExportFilters {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExportQueueConfig) DeepCopyInto(out *ExportQueueConfig) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExportQueueConfig.
+func (in *ExportQueueConfig) DeepCopy() *ExportQueueConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ExportQueueConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GlobalRules) DeepCopyInto(out *GlobalRules) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
@@ -423,17 +553,17 @@ func (in *HTTPClientConfig) DeepCopyInto(out *HTTPClientConfig) {
 	if in.Authorization != nil {
 		in, out := &in.Authorization, &out.Authorization
 		*out = new(Auth)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	if in.BasicAuth != nil {
 		in, out := &in.BasicAuth, &out.BasicAuth
 		*out = new(BasicAuth)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	if in.TLS != nil {
 		in, out := &in.TLS, &out.TLS
 		*out = new(TLS)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	if in.OAuth2 != nil {
 		in, out := &in.OAuth2, &out.OAuth2
@@ -441,6 +571,11 @@ func (in *HTTPClientConfig) DeepCopyInto(out *HTTPClientConfig) {
 		(*in).DeepCopyInto(*out)
 	}
 	out.ProxyConfig = in.ProxyConfig
+	if in.FollowRedirects != nil {
+		in, out := &in.FollowRedirects, &out.FollowRedirects
+		*out = new(bool)
+		**out = **in
+	}
 	return
 }
 
@@ -454,6 +589,22 @@ func (in *HTTPClientConfig) DeepCopy() *HTTPClientConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IstioTLS) DeepCopyInto(out *IstioTLS) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IstioTLS.
+func (in *IstioTLS) DeepCopy() *IstioTLS {
+	if in == nil {
+		return nil
+	}
+	out := new(IstioTLS)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *KubeletScraping) DeepCopyInto(out *KubeletScraping) {
 	*out = *in
@@ -628,6 +779,16 @@ func (in *ClusterNodeMonitoringSpec) DeepCopyInto(out *ClusterNodeMonitoringSpec
 	return
 }
 
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterNodeMonitoringSpec.
+func (in *ClusterNodeMonitoringSpec) DeepCopy() *ClusterNodeMonitoringSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterNodeMonitoringSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *OAuth2) DeepCopyInto(out *OAuth2) {
 	*out = *in
@@ -646,7 +807,7 @@ func (in *OAuth2) DeepCopyInto(out *OAuth2) {
 	if in.TLS != nil {
 		in, out := &in.TLS, &out.TLS
 		*out = new(TLS)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	out.ProxyConfig = in.ProxyConfig
 	return
@@ -674,7 +835,8 @@ func (in *OperatorConfig) DeepCopyInto(out *OperatorConfig) {
 		*out = new(ManagedAlertmanagerSpec)
 		(*in).DeepCopyInto(*out)
 	}
-	out.Features = in.Features
+	in.Features.DeepCopyInto(&out.Features)
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
@@ -729,10 +891,33 @@ func (in *OperatorConfigList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OperatorConfigStatus) DeepCopyInto(out *OperatorConfigStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]MonitoringCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OperatorConfigStatus.
+func (in *OperatorConfigStatus) DeepCopy() *OperatorConfigStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(OperatorConfigStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *OperatorFeatures) DeepCopyInto(out *OperatorFeatures) {
 	*out = *in
-	out.TargetStatus = in.TargetStatus
+	in.TargetStatus.DeepCopyInto(&out.TargetStatus)
 	out.Config = in.Config
 	return
 }
@@ -812,6 +997,11 @@ func (in *PodMonitoringList) DeepCopyObject() runtime.Object {
 func (in *PodMonitoringSpec) DeepCopyInto(out *PodMonitoringSpec) {
 	*out = *in
 	in.Selector.DeepCopyInto(&out.Selector)
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Endpoints != nil {
 		in, out := &in.Endpoints, &out.Endpoints
 		*out = make([]ScrapeEndpoint, len(*in))
@@ -825,6 +1015,20 @@ func (in *PodMonitoringSpec) DeepCopyInto(out *PodMonitoringSpec) {
 		*out = new(ScrapeLimits)
 		**out = **in
 	}
+	if in.LabelOverrides != nil {
+		in, out := &in.LabelOverrides, &out.LabelOverrides
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ExternalLabels != nil {
+		in, out := &in.ExternalLabels, &out.ExternalLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	if in.FilterRunning != nil {
 		in, out := &in.FilterRunning, &out.FilterRunning
 		*out = new(bool)
@@ -883,6 +1087,27 @@ func (in *ProxyConfig) DeepCopy() *ProxyConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *QueryLogSpec) DeepCopyInto(out *QueryLogSpec) {
+	*out = *in
+	if in.MaxSize != nil {
+		in, out := &in.MaxSize, &out.MaxSize
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new QueryLogSpec.
+func (in *QueryLogSpec) DeepCopy() *QueryLogSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(QueryLogSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RelabelingRule) DeepCopyInto(out *RelabelingRule) {
 	*out = *in
@@ -950,6 +1175,11 @@ func (in *RuleEvaluatorSpec) DeepCopyInto(out *RuleEvaluatorSpec) {
 		*out = new(corev1.SecretKeySelector)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.QueryLog != nil {
+		in, out := &in.QueryLog, &out.QueryLog
+		*out = new(QueryLogSpec)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -992,7 +1222,7 @@ func (in *Rules) DeepCopyInto(out *Rules) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
@@ -1057,6 +1287,13 @@ func (in *RulesSpec) DeepCopyInto(out *RulesSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	return
 }
 
@@ -1073,6 +1310,7 @@ func (in *RulesSpec) DeepCopy() *RulesSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RulesStatus) DeepCopyInto(out *RulesStatus) {
 	*out = *in
+	in.MonitoringStatus.DeepCopyInto(&out.MonitoringStatus)
 	return
 }
 
@@ -1129,6 +1367,10 @@ func (in *SampleTarget) DeepCopyInto(out *SampleTarget) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.LastScrapeTime != nil {
+		in, out := &in.LastScrapeTime, &out.LastScrapeTime
+		*out = (*in).DeepCopy()
+	}
 	return
 }
 
@@ -1161,6 +1403,11 @@ func (in *ScrapeEndpoint) DeepCopyInto(out *ScrapeEndpoint) {
 			(*out)[key] = outVal
 		}
 	}
+	if in.ProbeTargets != nil {
+		in, out := &in.ProbeTargets, &out.ProbeTargets
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.MetricRelabeling != nil {
 		in, out := &in.MetricRelabeling, &out.MetricRelabeling
 		*out = make([]RelabelingRule, len(*in))
@@ -1168,6 +1415,28 @@ func (in *ScrapeEndpoint) DeepCopyInto(out *ScrapeEndpoint) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.MetricKeep != nil {
+		in, out := &in.MetricKeep, &out.MetricKeep
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.MetricDrop != nil {
+		in, out := &in.MetricDrop, &out.MetricDrop
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Relabeling != nil {
+		in, out := &in.Relabeling, &out.Relabeling
+		*out = make([]RelabelingRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ResourceAttributes != nil {
+		in, out := &in.ResourceAttributes, &out.ResourceAttributes
+		*out = make([]LabelMapping, len(*in))
+		copy(*out, *in)
+	}
 	in.HTTPClientConfig.DeepCopyInto(&out.HTTPClientConfig)
 	return
 }
@@ -1186,6 +1455,10 @@ func (in *ScrapeEndpoint) DeepCopy() *ScrapeEndpoint {
 func (in *ScrapeEndpointStatus) DeepCopyInto(out *ScrapeEndpointStatus) {
 	*out = *in
 	in.LastUpdateTime.DeepCopyInto(&out.LastUpdateTime)
+	if in.LastScrapeTime != nil {
+		in, out := &in.LastScrapeTime, &out.LastScrapeTime
+		*out = (*in).DeepCopy()
+	}
 	if in.SampleGroups != nil {
 		in, out := &in.SampleGroups, &out.SampleGroups
 		*out = make([]SampleGroup, len(*in))
@@ -1286,9 +1559,53 @@ func (in *SecretOrConfigMap) DeepCopy() *SecretOrConfigMap {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SelfMonitoring) DeepCopyInto(out *SelfMonitoring) {
+	*out = *in
+	if in.ExternalLabels != nil {
+		in, out := &in.ExternalLabels, &out.ExternalLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SelfMonitoring.
+func (in *SelfMonitoring) DeepCopy() *SelfMonitoring {
+	if in == nil {
+		return nil
+	}
+	out := new(SelfMonitoring)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceAccountToken) DeepCopyInto(out *ServiceAccountToken) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceAccountToken.
+func (in *ServiceAccountToken) DeepCopy() *ServiceAccountToken {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceAccountToken)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TLS) DeepCopyInto(out *TLS) {
 	*out = *in
+	if in.Istio != nil {
+		in, out := &in.Istio, &out.Istio
+		*out = new(IstioTLS)
+		**out = **in
+	}
 	return
 }
 
@@ -1366,6 +1683,11 @@ func (in *TargetLabels) DeepCopy() *TargetLabels {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TargetStatusSpec) DeepCopyInto(out *TargetStatusSpec) {
 	*out = *in
+	if in.ReadyThreshold != nil {
+		in, out := &in.ReadyThreshold, &out.ReadyThreshold
+		*out = new(int32)
+		**out = **in
+	}
 	return
 }
 
@@ -0,0 +1,168 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	promconfig "github.com/prometheus/prometheus/config"
+	yaml "gopkg.in/yaml.v2"
+
+	monitoringv1 "github.com/GoogleCloudPlatform/prometheus-engine/pkg/operator/apis/monitoring/v1"
+)
+
+// baseScrapeConfigData is passed to the base scrape config template of every scrape config.
+type baseScrapeConfigData struct {
+	// The name of the scrape job, e.g. "PodMonitoring/my-namespace/my-app/metrics".
+	JobName string
+}
+
+// baseScrapeConfig is a parsed base scrape config template.
+type baseScrapeConfig struct {
+	tmpl *template.Template
+}
+
+// parseBaseScrapeConfig parses the base scrape config template and validates it by
+// rendering it for an example scrape job. It returns nil if the template is empty.
+func parseBaseScrapeConfig(text string) (*baseScrapeConfig, error) {
+	if text == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New("base").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base scrape config template: %w", err)
+	}
+	base := &baseScrapeConfig{tmpl: tmpl}
+	if _, err := base.render("PodMonitoring/example/example/metrics"); err != nil {
+		return nil, err
+	}
+	return base, nil
+}
+
+// render returns the fields of the base scrape config for the given scrape job.
+func (b *baseScrapeConfig) render(jobName string) (map[interface{}]interface{}, error) {
+	var buf bytes.Buffer
+	if err := b.tmpl.Execute(&buf, baseScrapeConfigData{JobName: jobName}); err != nil {
+		return nil, fmt.Errorf("render base scrape config: %w", err)
+	}
+	fields := map[interface{}]interface{}{}
+	if err := yaml.Unmarshal(buf.Bytes(), &fields); err != nil {
+		return nil, fmt.Errorf("invalid base scrape config: %w", err)
+	}
+	for k := range fields {
+		key, ok := k.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid base scrape config key %v", k)
+		}
+		// Target discovery and target labels are always defined by the generated scrape configs.
+		if key == "job_name" || key == "relabel_configs" || key == "static_configs" || strings.HasSuffix(key, "_sd_configs") {
+			return nil, fmt.Errorf("base scrape config must not set %q", key)
+		}
+	}
+	// Validate the fields and their values on their own.
+	rendered, err := yaml.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	var sc promconfig.ScrapeConfig
+	if err := yaml.UnmarshalStrict(append([]byte("job_name: base\n"), rendered...), &sc); err != nil {
+		return nil, fmt.Errorf("invalid base scrape config: %w", err)
+	}
+	if err := validateBaseMetricRelabeling(&sc); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// validateBaseMetricRelabeling ensures that the metric relabeling rules of the base scrape
+// config are subject to the same restrictions as those of the export relabeling.
+func validateBaseMetricRelabeling(sc *promconfig.ScrapeConfig) error {
+	var spec monitoringv1.CollectionSpec
+	for _, rc := range sc.MetricRelabelConfigs {
+		rule := monitoringv1.RelabelingRule{
+			TargetLabel: rc.TargetLabel,
+			Separator:   rc.Separator,
+			Replacement: rc.Replacement,
+			Modulus:     rc.Modulus,
+			Action:      string(rc.Action),
+		}
+		if rc.Regex.Regexp != nil {
+			rule.Regex = rc.Regex.String()
+		}
+		for _, l := range rc.SourceLabels {
+			rule.SourceLabels = append(rule.SourceLabels, string(l))
+		}
+		spec.ExportRelabeling = append(spec.ExportRelabeling, rule)
+	}
+	if _, err := spec.ExportRelabelConfigs(); err != nil {
+		return fmt.Errorf("invalid base scrape config: %w", err)
+	}
+	return nil
+}
+
+// apply returns the scrape config merged on top of the base scrape config.
+//
+// Fields set by the scrape config take precedence over those of the base, including fields
+// that are always set to their default value. Nested fields, such as the TLS configuration,
+// are merged individually. The metric relabeling rules of
+// the base are applied before those of the scrape config.
+func (b *baseScrapeConfig) apply(sc *promconfig.ScrapeConfig) (*promconfig.ScrapeConfig, error) {
+	base, err := b.render(sc.JobName)
+	if err != nil {
+		return nil, err
+	}
+	out, err := yaml.Marshal(sc)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[interface{}]interface{}{}
+	if err := yaml.Unmarshal(out, &fields); err != nil {
+		return nil, err
+	}
+	if rules, ok := base["metric_relabel_configs"].([]interface{}); ok {
+		own, _ := fields["metric_relabel_configs"].([]interface{})
+		fields["metric_relabel_configs"] = append(rules, own...)
+	}
+	mergeYAMLMaps(fields, base)
+
+	out, err = yaml.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	var result promconfig.ScrapeConfig
+	if err := yaml.UnmarshalStrict(out, &result); err != nil {
+		return nil, fmt.Errorf("invalid scrape config %q after applying base scrape config: %w", sc.JobName, err)
+	}
+	return &result, nil
+}
+
+// mergeYAMLMaps sets all fields of base in dst that dst does not set itself.
+func mergeYAMLMaps(dst, base map[interface{}]interface{}) {
+	for k, v := range base {
+		existing, ok := dst[k]
+		if !ok {
+			dst[k] = v
+			continue
+		}
+		dstMap, ok1 := existing.(map[interface{}]interface{})
+		baseMap, ok2 := v.(map[interface{}]interface{})
+		if ok1 && ok2 {
+			mergeYAMLMaps(dstMap, baseMap)
+		}
+	}
+}
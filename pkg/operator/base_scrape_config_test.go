@@ -0,0 +1,185 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	yaml "gopkg.in/yaml.v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	monitoringv1 "github.com/GoogleCloudPlatform/prometheus-engine/pkg/operator/apis/monitoring/v1"
+)
+
+func TestBaseScrapeConfigApply(t *testing.T) {
+	base, err := parseBaseScrapeConfig(`
+sample_limit: 1000
+scrape_timeout: 30s
+tls_config:
+  min_version: TLS12
+  server_name: base.example.com
+metric_relabel_configs:
+- source_labels: [__name__]
+  regex: go_.+
+  action: drop
+- target_label: scraped_by
+  replacement: "{{ .JobName }}"
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pm := &monitoringv1.PodMonitoring{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns1",
+			Name:      "app",
+		},
+		Spec: monitoringv1.PodMonitoringSpec{
+			Endpoints: []monitoringv1.ScrapeEndpoint{
+				{
+					Port:     intstr.FromString("metrics"),
+					Interval: "10s",
+					MetricRelabeling: []monitoringv1.RelabelingRule{
+						{Action: "keep", SourceLabels: []string{"__name__"}, Regex: "app_.+"},
+					},
+					HTTPClientConfig: monitoringv1.HTTPClientConfig{
+						TLS: &monitoringv1.TLS{ServerName: "app.example.com"},
+					},
+				},
+			},
+		},
+	}
+	scrapeCfgs, err := pm.ScrapeConfigs("test_project", "test_location", "test_cluster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged, err := base.apply(scrapeCfgs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only compare the fields affected by the base. The target relabeling is unchanged.
+	merged.RelabelConfigs = nil
+	merged.ServiceDiscoveryConfigs = nil
+
+	got, err := yaml.Marshal(merged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `job_name: PodMonitoring/ns1/app/metrics
+honor_timestamps: false
+scrape_interval: 10s
+scrape_timeout: 10s
+metrics_path: /metrics
+scheme: http
+sample_limit: 1000
+tls_config:
+  server_name: app.example.com
+  insecure_skip_verify: false
+  min_version: TLS12
+follow_redirects: true
+enable_http2: true
+metric_relabel_configs:
+- source_labels: [__name__]
+  separator: ;
+  regex: go_.+
+  replacement: $1
+  action: drop
+- separator: ;
+  regex: (.*)
+  target_label: scraped_by
+  replacement: PodMonitoring/ns1/app/metrics
+  action: replace
+- source_labels: [__name__]
+  separator: ;
+  regex: app_.+
+  replacement: $1
+  action: keep
+`
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("unexpected merged scrape config (-want, +got): %s", diff)
+	}
+}
+
+func TestParseBaseScrapeConfig(t *testing.T) {
+	cases := []struct {
+		desc string
+		base string
+		err  string
+	}{
+		{
+			desc: "empty",
+		},
+		{
+			desc: "valid",
+			base: "sample_limit: {{ if eq .JobName \"kubelet/cadvisor\" }}0{{ else }}1000{{ end }}",
+		},
+		{
+			desc: "invalid template",
+			base: "sample_limit: {{ .JobName",
+			err:  "invalid base scrape config template",
+		},
+		{
+			desc: "unknown template field",
+			base: "sample_limit: {{ .Namespace }}",
+			err:  "render base scrape config",
+		},
+		{
+			desc: "unknown field",
+			base: "sample_limits: 1000",
+			err:  "field sample_limits not found",
+		},
+		{
+			desc: "invalid value",
+			base: "scrape_timeout: soon",
+			err:  "invalid base scrape config",
+		},
+		{
+			desc: "job name",
+			base: "job_name: other",
+			err:  `base scrape config must not set "job_name"`,
+		},
+		{
+			desc: "target relabeling",
+			base: "relabel_configs: []",
+			err:  `base scrape config must not set "relabel_configs"`,
+		},
+		{
+			desc: "service discovery",
+			base: "kubernetes_sd_configs: []",
+			err:  `base scrape config must not set "kubernetes_sd_configs"`,
+		},
+		{
+			desc: "protected label",
+			base: "metric_relabel_configs: [{target_label: project_id, replacement: other}]",
+			err:  `cannot relabel with action "replace" onto protected label "project_id"`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			_, err := parseBaseScrapeConfig(c.base)
+			if c.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Fatalf("expected error containing %q, got %v", c.err, err)
+			}
+		})
+	}
+}
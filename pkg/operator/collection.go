@@ -19,12 +19,17 @@ import (
 	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"path"
+	"regexp"
+	"slices"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/config"
 	prommodel "github.com/prometheus/common/model"
 	promconfig "github.com/prometheus/prometheus/config"
@@ -35,21 +40,34 @@ import (
 	yaml "gopkg.in/yaml.v3"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/clock"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
 	"github.com/GoogleCloudPlatform/prometheus-engine/pkg/export"
 	monitoringv1 "github.com/GoogleCloudPlatform/prometheus-engine/pkg/operator/apis/monitoring/v1"
 )
 
-func setupCollectionControllers(op *Operator) error {
+var configRegenerationsCoalesced = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "prometheus_engine_config_regenerations_coalesced_total",
+	Help: "Number of collector configuration regenerations that were deferred and coalesced into a later one.",
+})
+
+func setupCollectionControllers(op *Operator, registry prometheus.Registerer) error {
+	if err := registry.Register(configRegenerationsCoalesced); err != nil {
+		return err
+	}
+
 	// The singleton OperatorConfig is the request object we reconcile against.
 	objRequest := reconcile.Request{
 		NamespacedName: types.NamespacedName{
@@ -73,8 +91,20 @@ func setupCollectionControllers(op *Operator) error {
 		name:      CollectionSecretName,
 	}
 
+	r := &collectionReconciler{
+		client: op.manager.GetClient(),
+		// Secrets referenced by PodMonitorings may live in any namespace and are thus
+		// not part of the cache.
+		secretReader: op.manager.GetAPIReader(),
+		opts:         op.opts,
+		clock:        clock.RealClock{},
+	}
+	if op.opts.EmitEvents {
+		r.events = newScrapeConfigEvents(op.manager.GetEventRecorderFor(NameOperator))
+	}
+
 	// Reconcile the generated Prometheus configuration that is used by all collectors.
-	err := ctrl.NewControllerManagedBy(op.manager).
+	b := ctrl.NewControllerManagedBy(op.manager).
 		Named("collector-config").
 		// Filter events without changes for all watches.
 		WithEventFilter(predicate.ResourceVersionChangedPredicate{}).
@@ -84,17 +114,26 @@ func setupCollectionControllers(op *Operator) error {
 			&monitoringv1.OperatorConfig{},
 			builder.WithPredicates(objFilterOperatorConfig),
 		).
-		// Any update to a PodMonitoring requires regenerating the config.
+		// Any update to a PodMonitoring requires regenerating the config. Annotations
+		// may pause or resume its reconciliation.
 		Watches(
 			&monitoringv1.PodMonitoring{},
 			enqueueConst(objRequest),
-			builder.WithPredicates(predicate.GenerationChangedPredicate{}),
+			builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{})),
 		).
-		// Any update to a ClusterPodMonitoring requires regenerating the config.
+		// Any update to a ClusterPodMonitoring requires regenerating the config. Annotations
+		// may pause or resume its reconciliation.
 		Watches(
 			&monitoringv1.ClusterPodMonitoring{},
 			enqueueConst(objRequest),
-			builder.WithPredicates(predicate.GenerationChangedPredicate{}),
+			builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{})),
+		).
+		// Namespaces and their labels determine the pods that ClusterPodMonitorings with
+		// a namespace selector scrape.
+		Watches(
+			&corev1.Namespace{},
+			enqueueConst(objRequest),
+			builder.WithPredicates(predicate.LabelChangedPredicate{}),
 		).
 		// Any update to a ClusterNodeMonitoring requires regenerating the config.
 		Watches(
@@ -120,9 +159,20 @@ func setupCollectionControllers(op *Operator) error {
 		Watches(
 			&corev1.Secret{},
 			enqueueConst(objRequest),
-			builder.WithPredicates(objFilterSecret)).
-		Complete(newCollectionReconciler(op.manager.GetClient(), op.opts))
-	if err != nil {
+			builder.WithPredicates(objFilterSecret))
+	if op.opts.ResolveScrapeSecrets {
+		// Changes of Secrets referenced by PodMonitorings must be copied into the collector secret.
+		b = b.Watches(
+			&corev1.Secret{},
+			handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []reconcile.Request {
+				if r.scrapeSecretReferenced(ctx, obj) {
+					return []reconcile.Request{objRequest}
+				}
+				return nil
+			}),
+		)
+	}
+	if err := b.Complete(r); err != nil {
 		return fmt.Errorf("create collector config controller: %w", err)
 	}
 	return nil
@@ -130,14 +180,25 @@ func setupCollectionControllers(op *Operator) error {
 
 type collectionReconciler struct {
 	client        client.Client
+	secretReader  client.Reader
 	opts          Options
+	clock         clock.Clock
 	statusUpdates []monitoringv1.MonitoringCRD
+	// Time of the last successful collector configuration update.
+	lastConfigUpdate time.Time
+	// Emits events on monitoring resources whose scrape configs fail to generate, if enabled.
+	events *scrapeConfigEvents
+	// Errors resolving the Secrets referenced by PodMonitorings, by their key. Set when
+	// updating the collector secret and reported when generating the collector config.
+	scrapeSecretErrors map[string]error
 }
 
 func newCollectionReconciler(c client.Client, opts Options) *collectionReconciler {
 	return &collectionReconciler{
-		client: c,
-		opts:   opts,
+		client:       c,
+		secretReader: c,
+		opts:         opts,
+		clock:        clock.RealClock{},
 	}
 }
 
@@ -147,6 +208,12 @@ func patchMonitoringStatus(ctx context.Context, kubeClient client.Client, obj cl
 		"conditions":         status.Conditions,
 		"observedGeneration": status.ObservedGeneration,
 	}
+	// A null value removes a previously reported hash.
+	var configHash interface{}
+	if status.GeneratedConfigHash != "" {
+		configHash = status.GeneratedConfigHash
+	}
+	patchStatus["generatedConfigHash"] = configHash
 	patchObject := map[string]interface{}{"status": patchStatus}
 
 	patchBytes, err := json.Marshal(patchObject)
@@ -167,12 +234,24 @@ func (r *collectionReconciler) Reconcile(ctx context.Context, req reconcile.Requ
 
 	var config monitoringv1.OperatorConfig
 	// Fetch OperatorConfig if it exists.
+	configExists := true
 	if err := r.client.Get(ctx, req.NamespacedName, &config); apierrors.IsNotFound(err) {
 		logger.Info("no operatorconfig created yet")
+		configExists = false
 	} else if err != nil {
 		return reconcile.Result{}, fmt.Errorf("get operatorconfig for incoming: %q: %w", req.String(), err)
 	}
 
+	// Coalesce rapid changes into a single update of the collectors. All events map onto the same
+	// request, so the requeue below picks up any changes that arrive in the meantime. The
+	// collector Secret, DaemonSet, and configuration are updated together so that the
+	// configuration never references Secret keys that are not written yet or vice versa.
+	if delay := r.configRegenerationDelay(); delay > 0 {
+		configRegenerationsCoalesced.Inc()
+		logger.V(1).Info("deferring collector config regeneration", "delay", delay)
+		return reconcile.Result{RequeueAfter: delay}, nil
+	}
+
 	if err := r.ensureCollectorSecrets(ctx, &config.Collection); err != nil {
 		return reconcile.Result{}, fmt.Errorf("ensure collector secrets: %w", err)
 	}
@@ -180,10 +259,18 @@ func (r *collectionReconciler) Reconcile(ctx context.Context, req reconcile.Requ
 	if err := r.ensureCollectorDaemonSet(ctx, &config.Collection); err != nil {
 		return reconcile.Result{}, fmt.Errorf("ensure collector daemon set: %w", err)
 	}
-
+	if err := r.ensureCollectorPodDisruptionBudget(ctx, config.Collection.PodDisruptionBudget); err != nil {
+		return reconcile.Result{}, fmt.Errorf("ensure collector pod disruption budget: %w", err)
+	}
+	if configExists {
+		if err := r.ensureMonitoringLimitCondition(ctx, &config); err != nil {
+			return reconcile.Result{}, fmt.Errorf("ensure monitoring limit condition: %w", err)
+		}
+	}
 	if err := r.ensureCollectorConfig(ctx, &config.Collection, config.Features.Config.Compression); err != nil {
 		return reconcile.Result{}, fmt.Errorf("ensure collector config: %w", err)
 	}
+	r.lastConfigUpdate = r.clock.Now()
 
 	// Reconcile any status updates.
 	for _, obj := range r.statusUpdates {
@@ -197,6 +284,15 @@ func (r *collectionReconciler) Reconcile(ctx context.Context, req reconcile.Requ
 	return reconcile.Result{}, nil
 }
 
+// configRegenerationDelay returns how long to wait until the collector configuration may be
+// regenerated again. It is zero or negative if it may be regenerated immediately.
+func (r *collectionReconciler) configRegenerationDelay() time.Duration {
+	if r.opts.ConfigRegenerationInterval <= 0 || r.lastConfigUpdate.IsZero() {
+		return 0
+	}
+	return r.opts.ConfigRegenerationInterval - r.clock.Since(r.lastConfigUpdate)
+}
+
 func (r *collectionReconciler) ensureCollectorSecrets(ctx context.Context, spec *monitoringv1.CollectionSpec) error {
 	secret := &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
@@ -219,6 +315,9 @@ func (r *collectionReconciler) ensureCollectorSecrets(ctx context.Context, spec
 		}
 		secret.Data[p] = b
 	}
+	if err := r.addScrapeSecrets(ctx, secret.Data); err != nil {
+		return err
+	}
 
 	if err := r.client.Update(ctx, secret); apierrors.IsNotFound(err) {
 		if err := r.client.Create(ctx, secret); err != nil {
@@ -230,6 +329,71 @@ func (r *collectionReconciler) ensureCollectorSecrets(ctx context.Context, spec
 	return nil
 }
 
+// errScrapeSecretsDisabled is reported on PodMonitorings that reference Secrets if the
+// operator does not resolve them.
+var errScrapeSecretsDisabled = errors.New("resolving Secrets referenced by PodMonitorings is not enabled in the operator")
+
+// addScrapeSecrets adds the secret keys referenced by PodMonitoring endpoints to the
+// collector secret data. PodMonitorings whose keys cannot be resolved are recorded in
+// scrapeSecretErrors and skipped so that a single misconfigured PodMonitoring does not
+// block collection for all others.
+func (r *collectionReconciler) addScrapeSecrets(ctx context.Context, data map[string][]byte) error {
+	logger, _ := logr.FromContext(ctx)
+
+	var podMons monitoringv1.PodMonitoringList
+	if err := r.client.List(ctx, &podMons); err != nil {
+		return fmt.Errorf("failed to list PodMonitorings: %w", err)
+	}
+	r.scrapeSecretErrors = map[string]error{}
+
+	for _, pm := range podMons.Items {
+		for _, sel := range scrapeSecrets(&pm) {
+			if !r.opts.ResolveScrapeSecrets {
+				r.scrapeSecretErrors[pm.GetKey()] = errScrapeSecretsDisabled
+				break
+			}
+			b, err := getSecretKeyBytes(ctx, r.secretReader, pm.Namespace, sel)
+			if err != nil {
+				logger.Error(err, "resolving basic auth username secret failed", "namespace", pm.Namespace, "name", pm.Name)
+				r.scrapeSecretErrors[pm.GetKey()] = fmt.Errorf("resolving basic auth username secret failed: %w", err)
+				break
+			}
+			data[pathForSelector(pm.Namespace, &monitoringv1.SecretOrConfigMap{Secret: sel})] = b
+		}
+	}
+	return nil
+}
+
+// scrapeSecrets returns the Secret keys referenced by the endpoints of the PodMonitoring.
+func scrapeSecrets(pm *monitoringv1.PodMonitoring) []*corev1.SecretKeySelector {
+	var res []*corev1.SecretKeySelector
+	for _, ep := range pm.Spec.Endpoints {
+		if basicAuth := ep.HTTPClientConfig.BasicAuth; basicAuth != nil && basicAuth.UsernameSecret != nil {
+			res = append(res, basicAuth.UsernameSecret)
+		}
+	}
+	return res
+}
+
+// scrapeSecretReferenced returns true if any PodMonitoring references the Secret.
+func (r *collectionReconciler) scrapeSecretReferenced(ctx context.Context, secret client.Object) bool {
+	logger, _ := logr.FromContext(ctx)
+
+	var podMons monitoringv1.PodMonitoringList
+	if err := r.client.List(ctx, &podMons, client.InNamespace(secret.GetNamespace())); err != nil {
+		logger.Error(err, "listing PodMonitorings for secret failed", "namespace", secret.GetNamespace(), "name", secret.GetName())
+		return false
+	}
+	for i := range podMons.Items {
+		for _, sel := range scrapeSecrets(&podMons.Items[i]) {
+			if sel.Name == secret.GetName() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // ensureCollectorDaemonSet populates the collector DaemonSet with operator-provided values.
 func (r *collectionReconciler) ensureCollectorDaemonSet(ctx context.Context, spec *monitoringv1.CollectionSpec) error {
 	logger, _ := logr.FromContext(ctx)
@@ -265,9 +429,26 @@ func (r *collectionReconciler) ensureCollectorDaemonSet(ctx context.Context, spe
 	if len(spec.Compression) > 0 && spec.Compression != monitoringv1.CompressionNone {
 		flags = append(flags, fmt.Sprintf("--export.compression=%s", spec.Compression))
 	}
+	if q := spec.ExportQueue; q != nil {
+		if q.Capacity > 0 {
+			flags = append(flags, fmt.Sprintf("--export.debug.shard-buffer-size=%d", q.Capacity))
+		}
+		if q.MaxSamplesPerSend > 0 {
+			flags = append(flags, fmt.Sprintf("--export.debug.batch-size=%d", q.MaxSamplesPerSend))
+		}
+		if q.MaxShards > 0 {
+			flags = append(flags, fmt.Sprintf("--export.debug.shard-count=%d", q.MaxShards))
+		}
+		if q.BatchSendDeadline != "" {
+			flags = append(flags, fmt.Sprintf("--export.debug.batch-delay=%s", q.BatchSendDeadline))
+		}
+	}
 
 	// Set EXTRA_ARGS envvar in Prometheus container.
 	for i, c := range ds.Spec.Template.Spec.Containers {
+		if c.Name == "config-reloader" {
+			ds.Spec.Template.Spec.Containers[i].Env = collectorPodNameEnv(c.Env, spec.CollectorLabel != nil)
+		}
 		if c.Name != "prometheus" {
 			continue
 		}
@@ -282,9 +463,89 @@ func (r *collectionReconciler) ensureCollectorDaemonSet(ctx context.Context, spe
 
 		ds.Spec.Template.Spec.Containers[i].Env = repl
 	}
+	// Restore the priority class of the deployed manifests once the override is removed.
+	priorityClassName := spec.PriorityClassName
+	if priorityClassName == "" {
+		priorityClassName = defaultCollectorPriorityClassName
+	}
+	ds.Spec.Template.Spec.PriorityClassName = priorityClassName
+	audiences, err := r.serviceAccountTokenAudiences(ctx)
+	if err != nil {
+		return err
+	}
+	setServiceAccountTokensVolume(&ds.Spec.Template.Spec, audiences)
+
 	return r.client.Update(ctx, &ds)
 }
 
+// collectorPodNameEnv adds the downward API environment variable holding the pod name,
+// which is referenced by the collector label, to the config-reloader environment or removes it.
+func collectorPodNameEnv(env []corev1.EnvVar, enabled bool) []corev1.EnvVar {
+	var repl []corev1.EnvVar
+	for _, ev := range env {
+		if ev.Name != collectorPodNameEnvVar {
+			repl = append(repl, ev)
+		}
+	}
+	if enabled {
+		repl = append(repl, corev1.EnvVar{
+			Name: collectorPodNameEnvVar,
+			ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{
+					APIVersion: "v1",
+					FieldPath:  "metadata.name",
+				},
+			},
+		})
+	}
+	return repl
+}
+
+// ensureCollectorPodDisruptionBudget creates or updates the collector PodDisruptionBudget
+// and deletes it if none is configured.
+func (r *collectionReconciler) ensureCollectorPodDisruptionBudget(ctx context.Context, spec *monitoringv1.CollectorPodDisruptionBudget) error {
+	pdb := &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      NameCollector,
+			Namespace: r.opts.OperatorNamespace,
+			Labels: map[string]string{
+				LabelAppName: NameCollector,
+			},
+		},
+	}
+	if spec == nil {
+		if err := r.client.Delete(ctx, pdb); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("delete collector pod disruption budget: %w", err)
+		}
+		return nil
+	}
+	pdbSpec := policyv1.PodDisruptionBudgetSpec{
+		MinAvailable:   spec.MinAvailable,
+		MaxUnavailable: spec.MaxUnavailable,
+		Selector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{
+				LabelAppName: NameCollector,
+			},
+		},
+	}
+	// PodDisruptionBudgets do not allow unconditional updates, so fetch the current version first.
+	err := r.client.Get(ctx, client.ObjectKeyFromObject(pdb), pdb)
+	if apierrors.IsNotFound(err) {
+		pdb.Spec = pdbSpec
+		if err := r.client.Create(ctx, pdb); err != nil {
+			return fmt.Errorf("create collector pod disruption budget: %w", err)
+		}
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("get collector pod disruption budget: %w", err)
+	}
+	pdb.Spec = pdbSpec
+	if err := r.client.Update(ctx, pdb); err != nil {
+		return fmt.Errorf("update collector pod disruption budget: %w", err)
+	}
+	return nil
+}
+
 func resolveLabels(opts Options, externalLabels map[string]string) (projectID string, location string, cluster string) {
 	// Prioritize OperatorConfig's external labels over operator's flags
 	// to be consistent with our export layer's priorities.
@@ -377,10 +638,19 @@ func (r *collectionReconciler) makeCollectorConfig(ctx context.Context, spec *mo
 	}
 
 	var err error
+	cfg.StorageConfig, err = makeStorageConfig(spec.OutOfOrderTimeWindow)
+	if err != nil {
+		return nil, err
+	}
 	cfg.ScrapeConfigs, err = makeKubeletScrapeConfigs(spec.KubeletScraping)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create kubelet scrape config: %w", err)
 	}
+	selfCfgs, err := makeSelfMonitoringScrapeConfigs(r.opts.OperatorNamespace, spec.SelfMonitoring)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create self-monitoring scrape config: %w", err)
+	}
+	cfg.ScrapeConfigs = append(cfg.ScrapeConfigs, selfCfgs...)
 
 	// Generate a separate scrape job for every endpoint in every PodMonitoring.
 	var (
@@ -391,6 +661,29 @@ func (r *collectionReconciler) makeCollectorConfig(ctx context.Context, spec *mo
 	if err := r.client.List(ctx, &podMons); err != nil {
 		return nil, fmt.Errorf("failed to list PodMonitorings: %w", err)
 	}
+	if err := r.client.List(ctx, &clusterPodMons); err != nil {
+		return nil, fmt.Errorf("failed to list ClusterPodMonitorings: %w", err)
+	}
+
+	// Detect monitorings that may scrape the same targets more than once.
+	var scopes []monitoringScope
+	for i := range podMons.Items {
+		scopes = append(scopes, podMonitoringScope(&podMons.Items[i]))
+	}
+	for i := range clusterPodMons.Items {
+		scopes = append(scopes, clusterPodMonitoringScope(&clusterPodMons.Items[i]))
+	}
+	overlaps := findTargetOverlaps(scopes)
+
+	// Monitorings with paused reconciliation keep their previously applied scrape configs.
+	var appliedCfgs, pausedCfgs []*promconfig.ScrapeConfig
+	if slices.ContainsFunc(podMons.Items, func(pm monitoringv1.PodMonitoring) bool { return reconcilePaused(&pm) }) ||
+		slices.ContainsFunc(clusterPodMons.Items, func(cm monitoringv1.ClusterPodMonitoring) bool { return reconcilePaused(&cm) }) {
+		appliedCfgs, err = r.appliedScrapeConfigs(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	var projectID, location, cluster = resolveLabels(r.opts, spec.ExternalLabels)
 
@@ -398,6 +691,21 @@ func (r *collectionReconciler) makeCollectorConfig(ctx context.Context, spec *mo
 	for _, pm := range podMons.Items {
 		// Reassign so we can safely get a pointer.
 		pmon := pm
+		r.events.seen("PodMonitoring", &pmon)
+
+		if reconcilePaused(&pmon) {
+			cfgs := scrapeConfigsForKey(appliedCfgs, pmon.GetKey())
+			pausedCfgs = append(pausedCfgs, cfgs...)
+
+			change, err := setReconciliationPausedCondition(&pmon.Status.MonitoringStatus, pmon.GetGeneration(), true, len(cfgs))
+			if err != nil {
+				logger.Error(err, "setting podmonitoring paused status state", "namespace", pmon.Namespace, "name", pmon.Name)
+			}
+			if change {
+				r.statusUpdates = append(r.statusUpdates, &pmon)
+			}
+			continue
+		}
 
 		cond := &monitoringv1.MonitoringCondition{
 			Type:   monitoringv1.ConfigurationCreateSuccess,
@@ -415,8 +723,29 @@ func (r *collectionReconciler) makeCollectorConfig(ctx context.Context, spec *mo
 				Message: msg,
 			}
 			logger.Error(err, msg, "namespace", pmon.Namespace, "name", pmon.Name)
+			r.events.failed("PodMonitoring", &pmon, err)
+			continue
+		}
+		// Do not scrape with references to Secret keys that are missing in the collector secret.
+		if err := r.scrapeSecretErrors[pmon.GetKey()]; err != nil {
+			logger.Error(err, "resolving secrets failed for PodMonitoring", "namespace", pmon.Namespace, "name", pmon.Name)
+			r.events.failed("PodMonitoring", &pmon, err)
+
+			change, err := pmon.Status.SetMonitoringCondition(pmon.GetGeneration(), metav1.Now(), &monitoringv1.MonitoringCondition{
+				Type:    monitoringv1.ConfigurationCreateSuccess,
+				Status:  corev1.ConditionFalse,
+				Reason:  "SecretResolutionError",
+				Message: err.Error(),
+			})
+			if err != nil {
+				logger.Error(err, "setting podmonitoring status state", "namespace", pmon.Namespace, "name", pmon.Name)
+			}
+			if change {
+				r.statusUpdates = append(r.statusUpdates, &pmon)
+			}
 			continue
 		}
+		r.events.succeeded("PodMonitoring", &pmon)
 		cfg.ScrapeConfigs = append(cfg.ScrapeConfigs, cfgs...)
 
 		change, err := pmon.Status.SetMonitoringCondition(pmon.GetGeneration(), metav1.Now(), cond)
@@ -425,26 +754,55 @@ func (r *collectionReconciler) makeCollectorConfig(ctx context.Context, spec *mo
 			// on a potential bad resource.
 			logger.Error(err, "setting podmonitoring status state", "namespace", pmon.Namespace, "name", pmon.Name)
 		}
+		overlapChange, err := setTargetOverlapCondition(&pmon.Status.MonitoringStatus, pmon.GetGeneration(), overlaps[pmon.GetKey()])
+		if err != nil {
+			logger.Error(err, "setting podmonitoring overlap status state", "namespace", pmon.Namespace, "name", pmon.Name)
+		}
+		pausedChange, err := setReconciliationPausedCondition(&pmon.Status.MonitoringStatus, pmon.GetGeneration(), false, 0)
+		if err != nil {
+			logger.Error(err, "setting podmonitoring paused status state", "namespace", pmon.Namespace, "name", pmon.Name)
+		}
+		hashChange, err := r.setGeneratedConfigHash(&pmon.Status.MonitoringStatus, cfgs)
+		if err != nil {
+			logger.Error(err, "setting podmonitoring config hash", "namespace", pmon.Namespace, "name", pmon.Name)
+		}
 
-		if change {
+		if change || overlapChange || pausedChange || hashChange {
 			r.statusUpdates = append(r.statusUpdates, &pmon)
 		}
 	}
 
-	if err := r.client.List(ctx, &clusterPodMons); err != nil {
-		return nil, fmt.Errorf("failed to list ClusterPodMonitorings: %w", err)
-	}
-
 	// Mark status updates in batch with single timestamp.
 	for _, cm := range clusterPodMons.Items {
 		// Reassign so we can safely get a pointer.
 		cmon := cm
+		r.events.seen("ClusterPodMonitoring", &cmon)
+
+		if reconcilePaused(&cmon) {
+			cfgs := scrapeConfigsForKey(appliedCfgs, cmon.GetKey())
+			pausedCfgs = append(pausedCfgs, cfgs...)
+
+			change, err := setReconciliationPausedCondition(&cmon.Status.MonitoringStatus, cmon.GetGeneration(), true, len(cfgs))
+			if err != nil {
+				logger.Error(err, "setting clusterpodmonitoring paused status state", "namespace", cmon.Namespace, "name", cmon.Name)
+			}
+			if change {
+				r.statusUpdates = append(r.statusUpdates, &cmon)
+			}
+			continue
+		}
 
 		cond := &monitoringv1.MonitoringCondition{
 			Type:   monitoringv1.ConfigurationCreateSuccess,
 			Status: corev1.ConditionTrue,
 		}
-		cfgs, err := cmon.ScrapeConfigs(projectID, location, cluster)
+		namespaces, err := selectedNamespaces(ctx, r.client, &cmon)
+		if err != nil {
+			logger.Error(err, "selecting namespaces failed for ClusterPodMonitoring", "name", cmon.Name)
+			r.events.failed("ClusterPodMonitoring", &cmon, err)
+			continue
+		}
+		cfgs, err := cmon.NamespacedScrapeConfigs(projectID, location, cluster, namespaces)
 		if err != nil {
 			msg := "generating scrape config failed for ClusterPodMonitoring endpoint"
 			//TODO: Fix ineffectual assignment. Intended behavior is unclear.
@@ -456,8 +814,10 @@ func (r *collectionReconciler) makeCollectorConfig(ctx context.Context, spec *mo
 				Message: msg,
 			}
 			logger.Error(err, msg, "namespace", cmon.Namespace, "name", cmon.Name)
+			r.events.failed("ClusterPodMonitoring", &cmon, err)
 			continue
 		}
+		r.events.succeeded("ClusterPodMonitoring", &cmon)
 		cfg.ScrapeConfigs = append(cfg.ScrapeConfigs, cfgs...)
 
 		change, err := cmon.Status.SetMonitoringCondition(cmon.GetGeneration(), metav1.Now(), cond)
@@ -466,8 +826,20 @@ func (r *collectionReconciler) makeCollectorConfig(ctx context.Context, spec *mo
 			// on a potential bad resource.
 			logger.Error(err, "setting clusterpodmonitoring status state", "namespace", cmon.Namespace, "name", cmon.Name)
 		}
+		overlapChange, err := setTargetOverlapCondition(&cmon.Status.MonitoringStatus, cmon.GetGeneration(), overlaps[cmon.GetKey()])
+		if err != nil {
+			logger.Error(err, "setting clusterpodmonitoring overlap status state", "namespace", cmon.Namespace, "name", cmon.Name)
+		}
+		pausedChange, err := setReconciliationPausedCondition(&cmon.Status.MonitoringStatus, cmon.GetGeneration(), false, 0)
+		if err != nil {
+			logger.Error(err, "setting clusterpodmonitoring paused status state", "namespace", cmon.Namespace, "name", cmon.Name)
+		}
+		hashChange, err := r.setGeneratedConfigHash(&cmon.Status.MonitoringStatus, cfgs)
+		if err != nil {
+			logger.Error(err, "setting clusterpodmonitoring config hash", "namespace", cmon.Namespace, "name", cmon.Name)
+		}
 
-		if change {
+		if change || overlapChange || pausedChange || hashChange {
 			r.statusUpdates = append(r.statusUpdates, &cmon)
 		}
 	}
@@ -483,6 +855,7 @@ func (r *collectionReconciler) makeCollectorConfig(ctx context.Context, spec *mo
 	)
 	// Mark status updates in batch with single timestamp.
 	for _, cm := range clusterNodeMons.Items {
+		r.events.seen("ClusterNodeMonitoring", &cm)
 		if spec.KubeletScraping != nil && (cm.Name == reservedKubeletJobName || cm.Name == reservedCAdvisorJobName) {
 			logger.Info("ClusterNodeMonitoring job %s was not applied because OperatorConfig.collector.kubeletScraping is enabled. kubeletScraping already includes the metrics in this job.", "name", cm.Name)
 			continue
@@ -505,8 +878,10 @@ func (r *collectionReconciler) makeCollectorConfig(ctx context.Context, spec *mo
 				Message: msg,
 			}
 			logger.Error(err, msg, "namespace", cm.Namespace, "name", cm.Name)
+			r.events.failed("ClusterNodeMonitoring", &cm, err)
 			continue
 		}
+		r.events.succeeded("ClusterNodeMonitoring", &cm)
 		cfg.ScrapeConfigs = append(cfg.ScrapeConfigs, cfgs...)
 
 		change, err := cm.Status.SetMonitoringCondition(cm.GetGeneration(), metav1.Now(), cond)
@@ -515,14 +890,59 @@ func (r *collectionReconciler) makeCollectorConfig(ctx context.Context, spec *mo
 			// on a potential bad resource.
 			logger.Error(err, "setting clusternodemonitoring status state", "namespace", cm.Namespace, "name", cm.Name)
 		}
+		hashChange, err := r.setGeneratedConfigHash(&cm.Status, cfgs)
+		if err != nil {
+			logger.Error(err, "setting clusternodemonitoring config hash", "namespace", cm.Namespace, "name", cm.Name)
+		}
 
-		if change {
+		if change || hashChange {
 			r.statusUpdates = append(r.statusUpdates, &cm)
 		}
 	}
+	// Forget failing resources that were deleted.
+	r.events.prune()
 
-	// Sort to ensure reproducible configs.
-	sort.Slice(cfg.ScrapeConfigs, func(i, j int) bool {
+	base, err := parseBaseScrapeConfig(spec.BaseScrapeConfig)
+	if err != nil {
+		return nil, err
+	}
+	if base != nil {
+		for i, sc := range cfg.ScrapeConfigs {
+			if cfg.ScrapeConfigs[i], err = base.apply(sc); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Apply export relabeling last so that it sees the final series of every scrape config.
+	exportRelabelCfgs, err := spec.ExportRelabelConfigs()
+	if err != nil {
+		return nil, err
+	}
+	if len(exportRelabelCfgs) > 0 {
+		for _, sc := range cfg.ScrapeConfigs {
+			sc.MetricRelabelConfigs = append(sc.MetricRelabelConfigs, exportRelabelCfgs...)
+		}
+	}
+
+	collectorRelabelCfg, err := makeCollectorLabelRelabelConfig(spec.CollectorLabel)
+	if err != nil {
+		return nil, err
+	}
+	if collectorRelabelCfg != nil {
+		for _, sc := range cfg.ScrapeConfigs {
+			// Clip as relabeling rules may share a backing array across scrape configs.
+			sc.RelabelConfigs = append(slices.Clip(sc.RelabelConfigs), collectorRelabelCfg)
+		}
+	}
+
+	// Paused scrape configs already contain the base scrape config, export relabeling, and
+	// collector label rules they were applied with.
+	cfg.ScrapeConfigs = append(cfg.ScrapeConfigs, pausedCfgs...)
+
+	// Sort to ensure reproducible configs. The sort is stable so that scrape configs with
+	// the same job name, e.g. paused ones, keep the order in which they were generated.
+	sort.SliceStable(cfg.ScrapeConfigs, func(i, j int) bool {
 		return cfg.ScrapeConfigs[i].JobName < cfg.ScrapeConfigs[j].JobName
 	})
 
@@ -553,6 +973,64 @@ func (d *clusterPodMonitoringDefaulter) Default(_ context.Context, o runtime.Obj
 	return nil
 }
 
+// podMonitoringValidator validates PodMonitorings and ClusterPodMonitorings and enforces the
+// configured naming convention. The name is only checked on creation so that existing
+// resources with non-conforming names can still be updated.
+type podMonitoringValidator struct {
+	namePattern *regexp.Regexp
+	// Maximum total number of monitorings, counted through the reader. Zero permits any number.
+	maxMonitorings int
+	reader         client.Reader
+	// If set, pods are listed through the reader to warn about the approximate number of
+	// targets of ClusterPodMonitorings on creation and update.
+	targetEstimateReader client.Reader
+}
+
+func (v *podMonitoringValidator) ValidateCreate(ctx context.Context, o runtime.Object) (admission.Warnings, error) {
+	warnings, err := o.(admission.Validator).ValidateCreate()
+	if err != nil {
+		return warnings, err
+	}
+	if v.namePattern != nil {
+		name := o.(client.Object).GetName()
+		if !v.namePattern.MatchString(name) {
+			return warnings, fmt.Errorf("name %q does not match the required naming pattern %q", name, v.namePattern)
+		}
+	}
+	if v.maxMonitorings > 0 {
+		n, err := countMonitorings(ctx, v.reader)
+		if err != nil {
+			return warnings, err
+		}
+		if n >= v.maxMonitorings {
+			return warnings, fmt.Errorf("the maximum of %d PodMonitorings and ClusterPodMonitorings is reached", v.maxMonitorings)
+		}
+	}
+	return v.appendTargetEstimate(ctx, o, warnings), nil
+}
+
+func (v *podMonitoringValidator) ValidateUpdate(ctx context.Context, old, o runtime.Object) (admission.Warnings, error) {
+	warnings, err := o.(admission.Validator).ValidateUpdate(old)
+	if err != nil {
+		return warnings, err
+	}
+	return v.appendTargetEstimate(ctx, o, warnings), nil
+}
+
+// appendTargetEstimate adds a warning with the approximate number of targets if the object
+// is a ClusterPodMonitoring and target estimates are enabled.
+func (v *podMonitoringValidator) appendTargetEstimate(ctx context.Context, o runtime.Object, warnings admission.Warnings) admission.Warnings {
+	cm, ok := o.(*monitoringv1.ClusterPodMonitoring)
+	if !ok || v.targetEstimateReader == nil {
+		return warnings
+	}
+	return append(warnings, targetEstimateWarning(ctx, v.targetEstimateReader, cm))
+}
+
+func (v *podMonitoringValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
 func makeKubeletScrapeConfigs(cfg *monitoringv1.KubeletScraping) ([]*promconfig.ScrapeConfig, error) {
 	if cfg == nil {
 		return nil, nil
@@ -650,3 +1128,175 @@ func makeKubeletScrapeConfigs(cfg *monitoringv1.KubeletScraping) ([]*promconfig.
 		},
 	}, nil
 }
+
+// Labels that are set by the self-monitoring scrape config or on export and can thus
+// not be overridden through external labels.
+var selfMonitoringReservedLabels = []string{
+	export.KeyProjectID,
+	export.KeyLocation,
+	export.KeyCluster,
+	export.KeyNamespace,
+	export.KeyJob,
+	export.KeyInstance,
+	"pod",
+	"container",
+}
+
+const (
+	// Environment variable holding the collector pod name in the config-reloader container,
+	// which is interpolated into the generated configuration.
+	collectorPodNameEnvVar = "POD_NAME"
+	defaultCollectorLabel  = "collector"
+	// Priority class of the collector pods in the deployed manifests.
+	defaultCollectorPriorityClassName = "gmp-critical"
+)
+
+// makeCollectorLabelRelabelConfig returns a target relabeling rule that sets the configured
+// label to the name of the scraping collector pod.
+func makeCollectorLabelRelabelConfig(cfg *monitoringv1.CollectorLabel) (*relabel.Config, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	name := cfg.Name
+	if name == "" {
+		name = defaultCollectorLabel
+	}
+	if !prommodel.LabelName(name).IsValid() || strings.HasPrefix(name, "__") {
+		return nil, fmt.Errorf("invalid collector label name %q", name)
+	}
+	for _, l := range selfMonitoringReservedLabels {
+		if name == l {
+			return nil, fmt.Errorf("collector label %q is reserved", name)
+		}
+	}
+	return &relabel.Config{
+		Action:      relabel.Replace,
+		Replacement: fmt.Sprintf("$(%s)", collectorPodNameEnvVar),
+		TargetLabel: name,
+	}, nil
+}
+
+// maxOutOfOrderTimeWindow matches the TSDB retention of the collectors. Older samples
+// would be dropped right away.
+const maxOutOfOrderTimeWindow = 30 * time.Minute
+
+// makeStorageConfig returns the storage configuration of the collectors, which accept
+// out-of-order samples within the given time window.
+func makeStorageConfig(outOfOrderTimeWindow string) (promconfig.StorageConfig, error) {
+	if outOfOrderTimeWindow == "" {
+		return promconfig.StorageConfig{}, nil
+	}
+	window, err := prommodel.ParseDuration(outOfOrderTimeWindow)
+	if err != nil {
+		return promconfig.StorageConfig{}, fmt.Errorf("invalid out-of-order time window: %w", err)
+	}
+	if time.Duration(window) > maxOutOfOrderTimeWindow {
+		return promconfig.StorageConfig{}, fmt.Errorf("out-of-order time window must not exceed %s, got %s", prommodel.Duration(maxOutOfOrderTimeWindow), window)
+	}
+	if window == 0 {
+		return promconfig.StorageConfig{}, nil
+	}
+	return promconfig.StorageConfig{
+		TSDBConfig: &promconfig.TSDBConfig{
+			OutOfOrderTimeWindow:     time.Duration(window).Milliseconds(),
+			OutOfOrderTimeWindowFlag: window,
+		},
+	}, nil
+}
+
+func makeSelfMonitoringScrapeConfigs(namespace string, cfg *monitoringv1.SelfMonitoring) ([]*promconfig.ScrapeConfig, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	interval, err := prommodel.ParseDuration(cfg.Interval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid scrape interval: %w", err)
+	}
+	discoveryCfgs := discovery.Configs{
+		&discoverykube.SDConfig{
+			HTTPClientConfig: config.DefaultHTTPClientConfig,
+			Role:             discoverykube.RolePod,
+			NamespaceDiscovery: discoverykube.NamespaceDiscovery{
+				Names: []string{namespace},
+			},
+			// Only scrape the collector running on the same node, see makeKubeletScrapeConfigs.
+			Selectors: []discoverykube.SelectorConfig{
+				{
+					Role:  discoverykube.RolePod,
+					Field: fmt.Sprintf("spec.nodeName=$(%s)", monitoringv1.EnvVarNodeName),
+				},
+			},
+		},
+	}
+	relabelCfgs := []*relabel.Config{
+		{
+			Action:       relabel.Keep,
+			SourceLabels: prommodel.LabelNames{"__meta_kubernetes_pod_label_app_kubernetes_io_name"},
+			Regex:        relabel.MustNewRegexp(NameCollector),
+		},
+		{
+			Action:       relabel.Keep,
+			SourceLabels: prommodel.LabelNames{"__meta_kubernetes_pod_container_port_name"},
+			Regex:        relabel.MustNewRegexp("prom-metrics|cfg-rel-metrics"),
+		},
+		{
+			Action:      relabel.Replace,
+			Replacement: NameCollector,
+			TargetLabel: "job",
+		},
+		{
+			Action:       relabel.Replace,
+			SourceLabels: prommodel.LabelNames{"__meta_kubernetes_namespace"},
+			TargetLabel:  "namespace",
+		},
+		{
+			Action:       relabel.Replace,
+			SourceLabels: prommodel.LabelNames{"__meta_kubernetes_pod_name"},
+			TargetLabel:  "pod",
+		},
+		{
+			Action:       relabel.Replace,
+			SourceLabels: prommodel.LabelNames{"__meta_kubernetes_pod_container_name"},
+			TargetLabel:  "container",
+		},
+		{
+			Action:       relabel.Replace,
+			SourceLabels: prommodel.LabelNames{"__meta_kubernetes_pod_name", "__meta_kubernetes_pod_container_port_name"},
+			Regex:        relabel.MustNewRegexp("(.+);(.+)"),
+			Replacement:  "$1:$2",
+			TargetLabel:  "instance",
+		},
+	}
+	// Sort to ensure reproducible configs.
+	var keys []string
+	for k := range cfg.ExternalLabels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if !prommodel.LabelName(k).IsValid() || strings.HasPrefix(k, "__") {
+			return nil, fmt.Errorf("invalid external label name %q", k)
+		}
+		for _, l := range selfMonitoringReservedLabels {
+			if k == l {
+				return nil, fmt.Errorf("external label %q is reserved", k)
+			}
+		}
+		relabelCfgs = append(relabelCfgs, &relabel.Config{
+			Action:      relabel.Replace,
+			Replacement: cfg.ExternalLabels[k],
+			TargetLabel: k,
+		})
+	}
+	return []*promconfig.ScrapeConfig{
+		{
+			JobName:                 "collector/self",
+			ServiceDiscoveryConfigs: discoveryCfgs,
+			ScrapeInterval:          interval,
+			MetricsPath:             "/metrics",
+			HTTPClientConfig:        config.DefaultHTTPClientConfig,
+			RelabelConfigs:          relabelCfgs,
+		},
+	}, nil
+}
@@ -16,8 +16,12 @@ package operator
 
 import (
 	"context"
+	"flag"
 	"fmt"
+	"math/rand"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -25,14 +29,23 @@ import (
 	"github.com/go-logr/logr"
 	"github.com/go-logr/logr/testr"
 	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/prometheus/common/model"
+	promconfig "github.com/prometheus/prometheus/config"
+	"github.com/prometheus/prometheus/model/relabel"
+	yamlv2 "gopkg.in/yaml.v2"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
+	tclock "k8s.io/utils/clock/testing"
 	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
@@ -53,7 +66,12 @@ func newFakeClientBuilder() *fake.ClientBuilder {
 	return fake.NewClientBuilder().
 		WithScheme(testScheme).
 		WithStatusSubresource(&monitoringv1.PodMonitoring{}).
-		WithStatusSubresource(&monitoringv1.ClusterPodMonitoring{})
+		WithStatusSubresource(&monitoringv1.ClusterPodMonitoring{}).
+		WithStatusSubresource(&monitoringv1.ClusterNodeMonitoring{}).
+		WithStatusSubresource(&monitoringv1.Rules{}).
+		WithStatusSubresource(&monitoringv1.ClusterRules{}).
+		WithStatusSubresource(&monitoringv1.GlobalRules{}).
+		WithStatusSubresource(&monitoringv1.OperatorConfig{})
 }
 
 // Tests that the collection does not overwrite the non-managed status fields.
@@ -76,7 +94,8 @@ func TestCollectionStatus(t *testing.T) {
 								LastScrapeDurationSeconds: "1.2",
 							},
 						},
-						Count: ptr.To(int32(1)),
+						Count:                    ptr.To(int32(1)),
+						MaxScrapeDurationSeconds: "1.2",
 					},
 				},
 				CollectorsFraction: "1",
@@ -174,3 +193,1669 @@ func TestCollectionStatus(t *testing.T) {
 		t.Fatalf("invalid PodMonitorings found: %d", amount)
 	}
 }
+
+func TestCollectionConfigRegenerationInterval(t *testing.T) {
+	logger := testr.New(t)
+	ctx := logr.NewContext(context.Background(), logger)
+	opts := Options{
+		ProjectID:                  "test-proj",
+		Location:                   "test-loc",
+		Cluster:                    "test-cluster",
+		ConfigRegenerationInterval: time.Minute,
+	}
+	if err := opts.defaultAndValidate(logger); err != nil {
+		t.Fatal("Invalid options:", err)
+	}
+	kubeClient := newFakeClientBuilder().Build()
+
+	fakeClock := tclock.NewFakeClock(time.Now())
+	r := newCollectionReconciler(kubeClient, opts)
+	r.clock = fakeClock
+
+	req := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Namespace: opts.PublicNamespace,
+			Name:      NameOperatorConfig,
+		},
+	}
+	reconcileExpect := func(wantRequeue time.Duration) {
+		t.Helper()
+		res, err := r.Reconcile(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.RequeueAfter != wantRequeue {
+			t.Fatalf("expected requeue after %s, got %s", wantRequeue, res.RequeueAfter)
+		}
+	}
+	hasJob := func(job string) bool {
+		t.Helper()
+		var cm corev1.ConfigMap
+		if err := kubeClient.Get(ctx, client.ObjectKey{Namespace: opts.OperatorNamespace, Name: NameCollector}, &cm); err != nil {
+			t.Fatal(err)
+		}
+		return strings.Contains(cm.Data[configFilename], job)
+	}
+	hasSecret := func() bool {
+		t.Helper()
+		err := kubeClient.Get(ctx, client.ObjectKey{Namespace: opts.OperatorNamespace, Name: CollectionSecretName}, &corev1.Secret{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			t.Fatal(err)
+		}
+		return err == nil
+	}
+	coalescedBefore := testutil.ToFloat64(configRegenerationsCoalesced)
+
+	// The first change is applied immediately.
+	reconcileExpect(0)
+
+	pm := &monitoringv1.PodMonitoring{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "prom-example",
+			Namespace: "gmp-test",
+		},
+		Spec: monitoringv1.PodMonitoringSpec{
+			Endpoints: []monitoringv1.ScrapeEndpoint{{
+				Port:     intstr.FromString("metrics"),
+				Interval: "10s",
+			}},
+		},
+	}
+	if err := kubeClient.Create(ctx, pm); err != nil {
+		t.Fatal(err)
+	}
+	const job = "PodMonitoring/gmp-test/prom-example/metrics"
+	if err := kubeClient.Delete(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: opts.OperatorNamespace, Name: CollectionSecretName},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Changes within the interval are deferred until it has passed, including the ones
+	// of the collector Secret.
+	reconcileExpect(time.Minute)
+	fakeClock.Step(40 * time.Second)
+	reconcileExpect(20 * time.Second)
+	if hasJob(job) {
+		t.Fatal("expected config regeneration to be deferred")
+	}
+	if hasSecret() {
+		t.Fatal("expected collector secret update to be deferred")
+	}
+	if got := testutil.ToFloat64(configRegenerationsCoalesced) - coalescedBefore; got != 2 {
+		t.Errorf("expected 2 coalesced regenerations, got %v", got)
+	}
+
+	// Once the interval has passed, all changes are applied at once.
+	fakeClock.Step(20 * time.Second)
+	reconcileExpect(0)
+	if !hasJob(job) {
+		t.Fatal("expected config to contain job after the interval passed")
+	}
+	if !hasSecret() {
+		t.Fatal("expected collector secret after the interval passed")
+	}
+	if got := testutil.ToFloat64(configRegenerationsCoalesced) - coalescedBefore; got != 2 {
+		t.Errorf("expected 2 coalesced regenerations, got %v", got)
+	}
+}
+
+func TestCollectionSelfMonitoring(t *testing.T) {
+	logger := testr.New(t)
+	ctx := logr.NewContext(context.Background(), logger)
+	opts := Options{
+		ProjectID: "test-proj",
+		Location:  "test-loc",
+		Cluster:   "test-cluster",
+	}
+	if err := opts.defaultAndValidate(logger); err != nil {
+		t.Fatal("Invalid options:", err)
+	}
+	oc := &monitoringv1.OperatorConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: opts.PublicNamespace,
+			Name:      NameOperatorConfig,
+		},
+		Collection: monitoringv1.CollectionSpec{
+			SelfMonitoring: &monitoringv1.SelfMonitoring{
+				Interval: "30s",
+				ExternalLabels: map[string]string{
+					"fleet":       "fleet-1",
+					"environment": "prod",
+				},
+			},
+		},
+	}
+	kubeClient := newFakeClientBuilder().WithObjects(oc).Build()
+
+	r := newCollectionReconciler(kubeClient, opts)
+	if _, err := r.Reconcile(ctx, reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Namespace: opts.PublicNamespace,
+			Name:      NameOperatorConfig,
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	var cm corev1.ConfigMap
+	if err := kubeClient.Get(ctx, client.ObjectKey{Namespace: opts.OperatorNamespace, Name: NameCollector}, &cm); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := promconfig.Load(cm.Data[configFilename], false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var selfCfg *promconfig.ScrapeConfig
+	for _, sc := range cfg.ScrapeConfigs {
+		if sc.JobName == "collector/self" {
+			selfCfg = sc
+		}
+	}
+	if selfCfg == nil {
+		t.Fatal("expected self-monitoring scrape config")
+	}
+	got := map[string]string{}
+	for _, rc := range selfCfg.RelabelConfigs {
+		if rc.Action == relabel.Replace && len(rc.SourceLabels) == 0 {
+			got[rc.TargetLabel] = rc.Replacement
+		}
+	}
+	want := map[string]string{
+		"job":         NameCollector,
+		"environment": "prod",
+		"fleet":       "fleet-1",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected static labels (-want, +got): %s", diff)
+	}
+}
+
+func TestCollectionBasicAuthUsernameSecret(t *testing.T) {
+	logger := testr.New(t)
+	ctx := logr.NewContext(context.Background(), logger)
+	opts := Options{
+		ProjectID:            "test-proj",
+		Location:             "test-loc",
+		Cluster:              "test-cluster",
+		ResolveScrapeSecrets: true,
+	}
+	if err := opts.defaultAndValidate(logger); err != nil {
+		t.Fatal("Invalid options:", err)
+	}
+	podMonitoring := func(name, secretName string) *monitoringv1.PodMonitoring {
+		return &monitoringv1.PodMonitoring{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "gmp-test",
+			},
+			Spec: monitoringv1.PodMonitoringSpec{
+				Endpoints: []monitoringv1.ScrapeEndpoint{{
+					Port:     intstr.FromString("metrics"),
+					Interval: "10s",
+					HTTPClientConfig: monitoringv1.HTTPClientConfig{
+						BasicAuth: &monitoringv1.BasicAuth{
+							UsernameSecret: &corev1.SecretKeySelector{
+								LocalObjectReference: corev1.LocalObjectReference{
+									Name: secretName,
+								},
+								Key: "username",
+							},
+						},
+					},
+				}},
+			},
+		}
+	}
+	kubeClient := newFakeClientBuilder().WithObjects(
+		podMonitoring("prom-example", "auth"),
+		// A missing secret does not prevent the other secrets from being resolved.
+		podMonitoring("prom-missing", "missing"),
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "auth",
+				Namespace: "gmp-test",
+			},
+			Data: map[string][]byte{
+				"username": []byte("admin"),
+			},
+		},
+	).Build()
+
+	r := newCollectionReconciler(kubeClient, opts)
+	if _, err := r.Reconcile(ctx, reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Namespace: opts.PublicNamespace,
+			Name:      NameOperatorConfig,
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var cm corev1.ConfigMap
+	if err := kubeClient.Get(ctx, client.ObjectKey{Namespace: opts.OperatorNamespace, Name: NameCollector}, &cm); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := promconfig.Load(cm.Data[configFilename], false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var usernameFile string
+	for _, sc := range cfg.ScrapeConfigs {
+		if sc.JobName == "PodMonitoring/gmp-test/prom-example/metrics" {
+			usernameFile = sc.HTTPClientConfig.BasicAuth.UsernameFile
+		}
+	}
+	if usernameFile == "" {
+		t.Fatal("expected basic auth username file in scrape config")
+	}
+
+	var secret corev1.Secret
+	if err := kubeClient.Get(ctx, client.ObjectKey{Namespace: opts.OperatorNamespace, Name: CollectionSecretName}, &secret); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]byte{
+		strings.TrimPrefix(usernameFile, monitoringv1.CollectorSecretsDir+"/"): []byte("admin"),
+	}
+	if diff := cmp.Diff(want, secret.Data); diff != "" {
+		t.Errorf("unexpected collector secret data (-want, +got): %s", diff)
+	}
+
+	// The PodMonitoring with the missing secret is not scraped and reports the failure.
+	for _, sc := range cfg.ScrapeConfigs {
+		if sc.JobName == "PodMonitoring/gmp-test/prom-missing/metrics" {
+			t.Errorf("unexpected scrape config for PodMonitoring with missing secret")
+		}
+	}
+	expectConfigurationCreateFailed(ctx, t, kubeClient, "prom-missing", "SecretResolutionError")
+
+	// Only changes of referenced secrets trigger a reconcile.
+	for _, c := range []struct {
+		namespace, name string
+		want            bool
+	}{
+		{"gmp-test", "auth", true},
+		{"gmp-test", "missing", true},
+		{"gmp-test", "other", false},
+		{"other", "auth", false},
+	} {
+		secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: c.namespace, Name: c.name}}
+		if got := r.scrapeSecretReferenced(ctx, secret); got != c.want {
+			t.Errorf("expected secret %s/%s referenced to be %v, got %v", c.namespace, c.name, c.want, got)
+		}
+	}
+}
+
+func TestCollectionBasicAuthUsernameSecretDisabled(t *testing.T) {
+	logger := testr.New(t)
+	ctx := logr.NewContext(context.Background(), logger)
+	opts := Options{
+		ProjectID: "test-proj",
+		Location:  "test-loc",
+		Cluster:   "test-cluster",
+	}
+	if err := opts.defaultAndValidate(logger); err != nil {
+		t.Fatal("Invalid options:", err)
+	}
+	kubeClient := newFakeClientBuilder().WithObjects(
+		&monitoringv1.PodMonitoring{
+			ObjectMeta: metav1.ObjectMeta{Name: "prom-example", Namespace: "gmp-test"},
+			Spec: monitoringv1.PodMonitoringSpec{
+				Endpoints: []monitoringv1.ScrapeEndpoint{{
+					Port:     intstr.FromString("metrics"),
+					Interval: "10s",
+					HTTPClientConfig: monitoringv1.HTTPClientConfig{
+						BasicAuth: &monitoringv1.BasicAuth{
+							UsernameSecret: &corev1.SecretKeySelector{
+								LocalObjectReference: corev1.LocalObjectReference{Name: "auth"},
+								Key:                  "username",
+							},
+						},
+					},
+				}},
+			},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "auth", Namespace: "gmp-test"},
+			Data:       map[string][]byte{"username": []byte("admin")},
+		},
+	).Build()
+
+	r := newCollectionReconciler(kubeClient, opts)
+	if _, err := r.Reconcile(ctx, reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Namespace: opts.PublicNamespace,
+			Name:      NameOperatorConfig,
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Secrets are not read unless enabled.
+	var secret corev1.Secret
+	if err := kubeClient.Get(ctx, client.ObjectKey{Namespace: opts.OperatorNamespace, Name: CollectionSecretName}, &secret); err != nil {
+		t.Fatal(err)
+	}
+	if len(secret.Data) > 0 {
+		t.Errorf("expected empty collector secret, got %d keys", len(secret.Data))
+	}
+	expectConfigurationCreateFailed(ctx, t, kubeClient, "prom-example", "SecretResolutionError")
+}
+
+// expectConfigurationCreateFailed checks that the PodMonitoring in the gmp-test namespace
+// reports that its configuration failed with the given reason.
+func expectConfigurationCreateFailed(ctx context.Context, t *testing.T, kubeClient client.Client, name, reason string) {
+	t.Helper()
+	var pm monitoringv1.PodMonitoring
+	if err := kubeClient.Get(ctx, client.ObjectKey{Namespace: "gmp-test", Name: name}, &pm); err != nil {
+		t.Fatal(err)
+	}
+	for _, cond := range pm.Status.Conditions {
+		if cond.Type != monitoringv1.ConfigurationCreateSuccess {
+			continue
+		}
+		if cond.Status != corev1.ConditionFalse || cond.Reason != reason {
+			t.Errorf("expected condition %s to be false with reason %q, got %s with reason %q", cond.Type, reason, cond.Status, cond.Reason)
+		}
+		return
+	}
+	t.Errorf("expected condition %s on PodMonitoring %s", monitoringv1.ConfigurationCreateSuccess, name)
+}
+
+func TestCollectionServiceAccountTokens(t *testing.T) {
+	logger := testr.New(t)
+	ctx := logr.NewContext(context.Background(), logger)
+	opts := Options{
+		ProjectID: "test-proj",
+		Location:  "test-loc",
+		Cluster:   "test-cluster",
+	}
+	if err := opts.defaultAndValidate(logger); err != nil {
+		t.Fatal("Invalid options:", err)
+	}
+	endpoints := func(audience string) []monitoringv1.ScrapeEndpoint {
+		return []monitoringv1.ScrapeEndpoint{{
+			Port:     intstr.FromString("metrics"),
+			Interval: "10s",
+			HTTPClientConfig: monitoringv1.HTTPClientConfig{
+				Authorization: &monitoringv1.Auth{
+					ServiceAccountToken: &monitoringv1.ServiceAccountToken{Audience: audience},
+				},
+			},
+		}}
+	}
+	pm1 := &monitoringv1.PodMonitoring{
+		ObjectMeta: metav1.ObjectMeta{Name: "prom-example", Namespace: "gmp-test"},
+		Spec:       monitoringv1.PodMonitoringSpec{Endpoints: endpoints("https://example.com")},
+	}
+	pm2 := &monitoringv1.PodMonitoring{
+		ObjectMeta: metav1.ObjectMeta{Name: "prom-other", Namespace: "gmp-test"},
+		Spec:       monitoringv1.PodMonitoringSpec{Endpoints: endpoints("example")},
+	}
+	cm := &monitoringv1.ClusterPodMonitoring{
+		ObjectMeta: metav1.ObjectMeta{Name: "prom-example"},
+		Spec:       monitoringv1.ClusterPodMonitoringSpec{Endpoints: endpoints("example")},
+	}
+	storageMount := corev1.VolumeMount{Name: "storage", MountPath: "/prometheus/data"}
+	ds := &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: opts.OperatorNamespace,
+			Name:      NameCollector,
+		},
+		Spec: appsv1.DaemonSetSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{Name: "config-reloader"},
+						{
+							Name:         CollectorPrometheusContainerName,
+							VolumeMounts: []corev1.VolumeMount{storageMount},
+						},
+					},
+					Volumes: []corev1.Volume{{Name: "storage"}},
+				},
+			},
+		},
+	}
+	kubeClient := newFakeClientBuilder().WithObjects(pm1, pm2, cm, ds).Build()
+	r := newCollectionReconciler(kubeClient, opts)
+
+	reconcileAndGetDaemonSet := func() *appsv1.DaemonSet {
+		t.Helper()
+		if _, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Namespace: opts.PublicNamespace,
+				Name:      NameOperatorConfig,
+			},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		var got appsv1.DaemonSet
+		if err := kubeClient.Get(ctx, client.ObjectKeyFromObject(ds), &got); err != nil {
+			t.Fatal(err)
+		}
+		return &got
+	}
+
+	// Each distinct audience gets a token in the projected volume.
+	got := reconcileAndGetDaemonSet()
+	wantVolumes := []corev1.Volume{
+		{Name: "storage"},
+		{
+			Name: "service-account-tokens",
+			VolumeSource: corev1.VolumeSource{
+				Projected: &corev1.ProjectedVolumeSource{
+					Sources: []corev1.VolumeProjection{
+						{
+							ServiceAccountToken: &corev1.ServiceAccountTokenProjection{
+								Audience:          "example",
+								ExpirationSeconds: ptr.To[int64](3600),
+								Path:              "token_example",
+							},
+						},
+						{
+							ServiceAccountToken: &corev1.ServiceAccountTokenProjection{
+								Audience:          "https://example.com",
+								ExpirationSeconds: ptr.To[int64](3600),
+								Path:              "token_https:%2F%2Fexample.com",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	if diff := cmp.Diff(wantVolumes, got.Spec.Template.Spec.Volumes); diff != "" {
+		t.Errorf("unexpected volumes (-want, +got): %s", diff)
+	}
+	wantMounts := []corev1.VolumeMount{
+		storageMount,
+		{Name: "service-account-tokens", MountPath: "/etc/service-account-tokens", ReadOnly: true},
+	}
+	if diff := cmp.Diff(wantMounts, got.Spec.Template.Spec.Containers[1].VolumeMounts); diff != "" {
+		t.Errorf("unexpected volume mounts (-want, +got): %s", diff)
+	}
+	if mounts := got.Spec.Template.Spec.Containers[0].VolumeMounts; len(mounts) > 0 {
+		t.Errorf("unexpected volume mounts in config-reloader container: %v", mounts)
+	}
+
+	// The scrape configurations read the tokens from the mounted volume.
+	var cfgMap corev1.ConfigMap
+	if err := kubeClient.Get(ctx, client.ObjectKey{Namespace: opts.OperatorNamespace, Name: NameCollector}, &cfgMap); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := promconfig.Load(cfgMap.Data[configFilename], false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantFiles := map[string]string{
+		"ClusterPodMonitoring/prom-example/metrics":   "/etc/service-account-tokens/token_example",
+		"PodMonitoring/gmp-test/prom-example/metrics": "/etc/service-account-tokens/token_https:%2F%2Fexample.com",
+		"PodMonitoring/gmp-test/prom-other/metrics":   "/etc/service-account-tokens/token_example",
+	}
+	gotFiles := map[string]string{}
+	for _, sc := range cfg.ScrapeConfigs {
+		if auth := sc.HTTPClientConfig.Authorization; auth != nil {
+			gotFiles[sc.JobName] = auth.CredentialsFile
+		}
+	}
+	if diff := cmp.Diff(wantFiles, gotFiles); diff != "" {
+		t.Errorf("unexpected credentials files (-want, +got): %s", diff)
+	}
+
+	// The volume is removed once no tokens are referenced anymore.
+	for _, obj := range []client.Object{pm1, pm2, cm} {
+		if err := kubeClient.Delete(ctx, obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got = reconcileAndGetDaemonSet()
+	if diff := cmp.Diff(wantVolumes[:1], got.Spec.Template.Spec.Volumes); diff != "" {
+		t.Errorf("unexpected volumes (-want, +got): %s", diff)
+	}
+	if diff := cmp.Diff(wantMounts[:1], got.Spec.Template.Spec.Containers[1].VolumeMounts); diff != "" {
+		t.Errorf("unexpected volume mounts (-want, +got): %s", diff)
+	}
+}
+
+func TestCollectionMonitoringLimitCondition(t *testing.T) {
+	logger := testr.New(t)
+	ctx := logr.NewContext(context.Background(), logger)
+	opts := Options{
+		ProjectID:      "test-proj",
+		Location:       "test-loc",
+		Cluster:        "test-cluster",
+		MaxMonitorings: 2,
+	}
+	if err := opts.defaultAndValidate(logger); err != nil {
+		t.Fatal("Invalid options:", err)
+	}
+	oc := &monitoringv1.OperatorConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: opts.PublicNamespace,
+			Name:      NameOperatorConfig,
+		},
+	}
+	podMonitoring := func(name string) *monitoringv1.PodMonitoring {
+		return &monitoringv1.PodMonitoring{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "gmp-test"},
+			Spec: monitoringv1.PodMonitoringSpec{
+				Endpoints: []monitoringv1.ScrapeEndpoint{{
+					Port:     intstr.FromString("metrics"),
+					Interval: "10s",
+				}},
+			},
+		}
+	}
+	pm := podMonitoring("prom-example")
+	kubeClient := newFakeClientBuilder().WithObjects(oc, pm).Build()
+	r := newCollectionReconciler(kubeClient, opts)
+
+	reconcileAndGetConditions := func() []monitoringv1.MonitoringCondition {
+		t.Helper()
+		if _, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Namespace: opts.PublicNamespace,
+				Name:      NameOperatorConfig,
+			},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		var got monitoringv1.OperatorConfig
+		if err := kubeClient.Get(ctx, client.ObjectKeyFromObject(oc), &got); err != nil {
+			t.Fatal(err)
+		}
+		// Timestamps are not compared.
+		for i := range got.Status.Conditions {
+			got.Status.Conditions[i].LastUpdateTime = metav1.Time{}
+			got.Status.Conditions[i].LastTransitionTime = metav1.Time{}
+		}
+		return got.Status.Conditions
+	}
+
+	// No condition is set while below the limit.
+	if got := reconcileAndGetConditions(); len(got) > 0 {
+		t.Errorf("expected no conditions, got %v", got)
+	}
+
+	// The condition is set once the limit is reached.
+	if err := kubeClient.Create(ctx, podMonitoring("prom-other")); err != nil {
+		t.Fatal(err)
+	}
+	want := []monitoringv1.MonitoringCondition{{
+		Type:    monitoringv1.MonitoringLimitReached,
+		Status:  corev1.ConditionTrue,
+		Reason:  "MaxMonitorings",
+		Message: "2 of at most 2 PodMonitorings and ClusterPodMonitorings exist, creating further ones is rejected",
+	}}
+	if diff := cmp.Diff(want, reconcileAndGetConditions()); diff != "" {
+		t.Errorf("unexpected conditions (-want, +got): %s", diff)
+	}
+
+	// The condition is cleared once monitorings are deleted.
+	if err := kubeClient.Delete(ctx, pm); err != nil {
+		t.Fatal(err)
+	}
+	want = []monitoringv1.MonitoringCondition{{
+		Type:   monitoringv1.MonitoringLimitReached,
+		Status: corev1.ConditionFalse,
+	}}
+	if diff := cmp.Diff(want, reconcileAndGetConditions()); diff != "" {
+		t.Errorf("unexpected conditions (-want, +got): %s", diff)
+	}
+}
+
+func TestCollectionPriorityClassAndPodDisruptionBudget(t *testing.T) {
+	logger := testr.New(t)
+	ctx := logr.NewContext(context.Background(), logger)
+	opts := Options{
+		ProjectID: "test-proj",
+		Location:  "test-loc",
+		Cluster:   "test-cluster",
+	}
+	if err := opts.defaultAndValidate(logger); err != nil {
+		t.Fatal("Invalid options:", err)
+	}
+	oc := &monitoringv1.OperatorConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: opts.PublicNamespace,
+			Name:      NameOperatorConfig,
+		},
+		Collection: monitoringv1.CollectionSpec{
+			PriorityClassName: "collector-critical",
+			PodDisruptionBudget: &monitoringv1.CollectorPodDisruptionBudget{
+				MaxUnavailable: ptr.To(intstr.FromInt(1)),
+			},
+		},
+	}
+	ds := &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: opts.OperatorNamespace,
+			Name:      NameCollector,
+		},
+		Spec: appsv1.DaemonSetSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					PriorityClassName: "gmp-critical",
+					Containers: []corev1.Container{
+						{Name: "prometheus"},
+					},
+				},
+			},
+		},
+	}
+	kubeClient := newFakeClientBuilder().WithObjects(oc, ds).Build()
+	r := newCollectionReconciler(kubeClient, opts)
+
+	reconcileAndGetPDB := func() (*policyv1.PodDisruptionBudget, error) {
+		t.Helper()
+		if _, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Namespace: opts.PublicNamespace,
+				Name:      NameOperatorConfig,
+			},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		var pdb policyv1.PodDisruptionBudget
+		err := kubeClient.Get(ctx, client.ObjectKey{Namespace: opts.OperatorNamespace, Name: NameCollector}, &pdb)
+		return &pdb, err
+	}
+	updateOperatorConfig := func(f func(*monitoringv1.CollectionSpec)) {
+		t.Helper()
+		if err := kubeClient.Get(ctx, client.ObjectKeyFromObject(oc), oc); err != nil {
+			t.Fatal(err)
+		}
+		f(&oc.Collection)
+		if err := kubeClient.Update(ctx, oc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	selector := &metav1.LabelSelector{
+		MatchLabels: map[string]string{LabelAppName: NameCollector},
+	}
+
+	// The PodDisruptionBudget is created and the priority class applied.
+	pdb, err := reconcileAndGetPDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := policyv1.PodDisruptionBudgetSpec{
+		MaxUnavailable: ptr.To(intstr.FromInt(1)),
+		Selector:       selector,
+	}
+	if diff := cmp.Diff(want, pdb.Spec); diff != "" {
+		t.Errorf("unexpected pod disruption budget (-want, +got): %s", diff)
+	}
+	if err := kubeClient.Get(ctx, client.ObjectKeyFromObject(ds), ds); err != nil {
+		t.Fatal(err)
+	}
+	if got := ds.Spec.Template.Spec.PriorityClassName; got != "collector-critical" {
+		t.Errorf("expected priority class %q, got %q", "collector-critical", got)
+	}
+
+	// The PodDisruptionBudget is updated.
+	updateOperatorConfig(func(spec *monitoringv1.CollectionSpec) {
+		spec.PodDisruptionBudget = &monitoringv1.CollectorPodDisruptionBudget{
+			MinAvailable: ptr.To(intstr.FromString("50%")),
+		}
+	})
+	pdb, err = reconcileAndGetPDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = policyv1.PodDisruptionBudgetSpec{
+		MinAvailable: ptr.To(intstr.FromString("50%")),
+		Selector:     selector,
+	}
+	if diff := cmp.Diff(want, pdb.Spec); diff != "" {
+		t.Errorf("unexpected pod disruption budget (-want, +got): %s", diff)
+	}
+
+	// The PodDisruptionBudget is deleted once unset.
+	updateOperatorConfig(func(spec *monitoringv1.CollectionSpec) {
+		spec.PodDisruptionBudget = nil
+	})
+	if _, err := reconcileAndGetPDB(); !apierrors.IsNotFound(err) {
+		t.Errorf("expected pod disruption budget to be deleted, got: %v", err)
+	}
+
+	// The default priority class is restored once the override is unset.
+	updateOperatorConfig(func(spec *monitoringv1.CollectionSpec) {
+		spec.PriorityClassName = ""
+	})
+	if _, err := reconcileAndGetPDB(); !apierrors.IsNotFound(err) {
+		t.Errorf("expected no pod disruption budget, got: %v", err)
+	}
+	if err := kubeClient.Get(ctx, client.ObjectKeyFromObject(ds), ds); err != nil {
+		t.Fatal(err)
+	}
+	if got := ds.Spec.Template.Spec.PriorityClassName; got != defaultCollectorPriorityClassName {
+		t.Errorf("expected priority class %q, got %q", defaultCollectorPriorityClassName, got)
+	}
+}
+
+func TestCollectionExportRelabeling(t *testing.T) {
+	logger := testr.New(t)
+	ctx := logr.NewContext(context.Background(), logger)
+	opts := Options{
+		ProjectID: "test-proj",
+		Location:  "test-loc",
+		Cluster:   "test-cluster",
+	}
+	if err := opts.defaultAndValidate(logger); err != nil {
+		t.Fatal("Invalid options:", err)
+	}
+	oc := &monitoringv1.OperatorConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: opts.PublicNamespace,
+			Name:      NameOperatorConfig,
+		},
+		Collection: monitoringv1.CollectionSpec{
+			ExportRelabeling: []monitoringv1.RelabelingRule{
+				{
+					Action:       "drop",
+					SourceLabels: []string{"__name__"},
+					Regex:        "go_.+",
+				},
+				{
+					Action: "labeldrop",
+					Regex:  "pod_template_hash",
+				},
+			},
+		},
+	}
+	pm := &monitoringv1.PodMonitoring{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "prom-example",
+			Namespace: "gmp-test",
+		},
+		Spec: monitoringv1.PodMonitoringSpec{
+			Endpoints: []monitoringv1.ScrapeEndpoint{{
+				Port:     intstr.FromString("metrics"),
+				Interval: "10s",
+				MetricRelabeling: []monitoringv1.RelabelingRule{
+					{
+						Action:       "keep",
+						SourceLabels: []string{"__name__"},
+						Regex:        "(go|http)_.+",
+					},
+				},
+			}},
+		},
+	}
+	kubeClient := newFakeClientBuilder().WithObjects(oc, pm).Build()
+
+	r := newCollectionReconciler(kubeClient, opts)
+	if _, err := r.Reconcile(ctx, reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Namespace: opts.PublicNamespace,
+			Name:      NameOperatorConfig,
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	var cm corev1.ConfigMap
+	if err := kubeClient.Get(ctx, client.ObjectKey{Namespace: opts.OperatorNamespace, Name: NameCollector}, &cm); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := promconfig.Load(cm.Data[configFilename], false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.ScrapeConfigs) != 1 {
+		t.Fatalf("expected 1 scrape config, got %d", len(cfg.ScrapeConfigs))
+	}
+	b, err := yamlv2.Marshal(cfg.ScrapeConfigs[0].MetricRelabelConfigs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `- source_labels: [__name__]
+  separator: ;
+  regex: (go|http)_.+
+  replacement: $1
+  action: keep
+- source_labels: [__name__]
+  separator: ;
+  regex: go_.+
+  replacement: $1
+  action: drop
+- separator: ;
+  regex: pod_template_hash
+  replacement: $1
+  action: labeldrop
+`
+	if diff := cmp.Diff(want, string(b)); diff != "" {
+		t.Errorf("unexpected metric relabeling YAML (-want, +got): %s", diff)
+	}
+}
+
+func TestCollectionExportQueue(t *testing.T) {
+	logger := testr.New(t)
+	ctx := logr.NewContext(context.Background(), logger)
+	opts := Options{
+		ProjectID: "test-proj",
+		Location:  "test-loc",
+		Cluster:   "test-cluster",
+	}
+	if err := opts.defaultAndValidate(logger); err != nil {
+		t.Fatal("Invalid options:", err)
+	}
+	oc := &monitoringv1.OperatorConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: opts.PublicNamespace,
+			Name:      NameOperatorConfig,
+		},
+		Collection: monitoringv1.CollectionSpec{
+			ExportQueue: &monitoringv1.ExportQueueConfig{
+				Capacity:          5000,
+				MaxSamplesPerSend: 100,
+				MaxShards:         512,
+				BatchSendDeadline: "200ms",
+			},
+		},
+	}
+	ds := &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: opts.OperatorNamespace,
+			Name:      NameCollector,
+		},
+		Spec: appsv1.DaemonSetSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{Name: "prometheus"},
+					},
+				},
+			},
+		},
+	}
+	kubeClient := newFakeClientBuilder().WithObjects(oc, ds).Build()
+
+	r := newCollectionReconciler(kubeClient, opts)
+	if _, err := r.Reconcile(ctx, reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Namespace: opts.PublicNamespace,
+			Name:      NameOperatorConfig,
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := kubeClient.Get(ctx, client.ObjectKeyFromObject(ds), ds); err != nil {
+		t.Fatal(err)
+	}
+	want := []corev1.EnvVar{{
+		Name: "EXTRA_ARGS",
+		Value: `--export.label.project-id="test-proj" --export.label.location="test-loc" --export.label.cluster="test-cluster"` +
+			` --export.debug.shard-buffer-size=5000 --export.debug.batch-size=100 --export.debug.shard-count=512 --export.debug.batch-delay=200ms`,
+	}}
+	if diff := cmp.Diff(want, ds.Spec.Template.Spec.Containers[0].Env); diff != "" {
+		t.Errorf("unexpected collector environment (-want, +got): %s", diff)
+	}
+}
+
+func TestCollectionExportCompression(t *testing.T) {
+	logger := testr.New(t)
+	ctx := logr.NewContext(context.Background(), logger)
+	opts := Options{
+		ProjectID: "test-proj",
+		Location:  "test-loc",
+		Cluster:   "test-cluster",
+	}
+	if err := opts.defaultAndValidate(logger); err != nil {
+		t.Fatal("Invalid options:", err)
+	}
+	baseFlags := `--export.label.project-id="test-proj" --export.label.location="test-loc" --export.label.cluster="test-cluster"`
+
+	cases := []struct {
+		compression monitoringv1.CompressionType
+		want        string
+	}{
+		{compression: "", want: baseFlags},
+		{compression: monitoringv1.CompressionNone, want: baseFlags},
+		{compression: monitoringv1.CompressionGzip, want: baseFlags + " --export.compression=gzip"},
+	}
+	for _, c := range cases {
+		t.Run(string(c.compression), func(t *testing.T) {
+			oc := &monitoringv1.OperatorConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: opts.PublicNamespace,
+					Name:      NameOperatorConfig,
+				},
+				Collection: monitoringv1.CollectionSpec{
+					Compression: c.compression,
+				},
+			}
+			ds := &appsv1.DaemonSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: opts.OperatorNamespace,
+					Name:      NameCollector,
+				},
+				Spec: appsv1.DaemonSetSpec{
+					Template: corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{
+							Containers: []corev1.Container{
+								{Name: "prometheus"},
+							},
+						},
+					},
+				},
+			}
+			kubeClient := newFakeClientBuilder().WithObjects(oc, ds).Build()
+
+			r := newCollectionReconciler(kubeClient, opts)
+			if _, err := r.Reconcile(ctx, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Namespace: opts.PublicNamespace,
+					Name:      NameOperatorConfig,
+				},
+			}); err != nil {
+				t.Fatal(err)
+			}
+			if err := kubeClient.Get(ctx, client.ObjectKeyFromObject(ds), ds); err != nil {
+				t.Fatal(err)
+			}
+			want := []corev1.EnvVar{{Name: "EXTRA_ARGS", Value: c.want}}
+			if diff := cmp.Diff(want, ds.Spec.Template.Spec.Containers[0].Env); diff != "" {
+				t.Errorf("unexpected collector environment (-want, +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestCollectionOutOfOrderTimeWindow(t *testing.T) {
+	logger := testr.New(t)
+	ctx := logr.NewContext(context.Background(), logger)
+	opts := Options{
+		ProjectID: "test-proj",
+		Location:  "test-loc",
+		Cluster:   "test-cluster",
+	}
+	if err := opts.defaultAndValidate(logger); err != nil {
+		t.Fatal("Invalid options:", err)
+	}
+
+	cases := []struct {
+		window string
+		want   string
+	}{
+		{window: "", want: ""},
+		{window: "0s", want: ""},
+		{
+			window: "5m",
+			want: `storage:
+  tsdb:
+    outofordertimewindow: 300000
+    out_of_order_time_window: 5m
+`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.window, func(t *testing.T) {
+			oc := &monitoringv1.OperatorConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: opts.PublicNamespace,
+					Name:      NameOperatorConfig,
+				},
+				Collection: monitoringv1.CollectionSpec{
+					OutOfOrderTimeWindow: c.window,
+				},
+			}
+			kubeClient := newFakeClientBuilder().WithObjects(oc).Build()
+
+			r := newCollectionReconciler(kubeClient, opts)
+			if _, err := r.Reconcile(ctx, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Namespace: opts.PublicNamespace,
+					Name:      NameOperatorConfig,
+				},
+			}); err != nil {
+				t.Fatal(err)
+			}
+			var cm corev1.ConfigMap
+			if err := kubeClient.Get(ctx, client.ObjectKey{Namespace: opts.OperatorNamespace, Name: NameCollector}, &cm); err != nil {
+				t.Fatal(err)
+			}
+			// Compare the storage section of the rendered configuration.
+			var rendered yamlv2.MapSlice
+			if err := yamlv2.Unmarshal([]byte(cm.Data[configFilename]), &rendered); err != nil {
+				t.Fatal(err)
+			}
+			var got string
+			for _, item := range rendered {
+				if item.Key != "storage" {
+					continue
+				}
+				b, err := yamlv2.Marshal(yamlv2.MapSlice{item})
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = string(b)
+			}
+			if diff := cmp.Diff(c.want, got); diff != "" {
+				t.Errorf("unexpected storage config (-want, +got): %s", diff)
+			}
+			// The collectors must parse the window in the same unit as sample timestamps.
+			if c.want != "" {
+				cfg, err := promconfig.Load(cm.Data[configFilename], false, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got, want := cfg.StorageConfig.TSDBConfig.OutOfOrderTimeWindow, int64(5*time.Minute/time.Millisecond); got != want {
+					t.Errorf("expected out-of-order time window of %dms, got %dms", want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestCollectionCollectorLabel(t *testing.T) {
+	logger := testr.New(t)
+	ctx := logr.NewContext(context.Background(), logger)
+	opts := Options{
+		ProjectID: "test-proj",
+		Location:  "test-loc",
+		Cluster:   "test-cluster",
+	}
+	if err := opts.defaultAndValidate(logger); err != nil {
+		t.Fatal("Invalid options:", err)
+	}
+	oc := &monitoringv1.OperatorConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: opts.PublicNamespace,
+			Name:      NameOperatorConfig,
+		},
+		Collection: monitoringv1.CollectionSpec{
+			CollectorLabel: &monitoringv1.CollectorLabel{},
+		},
+	}
+	pm := &monitoringv1.PodMonitoring{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "prom-example",
+			Namespace: "gmp-test",
+		},
+		Spec: monitoringv1.PodMonitoringSpec{
+			Endpoints: []monitoringv1.ScrapeEndpoint{{
+				Port:     intstr.FromString("metrics"),
+				Interval: "10s",
+			}},
+		},
+	}
+	nodeNameEnv := corev1.EnvVar{
+		Name: "NODE_NAME",
+		ValueFrom: &corev1.EnvVarSource{
+			FieldRef: &corev1.ObjectFieldSelector{
+				APIVersion: "v1",
+				FieldPath:  "spec.nodeName",
+			},
+		},
+	}
+	ds := &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: opts.OperatorNamespace,
+			Name:      NameCollector,
+		},
+		Spec: appsv1.DaemonSetSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{Name: "config-reloader", Env: []corev1.EnvVar{nodeNameEnv}},
+						{Name: "prometheus"},
+					},
+				},
+			},
+		},
+	}
+	kubeClient := newFakeClientBuilder().WithObjects(oc, pm, ds).Build()
+	r := newCollectionReconciler(kubeClient, opts)
+
+	reconcileAndGetConfig := func() (*promconfig.Config, []corev1.EnvVar) {
+		t.Helper()
+		if _, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Namespace: opts.PublicNamespace,
+				Name:      NameOperatorConfig,
+			},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		var cm corev1.ConfigMap
+		if err := kubeClient.Get(ctx, client.ObjectKey{Namespace: opts.OperatorNamespace, Name: NameCollector}, &cm); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := promconfig.Load(cm.Data[configFilename], false, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := kubeClient.Get(ctx, client.ObjectKeyFromObject(ds), ds); err != nil {
+			t.Fatal(err)
+		}
+		return cfg, ds.Spec.Template.Spec.Containers[0].Env
+	}
+
+	cfg, env := reconcileAndGetConfig()
+	relabelCfgs := cfg.ScrapeConfigs[0].RelabelConfigs
+	b, err := yamlv2.Marshal(relabelCfgs[len(relabelCfgs)-1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantRelabel := `separator: ;
+regex: (.*)
+target_label: collector
+replacement: $(POD_NAME)
+action: replace
+`
+	if diff := cmp.Diff(wantRelabel, string(b)); diff != "" {
+		t.Errorf("unexpected collector relabeling YAML (-want, +got): %s", diff)
+	}
+	wantEnv := []corev1.EnvVar{
+		nodeNameEnv,
+		{
+			Name: "POD_NAME",
+			ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{
+					APIVersion: "v1",
+					FieldPath:  "metadata.name",
+				},
+			},
+		},
+	}
+	if diff := cmp.Diff(wantEnv, env); diff != "" {
+		t.Errorf("unexpected config-reloader environment (-want, +got): %s", diff)
+	}
+
+	// Disabling the label removes the relabeling rule and the environment variable.
+	if err := kubeClient.Get(ctx, client.ObjectKeyFromObject(oc), oc); err != nil {
+		t.Fatal(err)
+	}
+	oc.Collection.CollectorLabel = nil
+	if err := kubeClient.Update(ctx, oc); err != nil {
+		t.Fatal(err)
+	}
+	cfg, env = reconcileAndGetConfig()
+	for _, rcfg := range cfg.ScrapeConfigs[0].RelabelConfigs {
+		if rcfg.TargetLabel == "collector" {
+			t.Errorf("unexpected collector relabeling rule %+v", rcfg)
+		}
+	}
+	if diff := cmp.Diff([]corev1.EnvVar{nodeNameEnv}, env); diff != "" {
+		t.Errorf("unexpected config-reloader environment (-want, +got): %s", diff)
+	}
+}
+
+func TestCollectionReconcilePaused(t *testing.T) {
+	logger := testr.New(t)
+	ctx := logr.NewContext(context.Background(), logger)
+	opts := Options{
+		ProjectID: "test-proj",
+		Location:  "test-loc",
+		Cluster:   "test-cluster",
+	}
+	if err := opts.defaultAndValidate(logger); err != nil {
+		t.Fatal("Invalid options:", err)
+	}
+
+	for _, compression := range []monitoringv1.CompressionType{monitoringv1.CompressionNone, monitoringv1.CompressionGzip} {
+		t.Run(string(compression), func(t *testing.T) {
+			oc := &monitoringv1.OperatorConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: opts.PublicNamespace,
+					Name:      NameOperatorConfig,
+				},
+				Features: monitoringv1.OperatorFeatures{
+					Config: monitoringv1.ConfigSpec{
+						Compression: compression,
+					},
+				},
+			}
+			pm := &monitoringv1.PodMonitoring{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "prom-example",
+					Namespace: "gmp-test",
+				},
+				Spec: monitoringv1.PodMonitoringSpec{
+					Endpoints: []monitoringv1.ScrapeEndpoint{{
+						Port:     intstr.FromString("metrics"),
+						Interval: "10s",
+					}},
+				},
+			}
+			kubeClient := newFakeClientBuilder().WithObjects(oc, pm).Build()
+			r := newCollectionReconciler(kubeClient, opts)
+
+			// reconcileExpect regenerates the config and checks the scrape interval of the
+			// PodMonitoring's job and its paused condition.
+			reconcileExpect := func(wantInterval model.Duration, wantPaused corev1.ConditionStatus) {
+				t.Helper()
+				if _, err := r.Reconcile(ctx, reconcile.Request{
+					NamespacedName: types.NamespacedName{
+						Namespace: opts.PublicNamespace,
+						Name:      NameOperatorConfig,
+					},
+				}); err != nil {
+					t.Fatal(err)
+				}
+				cfgs, err := r.appliedScrapeConfigs(ctx)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var intervals []model.Duration
+				for _, cfg := range cfgs {
+					if cfg.JobName == "PodMonitoring/gmp-test/prom-example/metrics" {
+						intervals = append(intervals, cfg.ScrapeInterval)
+					}
+				}
+				if diff := cmp.Diff([]model.Duration{wantInterval}, intervals); diff != "" {
+					t.Errorf("unexpected scrape intervals (-want, +got): %s", diff)
+				}
+
+				if err := kubeClient.Get(ctx, client.ObjectKeyFromObject(pm), pm); err != nil {
+					t.Fatal(err)
+				}
+				var gotPaused corev1.ConditionStatus
+				for _, cond := range pm.Status.Conditions {
+					if cond.Type == monitoringv1.ReconciliationPaused {
+						gotPaused = cond.Status
+					}
+				}
+				if gotPaused != wantPaused {
+					t.Errorf("expected paused condition %q, got %q", wantPaused, gotPaused)
+				}
+			}
+			update := func(paused bool, interval string) {
+				t.Helper()
+				pm.Annotations = nil
+				if paused {
+					pm.Annotations = map[string]string{AnnotationReconcile: "false"}
+				}
+				pm.Spec.Endpoints[0].Interval = interval
+				if err := kubeClient.Update(ctx, pm); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			reconcileExpect(model.Duration(10*time.Second), "")
+
+			// Changes to a paused PodMonitoring are not applied.
+			update(true, "30s")
+			reconcileExpect(model.Duration(10*time.Second), corev1.ConditionTrue)
+			update(true, "1m")
+			reconcileExpect(model.Duration(10*time.Second), corev1.ConditionTrue)
+
+			// Resuming applies the latest state.
+			update(false, "1m")
+			reconcileExpect(model.Duration(time.Minute), corev1.ConditionFalse)
+		})
+	}
+}
+
+func TestCollectionNamespaceSelector(t *testing.T) {
+	logger := testr.New(t)
+	ctx := logr.NewContext(context.Background(), logger)
+	opts := Options{
+		ProjectID: "test-proj",
+		Location:  "test-loc",
+		Cluster:   "test-cluster",
+	}
+	if err := opts.defaultAndValidate(logger); err != nil {
+		t.Fatal("Invalid options:", err)
+	}
+	oc := &monitoringv1.OperatorConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: opts.PublicNamespace,
+			Name:      NameOperatorConfig,
+		},
+	}
+	cm := &monitoringv1.ClusterPodMonitoring{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "prom-example",
+		},
+		Spec: monitoringv1.ClusterPodMonitoringSpec{
+			NamespaceSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"team": "a"},
+			},
+			Endpoints: []monitoringv1.ScrapeEndpoint{{
+				Port:     intstr.FromString("metrics"),
+				Interval: "10s",
+			}},
+		},
+	}
+	namespace := func(name, team string) *corev1.Namespace {
+		return &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: map[string]string{"team": team},
+			},
+		}
+	}
+	kubeClient := newFakeClientBuilder().WithObjects(
+		oc, cm,
+		namespace("team-a-prod", "a"),
+		namespace("team-a-dev", "a"),
+		namespace("team-b", "b"),
+	).Build()
+
+	r := newCollectionReconciler(kubeClient, opts)
+	if _, err := r.Reconcile(ctx, reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Namespace: opts.PublicNamespace,
+			Name:      NameOperatorConfig,
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	var configMap corev1.ConfigMap
+	if err := kubeClient.Get(ctx, client.ObjectKey{Namespace: opts.OperatorNamespace, Name: NameCollector}, &configMap); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := promconfig.Load(configMap.Data[configFilename], false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.ScrapeConfigs) != 1 {
+		t.Fatalf("expected 1 scrape config, got %d", len(cfg.ScrapeConfigs))
+	}
+	var got []string
+	for _, rcfg := range cfg.ScrapeConfigs[0].RelabelConfigs {
+		if rcfg.Action == relabel.Keep && len(rcfg.SourceLabels) == 1 && rcfg.SourceLabels[0] == "__meta_kubernetes_namespace" {
+			got = append(got, rcfg.Regex.String())
+		}
+	}
+	if diff := cmp.Diff([]string{"team-a-dev|team-a-prod"}, got); diff != "" {
+		t.Errorf("unexpected namespace keep regexes (-want, +got): %s", diff)
+	}
+}
+
+var updateGolden = flag.Bool("update-golden", false, "update the golden files in testdata")
+
+// Tests that the same resources always result in the same collector config, regardless of
+// the order of map fields and of the listed resources.
+func TestCollectionConfigStable(t *testing.T) {
+	logger := testr.New(t)
+	ctx := logr.NewContext(context.Background(), logger)
+	opts := Options{
+		ProjectID: "test-proj",
+		Location:  "test-loc",
+		Cluster:   "test-cluster",
+	}
+	if err := opts.defaultAndValidate(logger); err != nil {
+		t.Fatal("Invalid options:", err)
+	}
+	newObjects := func() []client.Object {
+		return []client.Object{
+			&monitoringv1.OperatorConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: opts.PublicNamespace,
+					Name:      NameOperatorConfig,
+				},
+				Collection: monitoringv1.CollectionSpec{
+					ExternalLabels: map[string]string{"zone": "a", "env": "prod", "team": "x"},
+				},
+			},
+			&monitoringv1.PodMonitoring{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "gmp-test", Name: "b-example"},
+				Spec: monitoringv1.PodMonitoringSpec{
+					Selector: metav1.LabelSelector{
+						MatchLabels: map[string]string{"tier": "web", "app": "example", "env": "prod"},
+						MatchExpressions: []metav1.LabelSelectorRequirement{
+							{Key: "track", Operator: metav1.LabelSelectorOpIn, Values: []string{"stable", "canary", "beta"}},
+						},
+					},
+					Endpoints: []monitoringv1.ScrapeEndpoint{{
+						Port:     intstr.FromString("metrics"),
+						Interval: "10s",
+						Params:   map[string][]string{"module": {"http"}, "format": {"text"}, "debug": {"false"}},
+					}},
+					TargetLabels: monitoringv1.TargetLabels{
+						FromPod: []monitoringv1.LabelMapping{{From: "app"}, {From: "tier"}},
+					},
+				},
+			},
+			&monitoringv1.PodMonitoring{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "gmp-test", Name: "a-example"},
+				Spec: monitoringv1.PodMonitoringSpec{
+					Selector: metav1.LabelSelector{
+						MatchLabels: map[string]string{"app": "other"},
+					},
+					Endpoints: []monitoringv1.ScrapeEndpoint{
+						{Port: intstr.FromString("web"), Interval: "10s"},
+						{Port: intstr.FromString("admin"), Interval: "30s"},
+					},
+					LabelOverrides: map[string]string{"location": "other-loc", "cluster": "other-cluster"},
+				},
+			},
+			&monitoringv1.ClusterPodMonitoring{
+				ObjectMeta: metav1.ObjectMeta{Name: "example"},
+				Spec: monitoringv1.ClusterPodMonitoringSpec{
+					Selector: metav1.LabelSelector{
+						MatchLabels: map[string]string{"b": "2", "a": "1", "c": "3"},
+						MatchExpressions: []metav1.LabelSelectorRequirement{
+							{Key: "d", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"z", "y", "x"}},
+						},
+					},
+					Endpoints: []monitoringv1.ScrapeEndpoint{{Port: intstr.FromString("metrics"), Interval: "10s"}},
+				},
+			},
+		}
+	}
+
+	const runs = 20
+	var want string
+	for i := 0; i < runs; i++ {
+		objs := newObjects()
+		rand.Shuffle(len(objs), func(i, j int) { objs[i], objs[j] = objs[j], objs[i] })
+
+		kubeClient := newFakeClientBuilder().WithObjects(objs...).Build()
+		r := newCollectionReconciler(kubeClient, opts)
+		if _, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Namespace: opts.PublicNamespace,
+				Name:      NameOperatorConfig,
+			},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		var cm corev1.ConfigMap
+		if err := kubeClient.Get(ctx, client.ObjectKey{Namespace: opts.OperatorNamespace, Name: NameCollector}, &cm); err != nil {
+			t.Fatal(err)
+		}
+		got := cm.Data[configFilename]
+		if i == 0 {
+			want = got
+			continue
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Fatalf("config of run %d differs from first run (-want, +got): %s", i, diff)
+		}
+	}
+
+	golden := filepath.Join("testdata", "collector-config.golden.yaml")
+	if *updateGolden {
+		if err := os.WriteFile(golden, []byte(want), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(string(b), want); diff != "" {
+		t.Errorf("unexpected collector config, run with -update-golden to update (-want, +got): %s", diff)
+	}
+}
+
+func TestCollectionEvents(t *testing.T) {
+	logger := testr.New(t)
+	ctx := logr.NewContext(context.Background(), logger)
+	opts := Options{
+		ProjectID: "test-proj",
+		Location:  "test-loc",
+		Cluster:   "test-cluster",
+	}
+	if err := opts.defaultAndValidate(logger); err != nil {
+		t.Fatal("Invalid options:", err)
+	}
+	oc := &monitoringv1.OperatorConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: opts.PublicNamespace,
+			Name:      NameOperatorConfig,
+		},
+	}
+	pm := &monitoringv1.PodMonitoring{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "prom-example",
+			Namespace: "gmp-test",
+		},
+		Spec: monitoringv1.PodMonitoringSpec{
+			Endpoints: []monitoringv1.ScrapeEndpoint{{
+				Port:     intstr.FromString("metrics"),
+				Interval: "invalid",
+			}},
+		},
+	}
+	kubeClient := newFakeClientBuilder().WithObjects(oc, pm).Build()
+	recorder := record.NewFakeRecorder(10)
+	r := newCollectionReconciler(kubeClient, opts)
+	r.events = newScrapeConfigEvents(recorder)
+
+	reconcileExpect := func(want []string) {
+		t.Helper()
+		if _, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Namespace: opts.PublicNamespace,
+				Name:      NameOperatorConfig,
+			},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for len(recorder.Events) > 0 {
+			got = append(got, <-recorder.Events)
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("unexpected events (-want, +got): %s", diff)
+		}
+	}
+
+	// Failures are only reported once.
+	reconcileExpect([]string{
+		`Warning ScrapeConfigError Generating scrape config failed: invalid definition for endpoint with index 0: invalid scrape interval: not a valid duration string: "invalid"`,
+	})
+	reconcileExpect(nil)
+
+	// The recovery is reported once the scrape config is generated again.
+	if err := kubeClient.Get(ctx, client.ObjectKeyFromObject(pm), pm); err != nil {
+		t.Fatal(err)
+	}
+	pm.Spec.Endpoints[0].Interval = "10s"
+	if err := kubeClient.Update(ctx, pm); err != nil {
+		t.Fatal(err)
+	}
+	reconcileExpect([]string{
+		"Normal ScrapeConfigRecovered Scrape config generated successfully",
+	})
+	reconcileExpect(nil)
+}
+
+func TestCollectionGeneratedConfigHash(t *testing.T) {
+	logger := testr.New(t)
+	ctx := logr.NewContext(context.Background(), logger)
+	opts := Options{
+		ProjectID:        "test-proj",
+		Location:         "test-loc",
+		Cluster:          "test-cluster",
+		ReportConfigHash: true,
+	}
+	if err := opts.defaultAndValidate(logger); err != nil {
+		t.Fatal("Invalid options:", err)
+	}
+
+	oc := &monitoringv1.OperatorConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: opts.PublicNamespace,
+			Name:      NameOperatorConfig,
+		},
+	}
+	pm := &monitoringv1.PodMonitoring{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "prom-example",
+			Namespace: "gmp-test",
+		},
+		Spec: monitoringv1.PodMonitoringSpec{
+			Endpoints: []monitoringv1.ScrapeEndpoint{{
+				Port:     intstr.FromString("metrics"),
+				Interval: "10s",
+			}},
+		},
+	}
+	cm := &monitoringv1.ClusterNodeMonitoring{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "node-example",
+		},
+		Spec: monitoringv1.ClusterNodeMonitoringSpec{
+			Endpoints: []monitoringv1.ScrapeNodeEndpoint{{
+				Path:     "/metrics",
+				Interval: "10s",
+			}},
+		},
+	}
+	kubeClient := newFakeClientBuilder().WithObjects(oc, pm, cm).Build()
+	r := newCollectionReconciler(kubeClient, opts)
+
+	// reconcileHashes regenerates the config and returns the hashes reported by the
+	// PodMonitoring and the ClusterNodeMonitoring.
+	reconcileHashes := func() (string, string) {
+		t.Helper()
+		if _, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Namespace: opts.PublicNamespace,
+				Name:      NameOperatorConfig,
+			},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if err := kubeClient.Get(ctx, client.ObjectKeyFromObject(pm), pm); err != nil {
+			t.Fatal(err)
+		}
+		if err := kubeClient.Get(ctx, client.ObjectKeyFromObject(cm), cm); err != nil {
+			t.Fatal(err)
+		}
+		return pm.Status.GeneratedConfigHash, cm.Status.GeneratedConfigHash
+	}
+
+	pmHash, cmHash := reconcileHashes()
+	if pmHash == "" || cmHash == "" {
+		t.Fatalf("expected config hashes to be reported, got %q and %q", pmHash, cmHash)
+	}
+
+	// The hashes are stable if nothing changed.
+	if gotPM, gotCM := reconcileHashes(); gotPM != pmHash || gotCM != cmHash {
+		t.Errorf("expected unchanged hashes %q and %q, got %q and %q", pmHash, cmHash, gotPM, gotCM)
+	}
+
+	// Spec changes update the hash of the changed resource only.
+	pm.Spec.Endpoints[0].Interval = "30s"
+	if err := kubeClient.Update(ctx, pm); err != nil {
+		t.Fatal(err)
+	}
+	gotPM, gotCM := reconcileHashes()
+	if gotPM == "" || gotPM == pmHash {
+		t.Errorf("expected PodMonitoring hash to change from %q, got %q", pmHash, gotPM)
+	}
+	if gotCM != cmHash {
+		t.Errorf("expected unchanged ClusterNodeMonitoring hash %q, got %q", cmHash, gotCM)
+	}
+
+	cm.Spec.Endpoints[0].Interval = "30s"
+	if err := kubeClient.Update(ctx, cm); err != nil {
+		t.Fatal(err)
+	}
+	if _, gotCM := reconcileHashes(); gotCM == "" || gotCM == cmHash {
+		t.Errorf("expected ClusterNodeMonitoring hash to change from %q, got %q", cmHash, gotCM)
+	}
+
+	// Disabling the option clears the hashes.
+	r.opts.ReportConfigHash = false
+	if gotPM, gotCM := reconcileHashes(); gotPM != "" || gotCM != "" {
+		t.Errorf("expected hashes to be cleared, got %q and %q", gotPM, gotCM)
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	promconfig "github.com/prometheus/prometheus/config"
+	yaml "gopkg.in/yaml.v2"
+
+	monitoringv1 "github.com/GoogleCloudPlatform/prometheus-engine/pkg/operator/apis/monitoring/v1"
+)
+
+// generatedConfigHash returns the SHA-256 hash of the scrape configs generated for a
+// monitoring resource. Generated scrape configs are reproducible, so the hash only changes
+// if the scrape configs do.
+func generatedConfigHash(cfgs []*promconfig.ScrapeConfig) (string, error) {
+	b, err := yaml.Marshal(cfgs)
+	if err != nil {
+		return "", fmt.Errorf("marshal scrape configs: %w", err)
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// setGeneratedConfigHash sets the hash of the generated scrape configs in the status if
+// enabled and clears it otherwise. It returns whether the status changed.
+func (r *collectionReconciler) setGeneratedConfigHash(status *monitoringv1.MonitoringStatus, cfgs []*promconfig.ScrapeConfig) (bool, error) {
+	var hash string
+	if r.opts.ReportConfigHash {
+		var err error
+		if hash, err = generatedConfigHash(cfgs); err != nil {
+			return false, err
+		}
+	}
+	if status.GeneratedConfigHash == hash {
+		return false, nil
+	}
+	status.GeneratedConfigHash = hash
+	return true, nil
+}
@@ -0,0 +1,112 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	monitoringv1 "github.com/GoogleCloudPlatform/prometheus-engine/pkg/operator/apis/monitoring/v1"
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// EffectiveConfig is the resolved configuration the operator runs with.
+type EffectiveConfig struct {
+	Options EffectiveOptions `json:"options"`
+	// Minimum interval between polls of the collectors' targets for target status.
+	MinTargetStatusPollInterval string `json:"minTargetStatusPollInterval"`
+	// Features of the OperatorConfig, which are zero if it does not exist.
+	Features monitoringv1.OperatorFeatures `json:"features"`
+}
+
+// EffectiveOptions are the operator options after defaulting. Certificates and keys
+// are omitted and only reported as being set.
+type EffectiveOptions struct {
+	ProjectID                  string `json:"projectID"`
+	Location                   string `json:"location"`
+	Cluster                    string `json:"cluster"`
+	OperatorNamespace          string `json:"operatorNamespace"`
+	PublicNamespace            string `json:"publicNamespace"`
+	TLSCertSet                 bool   `json:"tlsCertSet"`
+	CACertSet                  bool   `json:"caCertSet"`
+	ListenAddr                 string `json:"listenAddr"`
+	CleanupAnnotKey            string `json:"cleanupAnnotKey"`
+	TargetPollConcurrency      uint16 `json:"targetPollConcurrency"`
+	WebhookFailurePolicy       string `json:"webhookFailurePolicy"`
+	ConfigRegenerationInterval string `json:"configRegenerationInterval"`
+	NamePattern                string `json:"namePattern"`
+	ValidateExisting           bool   `json:"validateExisting"`
+	MaxMonitorings             int    `json:"maxMonitorings"`
+}
+
+func effectiveOptions(opts Options) EffectiveOptions {
+	return EffectiveOptions{
+		ProjectID:                  opts.ProjectID,
+		Location:                   opts.Location,
+		Cluster:                    opts.Cluster,
+		OperatorNamespace:          opts.OperatorNamespace,
+		PublicNamespace:            opts.PublicNamespace,
+		TLSCertSet:                 opts.TLSCert != "" && opts.TLSKey != "",
+		CACertSet:                  opts.CACert != "",
+		ListenAddr:                 opts.ListenAddr,
+		CleanupAnnotKey:            opts.CleanupAnnotKey,
+		TargetPollConcurrency:      opts.TargetPollConcurrency,
+		WebhookFailurePolicy:       string(opts.WebhookFailurePolicy),
+		ConfigRegenerationInterval: opts.ConfigRegenerationInterval.String(),
+		NamePattern:                opts.NamePattern,
+		ValidateExisting:           opts.ValidateExisting,
+		MaxMonitorings:             opts.MaxMonitorings,
+	}
+}
+
+// configHandler serves the effective configuration of the operator.
+type configHandler struct {
+	logger     logr.Logger
+	opts       Options
+	kubeClient client.Client
+}
+
+// ConfigHandler returns a handler that serves the effective configuration of the operator
+// as JSON. The OperatorConfig is read on every request.
+func (o *Operator) ConfigHandler() http.Handler {
+	return &configHandler{
+		logger:     o.logger,
+		opts:       o.opts,
+		kubeClient: o.manager.GetClient(),
+	}
+}
+
+func (h *configHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	cfg := EffectiveConfig{
+		Options:                     effectiveOptions(h.opts),
+		MinTargetStatusPollInterval: minPollDuration.String(),
+	}
+	var oc monitoringv1.OperatorConfig
+	err := h.kubeClient.Get(req.Context(), client.ObjectKey{Namespace: h.opts.PublicNamespace, Name: NameOperatorConfig}, &oc)
+	if err != nil && !apierrors.IsNotFound(err) {
+		h.logger.Error(err, "getting OperatorConfig failed")
+		http.Error(w, fmt.Sprintf("get OperatorConfig: %s", err), http.StatusInternalServerError)
+		return
+	}
+	cfg.Features = oc.Features
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(cfg); err != nil {
+		h.logger.Error(err, "writing config failed")
+	}
+}
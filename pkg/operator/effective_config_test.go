@@ -0,0 +1,126 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	monitoringv1 "github.com/GoogleCloudPlatform/prometheus-engine/pkg/operator/apis/monitoring/v1"
+	"github.com/go-logr/logr/testr"
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestConfigHandler(t *testing.T) {
+	logger := testr.New(t)
+	opts := Options{
+		ProjectID:                  "test-proj",
+		Location:                   "test-loc",
+		Cluster:                    "test-cluster",
+		TLSCert:                    "c2VjcmV0LWNlcnQ=",
+		TLSKey:                     "c2VjcmV0LWtleQ==",
+		ConfigRegenerationInterval: 30 * time.Second,
+	}
+	if err := opts.defaultAndValidate(logger); err != nil {
+		t.Fatal(err)
+	}
+	oc := &monitoringv1.OperatorConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: opts.PublicNamespace,
+			Name:      NameOperatorConfig,
+		},
+		Features: monitoringv1.OperatorFeatures{
+			TargetStatus: monitoringv1.TargetStatusSpec{Enabled: true},
+			Config: monitoringv1.ConfigSpec{
+				Compression: monitoringv1.CompressionGzip,
+			},
+		},
+	}
+
+	cases := []struct {
+		doc     string
+		objects []*monitoringv1.OperatorConfig
+		want    EffectiveConfig
+	}{
+		{
+			doc:     "operator config",
+			objects: []*monitoringv1.OperatorConfig{oc},
+			want: EffectiveConfig{
+				Options: EffectiveOptions{
+					ProjectID:                  "test-proj",
+					Location:                   "test-loc",
+					Cluster:                    "test-cluster",
+					OperatorNamespace:          DefaultOperatorNamespace,
+					PublicNamespace:            DefaultOperatorNamespace,
+					TLSCertSet:                 true,
+					TargetPollConcurrency:      defaultTargetPollConcurrency,
+					ConfigRegenerationInterval: "30s",
+				},
+				MinTargetStatusPollInterval: "10s",
+				Features:                    oc.Features,
+			},
+		},
+		{
+			doc: "no operator config",
+			want: EffectiveConfig{
+				Options: EffectiveOptions{
+					ProjectID:                  "test-proj",
+					Location:                   "test-loc",
+					Cluster:                    "test-cluster",
+					OperatorNamespace:          DefaultOperatorNamespace,
+					PublicNamespace:            DefaultOperatorNamespace,
+					TLSCertSet:                 true,
+					TargetPollConcurrency:      defaultTargetPollConcurrency,
+					ConfigRegenerationInterval: "30s",
+				},
+				MinTargetStatusPollInterval: "10s",
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.doc, func(t *testing.T) {
+			builder := newFakeClientBuilder()
+			for _, o := range c.objects {
+				builder = builder.WithObjects(o)
+			}
+			handler := &configHandler{
+				logger:     logger,
+				opts:       opts,
+				kubeClient: builder.Build(),
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/config", nil))
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+			}
+			if strings.Contains(rec.Body.String(), opts.TLSKey) {
+				t.Errorf("response contains the TLS key: %s", rec.Body)
+			}
+			var got EffectiveConfig
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(c.want, got); diff != "" {
+				t.Errorf("unexpected config (-want, +got): %s", diff)
+			}
+		})
+	}
+}
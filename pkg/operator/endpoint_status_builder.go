@@ -16,6 +16,7 @@ package operator
 
 import (
 	"fmt"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -30,12 +31,13 @@ const (
 	maxSampleTargetSize = 5
 )
 
-func buildEndpointStatuses(targets []*prometheusv1.TargetsResult) (map[string][]monitoringv1.ScrapeEndpointStatus, error) {
+func buildEndpointStatuses(targets []*prometheusv1.TargetsResult, degradedOnTimeout bool) (map[string][]monitoringv1.ScrapeEndpointStatus, error) {
 	endpointBuilder := &scrapeEndpointBuilder{
 		mapByKeyByEndpoint: make(map[string]map[string]*scrapeEndpointStatusBuilder),
 		total:              0,
 		failed:             0,
 		time:               metav1.Now(),
+		degradedOnTimeout:  degradedOnTimeout,
 	}
 
 	for _, target := range targets {
@@ -52,6 +54,8 @@ type scrapeEndpointBuilder struct {
 	total              uint32
 	failed             uint32
 	time               metav1.Time
+	// Whether to report timed out targets as degraded rather than unhealthy.
+	degradedOnTimeout bool
 }
 
 func (b *scrapeEndpointBuilder) add(target *prometheusv1.TargetsResult) error {
@@ -141,6 +145,16 @@ func getClusterScopedScrapePool(full string, split []string) scrapePool {
 	}
 }
 
+// trimProbeTarget removes the probe target segment from the job name of a scrape
+// configuration generated for a probe target, so that it is reported as part of its endpoint.
+func trimProbeTarget(pool string, split []string, n int) (string, []string) {
+	if len(split) == n+1 && strings.HasPrefix(split[n], strings.TrimPrefix(monitoringv1.ProbeJobSuffix, "/")) {
+		split = split[:n]
+		return strings.Join(split, "/"), split
+	}
+	return pool, split
+}
+
 func parseScrapePool(pool string) (scrapePool, error) {
 	split := strings.Split(pool, "/")
 	switch split[0] {
@@ -153,11 +167,13 @@ func parseScrapePool(pool string) (scrapePool, error) {
 			group: split[1],
 		}, nil
 	case "PodMonitoring":
+		pool, split = trimProbeTarget(pool, split, 4)
 		if len(split) != 4 {
 			return scrapePool{}, fmt.Errorf("invalid PodMonitoring scrape pool format %q", pool)
 		}
 		return getNamespacedScrapePool(pool, split), nil
 	case "ClusterPodMonitoring":
+		pool, split = trimProbeTarget(pool, split, 3)
 		if len(split) != 3 {
 			return scrapePool{}, fmt.Errorf("invalid ClusterPodMonitoring scrape pool format %q", pool)
 		}
@@ -187,6 +203,7 @@ func (b *scrapeEndpointBuilder) addActiveTarget(activeTarget prometheusv1.Active
 	statusBuilder, exists := mapByEndpoint[scrapePool.group]
 	if !exists {
 		statusBuilder = newScrapeEndpointStatusBuilder(&activeTarget, time)
+		statusBuilder.degradedOnTimeout = b.degradedOnTimeout
 		mapByEndpoint[scrapePool.group] = statusBuilder
 	}
 	statusBuilder.addSampleTarget(&activeTarget)
@@ -218,8 +235,11 @@ func (b *scrapeEndpointBuilder) build() map[string][]monitoringv1.ScrapeEndpoint
 }
 
 type scrapeEndpointStatusBuilder struct {
-	status       monitoringv1.ScrapeEndpointStatus
-	groupByError map[string]*monitoringv1.SampleGroup
+	status            monitoringv1.ScrapeEndpointStatus
+	groupByError      map[string]*monitoringv1.SampleGroup
+	degradedOnTimeout bool
+	// Longest last scrape duration of the targets of each sample group.
+	maxScrapeDurationByError map[string]float64
 }
 
 func newScrapeEndpointStatusBuilder(target *prometheusv1.ActiveTarget, time metav1.Time) *scrapeEndpointStatusBuilder {
@@ -231,7 +251,8 @@ func newScrapeEndpointStatusBuilder(target *prometheusv1.ActiveTarget, time meta
 			LastUpdateTime:     time,
 			CollectorsFraction: "0",
 		},
-		groupByError: make(map[string]*monitoringv1.SampleGroup),
+		groupByError:             make(map[string]*monitoringv1.SampleGroup),
+		maxScrapeDurationByError: make(map[string]float64),
 	}
 }
 
@@ -240,12 +261,8 @@ func (b *scrapeEndpointStatusBuilder) addSampleTarget(target *prometheusv1.Activ
 	b.status.ActiveTargets++
 	errorType := target.LastError
 	lastError := &errorType
-	if target.Health == "up" {
-		if len(target.LastError) == 0 {
-			lastError = nil
-		}
-	} else {
-		b.status.UnhealthyTargets++
+	if target.Health == "up" && len(target.LastError) == 0 {
+		lastError = nil
 	}
 
 	sampleGroup, ok := b.groupByError[errorType]
@@ -255,6 +272,25 @@ func (b *scrapeEndpointStatusBuilder) addSampleTarget(target *prometheusv1.Activ
 		Labels:                    target.Labels,
 		LastScrapeDurationSeconds: strconv.FormatFloat(target.LastScrapeDuration, 'f', -1, 64),
 	}
+	if !target.LastScrape.IsZero() {
+		lastScrape := metav1.NewTime(target.LastScrape)
+		sampleTarget.LastScrapeTime = &lastScrape
+
+		if target.Health == "up" && (b.status.LastScrapeTime == nil || b.status.LastScrapeTime.Before(&lastScrape)) {
+			b.status.LastScrapeTime = lastScrape.DeepCopy()
+		}
+	}
+	if target.Health != "up" && len(target.LastError) > 0 {
+		sampleTarget.FailureReason = scrapeFailureReason(target.LastError)
+	}
+	if target.Health != "up" {
+		if b.degradedOnTimeout && sampleTarget.FailureReason == monitoringv1.ScrapeFailureTimeout {
+			sampleTarget.Health = monitoringv1.TargetHealthDegraded
+			b.status.DegradedTargets++
+		} else {
+			b.status.UnhealthyTargets++
+		}
+	}
 	if !ok {
 		sampleGroup = &monitoringv1.SampleGroup{
 			SampleTargets: []monitoringv1.SampleTarget{},
@@ -263,13 +299,17 @@ func (b *scrapeEndpointStatusBuilder) addSampleTarget(target *prometheusv1.Activ
 		b.groupByError[errorType] = sampleGroup
 	}
 	*sampleGroup.Count++
+	if d, ok := b.maxScrapeDurationByError[errorType]; !ok || target.LastScrapeDuration > d {
+		b.maxScrapeDurationByError[errorType] = target.LastScrapeDuration
+	}
 	sampleGroup.SampleTargets = append(sampleGroup.SampleTargets, sampleTarget)
 }
 
 // build a deterministic (regarding array ordering) status object.
 func (b *scrapeEndpointStatusBuilder) build() monitoringv1.ScrapeEndpointStatus {
 	// Deterministic sample group by error.
-	for _, sampleGroup := range b.groupByError {
+	for errorType, sampleGroup := range b.groupByError {
+		sampleGroup.MaxScrapeDurationSeconds = strconv.FormatFloat(b.maxScrapeDurationByError[errorType], 'f', -1, 64)
 		sort.SliceStable(sampleGroup.SampleTargets, func(i, j int) bool {
 			// Every sample target is guaranteed to have an instance label.
 			lhsInstance := sampleGroup.SampleTargets[i].Labels["instance"]
@@ -296,3 +336,46 @@ func (b *scrapeEndpointStatusBuilder) build() monitoringv1.ScrapeEndpointStatus
 	})
 	return b.status
 }
+
+var httpStatusRE = regexp.MustCompile(`server returned HTTP status (\d{3})`)
+
+// dnsErrorRE matches the message of a net.DNSError, i.e. "lookup <host>: <err>" or
+// "lookup <host> on <server>: <err>". The wrapped error may itself read like a connection
+// or timeout error of the DNS server, e.g. "i/o timeout" or "connection refused".
+var dnsErrorRE = regexp.MustCompile(`\blookup \S+( on \S+)?: `)
+
+// scrapeFailureReason classifies a scrape error message as reported by Prometheus.
+func scrapeFailureReason(lastError string) monitoringv1.ScrapeFailureReason {
+	// Token fetch errors wrap the error of the token request, which must not be mistaken
+	// for an error of the scrape request.
+	if strings.Contains(lastError, "oauth2: ") {
+		return monitoringv1.ScrapeFailureOAuth2Token
+	}
+	if m := httpStatusRE.FindStringSubmatch(lastError); m != nil {
+		switch m[1] {
+		case "401":
+			return monitoringv1.ScrapeFailureHTTPUnauthorized
+		case "403":
+			return monitoringv1.ScrapeFailureHTTPForbidden
+		case "404":
+			return monitoringv1.ScrapeFailureHTTPNotFound
+		default:
+			return monitoringv1.ScrapeFailureHTTPError
+		}
+	}
+	switch {
+	// Resolving the target's host failed. Check before other network errors, which
+	// DNS errors may wrap.
+	case dnsErrorRE.MatchString(lastError), strings.Contains(lastError, "no such host"):
+		return monitoringv1.ScrapeFailureDNSLookup
+	case strings.Contains(lastError, "connection refused"):
+		return monitoringv1.ScrapeFailureConnectionRefused
+	case strings.Contains(lastError, "tls: "), strings.Contains(lastError, "x509: "):
+		return monitoringv1.ScrapeFailureTLSHandshake
+	case strings.Contains(lastError, "context deadline exceeded"), strings.Contains(lastError, "Client.Timeout exceeded"), strings.Contains(lastError, "i/o timeout"):
+		return monitoringv1.ScrapeFailureTimeout
+	case strings.Contains(lastError, "limit exceeded"):
+		return monitoringv1.ScrapeFailureLimitExceeded
+	}
+	return monitoringv1.ScrapeFailureUnknown
+}
@@ -0,0 +1,124 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/clock"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Reasons of the events emitted on monitoring resources.
+const (
+	eventReasonScrapeConfigError     = "ScrapeConfigError"
+	eventReasonScrapeConfigRecovered = "ScrapeConfigRecovered"
+)
+
+// scrapeConfigEventDedupWindow is the time within which identical error events on the same
+// resource are only emitted once. It is well below the default TTL of events so that the
+// error remains visible while the resource keeps failing.
+const scrapeConfigEventDedupWindow = 10 * time.Minute
+
+// scrapeConfigEvents emits Kubernetes Events on monitoring resources whose scrape configs
+// fail to generate and once they are generated again, so that the errors are surfaced
+// by `kubectl describe`. Error events with the same message as the previous one are
+// deduplicated within scrapeConfigEventDedupWindow.
+//
+// The state is kept in memory, so failing resources emit an error event again after the
+// operator restarted.
+type scrapeConfigEvents struct {
+	recorder record.EventRecorder
+	clock    clock.Clock
+	// Last error event emitted for the resources whose scrape configs failed to generate,
+	// by kind and key.
+	failing map[string]scrapeConfigErrorEvent
+	// Keys of the resources seen since the last prune.
+	seenKeys map[string]struct{}
+}
+
+type scrapeConfigErrorEvent struct {
+	message string
+	time    time.Time
+}
+
+func newScrapeConfigEvents(recorder record.EventRecorder) *scrapeConfigEvents {
+	return &scrapeConfigEvents{
+		recorder: recorder,
+		clock:    clock.RealClock{},
+		failing:  map[string]scrapeConfigErrorEvent{},
+		seenKeys: map[string]struct{}{},
+	}
+}
+
+func scrapeConfigEventKey(kind string, obj client.Object) string {
+	return fmt.Sprintf("%s/%s/%s", kind, obj.GetNamespace(), obj.GetName())
+}
+
+// failed records that generating the scrape configs of the resource failed. The event is
+// skipped if the same error was emitted for the resource within the dedup window.
+func (e *scrapeConfigEvents) failed(kind string, obj client.Object, err error) {
+	if e == nil {
+		return
+	}
+	key := scrapeConfigEventKey(kind, obj)
+	now := e.clock.Now()
+	message := fmt.Sprintf("Generating scrape config failed: %s", err)
+
+	if last, ok := e.failing[key]; ok && last.message == message && now.Sub(last.time) < scrapeConfigEventDedupWindow {
+		return
+	}
+	e.failing[key] = scrapeConfigErrorEvent{message: message, time: now}
+	e.recorder.Event(obj, corev1.EventTypeWarning, eventReasonScrapeConfigError, message)
+}
+
+// succeeded records that the scrape configs of the resource were generated. An event is
+// only emitted if the previous attempt failed.
+func (e *scrapeConfigEvents) succeeded(kind string, obj client.Object) {
+	if e == nil {
+		return
+	}
+	key := scrapeConfigEventKey(kind, obj)
+	if _, ok := e.failing[key]; !ok {
+		return
+	}
+	delete(e.failing, key)
+	e.recorder.Event(obj, corev1.EventTypeNormal, eventReasonScrapeConfigRecovered, "Scrape config generated successfully")
+}
+
+// seen records that the resource exists, so that it is kept by the next prune.
+func (e *scrapeConfigEvents) seen(kind string, obj client.Object) {
+	if e == nil {
+		return
+	}
+	e.seenKeys[scrapeConfigEventKey(kind, obj)] = struct{}{}
+}
+
+// prune forgets the failing resources that were not seen since the last prune, e.g.
+// because they were deleted while failing.
+func (e *scrapeConfigEvents) prune() {
+	if e == nil {
+		return
+	}
+	for key := range e.failing {
+		if _, ok := e.seenKeys[key]; !ok {
+			delete(e.failing, key)
+		}
+	}
+	e.seenKeys = map[string]struct{}{}
+}
@@ -0,0 +1,126 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	tclock "k8s.io/utils/clock/testing"
+
+	monitoringv1 "github.com/GoogleCloudPlatform/prometheus-engine/pkg/operator/apis/monitoring/v1"
+)
+
+func TestScrapeConfigEventsDedup(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	fakeClock := tclock.NewFakeClock(time.Now())
+	e := newScrapeConfigEvents(recorder)
+	e.clock = fakeClock
+
+	pm := &monitoringv1.PodMonitoring{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "prom-example",
+			Namespace: "gmp-test",
+		},
+	}
+	other := pm.DeepCopy()
+	other.Name = "other"
+
+	expectEvents := func(want []string) {
+		t.Helper()
+		var got []string
+		for len(recorder.Events) > 0 {
+			got = append(got, <-recorder.Events)
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("unexpected events (-want, +got): %s", diff)
+		}
+	}
+	errInterval := errors.New("invalid scrape interval")
+	errTimeout := errors.New("invalid scrape timeout")
+
+	// Identical errors are deduplicated per resource within the window.
+	e.failed("PodMonitoring", pm, errInterval)
+	e.failed("PodMonitoring", pm, errInterval)
+	e.failed("PodMonitoring", other, errInterval)
+	expectEvents([]string{
+		"Warning ScrapeConfigError Generating scrape config failed: invalid scrape interval",
+		"Warning ScrapeConfigError Generating scrape config failed: invalid scrape interval",
+	})
+
+	fakeClock.Step(scrapeConfigEventDedupWindow - time.Second)
+	e.failed("PodMonitoring", pm, errInterval)
+	expectEvents(nil)
+
+	// A different error is emitted right away.
+	e.failed("PodMonitoring", pm, errTimeout)
+	expectEvents([]string{
+		"Warning ScrapeConfigError Generating scrape config failed: invalid scrape timeout",
+	})
+
+	// The same error is emitted again once the window passed.
+	fakeClock.Step(scrapeConfigEventDedupWindow)
+	e.failed("PodMonitoring", pm, errTimeout)
+	expectEvents([]string{
+		"Warning ScrapeConfigError Generating scrape config failed: invalid scrape timeout",
+	})
+
+	// After recovering, the next failure is emitted regardless of the window.
+	e.succeeded("PodMonitoring", pm)
+	e.succeeded("PodMonitoring", pm)
+	e.failed("PodMonitoring", pm, errTimeout)
+	expectEvents([]string{
+		"Normal ScrapeConfigRecovered Scrape config generated successfully",
+		"Warning ScrapeConfigError Generating scrape config failed: invalid scrape timeout",
+	})
+}
+
+func TestScrapeConfigEventsPrune(t *testing.T) {
+	e := newScrapeConfigEvents(record.NewFakeRecorder(10))
+
+	pm := &monitoringv1.PodMonitoring{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "prom-example",
+			Namespace: "gmp-test",
+		},
+	}
+	deleted := pm.DeepCopy()
+	deleted.Name = "deleted"
+	err := errors.New("invalid scrape interval")
+
+	e.seen("PodMonitoring", pm)
+	e.failed("PodMonitoring", pm, err)
+	e.seen("PodMonitoring", deleted)
+	e.failed("PodMonitoring", deleted, err)
+	e.prune()
+	if len(e.failing) != 2 {
+		t.Fatalf("expected 2 failing resources, got %d", len(e.failing))
+	}
+
+	// Resources that are no longer seen are forgotten.
+	e.seen("PodMonitoring", pm)
+	e.prune()
+	if _, ok := e.failing[scrapeConfigEventKey("PodMonitoring", pm)]; !ok || len(e.failing) != 1 {
+		t.Errorf("expected only %q to be failing, got %v", pm.Name, e.failing)
+	}
+	e.prune()
+	if len(e.failing) != 0 {
+		t.Errorf("expected no failing resources, got %d", len(e.failing))
+	}
+}
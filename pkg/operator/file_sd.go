@@ -0,0 +1,110 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	prommodel "github.com/prometheus/common/model"
+)
+
+// FileSDTargetGroup is a target group in the format of Prometheus' file-based service
+// discovery.
+type FileSDTargetGroup struct {
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels,omitempty"`
+}
+
+// fileSDHandler serves the active targets of a PodMonitoring or ClusterPodMonitoring
+// as file-based service discovery target groups. It fetches targets like the
+// targetLabelsHandler.
+type fileSDHandler struct {
+	targetLabelsHandler
+}
+
+// FileSDHandler returns a handler that serves the active targets of a monitoring resource
+// in the JSON format of Prometheus' file-based service discovery, e.g. to scrape them
+// from a Prometheus server outside of the cluster. The resource is selected through the
+// same query parameters as for TargetLabelsHandler.
+// Every target is served as its own target group with its final label set. The scheme,
+// metrics path, and URL params of the scrape are set as the respective reserved labels.
+func (o *Operator) FileSDHandler() http.Handler {
+	return &fileSDHandler{
+		targetLabelsHandler: targetLabelsHandler{
+			logger:     o.logger,
+			opts:       o.opts,
+			getTarget:  getTarget,
+			httpClient: o.opts.CollectorHTTPClient,
+			kubeClient: o.manager.GetClient(),
+		},
+	}
+}
+
+func (h *fileSDHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	key, err := monitoringKeyFromQuery(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	targets, err := fetchTargets(req.Context(), h.logger, h.opts, h.httpClient, h.getTarget, h.kubeClient)
+	if err != nil {
+		h.logger.Error(err, "fetching targets failed")
+		http.Error(w, fmt.Sprintf("fetch targets: %s", err), http.StatusInternalServerError)
+		return
+	}
+	groups := []FileSDTargetGroup{}
+	for _, t := range selectTargetLabels(key, targets) {
+		group, err := fileSDTargetGroup(t)
+		if err != nil {
+			h.logger.Error(err, "skipping target with invalid scrape URL", "url", t.ScrapeURL)
+			continue
+		}
+		groups = append(groups, group)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(groups); err != nil {
+		h.logger.Error(err, "writing file SD target groups failed")
+	}
+}
+
+// fileSDTargetGroup converts the target into a target group that, when scraped, results
+// in the same scrape URL and label set.
+func fileSDTargetGroup(t TargetLabels) (FileSDTargetGroup, error) {
+	u, err := url.Parse(t.ScrapeURL)
+	if err != nil {
+		return FileSDTargetGroup{}, err
+	}
+	if u.Host == "" {
+		return FileSDTargetGroup{}, fmt.Errorf("scrape URL %q has no host", t.ScrapeURL)
+	}
+	labels := make(map[string]string, len(t.Labels)+2)
+	for k, v := range t.Labels {
+		labels[k] = v
+	}
+	labels[prommodel.SchemeLabel] = u.Scheme
+	labels[prommodel.MetricsPathLabel] = u.Path
+	for name, values := range u.Query() {
+		if len(values) > 0 {
+			labels[prommodel.ParamLabelPrefix+name] = values[0]
+		}
+	}
+	return FileSDTargetGroup{
+		Targets: []string{u.Host},
+		Labels:  labels,
+	}, nil
+}
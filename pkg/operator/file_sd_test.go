@@ -0,0 +1,126 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+)
+
+func TestFileSDHandler(t *testing.T) {
+	handler := &fileSDHandler{targetLabelsHandler: *newTestTargetLabelsHandler(t)}
+
+	cases := []struct {
+		doc    string
+		query  string
+		status int
+		want   []*targetgroup.Group
+	}{
+		{
+			doc:    "pod monitoring",
+			query:  "kind=PodMonitoring&namespace=gmp-test&name=example",
+			status: http.StatusOK,
+			want: []*targetgroup.Group{
+				{
+					Targets: []model.LabelSet{{"__address__": "10.0.0.1:9090"}},
+					Labels: model.LabelSet{
+						"__scheme__": "http", "__metrics_path__": "/metrics",
+						"instance": "node-a:admin", "job": "example", "namespace": "gmp-test", "pod": "example-1",
+					},
+				},
+				{
+					Targets: []model.LabelSet{{"__address__": "10.0.0.1:8080"}},
+					Labels: model.LabelSet{
+						"__scheme__": "http", "__metrics_path__": "/metrics",
+						"instance": "node-a:metrics", "job": "example", "namespace": "gmp-test", "pod": "example-1",
+					},
+				},
+				{
+					Targets: []model.LabelSet{{"__address__": "10.0.0.2:8080"}},
+					Labels: model.LabelSet{
+						"__scheme__": "http", "__metrics_path__": "/metrics",
+						"instance": "node-a:metrics", "job": "example", "namespace": "gmp-test", "pod": "example-2",
+					},
+				},
+			},
+		},
+		{
+			doc:    "no targets",
+			query:  "kind=ClusterPodMonitoring&name=other",
+			status: http.StatusOK,
+			want:   []*targetgroup.Group{},
+		},
+		{
+			doc:    "missing name",
+			query:  "kind=ClusterPodMonitoring",
+			status: http.StatusBadRequest,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.doc, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/file-sd?"+c.query, nil))
+
+			if rec.Code != c.status {
+				t.Fatalf("expected status %d, got %d: %s", c.status, rec.Code, rec.Body)
+			}
+			if c.status != http.StatusOK {
+				return
+			}
+			// Decode the response like Prometheus' file-based service discovery, which rejects
+			// unknown fields and invalid label names.
+			var got []*targetgroup.Group
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(c.want, got); diff != "" {
+				t.Errorf("unexpected target groups (-want, +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestFileSDTargetGroupParams(t *testing.T) {
+	got, err := fileSDTargetGroup(TargetLabels{
+		ScrapeURL: "https://10.0.0.1:9115/probe?module=http_2xx&target=example.com",
+		Labels:    map[string]string{"instance": "example.com"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := FileSDTargetGroup{
+		Targets: []string{"10.0.0.1:9115"},
+		Labels: map[string]string{
+			"__scheme__":       "https",
+			"__metrics_path__": "/probe",
+			"__param_module":   "http_2xx",
+			"__param_target":   "example.com",
+			"instance":         "example.com",
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected target group (-want, +got): %s", diff)
+	}
+
+	if _, err := fileSDTargetGroup(TargetLabels{ScrapeURL: "/metrics"}); err == nil {
+		t.Error("expected error for scrape URL without host")
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	monitoringv1 "github.com/GoogleCloudPlatform/prometheus-engine/pkg/operator/apis/monitoring/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// countMonitorings returns the total number of PodMonitorings and ClusterPodMonitorings.
+func countMonitorings(ctx context.Context, c client.Reader) (int, error) {
+	var (
+		podMons        monitoringv1.PodMonitoringList
+		clusterPodMons monitoringv1.ClusterPodMonitoringList
+	)
+	if err := c.List(ctx, &podMons); err != nil {
+		return 0, fmt.Errorf("failed to list PodMonitorings: %w", err)
+	}
+	if err := c.List(ctx, &clusterPodMons); err != nil {
+		return 0, fmt.Errorf("failed to list ClusterPodMonitorings: %w", err)
+	}
+	return len(podMons.Items) + len(clusterPodMons.Items), nil
+}
+
+// ensureMonitoringLimitCondition reports on the OperatorConfig whether the maximum number of
+// monitorings is reached.
+func (r *collectionReconciler) ensureMonitoringLimitCondition(ctx context.Context, config *monitoringv1.OperatorConfig) error {
+	var n int
+	if r.opts.MaxMonitorings > 0 {
+		var err error
+		if n, err = countMonitorings(ctx, r.client); err != nil {
+			return err
+		}
+	}
+	if !setMonitoringLimitCondition(&config.Status, n, r.opts.MaxMonitorings) {
+		return nil
+	}
+	return r.client.Status().Update(ctx, config)
+}
+
+// setMonitoringLimitCondition sets the MonitoringLimitReached condition if the limit is reached
+// and clears it otherwise. It returns whether the status changed.
+func setMonitoringLimitCondition(status *monitoringv1.OperatorConfigStatus, n, limit int) bool {
+	cond := monitoringv1.MonitoringCondition{
+		Type:   monitoringv1.MonitoringLimitReached,
+		Status: corev1.ConditionFalse,
+	}
+	if limit > 0 && n >= limit {
+		cond.Status = corev1.ConditionTrue
+		cond.Reason = "MaxMonitorings"
+		cond.Message = fmt.Sprintf("%d of at most %d PodMonitorings and ClusterPodMonitorings exist, creating further ones is rejected", n, limit)
+	} else if !slices.ContainsFunc(status.Conditions, func(c monitoringv1.MonitoringCondition) bool {
+		return c.Type == monitoringv1.MonitoringLimitReached
+	}) {
+		return false
+	}
+	return status.SetCondition(metav1.Now(), cond)
+}
@@ -0,0 +1,43 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	monitoringv1 "github.com/GoogleCloudPlatform/prometheus-engine/pkg/operator/apis/monitoring/v1"
+)
+
+// selectedNamespaces returns the namespaces matching the namespace selector of the
+// ClusterPodMonitoring. It returns nil if pods in all namespaces are selected.
+func selectedNamespaces(ctx context.Context, c client.Reader, cm *monitoringv1.ClusterPodMonitoring) ([]string, error) {
+	selector, err := cm.NamespaceLabelSelector()
+	if err != nil || selector == nil {
+		return nil, err
+	}
+	var namespaces corev1.NamespaceList
+	if err := c.List(ctx, &namespaces, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+	res := make([]string, 0, len(namespaces.Items))
+	for _, ns := range namespaces.Items {
+		res = append(res, ns.Name)
+	}
+	return res, nil
+}
@@ -24,6 +24,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"time"
 
@@ -33,6 +34,7 @@ import (
 	arv1 "k8s.io/api/admissionregistration/v1"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
@@ -129,6 +131,37 @@ type Options struct {
 	TargetPollConcurrency uint16
 	// The HTTP client to use when targeting collector endpoints.
 	CollectorHTTPClient *http.Client
+	// Failure policy enforced on the operator's admission webhooks. If empty,
+	// the policy of the installed webhook configurations is left unchanged.
+	WebhookFailurePolicy arv1.FailurePolicyType
+	// Minimum interval between regenerations of the collector configuration.
+	// Changes within the interval are coalesced into a single update of the
+	// collector configuration, Secret, and DaemonSet. Zero regenerates the
+	// configuration on every change.
+	ConfigRegenerationInterval time.Duration
+	// Regular expression that names of newly created PodMonitorings and ClusterPodMonitorings
+	// must fully match. Any name is permitted if empty.
+	NamePattern string
+	// Validate all existing PodMonitorings and ClusterPodMonitorings at startup and report
+	// the ones that the admission webhooks would reject.
+	ValidateExisting bool
+	// Maximum total number of PodMonitorings and ClusterPodMonitorings. Creating further ones
+	// is rejected by the admission webhooks. Zero permits any number.
+	MaxMonitorings int
+	// Warn on creation and update of ClusterPodMonitorings with the approximate number of
+	// targets they match. Requires permission to list pods in all namespaces.
+	EstimateTargets bool
+	// Emit Kubernetes Events on PodMonitorings, ClusterPodMonitorings, and
+	// ClusterNodeMonitorings when generating their scrape configs fails and once it
+	// succeeds again. Requires permission to create and patch events in all namespaces.
+	EmitEvents bool
+	// Report the hash of the scrape configs generated for PodMonitorings,
+	// ClusterPodMonitorings, and ClusterNodeMonitorings in their status.
+	ReportConfigHash bool
+	// Resolve Secrets referenced by PodMonitorings, such as basic auth usernames, and
+	// provide them to the collectors. Changes of the Secrets are watched. Requires
+	// permission to get, list, and watch Secrets in all namespaces.
+	ResolveScrapeSecrets bool
 }
 
 func (o *Options) defaultAndValidate(_ logr.Logger) error {
@@ -151,6 +184,23 @@ func (o *Options) defaultAndValidate(_ logr.Logger) error {
 		return errors.New("cluster must be set")
 	}
 
+	switch o.WebhookFailurePolicy {
+	case "", arv1.Fail, arv1.Ignore:
+	default:
+		return fmt.Errorf("invalid webhook failure policy %q, must be one of %q or %q", o.WebhookFailurePolicy, arv1.Fail, arv1.Ignore)
+	}
+
+	if _, err := o.namePatternRegexp(); err != nil {
+		return fmt.Errorf("invalid name pattern: %w", err)
+	}
+
+	if o.ConfigRegenerationInterval < 0 {
+		return fmt.Errorf("config regeneration interval must not be negative, got %s", o.ConfigRegenerationInterval)
+	}
+	if o.MaxMonitorings < 0 {
+		return fmt.Errorf("max monitorings must not be negative, got %d", o.MaxMonitorings)
+	}
+
 	if o.TargetPollConcurrency == 0 {
 		o.TargetPollConcurrency = defaultTargetPollConcurrency
 	}
@@ -163,6 +213,15 @@ func (o *Options) defaultAndValidate(_ logr.Logger) error {
 	return nil
 }
 
+// namePatternRegexp returns the compiled name pattern anchored to match entire names,
+// or nil if no pattern is set.
+func (o *Options) namePatternRegexp() (*regexp.Regexp, error) {
+	if o.NamePattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile("^(?:" + o.NamePattern + ")$")
+}
+
 // NewScheme creates a new Kubernetes runtime.Scheme for the GMP Operator.
 func NewScheme() (*runtime.Scheme, error) {
 	sc := runtime.NewScheme()
@@ -241,12 +300,7 @@ func New(logger logr.Logger, clientConfig *rest.Config, opts Options) (*Operator
 					&monitoringv1.Rules{}: {
 						Field: fields.Everything(),
 					},
-					&corev1.Secret{}: {
-						Namespaces: map[string]cache.Config{
-							opts.OperatorNamespace: {},
-							opts.PublicNamespace:   {},
-						},
-					},
+					&corev1.Secret{}: secretCacheConfig(opts),
 					&monitoringv1.OperatorConfig{}: {
 						Field: fields.SelectorFromSet(fields.Set{"metadata.namespace": opts.PublicNamespace}),
 					},
@@ -265,6 +319,12 @@ func New(logger logr.Logger, clientConfig *rest.Config, opts Options) (*Operator
 							"metadata.name":      NameCollector,
 						}),
 					},
+					&policyv1.PodDisruptionBudget{}: {
+						Field: fields.SelectorFromSet(fields.Set{
+							"metadata.namespace": opts.OperatorNamespace,
+							"metadata.name":      NameCollector,
+						}),
+					},
 					&appsv1.Deployment{}: {
 						Field: fields.SelectorFromSet(fields.Set{
 							"metadata.namespace": opts.OperatorNamespace,
@@ -292,57 +352,110 @@ func New(logger logr.Logger, clientConfig *rest.Config, opts Options) (*Operator
 	return op, nil
 }
 
+var webhookAdmissions = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "gmp_webhook_admissions_total",
+	Help: "Number of admission decisions made by the operator's validating webhooks.",
+}, []string{"kind", "decision"})
+
+// admissionMetricsHandler counts the decisions of the wrapped admission handler.
+type admissionMetricsHandler struct {
+	kind    string
+	handler admission.Handler
+}
+
+func (h *admissionMetricsHandler) Handle(ctx context.Context, req admission.Request) admission.Response {
+	resp := h.handler.Handle(ctx, req)
+
+	decision := "allowed"
+	switch {
+	case !resp.Allowed:
+		decision = "denied"
+	case len(resp.Warnings) > 0:
+		decision = "warned"
+	}
+	webhookAdmissions.WithLabelValues(h.kind, decision).Inc()
+	return resp
+}
+
+// instrumentAdmission records the admission decisions of the webhook for the given resource kind.
+func instrumentAdmission(kind string, wh *admission.Webhook) *admission.Webhook {
+	wh.Handler = &admissionMetricsHandler{kind: kind, handler: wh.Handler}
+	return wh
+}
+
 // setupAdmissionWebhooks configures validating webhooks for the operator-managed
 // custom resources and registers handlers with the webhook server.
-func (o *Operator) setupAdmissionWebhooks(ctx context.Context) error {
+func (o *Operator) setupAdmissionWebhooks(ctx context.Context, registry prometheus.Registerer) error {
+	if err := registry.Register(webhookAdmissions); err != nil {
+		return err
+	}
+
 	// Write provided cert files.
 	caBundle, err := o.ensureCerts(o.manager.GetWebhookServer().(*webhook.DefaultServer).Options.CertDir)
 	if err != nil {
 		return err
 	}
 
-	if len(caBundle) > 0 {
-		// Keep setting the caBundle, if "ensureCerts" gives us those, in the expected webhook configurations.
+	if len(caBundle) > 0 || o.opts.WebhookFailurePolicy != "" {
+		// Keep setting the caBundle, if "ensureCerts" gives us those, and the failure policy
+		// in the expected webhook configurations.
 		// In case of not enough permissions we will keep trying with error message.
-		go o.continuouslySetCABundle(ctx, caBundle)
+		go o.continuouslyUpdateWebhookConfigs(ctx, caBundle)
 	}
 
 	s := o.manager.GetWebhookServer()
 
 	// Validating webhooks.
+	namePattern, err := o.opts.namePatternRegexp()
+	if err != nil {
+		return err
+	}
+	clusterPodMonitoringValidator := &podMonitoringValidator{
+		namePattern:    namePattern,
+		maxMonitorings: o.opts.MaxMonitorings,
+		reader:         o.manager.GetClient(),
+	}
+	if o.opts.EstimateTargets {
+		// Pods are read directly from the API server rather than caching all pods of the cluster.
+		clusterPodMonitoringValidator.targetEstimateReader = o.manager.GetAPIReader()
+	}
 	s.Register(
 		validatePath(monitoringv1.PodMonitoringResource()),
-		admission.ValidatingWebhookFor(o.manager.GetScheme(), &monitoringv1.PodMonitoring{}),
+		instrumentAdmission("PodMonitoring", admission.WithCustomValidator(o.manager.GetScheme(), &monitoringv1.PodMonitoring{}, &podMonitoringValidator{
+			namePattern:    namePattern,
+			maxMonitorings: o.opts.MaxMonitorings,
+			reader:         o.manager.GetClient(),
+		})),
 	)
 	s.Register(
 		validatePath(monitoringv1.ClusterPodMonitoringResource()),
-		admission.ValidatingWebhookFor(o.manager.GetScheme(), &monitoringv1.ClusterPodMonitoring{}),
+		instrumentAdmission("ClusterPodMonitoring", admission.WithCustomValidator(o.manager.GetScheme(), &monitoringv1.ClusterPodMonitoring{}, clusterPodMonitoringValidator)),
 	)
 	s.Register(
 		validatePath(monitoringv1.ClusterNodeMonitoringResource()),
-		admission.ValidatingWebhookFor(o.manager.GetScheme(), &monitoringv1.ClusterNodeMonitoring{}),
+		instrumentAdmission("ClusterNodeMonitoring", admission.ValidatingWebhookFor(o.manager.GetScheme(), &monitoringv1.ClusterNodeMonitoring{})),
 	)
 	s.Register(
 		validatePath(monitoringv1.OperatorConfigResource()),
-		admission.WithCustomValidator(o.manager.GetScheme(), &monitoringv1.OperatorConfig{}, &operatorConfigValidator{
+		instrumentAdmission("OperatorConfig", admission.WithCustomValidator(o.manager.GetScheme(), &monitoringv1.OperatorConfig{}, &operatorConfigValidator{
 			namespace: o.opts.PublicNamespace,
-		}),
+		})),
 	)
 	s.Register(
 		validatePath(monitoringv1.RulesResource()),
-		admission.WithCustomValidator(o.manager.GetScheme(), &monitoringv1.Rules{}, &rulesValidator{
+		instrumentAdmission("Rules", admission.WithCustomValidator(o.manager.GetScheme(), &monitoringv1.Rules{}, &rulesValidator{
 			opts: o.opts,
-		}),
+		})),
 	)
 	s.Register(
 		validatePath(monitoringv1.ClusterRulesResource()),
-		admission.WithCustomValidator(o.manager.GetScheme(), &monitoringv1.ClusterRules{}, &clusterRulesValidator{
+		instrumentAdmission("ClusterRules", admission.WithCustomValidator(o.manager.GetScheme(), &monitoringv1.ClusterRules{}, &clusterRulesValidator{
 			opts: o.opts,
-		}),
+		})),
 	)
 	s.Register(
 		validatePath(monitoringv1.GlobalRulesResource()),
-		admission.WithCustomValidator(o.manager.GetScheme(), &monitoringv1.GlobalRules{}, &globalRulesValidator{}),
+		instrumentAdmission("GlobalRules", admission.WithCustomValidator(o.manager.GetScheme(), &monitoringv1.GlobalRules{}, &globalRulesValidator{})),
 	)
 	// Defaulting webhooks.
 	s.Register(
@@ -365,10 +478,10 @@ func (o *Operator) Run(ctx context.Context, registry prometheus.Registerer) erro
 	if err := o.cleanupOldResources(ctx); err != nil {
 		return fmt.Errorf("cleanup old resources: %w", err)
 	}
-	if err := o.setupAdmissionWebhooks(ctx); err != nil {
+	if err := o.setupAdmissionWebhooks(ctx, registry); err != nil {
 		return fmt.Errorf("init admission resources: %w", err)
 	}
-	if err := setupCollectionControllers(o); err != nil {
+	if err := setupCollectionControllers(o, registry); err != nil {
 		return fmt.Errorf("setup collection controllers: %w", err)
 	}
 	if err := setupRulesControllers(o); err != nil {
@@ -380,6 +493,11 @@ func (o *Operator) Run(ctx context.Context, registry prometheus.Registerer) erro
 	if err := setupTargetStatusPoller(o, registry, o.opts.CollectorHTTPClient); err != nil {
 		return fmt.Errorf("setup target status processor: %w", err)
 	}
+	if o.opts.ValidateExisting {
+		if err := setupExistingResourceValidation(o, registry); err != nil {
+			return fmt.Errorf("setup existing resource validation: %w", err)
+		}
+	}
 
 	o.logger.Info("starting GMP operator")
 	return o.manager.Start(ctx)
@@ -487,6 +605,32 @@ func (o *Operator) ensureCerts(dir string) ([]byte, error) {
 	return caData, nil
 }
 
+// secretCacheConfig returns the cache configuration for Secrets. Only the Secrets in the
+// operator's namespaces are cached unless Secrets referenced by PodMonitorings are resolved.
+// Those may be in any namespace and are watched for changes, but their data is read from
+// the API server when needed and not kept in the cache.
+func secretCacheConfig(opts Options) cache.ByObject {
+	if !opts.ResolveScrapeSecrets {
+		return cache.ByObject{
+			Namespaces: map[string]cache.Config{
+				opts.OperatorNamespace: {},
+				opts.PublicNamespace:   {},
+			},
+		}
+	}
+	return cache.ByObject{
+		Transform: func(obj interface{}) (interface{}, error) {
+			secret, ok := obj.(*corev1.Secret)
+			if !ok || secret.Namespace == opts.OperatorNamespace || secret.Namespace == opts.PublicNamespace {
+				return obj, nil
+			}
+			secret.Data = nil
+			secret.StringData = nil
+			return secret, nil
+		},
+	}
+}
+
 // namespacedNamePredicate is an event filter predicate that only allows events with
 // a single object.
 type namespacedNamePredicate struct {
@@ -538,7 +682,7 @@ func (o *Operator) webhookConfigName() string {
 	return fmt.Sprintf("%s.%s.monitoring.googleapis.com", NameOperator, o.opts.OperatorNamespace)
 }
 
-func (o *Operator) setValidatingWebhookCABundle(ctx context.Context, caBundle []byte) error {
+func (o *Operator) updateValidatingWebhookConfig(ctx context.Context, caBundle []byte) error {
 	var vwc arv1.ValidatingWebhookConfiguration
 	err := o.client.Get(ctx, client.ObjectKey{Name: o.webhookConfigName()}, &vwc)
 	if apierrors.IsNotFound(err) {
@@ -548,12 +692,17 @@ func (o *Operator) setValidatingWebhookCABundle(ctx context.Context, caBundle []
 	}
 
 	for i := range vwc.Webhooks {
-		vwc.Webhooks[i].ClientConfig.CABundle = caBundle
+		if len(caBundle) > 0 {
+			vwc.Webhooks[i].ClientConfig.CABundle = caBundle
+		}
+		if o.opts.WebhookFailurePolicy != "" {
+			vwc.Webhooks[i].FailurePolicy = &o.opts.WebhookFailurePolicy
+		}
 	}
 	return o.client.Update(ctx, &vwc)
 }
 
-func (o *Operator) setMutatingWebhookCABundle(ctx context.Context, caBundle []byte) error {
+func (o *Operator) updateMutatingWebhookConfig(ctx context.Context, caBundle []byte) error {
 	var mwc arv1.MutatingWebhookConfiguration
 	err := o.client.Get(ctx, client.ObjectKey{Name: o.webhookConfigName()}, &mwc)
 	if apierrors.IsNotFound(err) {
@@ -563,22 +712,27 @@ func (o *Operator) setMutatingWebhookCABundle(ctx context.Context, caBundle []by
 	}
 
 	for i := range mwc.Webhooks {
-		mwc.Webhooks[i].ClientConfig.CABundle = caBundle
+		if len(caBundle) > 0 {
+			mwc.Webhooks[i].ClientConfig.CABundle = caBundle
+		}
+		if o.opts.WebhookFailurePolicy != "" {
+			mwc.Webhooks[i].FailurePolicy = &o.opts.WebhookFailurePolicy
+		}
 	}
 	return o.client.Update(ctx, &mwc)
 }
 
-func (o *Operator) continuouslySetCABundle(ctx context.Context, caBundle []byte) {
+func (o *Operator) continuouslyUpdateWebhookConfigs(ctx context.Context, caBundle []byte) {
 	// Initial sleep for the client to initialize before our first calls.
 	// Ideally we could explicitly wait for it.
 	time.Sleep(5 * time.Second)
 
 	for {
-		if err := o.setValidatingWebhookCABundle(ctx, caBundle); err != nil {
-			o.logger.Error(err, "Setting CA bundle for ValidatingWebhookConfiguration failed; retrying in 1m...")
+		if err := o.updateValidatingWebhookConfig(ctx, caBundle); err != nil {
+			o.logger.Error(err, "Updating ValidatingWebhookConfiguration failed; retrying in 1m...")
 		}
-		if err := o.setMutatingWebhookCABundle(ctx, caBundle); err != nil {
-			o.logger.Error(err, "Setting CA bundle for MutatingWebhookConfiguration failed; retrying in 1m...")
+		if err := o.updateMutatingWebhookConfig(ctx, caBundle); err != nil {
+			o.logger.Error(err, "Updating MutatingWebhookConfiguration failed; retrying in 1m...")
 		}
 		select {
 		case <-ctx.Done():
@@ -21,7 +21,9 @@ import (
 	"net/url"
 	"path"
 	"strings"
+	"time"
 
+	"github.com/GoogleCloudPlatform/prometheus-engine/pkg/export"
 	monitoringv1 "github.com/GoogleCloudPlatform/prometheus-engine/pkg/operator/apis/monitoring/v1"
 	"github.com/go-logr/logr"
 	promcommonconfig "github.com/prometheus/common/config"
@@ -39,6 +41,8 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -64,7 +68,7 @@ const (
 	AlertmanagerPublicSecretName = "alertmanager"
 	AlertmanagerPublicSecretKey  = "alertmanager.yaml"
 	rulesDir                     = "/etc/rules"
-	secretsDir                   = "/etc/secrets"
+	secretsDir                   = monitoringv1.CollectorSecretsDir
 	AlertmanagerConfigKey        = "config.yaml"
 )
 
@@ -288,6 +292,9 @@ func (r *operatorConfigReconciler) makeRuleEvaluatorConfig(ctx context.Context,
 		},
 		RuleFiles: []string{path.Join(rulesDir, "*.yaml")},
 	}
+	if spec.QueryLog != nil {
+		cfg.GlobalConfig.QueryLogFile = spec.QueryLog.Path
+	}
 	cfgEncoded, err := yaml.Marshal(cfg)
 	if err != nil {
 		return nil, nil, fmt.Errorf("marshal Prometheus config: %w", err)
@@ -477,6 +484,9 @@ func (r *operatorConfigReconciler) ensureRuleEvaluatorDeployment(ctx context.Con
 	if spec.GeneratorURL != "" {
 		flags = append(flags, fmt.Sprintf("--query.generator-url=%q", spec.GeneratorURL))
 	}
+	if spec.QueryLog != nil && spec.QueryLog.MaxSize != nil {
+		flags = append(flags, fmt.Sprintf("--query.log-max-size=%dB", spec.QueryLog.MaxSize.Value()))
+	}
 
 	// Set EXTRA_ARGS envvar in evaluator container.
 	for i, c := range deploy.Spec.Template.Spec.Containers {
@@ -749,16 +759,62 @@ func getConfigMapKeyBytes(ctx context.Context, c client.Reader, namespace string
 // pathForSelector cretes the filepath for the provided NamespacedSecretOrConfigMap.
 // This can be used to avoid naming collisions of like-keys across K8s resources.
 func pathForSelector(namespace string, scm *monitoringv1.SecretOrConfigMap) string {
-	if scm == nil {
-		return ""
+	return scm.FileName(namespace)
+}
+
+func validateCollectorPodDisruptionBudget(pdb *monitoringv1.CollectorPodDisruptionBudget) error {
+	if pdb == nil {
+		return nil
+	}
+	if (pdb.MinAvailable == nil) == (pdb.MaxUnavailable == nil) {
+		return errors.New("exactly one of minAvailable and maxUnavailable must be set")
+	}
+	v := pdb.MinAvailable
+	if v == nil {
+		v = pdb.MaxUnavailable
+	}
+	n, err := intstr.GetScaledValueFromIntOrPercent(v, 100, true)
+	if err != nil {
+		return err
+	}
+	if n < 0 {
+		return fmt.Errorf("value %q must not be negative", v.String())
+	}
+	return nil
+}
+
+func validateCompression(c monitoringv1.CompressionType) error {
+	switch c {
+	case "", monitoringv1.CompressionNone, monitoringv1.CompressionGzip:
+		return nil
+	}
+	return fmt.Errorf("unsupported compression %q, must be one of %q or %q", c, monitoringv1.CompressionNone, monitoringv1.CompressionGzip)
+}
+
+func validateExportQueueConfig(q *monitoringv1.ExportQueueConfig) error {
+	if q == nil {
+		return nil
+	}
+	if q.MaxSamplesPerSend > export.BatchSizeMax {
+		return fmt.Errorf("maxSamplesPerSend must not exceed %d, got %d", export.BatchSizeMax, q.MaxSamplesPerSend)
+	}
+	capacity := q.Capacity
+	if capacity == 0 {
+		capacity = export.DefaultShardBufferSize
 	}
-	if scm.ConfigMap != nil {
-		return fmt.Sprintf("%s_%s_%s_%s", "configmap", namespace, scm.ConfigMap.Name, scm.ConfigMap.Key)
+	if q.MaxSamplesPerSend > capacity {
+		return fmt.Errorf("maxSamplesPerSend %d must not be greater than capacity %d", q.MaxSamplesPerSend, capacity)
 	}
-	if scm.Secret != nil {
-		return fmt.Sprintf("%s_%s_%s_%s", "secret", namespace, scm.Secret.Name, scm.Secret.Key)
+	if q.BatchSendDeadline != "" {
+		d, err := time.ParseDuration(q.BatchSendDeadline)
+		if err != nil {
+			return fmt.Errorf("invalid batchSendDeadline: %w", err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("batchSendDeadline must be positive, got %s", q.BatchSendDeadline)
+		}
 	}
-	return ""
+	return nil
 }
 
 func validateRules(rules *monitoringv1.RuleEvaluatorSpec) error {
@@ -771,6 +827,9 @@ func validateRules(rules *monitoringv1.RuleEvaluatorSpec) error {
 	if err := validateSecretKeySelector(rules.Credentials); err != nil {
 		return fmt.Errorf("invalid credentials: %w", err)
 	}
+	if err := validateQueryLog(rules.QueryLog); err != nil {
+		return fmt.Errorf("invalid query log: %w", err)
+	}
 	for i, alertManagerEndpoint := range rules.Alerting.Alertmanagers {
 		if err := validateAlertManagerEndpoint(&alertManagerEndpoint); err != nil {
 			return fmt.Errorf("invalid alert manager endpoint `%s` (index %d): %w", alertManagerEndpoint.Name, i, err)
@@ -779,6 +838,19 @@ func validateRules(rules *monitoringv1.RuleEvaluatorSpec) error {
 	return nil
 }
 
+func validateQueryLog(queryLog *monitoringv1.QueryLogSpec) error {
+	if queryLog == nil {
+		return nil
+	}
+	if !path.IsAbs(queryLog.Path) {
+		return fmt.Errorf("path must be absolute, got %q", queryLog.Path)
+	}
+	if queryLog.MaxSize != nil && queryLog.MaxSize.Value() <= 0 {
+		return fmt.Errorf("maxSize must be positive, got %s", queryLog.MaxSize)
+	}
+	return nil
+}
+
 func validateAlertManagerEndpoint(alertManagerEndpoint *monitoringv1.AlertmanagerEndpoints) error {
 	if alertManagerEndpoint.Authorization != nil {
 		if err := validateSecretKeySelector(alertManagerEndpoint.Authorization.Credentials); err != nil {
@@ -837,10 +909,39 @@ func (v *operatorConfigValidator) ValidateCreate(_ context.Context, o runtime.Ob
 	if _, err := makeKubeletScrapeConfigs(oc.Collection.KubeletScraping); err != nil {
 		return nil, fmt.Errorf("failed to create kubelet scrape config: %w", err)
 	}
+	if _, err := makeSelfMonitoringScrapeConfigs(v.namespace, oc.Collection.SelfMonitoring); err != nil {
+		return nil, fmt.Errorf("failed to create self-monitoring scrape config: %w", err)
+	}
 
 	if err := validateSecretKeySelector(oc.Collection.Credentials); err != nil {
 		return nil, fmt.Errorf("invalid collection credentials: %w", err)
 	}
+	if _, err := oc.Collection.ExportRelabelConfigs(); err != nil {
+		return nil, err
+	}
+	if _, err := makeCollectorLabelRelabelConfig(oc.Collection.CollectorLabel); err != nil {
+		return nil, err
+	}
+	if _, err := makeStorageConfig(oc.Collection.OutOfOrderTimeWindow); err != nil {
+		return nil, err
+	}
+	if _, err := parseBaseScrapeConfig(oc.Collection.BaseScrapeConfig); err != nil {
+		return nil, err
+	}
+	if name := oc.Collection.PriorityClassName; name != "" {
+		if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid collector priority class name %q: %s", name, strings.Join(errs, ", "))
+		}
+	}
+	if err := validateCompression(oc.Collection.Compression); err != nil {
+		return nil, fmt.Errorf("invalid collection compression: %w", err)
+	}
+	if err := validateExportQueueConfig(oc.Collection.ExportQueue); err != nil {
+		return nil, fmt.Errorf("invalid export queue: %w", err)
+	}
+	if err := validateCollectorPodDisruptionBudget(oc.Collection.PodDisruptionBudget); err != nil {
+		return nil, fmt.Errorf("invalid collector pod disruption budget: %w", err)
+	}
 	if oc.ManagedAlertmanager != nil {
 		if err := validateSecretKeySelector(oc.ManagedAlertmanager.ConfigSecret); err != nil {
 			return nil, fmt.Errorf("invalid managed alert manager config secret: %w", err)
@@ -20,8 +20,15 @@ import (
 	"testing"
 
 	monitoringv1 "github.com/GoogleCloudPlatform/prometheus-engine/pkg/operator/apis/monitoring/v1"
+	"github.com/go-logr/logr/testr"
+	"github.com/google/go-cmp/cmp"
+	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 func TestOperatorConfigValidator(t *testing.T) {
@@ -91,6 +98,299 @@ func TestOperatorConfigValidator(t *testing.T) {
 			},
 			err: `invalid scrape interval: empty duration string`,
 		},
+		{
+			desc: "bad self-monitoring scrape interval",
+			oc: &monitoringv1.OperatorConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+					Name:      "config",
+				},
+				Collection: monitoringv1.CollectionSpec{
+					SelfMonitoring: &monitoringv1.SelfMonitoring{
+						Interval: "xyz",
+					},
+				},
+			},
+			err: `failed to create self-monitoring scrape config: invalid scrape interval: not a valid duration string: "xyz"`,
+		},
+		{
+			desc: "invalid self-monitoring external label",
+			oc: &monitoringv1.OperatorConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+					Name:      "config",
+				},
+				Collection: monitoringv1.CollectionSpec{
+					SelfMonitoring: &monitoringv1.SelfMonitoring{
+						Interval:       "30s",
+						ExternalLabels: map[string]string{"foo-bar": "x"},
+					},
+				},
+			},
+			err: `failed to create self-monitoring scrape config: invalid external label name "foo-bar"`,
+		},
+		{
+			desc: "reserved self-monitoring external label",
+			oc: &monitoringv1.OperatorConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+					Name:      "config",
+				},
+				Collection: monitoringv1.CollectionSpec{
+					SelfMonitoring: &monitoringv1.SelfMonitoring{
+						Interval:       "30s",
+						ExternalLabels: map[string]string{"cluster": "x"},
+					},
+				},
+			},
+			err: `failed to create self-monitoring scrape config: external label "cluster" is reserved`,
+		},
+		{
+			desc: "bad collector priority class name",
+			oc: &monitoringv1.OperatorConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+					Name:      "config",
+				},
+				Collection: monitoringv1.CollectionSpec{
+					PriorityClassName: "Foo_Bar",
+				},
+			},
+			err: `invalid collector priority class name "Foo_Bar"`,
+		},
+		{
+			desc: "collector pod disruption budget",
+			oc: &monitoringv1.OperatorConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+					Name:      "config",
+				},
+				Collection: monitoringv1.CollectionSpec{
+					PriorityClassName: "gmp-critical",
+					PodDisruptionBudget: &monitoringv1.CollectorPodDisruptionBudget{
+						MaxUnavailable: ptr.To(intstr.FromString("10%")),
+					},
+				},
+			},
+		},
+		{
+			desc: "collector pod disruption budget without limits",
+			oc: &monitoringv1.OperatorConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+					Name:      "config",
+				},
+				Collection: monitoringv1.CollectionSpec{
+					PodDisruptionBudget: &monitoringv1.CollectorPodDisruptionBudget{},
+				},
+			},
+			err: "invalid collector pod disruption budget: exactly one of minAvailable and maxUnavailable must be set",
+		},
+		{
+			desc: "collector pod disruption budget with both limits",
+			oc: &monitoringv1.OperatorConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+					Name:      "config",
+				},
+				Collection: monitoringv1.CollectionSpec{
+					PodDisruptionBudget: &monitoringv1.CollectorPodDisruptionBudget{
+						MinAvailable:   ptr.To(intstr.FromInt(1)),
+						MaxUnavailable: ptr.To(intstr.FromInt(1)),
+					},
+				},
+			},
+			err: "invalid collector pod disruption budget: exactly one of minAvailable and maxUnavailable must be set",
+		},
+		{
+			desc: "collector pod disruption budget with bad value",
+			oc: &monitoringv1.OperatorConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+					Name:      "config",
+				},
+				Collection: monitoringv1.CollectionSpec{
+					PodDisruptionBudget: &monitoringv1.CollectorPodDisruptionBudget{
+						MinAvailable: ptr.To(intstr.FromString("half")),
+					},
+				},
+			},
+			err: "invalid collector pod disruption budget: invalid value for IntOrString",
+		},
+		{
+			desc: "bad export relabeling",
+			oc: &monitoringv1.OperatorConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+					Name:      "config",
+				},
+				Collection: monitoringv1.CollectionSpec{
+					ExportRelabeling: []monitoringv1.RelabelingRule{
+						{
+							Action: "labeldrop",
+							Regex:  "cluster",
+						},
+					},
+				},
+			},
+			err: "invalid export relabeling rule 0: regex cluster would drop at least one of the protected labels",
+		},
+		{
+			desc: "gzip compression",
+			oc: &monitoringv1.OperatorConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+					Name:      "config",
+				},
+				Collection: monitoringv1.CollectionSpec{
+					Compression: monitoringv1.CompressionGzip,
+				},
+			},
+		},
+		{
+			desc: "unsupported compression",
+			oc: &monitoringv1.OperatorConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+					Name:      "config",
+				},
+				Collection: monitoringv1.CollectionSpec{
+					Compression: "snappy",
+				},
+			},
+			err: `invalid collection compression: unsupported compression "snappy", must be one of "none" or "gzip"`,
+		},
+		{
+			desc: "valid export queue",
+			oc: &monitoringv1.OperatorConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+					Name:      "config",
+				},
+				Collection: monitoringv1.CollectionSpec{
+					ExportQueue: &monitoringv1.ExportQueueConfig{
+						MaxSamplesPerSend: 100,
+						MaxShards:         256,
+						BatchSendDeadline: "1s",
+					},
+				},
+			},
+		},
+		{
+			desc: "export queue batch size too large",
+			oc: &monitoringv1.OperatorConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+					Name:      "config",
+				},
+				Collection: monitoringv1.CollectionSpec{
+					ExportQueue: &monitoringv1.ExportQueueConfig{
+						MaxSamplesPerSend: 500,
+					},
+				},
+			},
+			err: "invalid export queue: maxSamplesPerSend must not exceed 200, got 500",
+		},
+		{
+			desc: "export queue batch size exceeds capacity",
+			oc: &monitoringv1.OperatorConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+					Name:      "config",
+				},
+				Collection: monitoringv1.CollectionSpec{
+					ExportQueue: &monitoringv1.ExportQueueConfig{
+						Capacity:          50,
+						MaxSamplesPerSend: 100,
+					},
+				},
+			},
+			err: "invalid export queue: maxSamplesPerSend 100 must not be greater than capacity 50",
+		},
+		{
+			desc: "bad export queue deadline",
+			oc: &monitoringv1.OperatorConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+					Name:      "config",
+				},
+				Collection: monitoringv1.CollectionSpec{
+					ExportQueue: &monitoringv1.ExportQueueConfig{
+						BatchSendDeadline: "soon",
+					},
+				},
+			},
+			err: "invalid export queue: invalid batchSendDeadline",
+		},
+		{
+			desc: "invalid collector label",
+			oc: &monitoringv1.OperatorConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+					Name:      "config",
+				},
+				Collection: monitoringv1.CollectionSpec{
+					CollectorLabel: &monitoringv1.CollectorLabel{
+						Name: "collector-name",
+					},
+				},
+			},
+			err: `invalid collector label name "collector-name"`,
+		},
+		{
+			desc: "reserved collector label",
+			oc: &monitoringv1.OperatorConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+					Name:      "config",
+				},
+				Collection: monitoringv1.CollectionSpec{
+					CollectorLabel: &monitoringv1.CollectorLabel{
+						Name: "instance",
+					},
+				},
+			},
+			err: `collector label "instance" is reserved`,
+		},
+		{
+			desc: "bad out-of-order time window",
+			oc: &monitoringv1.OperatorConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+					Name:      "config",
+				},
+				Collection: monitoringv1.CollectionSpec{
+					OutOfOrderTimeWindow: "5 minutes",
+				},
+			},
+			err: `invalid out-of-order time window: unknown unit " minutes" in duration "5 minutes"`,
+		},
+		{
+			desc: "out-of-order time window exceeding retention",
+			oc: &monitoringv1.OperatorConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+					Name:      "config",
+				},
+				Collection: monitoringv1.CollectionSpec{
+					OutOfOrderTimeWindow: "1h",
+				},
+			},
+			err: `out-of-order time window must not exceed 30m, got 1h`,
+		},
+		{
+			desc: "bad base scrape config",
+			oc: &monitoringv1.OperatorConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+					Name:      "config",
+				},
+				Collection: monitoringv1.CollectionSpec{
+					BaseScrapeConfig: "job_name: other",
+				},
+			},
+			err: `base scrape config must not set "job_name"`,
+		},
 		{
 			desc: "bad generator URL",
 			oc: &monitoringv1.OperatorConfig{
@@ -191,6 +491,52 @@ func TestOperatorConfigValidator(t *testing.T) {
 				},
 			},
 		},
+		{
+			desc: "relative query log path",
+			oc: &monitoringv1.OperatorConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+					Name:      "config",
+				},
+				Rules: monitoringv1.RuleEvaluatorSpec{
+					QueryLog: &monitoringv1.QueryLogSpec{
+						Path: "query.log",
+					},
+				},
+			},
+			err: `invalid rules config: invalid query log: path must be absolute, got "query.log"`,
+		},
+		{
+			desc: "zero query log size",
+			oc: &monitoringv1.OperatorConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+					Name:      "config",
+				},
+				Rules: monitoringv1.RuleEvaluatorSpec{
+					QueryLog: &monitoringv1.QueryLogSpec{
+						Path:    "/tmp/query.log",
+						MaxSize: ptr.To(resource.MustParse("0")),
+					},
+				},
+			},
+			err: "invalid rules config: invalid query log: maxSize must be positive, got 0",
+		},
+		{
+			desc: "query log",
+			oc: &monitoringv1.OperatorConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+					Name:      "config",
+				},
+				Rules: monitoringv1.RuleEvaluatorSpec{
+					QueryLog: &monitoringv1.QueryLogSpec{
+						Path:    "/tmp/query.log",
+						MaxSize: ptr.To(resource.MustParse("10Mi")),
+					},
+				},
+			},
+		},
 		{
 			desc: "missing rule manager authorization credentials secret key",
 			oc: &monitoringv1.OperatorConfig{
@@ -389,3 +735,63 @@ func TestOperatorConfigValidator(t *testing.T) {
 		})
 	}
 }
+
+func TestRuleEvaluatorQueryLog(t *testing.T) {
+	ctx := context.Background()
+	opts := Options{
+		ProjectID: "test-proj",
+		Location:  "us-central1-c",
+		Cluster:   "test-cluster",
+	}
+	if err := opts.defaultAndValidate(testr.New(t)); err != nil {
+		t.Fatal(err)
+	}
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: opts.OperatorNamespace,
+			Name:      NameRuleEvaluator,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Template: v1.PodTemplateSpec{
+				Spec: v1.PodSpec{
+					Containers: []v1.Container{{Name: RuleEvaluatorContainerName}},
+				},
+			},
+		},
+	}
+	kubeClient := newFakeClientBuilder().WithObjects(deploy).Build()
+	r := newOperatorConfigReconciler(kubeClient, opts)
+
+	spec := &monitoringv1.RuleEvaluatorSpec{
+		QueryLog: &monitoringv1.QueryLogSpec{
+			Path:    "/tmp/query.log",
+			MaxSize: ptr.To(resource.MustParse("5Mi")),
+		},
+	}
+	cm, _, err := r.makeRuleEvaluatorConfig(ctx, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantConfig := `global:
+    query_log_file: /tmp/query.log
+rule_files:
+    - /etc/rules/*.yaml
+`
+	if diff := cmp.Diff(wantConfig, cm.Data[configFilename]); diff != "" {
+		t.Errorf("unexpected config (-want, +got): %s", diff)
+	}
+
+	if err := r.ensureRuleEvaluatorDeployment(ctx, spec); err != nil {
+		t.Fatal(err)
+	}
+	if err := kubeClient.Get(ctx, client.ObjectKeyFromObject(deploy), deploy); err != nil {
+		t.Fatal(err)
+	}
+	wantEnv := []v1.EnvVar{{
+		Name:  "EXTRA_ARGS",
+		Value: `--export.label.project-id="test-proj" --export.label.location="us-central1-c" --export.label.cluster="test-cluster" --query.project-id="test-proj" --query.log-max-size=5242880B`,
+	}}
+	if diff := cmp.Diff(wantEnv, deploy.Spec.Template.Spec.Containers[0].Env); diff != "" {
+		t.Errorf("unexpected evaluator env (-want, +got): %s", diff)
+	}
+}
@@ -16,17 +16,31 @@ package operator
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path"
+	"strings"
 	"testing"
 
+	admissionv1 "k8s.io/api/admission/v1"
+	arv1 "k8s.io/api/admissionregistration/v1"
 	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 
+	monitoringv1 "github.com/GoogleCloudPlatform/prometheus-engine/pkg/operator/apis/monitoring/v1"
 	"github.com/go-logr/logr/testr"
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
 func readKeyAndCertFiles(dir string, t *testing.T) ([]byte, []byte) {
@@ -299,3 +313,519 @@ func TestCleanupOldResources(t *testing.T) {
 		})
 	}
 }
+
+func TestUpdateWebhookConfigs(t *testing.T) {
+	var cases = []struct {
+		desc          string
+		failurePolicy arv1.FailurePolicyType
+		caBundle      []byte
+		wantPolicy    arv1.FailurePolicyType
+		wantCABundle  []byte
+	}{
+		{
+			desc:         "keep installed policy",
+			caBundle:     []byte("ca"),
+			wantPolicy:   arv1.Fail,
+			wantCABundle: []byte("ca"),
+		},
+		{
+			desc:          "ignore",
+			failurePolicy: arv1.Ignore,
+			caBundle:      []byte("ca"),
+			wantPolicy:    arv1.Ignore,
+			wantCABundle:  []byte("ca"),
+		},
+		{
+			desc:          "ignore without CA bundle",
+			failurePolicy: arv1.Ignore,
+			wantPolicy:    arv1.Ignore,
+			wantCABundle:  []byte("installed"),
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			ctx := context.Background()
+			opts := Options{
+				OperatorNamespace:    "gmp-system",
+				WebhookFailurePolicy: c.failurePolicy,
+			}
+			op := &Operator{
+				logger: testr.New(t),
+				opts:   opts,
+			}
+			installedPolicy := arv1.Fail
+			webhook := func(name string) arv1.ValidatingWebhook {
+				return arv1.ValidatingWebhook{
+					Name:          name,
+					ClientConfig:  arv1.WebhookClientConfig{CABundle: []byte("installed")},
+					FailurePolicy: &installedPolicy,
+				}
+			}
+			vwc := &arv1.ValidatingWebhookConfiguration{
+				ObjectMeta: v1.ObjectMeta{Name: op.webhookConfigName()},
+				Webhooks:   []arv1.ValidatingWebhook{webhook("a"), webhook("b")},
+			}
+			mwc := &arv1.MutatingWebhookConfiguration{
+				ObjectMeta: v1.ObjectMeta{Name: op.webhookConfigName()},
+				Webhooks: []arv1.MutatingWebhook{
+					{
+						Name:          "a",
+						ClientConfig:  arv1.WebhookClientConfig{CABundle: []byte("installed")},
+						FailurePolicy: &installedPolicy,
+					},
+				},
+			}
+			op.client = fake.NewClientBuilder().WithObjects(vwc, mwc).Build()
+
+			if err := op.updateValidatingWebhookConfig(ctx, c.caBundle); err != nil {
+				t.Fatal(err)
+			}
+			if err := op.updateMutatingWebhookConfig(ctx, c.caBundle); err != nil {
+				t.Fatal(err)
+			}
+
+			var gotVWC arv1.ValidatingWebhookConfiguration
+			if err := op.client.Get(ctx, client.ObjectKey{Name: op.webhookConfigName()}, &gotVWC); err != nil {
+				t.Fatal(err)
+			}
+			for _, wh := range gotVWC.Webhooks {
+				if *wh.FailurePolicy != c.wantPolicy {
+					t.Errorf("validating webhook %q: want failure policy %q, got %q", wh.Name, c.wantPolicy, *wh.FailurePolicy)
+				}
+				if string(wh.ClientConfig.CABundle) != string(c.wantCABundle) {
+					t.Errorf("validating webhook %q: want CA bundle %q, got %q", wh.Name, c.wantCABundle, wh.ClientConfig.CABundle)
+				}
+			}
+			var gotMWC arv1.MutatingWebhookConfiguration
+			if err := op.client.Get(ctx, client.ObjectKey{Name: op.webhookConfigName()}, &gotMWC); err != nil {
+				t.Fatal(err)
+			}
+			for _, wh := range gotMWC.Webhooks {
+				if *wh.FailurePolicy != c.wantPolicy {
+					t.Errorf("mutating webhook %q: want failure policy %q, got %q", wh.Name, c.wantPolicy, *wh.FailurePolicy)
+				}
+				if string(wh.ClientConfig.CABundle) != string(c.wantCABundle) {
+					t.Errorf("mutating webhook %q: want CA bundle %q, got %q", wh.Name, c.wantCABundle, wh.ClientConfig.CABundle)
+				}
+			}
+		})
+	}
+}
+
+func TestOptionsWebhookFailurePolicy(t *testing.T) {
+	for _, policy := range []arv1.FailurePolicyType{"", arv1.Fail, arv1.Ignore} {
+		opts := Options{ProjectID: "test-proj", Cluster: "test-cluster", WebhookFailurePolicy: policy}
+		if err := opts.defaultAndValidate(testr.New(t)); err != nil {
+			t.Errorf("unexpected error for policy %q: %s", policy, err)
+		}
+	}
+	opts := Options{ProjectID: "test-proj", Cluster: "test-cluster", WebhookFailurePolicy: "Retry"}
+	if err := opts.defaultAndValidate(testr.New(t)); err == nil {
+		t.Errorf("expected error for invalid policy")
+	}
+}
+
+func TestSecretCacheConfig(t *testing.T) {
+	opts := Options{OperatorNamespace: "gmp-system", PublicNamespace: "gmp-public"}
+	if cfg := secretCacheConfig(opts); len(cfg.Namespaces) != 2 || cfg.Transform != nil {
+		t.Errorf("expected secrets to be cached in the operator namespaces only, got %+v", cfg)
+	}
+
+	// Secrets in all namespaces are watched, but only the data of the ones in the
+	// operator namespaces is cached.
+	opts.ResolveScrapeSecrets = true
+	cfg := secretCacheConfig(opts)
+	if len(cfg.Namespaces) != 0 || cfg.Transform == nil {
+		t.Fatalf("expected secrets to be cached in all namespaces, got %+v", cfg)
+	}
+	for ns, wantData := range map[string]bool{
+		"gmp-system": true,
+		"gmp-public": true,
+		"default":    false,
+	} {
+		secret := &corev1.Secret{
+			ObjectMeta: v1.ObjectMeta{Namespace: ns, Name: "auth"},
+			Data:       map[string][]byte{"username": []byte("admin")},
+		}
+		obj, err := cfg.Transform(secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := obj.(*corev1.Secret).Data != nil; got != wantData {
+			t.Errorf("expected data of secret in namespace %q to be kept %v, got %v", ns, wantData, got)
+		}
+	}
+}
+
+func TestPodMonitoringValidatorNamePattern(t *testing.T) {
+	opts := Options{ProjectID: "test-proj", Cluster: "test-cluster", NamePattern: "("}
+	if err := opts.defaultAndValidate(testr.New(t)); err == nil {
+		t.Errorf("expected error for invalid name pattern")
+	}
+
+	endpoints := []monitoringv1.ScrapeEndpoint{{
+		Port:     intstr.FromString("metrics"),
+		Interval: "10s",
+	}}
+	podMonitoring := func(name string) *monitoringv1.PodMonitoring {
+		return &monitoringv1.PodMonitoring{
+			ObjectMeta: v1.ObjectMeta{Namespace: "default", Name: name},
+			Spec:       monitoringv1.PodMonitoringSpec{Endpoints: endpoints},
+		}
+	}
+	clusterPodMonitoring := func(name string) *monitoringv1.ClusterPodMonitoring {
+		return &monitoringv1.ClusterPodMonitoring{
+			ObjectMeta: v1.ObjectMeta{Name: name},
+			Spec:       monitoringv1.ClusterPodMonitoringSpec{Endpoints: endpoints},
+		}
+	}
+
+	cases := []struct {
+		desc    string
+		pattern string
+		obj     runtime.Object
+		wantErr bool
+	}{
+		{
+			desc: "no pattern",
+			obj:  podMonitoring("web"),
+		},
+		{
+			desc:    "conforming PodMonitoring",
+			pattern: "team-[a-z]+-.+",
+			obj:     podMonitoring("team-a-web"),
+		},
+		{
+			desc:    "non-conforming PodMonitoring",
+			pattern: "team-[a-z]+-.+",
+			obj:     podMonitoring("web"),
+			wantErr: true,
+		},
+		{
+			// The pattern must match the entire name.
+			desc:    "partially matching PodMonitoring",
+			pattern: "team-[a-z]+",
+			obj:     podMonitoring("team-a-web"),
+			wantErr: true,
+		},
+		{
+			desc:    "conforming ClusterPodMonitoring",
+			pattern: "team-[a-z]+-.+",
+			obj:     clusterPodMonitoring("team-a-web"),
+		},
+		{
+			desc:    "non-conforming ClusterPodMonitoring",
+			pattern: "team-[a-z]+-.+",
+			obj:     clusterPodMonitoring("web"),
+			wantErr: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			opts := Options{ProjectID: "test-proj", Cluster: "test-cluster", NamePattern: c.pattern}
+			if err := opts.defaultAndValidate(testr.New(t)); err != nil {
+				t.Fatal(err)
+			}
+			namePattern, err := opts.namePatternRegexp()
+			if err != nil {
+				t.Fatal(err)
+			}
+			v := &podMonitoringValidator{namePattern: namePattern}
+
+			_, err = v.ValidateCreate(context.Background(), c.obj)
+			if c.wantErr && (err == nil || !strings.Contains(err.Error(), "does not match the required naming pattern")) {
+				t.Errorf("expected naming pattern error, got %v", err)
+			}
+			if !c.wantErr && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+			// Existing resources can be updated regardless of their name.
+			if _, err := v.ValidateUpdate(context.Background(), c.obj, c.obj); err != nil {
+				t.Errorf("unexpected update error: %s", err)
+			}
+		})
+	}
+}
+
+func TestPodMonitoringValidatorMaxMonitorings(t *testing.T) {
+	opts := Options{ProjectID: "test-proj", Cluster: "test-cluster", MaxMonitorings: -1}
+	if err := opts.defaultAndValidate(testr.New(t)); err == nil {
+		t.Errorf("expected error for negative max monitorings")
+	}
+
+	endpoints := []monitoringv1.ScrapeEndpoint{{
+		Port:     intstr.FromString("metrics"),
+		Interval: "10s",
+	}}
+	podMonitoring := func(name string) *monitoringv1.PodMonitoring {
+		return &monitoringv1.PodMonitoring{
+			ObjectMeta: v1.ObjectMeta{Namespace: "default", Name: name},
+			Spec:       monitoringv1.PodMonitoringSpec{Endpoints: endpoints},
+		}
+	}
+	clusterPodMonitoring := func(name string) *monitoringv1.ClusterPodMonitoring {
+		return &monitoringv1.ClusterPodMonitoring{
+			ObjectMeta: v1.ObjectMeta{Name: name},
+			Spec:       monitoringv1.ClusterPodMonitoringSpec{Endpoints: endpoints},
+		}
+	}
+	kubeClient := newFakeClientBuilder().WithObjects(
+		podMonitoring("a"),
+		clusterPodMonitoring("b"),
+	).Build()
+
+	cases := []struct {
+		desc    string
+		max     int
+		obj     runtime.Object
+		wantErr bool
+	}{
+		{
+			desc: "no limit",
+			obj:  podMonitoring("c"),
+		},
+		{
+			desc: "PodMonitoring below limit",
+			max:  3,
+			obj:  podMonitoring("c"),
+		},
+		{
+			desc:    "PodMonitoring at limit",
+			max:     2,
+			obj:     podMonitoring("c"),
+			wantErr: true,
+		},
+		{
+			desc: "ClusterPodMonitoring below limit",
+			max:  3,
+			obj:  clusterPodMonitoring("c"),
+		},
+		{
+			desc:    "ClusterPodMonitoring above limit",
+			max:     1,
+			obj:     clusterPodMonitoring("c"),
+			wantErr: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			v := &podMonitoringValidator{maxMonitorings: c.max, reader: kubeClient}
+
+			_, err := v.ValidateCreate(context.Background(), c.obj)
+			if c.wantErr && (err == nil || !strings.Contains(err.Error(), fmt.Sprintf("the maximum of %d PodMonitorings and ClusterPodMonitorings is reached", c.max))) {
+				t.Errorf("expected limit error, got %v", err)
+			}
+			if !c.wantErr && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+			// Existing resources can be updated regardless of the limit.
+			if _, err := v.ValidateUpdate(context.Background(), c.obj, c.obj); err != nil {
+				t.Errorf("unexpected update error: %s", err)
+			}
+		})
+	}
+}
+
+func TestPodMonitoringValidatorTargetEstimate(t *testing.T) {
+	pod := func(namespace, name, app string, phase corev1.PodPhase) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: v1.ObjectMeta{Namespace: namespace, Name: name, Labels: map[string]string{"app": app}},
+			Status:     corev1.PodStatus{Phase: phase},
+		}
+	}
+	kubeClient := newFakeClientBuilder().WithObjects(
+		pod("ns-a", "example-1", "example", corev1.PodRunning),
+		pod("ns-a", "example-2", "example", corev1.PodRunning),
+		pod("ns-b", "example-1", "example", corev1.PodPending),
+		pod("ns-b", "example-2", "example", corev1.PodSucceeded),
+		pod("ns-c", "other-1", "other", corev1.PodRunning),
+		pod("kube-system", "example-1", "example", corev1.PodRunning),
+		&corev1.Namespace{ObjectMeta: v1.ObjectMeta{Name: "ns-a", Labels: map[string]string{"team": "a"}}},
+		&corev1.Namespace{ObjectMeta: v1.ObjectMeta{Name: "ns-b"}},
+		&corev1.Namespace{ObjectMeta: v1.ObjectMeta{Name: "kube-system", Labels: map[string]string{"team": "a"}}},
+	).Build()
+
+	clusterPodMonitoring := func(spec monitoringv1.ClusterPodMonitoringSpec) *monitoringv1.ClusterPodMonitoring {
+		if spec.Endpoints == nil {
+			spec.Endpoints = []monitoringv1.ScrapeEndpoint{{Port: intstr.FromString("metrics"), Interval: "10s"}}
+		}
+		// Defaulted metadata labels, which cause no other warnings.
+		spec.TargetLabels.Metadata = &[]string{"namespace", "pod", "container"}
+		return &monitoringv1.ClusterPodMonitoring{
+			ObjectMeta: v1.ObjectMeta{Name: "example"},
+			Spec:       spec,
+		}
+	}
+	exampleSelector := v1.LabelSelector{MatchLabels: map[string]string{"app": "example"}}
+
+	cases := []struct {
+		desc        string
+		obj         runtime.Object
+		wantWarning string
+	}{
+		{
+			desc:        "defaults",
+			obj:         clusterPodMonitoring(monitoringv1.ClusterPodMonitoringSpec{Selector: exampleSelector}),
+			wantWarning: "ClusterPodMonitoring matches approximately 3 targets on 3 pods in 2 namespaces",
+		},
+		{
+			desc:        "empty selector",
+			obj:         clusterPodMonitoring(monitoringv1.ClusterPodMonitoringSpec{}),
+			wantWarning: "ClusterPodMonitoring matches approximately 4 targets on 4 pods in 3 namespaces",
+		},
+		{
+			desc: "multiple endpoints and probe targets",
+			obj: clusterPodMonitoring(monitoringv1.ClusterPodMonitoringSpec{
+				Selector: exampleSelector,
+				Endpoints: []monitoringv1.ScrapeEndpoint{
+					{Port: intstr.FromString("metrics"), Interval: "10s"},
+					{Port: intstr.FromString("probe"), Interval: "10s", ProbeTargets: []string{"a.example.com", "b.example.com"}},
+				},
+			}),
+			wantWarning: "ClusterPodMonitoring matches approximately 9 targets on 3 pods in 2 namespaces",
+		},
+		{
+			desc: "without namespace exclusion and running filter",
+			obj: clusterPodMonitoring(monitoringv1.ClusterPodMonitoringSpec{
+				Selector:          exampleSelector,
+				ExcludeNamespaces: &[]string{},
+				FilterRunning:     new(bool),
+			}),
+			wantWarning: "ClusterPodMonitoring matches approximately 5 targets on 5 pods in 3 namespaces",
+		},
+		{
+			desc: "namespace selector",
+			obj: clusterPodMonitoring(monitoringv1.ClusterPodMonitoringSpec{
+				Selector:          exampleSelector,
+				NamespaceSelector: &v1.LabelSelector{MatchLabels: map[string]string{"team": "a"}},
+			}),
+			wantWarning: "ClusterPodMonitoring matches approximately 2 targets on 2 pods in 1 namespaces",
+		},
+		{
+			desc: "no matches",
+			obj: clusterPodMonitoring(monitoringv1.ClusterPodMonitoringSpec{
+				Selector: v1.LabelSelector{MatchLabels: map[string]string{"app": "missing"}},
+			}),
+			wantWarning: "ClusterPodMonitoring matches approximately 0 targets on 0 pods in 0 namespaces",
+		},
+		{
+			desc: "PodMonitoring",
+			obj: &monitoringv1.PodMonitoring{
+				ObjectMeta: v1.ObjectMeta{Namespace: "ns-a", Name: "example"},
+				Spec: monitoringv1.PodMonitoringSpec{
+					Selector:     exampleSelector,
+					Endpoints:    []monitoringv1.ScrapeEndpoint{{Port: intstr.FromString("metrics"), Interval: "10s"}},
+					TargetLabels: monitoringv1.TargetLabels{Metadata: &[]string{"pod", "container"}},
+				},
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			v := &podMonitoringValidator{targetEstimateReader: kubeClient}
+
+			expectWarnings := func(warnings admission.Warnings, err error) {
+				t.Helper()
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				var want admission.Warnings
+				if c.wantWarning != "" {
+					want = admission.Warnings{c.wantWarning}
+				}
+				if diff := cmp.Diff(want, warnings); diff != "" {
+					t.Errorf("unexpected warnings (-want, +got): %s", diff)
+				}
+			}
+			expectWarnings(v.ValidateCreate(context.Background(), c.obj))
+			expectWarnings(v.ValidateUpdate(context.Background(), c.obj, c.obj))
+
+			// No warnings without a reader.
+			v.targetEstimateReader = nil
+			if warnings, _ := v.ValidateCreate(context.Background(), c.obj); len(warnings) > 0 {
+				t.Errorf("unexpected warnings with disabled target estimate: %v", warnings)
+			}
+		})
+	}
+}
+
+func TestPodMonitoringValidatorTargetEstimateForbidden(t *testing.T) {
+	kubeClient := newFakeClientBuilder().WithInterceptorFuncs(interceptor.Funcs{
+		List: func(_ context.Context, _ client.WithWatch, _ client.ObjectList, _ ...client.ListOption) error {
+			return apierrors.NewForbidden(corev1.Resource("pods"), "", errors.New("missing RBAC"))
+		}}).Build()
+	v := &podMonitoringValidator{targetEstimateReader: kubeClient}
+	cm := &monitoringv1.ClusterPodMonitoring{
+		ObjectMeta: v1.ObjectMeta{Name: "example"},
+		Spec: monitoringv1.ClusterPodMonitoringSpec{
+			Selector:     v1.LabelSelector{MatchLabels: map[string]string{"app": "example"}},
+			Endpoints:    []monitoringv1.ScrapeEndpoint{{Port: intstr.FromString("metrics"), Interval: "10s"}},
+			TargetLabels: monitoringv1.TargetLabels{Metadata: &[]string{"namespace", "pod", "container"}},
+		},
+	}
+
+	// Missing permissions are reported as a warning and do not fail the admission.
+	warnings, err := v.ValidateCreate(context.Background(), cm)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := admission.Warnings{"unable to estimate the number of matched targets: the operator is not permitted to list pods in all namespaces"}
+	if diff := cmp.Diff(want, warnings); diff != "" {
+		t.Errorf("unexpected warnings (-want, +got): %s", diff)
+	}
+}
+
+func TestAdmissionMetrics(t *testing.T) {
+	wh := instrumentAdmission("PodMonitoring", admission.ValidatingWebhookFor(testScheme, &monitoringv1.PodMonitoring{}))
+
+	request := func(pm *monitoringv1.PodMonitoring) admission.Request {
+		pm.TypeMeta = v1.TypeMeta{
+			APIVersion: monitoringv1.SchemeGroupVersion.String(),
+			Kind:       "PodMonitoring",
+		}
+		raw, err := json.Marshal(pm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return admission.Request{
+			AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: admissionv1.Create,
+				Object:    runtime.RawExtension{Raw: raw},
+			},
+		}
+	}
+	valid := &monitoringv1.PodMonitoring{
+		ObjectMeta: v1.ObjectMeta{Name: "valid", Namespace: "default"},
+		Spec: monitoringv1.PodMonitoringSpec{
+			Endpoints: []monitoringv1.ScrapeEndpoint{{
+				Port:     intstr.FromString("metrics"),
+				Interval: "10s",
+			}},
+			TargetLabels: monitoringv1.TargetLabels{Metadata: &[]string{"pod", "container"}},
+		},
+	}
+	invalid := &monitoringv1.PodMonitoring{
+		ObjectMeta: v1.ObjectMeta{Name: "invalid", Namespace: "default"},
+	}
+
+	allowedBefore := testutil.ToFloat64(webhookAdmissions.WithLabelValues("PodMonitoring", "allowed"))
+	deniedBefore := testutil.ToFloat64(webhookAdmissions.WithLabelValues("PodMonitoring", "denied"))
+
+	ctx := context.Background()
+	if resp := wh.Handle(ctx, request(valid)); !resp.Allowed {
+		t.Fatalf("expected valid PodMonitoring to be allowed: %v", resp.Result)
+	}
+	if resp := wh.Handle(ctx, request(invalid)); resp.Allowed {
+		t.Fatal("expected invalid PodMonitoring to be denied")
+	}
+	if resp := wh.Handle(ctx, request(invalid)); resp.Allowed {
+		t.Fatal("expected invalid PodMonitoring to be denied")
+	}
+
+	if got := testutil.ToFloat64(webhookAdmissions.WithLabelValues("PodMonitoring", "allowed")) - allowedBefore; got != 1 {
+		t.Errorf("expected 1 allowed admission, got %v", got)
+	}
+	if got := testutil.ToFloat64(webhookAdmissions.WithLabelValues("PodMonitoring", "denied")) - deniedBefore; got != 2 {
+		t.Errorf("expected 2 denied admissions, got %v", got)
+	}
+}
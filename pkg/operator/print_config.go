@@ -0,0 +1,119 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	promconfig "github.com/prometheus/prometheus/config"
+	yaml "gopkg.in/yaml.v2"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
+
+	monitoringv1 "github.com/GoogleCloudPlatform/prometheus-engine/pkg/operator/apis/monitoring/v1"
+)
+
+// defaultScrapeInterval is the scrape interval the CRDs default endpoints to.
+const defaultScrapeInterval = "1m"
+
+// PrintScrapeConfigs reads PodMonitorings, ClusterPodMonitorings, and ClusterNodeMonitorings
+// from the YAML documents in r and writes the Prometheus scrape configs that the operator
+// generates for them to w, e.g. to debug them offline or check them against golden files.
+//
+// The defaults of the CRDs and admission webhooks are applied first. Settings that depend on
+// the cluster state, such as the OperatorConfig or paused reconciliation, are not taken into
+// account.
+func PrintScrapeConfigs(w io.Writer, r io.Reader, projectID, location, cluster string) error {
+	sc, err := NewScheme()
+	if err != nil {
+		return err
+	}
+	decoder := serializer.NewCodecFactory(sc).UniversalDeserializer()
+	reader := k8syaml.NewYAMLReader(bufio.NewReader(r))
+
+	var cfgs []*promconfig.ScrapeConfig
+	for i := 0; ; i++ {
+		doc, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read document %d: %w", i, err)
+		}
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+		obj, _, err := decoder.Decode(doc, nil, nil)
+		if err != nil {
+			return fmt.Errorf("decode document %d: %w", i, err)
+		}
+		var docCfgs []*promconfig.ScrapeConfig
+
+		switch o := obj.(type) {
+		case *monitoringv1.PodMonitoring:
+			if err := (&podMonitoringDefaulter{}).Default(context.Background(), o); err != nil {
+				return err
+			}
+			defaultEndpointIntervals(o.Spec.Endpoints)
+			docCfgs, err = o.ScrapeConfigs(projectID, location, cluster)
+		case *monitoringv1.ClusterPodMonitoring:
+			if err := (&clusterPodMonitoringDefaulter{}).Default(context.Background(), o); err != nil {
+				return err
+			}
+			defaultEndpointIntervals(o.Spec.Endpoints)
+			docCfgs, err = o.ScrapeConfigs(projectID, location, cluster)
+		case *monitoringv1.ClusterNodeMonitoring:
+			for i := range o.Spec.Endpoints {
+				if o.Spec.Endpoints[i].Interval == "" {
+					o.Spec.Endpoints[i].Interval = defaultScrapeInterval
+				}
+			}
+			docCfgs, err = o.ScrapeConfigs(projectID, location, cluster)
+		default:
+			return fmt.Errorf("document %d: unsupported kind %q", i, obj.GetObjectKind().GroupVersionKind().Kind)
+		}
+		if err != nil {
+			return fmt.Errorf("generate scrape configs of document %d: %w", i, err)
+		}
+		cfgs = append(cfgs, docCfgs...)
+	}
+	// Sort like the scrape configs of the collector configuration.
+	sort.SliceStable(cfgs, func(i, j int) bool {
+		return cfgs[i].JobName < cfgs[j].JobName
+	})
+
+	b, err := yaml.Marshal(struct {
+		ScrapeConfigs []*promconfig.ScrapeConfig `yaml:"scrape_configs"`
+	}{cfgs})
+	if err != nil {
+		return fmt.Errorf("marshal scrape configs: %w", err)
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+func defaultEndpointIntervals(eps []monitoringv1.ScrapeEndpoint) {
+	for i := range eps {
+		if eps[i].Interval == "" {
+			eps[i].Interval = defaultScrapeInterval
+		}
+	}
+}
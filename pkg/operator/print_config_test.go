@@ -0,0 +1,94 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPrintScrapeConfigs(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "print-config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var buf bytes.Buffer
+	if err := PrintScrapeConfigs(&buf, f, "test-proj", "test-loc", "test-cluster"); err != nil {
+		t.Fatal(err)
+	}
+	golden := filepath.Join("testdata", "print-config.golden.yaml")
+	if *updateGolden {
+		if err := os.WriteFile(golden, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(string(want), buf.String()); diff != "" {
+		t.Errorf("unexpected scrape configs, run with -update-golden to update (-want, +got): %s", diff)
+	}
+}
+
+func TestPrintScrapeConfigsErrors(t *testing.T) {
+	cases := []struct {
+		desc        string
+		input       string
+		errContains string
+	}{
+		{
+			desc: "unsupported kind",
+			input: `apiVersion: monitoring.googleapis.com/v1
+kind: Rules
+metadata:
+  name: example
+  namespace: gmp-test
+`,
+			errContains: `document 0: unsupported kind "Rules"`,
+		},
+		{
+			desc: "invalid endpoint",
+			input: `apiVersion: monitoring.googleapis.com/v1
+kind: PodMonitoring
+metadata:
+  name: example
+  namespace: gmp-test
+spec:
+  endpoints:
+  - port: metrics
+    interval: 10s
+    timeout: 20s
+`,
+			errContains: "generate scrape configs of document 0",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := PrintScrapeConfigs(&buf, strings.NewReader(c.input), "test-proj", "test-loc", "test-cluster")
+			if err == nil || !strings.Contains(err.Error(), c.errContains) {
+				t.Fatalf("expected error containing %q, got %v", c.errContains, err)
+			}
+		})
+	}
+}
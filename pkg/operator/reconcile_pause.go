@@ -0,0 +1,99 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	monitoringv1 "github.com/GoogleCloudPlatform/prometheus-engine/pkg/operator/apis/monitoring/v1"
+	promconfig "github.com/prometheus/prometheus/config"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// AnnotationReconcile pauses the reconciliation of a PodMonitoring or ClusterPodMonitoring
+// if set to "false". Its previously applied scrape configuration is kept as is until the
+// annotation is removed or set to another value.
+const AnnotationReconcile = "gmp.googleapis.com/reconcile"
+
+// reconcilePaused returns whether the reconciliation of the object is paused.
+func reconcilePaused(obj metav1.Object) bool {
+	return obj.GetAnnotations()[AnnotationReconcile] == "false"
+}
+
+// appliedScrapeConfigs returns the scrape configurations of the collector configuration
+// that is currently applied.
+func (r *collectionReconciler) appliedScrapeConfigs(ctx context.Context) ([]*promconfig.ScrapeConfig, error) {
+	var cm corev1.ConfigMap
+	err := r.client.Get(ctx, client.ObjectKey{Namespace: r.opts.OperatorNamespace, Name: NameCollector}, &cm)
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("get Prometheus config: %w", err)
+	}
+	b := []byte(cm.Data[configFilename])
+	if compressed, ok := cm.BinaryData[configFilename]; ok {
+		zr, err := gzip.NewReader(bytes.NewReader(compressed))
+		if err != nil {
+			return nil, fmt.Errorf("create gzip reader: %w", err)
+		}
+		defer zr.Close()
+
+		if b, err = io.ReadAll(zr); err != nil {
+			return nil, fmt.Errorf("decompress Prometheus config: %w", err)
+		}
+	}
+	cfg, err := promconfig.Load(string(b), false, nil)
+	if err != nil {
+		return nil, fmt.Errorf("load Prometheus config: %w", err)
+	}
+	return cfg.ScrapeConfigs, nil
+}
+
+// scrapeConfigsForKey returns the scrape configurations generated for the monitoring
+// resource with the given key.
+func scrapeConfigsForKey(cfgs []*promconfig.ScrapeConfig, key string) (res []*promconfig.ScrapeConfig) {
+	for _, cfg := range cfgs {
+		if strings.HasPrefix(cfg.JobName, key+"/") {
+			res = append(res, cfg)
+		}
+	}
+	return res
+}
+
+func setReconciliationPausedCondition(status *monitoringv1.MonitoringStatus, gen int64, paused bool, scrapeConfigs int) (bool, error) {
+	cond := &monitoringv1.MonitoringCondition{
+		Type:   monitoringv1.ReconciliationPaused,
+		Status: corev1.ConditionFalse,
+	}
+	if paused {
+		cond.Status = corev1.ConditionTrue
+		cond.Reason = "ReconcileAnnotation"
+		cond.Message = fmt.Sprintf("reconciliation is paused by the %s=false annotation, keeping %d previously applied scrape configs", AnnotationReconcile, scrapeConfigs)
+	} else if !slices.ContainsFunc(status.Conditions, func(c monitoringv1.MonitoringCondition) bool {
+		return c.Type == monitoringv1.ReconciliationPaused
+	}) {
+		return false, nil
+	}
+	return status.SetMonitoringCondition(gen, metav1.Now(), cond)
+}
@@ -239,14 +239,21 @@ func (r *rulesReconciler) ensureRuleConfigs(ctx context.Context, projectID, loca
 	if err := r.client.List(ctx, &rulesList); err != nil {
 		return fmt.Errorf("list rules: %w", err)
 	}
+	var statusUpdates []monitoringv1.MonitoringCRD
 	for _, rs := range rulesList.Items {
+		// Reassign so we can safely get a pointer.
+		rs := rs
+
 		result, err := generateRules(&rs, projectID, location, cluster)
 		if err != nil {
-			// TODO(freinartz): update resource condition.
 			logger.Error(err, "converting rules failed", "rules_namespace", rs.Namespace, "rules_name", rs.Name)
+		} else {
+			filename := fmt.Sprintf("rules__%s__%s.yaml", rs.Namespace, rs.Name)
+			cm.Data[filename] = result
+		}
+		if setRulesCondition(ctx, &rs, err) {
+			statusUpdates = append(statusUpdates, &rs)
 		}
-		filename := fmt.Sprintf("rules__%s__%s.yaml", rs.Namespace, rs.Name)
-		cm.Data[filename] = result
 	}
 
 	var clusterRulesList monitoringv1.ClusterRulesList
@@ -254,13 +261,19 @@ func (r *rulesReconciler) ensureRuleConfigs(ctx context.Context, projectID, loca
 		return fmt.Errorf("list cluster rules: %w", err)
 	}
 	for _, rs := range clusterRulesList.Items {
+		// Reassign so we can safely get a pointer.
+		rs := rs
+
 		result, err := generateClusterRules(&rs, projectID, location, cluster)
 		if err != nil {
-			// TODO(freinartz): update resource condition.
 			logger.Error(err, "converting rules failed", "clusterrules_name", rs.Name)
+		} else {
+			filename := fmt.Sprintf("clusterrules__%s.yaml", rs.Name)
+			cm.Data[filename] = result
+		}
+		if setRulesCondition(ctx, &rs, err) {
+			statusUpdates = append(statusUpdates, &rs)
 		}
-		filename := fmt.Sprintf("clusterrules__%s.yaml", rs.Name)
-		cm.Data[filename] = result
 	}
 
 	var globalRulesList monitoringv1.GlobalRulesList
@@ -268,13 +281,19 @@ func (r *rulesReconciler) ensureRuleConfigs(ctx context.Context, projectID, loca
 		return fmt.Errorf("list global rules: %w", err)
 	}
 	for _, rs := range globalRulesList.Items {
+		// Reassign so we can safely get a pointer.
+		rs := rs
+
 		result, err := generateGlobalRules(&rs)
 		if err != nil {
-			// TODO(freinartz): update resource condition.
 			logger.Error(err, "converting rules failed", "globalrules_name", rs.Name)
+		} else {
+			filename := fmt.Sprintf("globalrules__%s.yaml", rs.Name)
+			cm.Data[filename] = result
+		}
+		if setRulesCondition(ctx, &rs, err) {
+			statusUpdates = append(statusUpdates, &rs)
 		}
-		filename := fmt.Sprintf("globalrules__%s.yaml", rs.Name)
-		cm.Data[filename] = result
 	}
 
 	// Create or update generated rule ConfigMap.
@@ -285,14 +304,51 @@ func (r *rulesReconciler) ensureRuleConfigs(ctx context.Context, projectID, loca
 	} else if err != nil {
 		return fmt.Errorf("update generated rules: %w", err)
 	}
+
+	// Only report status once the rule files were actually written.
+	for _, obj := range statusUpdates {
+		if err := patchMonitoringStatus(ctx, r.client, obj, obj.GetMonitoringStatus()); err != nil {
+			logger.Error(err, "update rules status", "namespace", obj.GetNamespace(), "name", obj.GetName())
+		}
+	}
 	return nil
 }
 
+// setRulesCondition records on the resource whether a rule file could be generated from it.
+// Resources that fail to convert are left out of the generated rule files so that they cannot
+// break evaluation of any other rules. It returns true if the status changed.
+func setRulesCondition(ctx context.Context, obj monitoringv1.MonitoringCRD, genErr error) bool {
+	logger, _ := logr.FromContext(ctx)
+
+	cond := &monitoringv1.MonitoringCondition{
+		Type:   monitoringv1.ConfigurationCreateSuccess,
+		Status: corev1.ConditionTrue,
+	}
+	if genErr != nil {
+		cond = &monitoringv1.MonitoringCondition{
+			Type:    monitoringv1.ConfigurationCreateSuccess,
+			Status:  corev1.ConditionFalse,
+			Reason:  "RulesGenerationError",
+			Message: genErr.Error(),
+		}
+	}
+	change, err := obj.GetMonitoringStatus().SetMonitoringCondition(obj.GetGeneration(), metav1.Now(), cond)
+	if err != nil {
+		// Log an error but let operator continue to avoid getting stuck
+		// on a potential bad resource.
+		logger.Error(err, "setting rules status state", "namespace", obj.GetNamespace(), "name", obj.GetName())
+	}
+	return change
+}
+
 func generateRules(apiRules *monitoringv1.Rules, projectID, location, cluster string) (string, error) {
 	rs, err := rules.FromAPIRules(apiRules.Spec.Groups)
 	if err != nil {
 		return "", fmt.Errorf("converting rules failed: %w", err)
 	}
+	if err := rules.AddLabels(&rs, apiRules.Spec.Labels); err != nil {
+		return "", fmt.Errorf("adding rule labels failed: %w", err)
+	}
 	if err := rules.Scope(&rs, map[string]string{
 		export.KeyProjectID: projectID,
 		export.KeyLocation:  location,
@@ -313,6 +369,9 @@ func generateClusterRules(apiRules *monitoringv1.ClusterRules, projectID, locati
 	if err != nil {
 		return "", fmt.Errorf("converting rules failed: %w", err)
 	}
+	if err := rules.AddLabels(&rs, apiRules.Spec.Labels); err != nil {
+		return "", fmt.Errorf("adding rule labels failed: %w", err)
+	}
 	if err := rules.Scope(&rs, map[string]string{
 		export.KeyProjectID: projectID,
 		export.KeyLocation:  location,
@@ -332,6 +391,9 @@ func generateGlobalRules(apiRules *monitoringv1.GlobalRules) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("converting rules failed: %w", err)
 	}
+	if err := rules.AddLabels(&rs, apiRules.Spec.Labels); err != nil {
+		return "", fmt.Errorf("adding rule labels failed: %w", err)
+	}
 	if err := rules.Scope(&rs, map[string]string{}); err != nil {
 		return "", fmt.Errorf("isolating rules failed: %w", err)
 	}
@@ -17,11 +17,13 @@ package operator
 import (
 	"context"
 	"errors"
+	"sort"
 	"testing"
 
 	monitoringv1 "github.com/GoogleCloudPlatform/prometheus-engine/pkg/operator/apis/monitoring/v1"
 	"github.com/google/go-cmp/cmp"
 	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
@@ -75,6 +77,84 @@ func TestGenerateRules(t *testing.T) {
 			want:        wantRules,
 			wantErr:     false,
 		},
+		{
+			name: "rules with labels",
+			apiRules: &monitoringv1.Rules{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "test-namespace",
+				},
+				Spec: monitoringv1.RulesSpec{
+					Groups: []monitoringv1.RuleGroup{
+						{
+							Name: "test-group",
+							Rules: []monitoringv1.Rule{
+								{
+									Record: "test_record",
+									Expr:   "test_expr",
+								},
+								{
+									Alert:  "test_alert",
+									Expr:   "test_expr > 0",
+									Labels: map[string]string{"severity": "critical"},
+								},
+							},
+						},
+					},
+					Labels: map[string]string{"team": "a", "severity": "warning"},
+				},
+			},
+			projectID:   "123",
+			location:    "us-central1",
+			clusterName: "test-cluster",
+			want: `groups:
+    - name: test-group
+      rules:
+        - record: test_record
+          expr: test_expr{cluster="test-cluster",location="us-central1",namespace="test-namespace",project_id="123"}
+          labels:
+            cluster: test-cluster
+            location: us-central1
+            namespace: test-namespace
+            project_id: "123"
+            severity: warning
+            team: a
+        - alert: test_alert
+          expr: test_expr{cluster="test-cluster",location="us-central1",namespace="test-namespace",project_id="123"} > 0
+          labels:
+            cluster: test-cluster
+            location: us-central1
+            namespace: test-namespace
+            project_id: "123"
+            severity: critical
+            team: a
+`,
+		},
+		{
+			name: "labels overriding scope",
+			apiRules: &monitoringv1.Rules{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "test-namespace",
+				},
+				Spec: monitoringv1.RulesSpec{
+					Groups: []monitoringv1.RuleGroup{
+						{
+							Name: "test-group",
+							Rules: []monitoringv1.Rule{
+								{
+									Record: "test_record",
+									Expr:   "test_expr",
+								},
+							},
+						},
+					},
+					Labels: map[string]string{"namespace": "other"},
+				},
+			},
+			projectID:   "123",
+			location:    "us-central1",
+			clusterName: "test-cluster",
+			wantErr:     true,
+		},
 		{
 			name: "invalid rules",
 			apiRules: &monitoringv1.Rules{
@@ -362,3 +442,108 @@ func TestScaleRuleConsumers(t *testing.T) {
 		})
 	}
 }
+
+func TestEnsureRuleConfigs(t *testing.T) {
+	validGroups := []monitoringv1.RuleGroup{
+		{
+			Name: "test-group",
+			Rules: []monitoringv1.Rule{
+				{
+					Record: "test_record",
+					Expr:   "test_expr",
+				},
+			},
+		},
+	}
+	invalidGroups := []monitoringv1.RuleGroup{
+		{
+			Name: "test-group",
+			Rules: []monitoringv1.Rule{
+				{
+					Record: "test_record",
+					Expr:   "test_expr{",
+				},
+			},
+		},
+	}
+	validRules := &monitoringv1.Rules{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "valid", Generation: 1},
+		Spec:       monitoringv1.RulesSpec{Groups: validGroups},
+	}
+	invalidRules := &monitoringv1.Rules{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "invalid", Generation: 1},
+		Spec:       monitoringv1.RulesSpec{Groups: invalidGroups},
+	}
+	validClusterRules := &monitoringv1.ClusterRules{
+		ObjectMeta: metav1.ObjectMeta{Name: "valid", Generation: 1},
+		Spec:       monitoringv1.RulesSpec{Groups: validGroups},
+	}
+	invalidGlobalRules := &monitoringv1.GlobalRules{
+		ObjectMeta: metav1.ObjectMeta{Name: "invalid", Generation: 1},
+		Spec:       monitoringv1.RulesSpec{Groups: invalidGroups},
+	}
+
+	ctx := context.Background()
+	c := newFakeClientBuilder().WithObjects(validRules, invalidRules, validClusterRules, invalidGlobalRules).Build()
+	r := newRulesReconciler(c, Options{OperatorNamespace: "gmp-system"})
+
+	if err := r.ensureRuleConfigs(ctx, "123", "us-central1", "test-cluster"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only valid resources must produce rule files so that invalid ones cannot
+	// break evaluation of the others.
+	var cm corev1.ConfigMap
+	if err := c.Get(ctx, client.ObjectKey{Namespace: "gmp-system", Name: nameRulesGenerated}, &cm); err != nil {
+		t.Fatal(err)
+	}
+	var gotFiles []string
+	for filename := range cm.Data {
+		gotFiles = append(gotFiles, filename)
+	}
+	sort.Strings(gotFiles)
+	wantFiles := []string{
+		"clusterrules__valid.yaml",
+		"empty.yaml",
+		"rules__ns1__valid.yaml",
+	}
+	if diff := cmp.Diff(wantFiles, gotFiles); diff != "" {
+		t.Errorf("unexpected rule files (-want, +got): %s", diff)
+	}
+
+	// Each resource must report its own status.
+	for _, tc := range []struct {
+		obj  monitoringv1.MonitoringCRD
+		want corev1.ConditionStatus
+	}{
+		{obj: &monitoringv1.Rules{}, want: corev1.ConditionTrue},
+		{obj: &monitoringv1.Rules{}, want: corev1.ConditionFalse},
+		{obj: &monitoringv1.ClusterRules{}, want: corev1.ConditionTrue},
+		{obj: &monitoringv1.GlobalRules{}, want: corev1.ConditionFalse},
+	} {
+		key := client.ObjectKey{Name: "valid"}
+		if tc.want == corev1.ConditionFalse {
+			key.Name = "invalid"
+		}
+		if _, ok := tc.obj.(*monitoringv1.Rules); ok {
+			key.Namespace = "ns1"
+		}
+		if err := c.Get(ctx, key, tc.obj); err != nil {
+			t.Fatal(err)
+		}
+		status := tc.obj.GetMonitoringStatus()
+		if status.ObservedGeneration != 1 {
+			t.Errorf("%T %s: expected observed generation 1, got %d", tc.obj, key, status.ObservedGeneration)
+		}
+		if len(status.Conditions) != 1 {
+			t.Fatalf("%T %s: expected 1 condition, got %d", tc.obj, key, len(status.Conditions))
+		}
+		cond := status.Conditions[0]
+		if cond.Type != monitoringv1.ConfigurationCreateSuccess || cond.Status != tc.want {
+			t.Errorf("%T %s: expected condition %s=%s, got %s=%s", tc.obj, key, monitoringv1.ConfigurationCreateSuccess, tc.want, cond.Type, cond.Status)
+		}
+		if tc.want == corev1.ConditionFalse && cond.Reason != "RulesGenerationError" {
+			t.Errorf("%T %s: unexpected reason %q", tc.obj, key, cond.Reason)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	monitoringv1 "github.com/GoogleCloudPlatform/prometheus-engine/pkg/operator/apis/monitoring/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// monitoringScope describes which pod endpoints a PodMonitoring or ClusterPodMonitoring
+// may scrape.
+type monitoringScope struct {
+	key string
+	// The namespace of a PodMonitoring. Empty for ClusterPodMonitorings.
+	namespace         string
+	excludeNamespaces []string
+	selector          metav1.LabelSelector
+	endpoints         []monitoringv1.ScrapeEndpoint
+}
+
+func podMonitoringScope(pm *monitoringv1.PodMonitoring) monitoringScope {
+	return monitoringScope{
+		key:       pm.GetKey(),
+		namespace: pm.Namespace,
+		selector:  pm.Spec.Selector,
+		endpoints: pm.Spec.Endpoints,
+	}
+}
+
+func clusterPodMonitoringScope(cm *monitoringv1.ClusterPodMonitoring) monitoringScope {
+	return monitoringScope{
+		key:               cm.GetKey(),
+		excludeNamespaces: cm.ExcludedNamespaces(),
+		selector:          cm.Spec.Selector,
+		endpoints:         cm.Spec.Endpoints,
+	}
+}
+
+// findTargetOverlaps returns the keys of the other monitorings each monitoring may share
+// (pod, port, path) targets with. Targets are only known to the collectors, so overlaps
+// are detected from the pod selectors and may include selectors that match no common pod
+// in practice. Selectors that provably select disjoint sets of pods never overlap.
+func findTargetOverlaps(scopes []monitoringScope) map[string][]string {
+	overlaps := map[string][]string{}
+	for i := range scopes {
+		for j := i + 1; j < len(scopes); j++ {
+			a, b := &scopes[i], &scopes[j]
+			if !scopesOverlap(a, b) {
+				continue
+			}
+			overlaps[a.key] = append(overlaps[a.key], b.key)
+			overlaps[b.key] = append(overlaps[b.key], a.key)
+		}
+	}
+	for _, keys := range overlaps {
+		sort.Strings(keys)
+	}
+	return overlaps
+}
+
+func scopesOverlap(a, b *monitoringScope) bool {
+	return namespacesOverlap(a, b) && endpointsOverlap(a.endpoints, b.endpoints) && !selectorsDisjoint(a.selector, b.selector)
+}
+
+func namespacesOverlap(a, b *monitoringScope) bool {
+	switch {
+	case a.namespace != "" && b.namespace != "":
+		return a.namespace == b.namespace
+	case a.namespace != "":
+		return !slices.Contains(b.excludeNamespaces, a.namespace)
+	case b.namespace != "":
+		return !slices.Contains(a.excludeNamespaces, b.namespace)
+	}
+	return true
+}
+
+func endpointsOverlap(a, b []monitoringv1.ScrapeEndpoint) bool {
+	targets := sets.New[string]()
+	for _, ep := range expandEndpointPorts(a) {
+		targets.Insert(endpointTarget(ep))
+	}
+	for _, ep := range expandEndpointPorts(b) {
+		if targets.Has(endpointTarget(ep)) {
+			return true
+		}
+	}
+	return false
+}
+
+// expandEndpointPorts returns an endpoint for each port scraped by the endpoints. Endpoints
+// with invalid port lists are kept as is as no scrape configuration is generated for them.
+func expandEndpointPorts(endpoints []monitoringv1.ScrapeEndpoint) []monitoringv1.ScrapeEndpoint {
+	var res []monitoringv1.ScrapeEndpoint
+	for i := range endpoints {
+		eps, err := endpoints[i].ExpandPorts()
+		if err != nil {
+			eps = endpoints[i : i+1]
+		}
+		res = append(res, eps...)
+	}
+	return res
+}
+
+// endpointTarget returns the port and path scraped by the endpoint on each selected pod.
+func endpointTarget(ep monitoringv1.ScrapeEndpoint) string {
+	path := ep.Path
+	if path == "" {
+		path = "/metrics"
+	}
+	return ep.Port.String() + path
+}
+
+// labelConstraint accumulates the requirements a pair of selectors places on a single label.
+type labelConstraint struct {
+	exists, notExists bool
+	// The values the label may have. Any value is allowed if nil.
+	allowed  sets.Set[string]
+	excluded sets.Set[string]
+}
+
+func (c *labelConstraint) satisfiable() bool {
+	if c.exists && c.notExists {
+		return false
+	}
+	if c.allowed != nil && c.allowed.Difference(c.excluded).Len() == 0 {
+		return false
+	}
+	return true
+}
+
+// selectorsDisjoint returns true if no set of pod labels can match both selectors.
+// Invalid selectors are considered disjoint as no scrape configuration is generated for them.
+func selectorsDisjoint(a, b metav1.LabelSelector) bool {
+	constraints := map[string]*labelConstraint{}
+	for _, s := range []metav1.LabelSelector{a, b} {
+		sel, err := metav1.LabelSelectorAsSelector(&s)
+		if err != nil {
+			return true
+		}
+		reqs, _ := sel.Requirements()
+		for _, req := range reqs {
+			c, ok := constraints[req.Key()]
+			if !ok {
+				c = &labelConstraint{excluded: sets.New[string]()}
+				constraints[req.Key()] = c
+			}
+			values := sets.Set[string](req.Values())
+			switch req.Operator() {
+			case selection.In, selection.Equals, selection.DoubleEquals:
+				c.exists = true
+				if c.allowed == nil {
+					c.allowed = values.Clone()
+				} else {
+					c.allowed = c.allowed.Intersection(values)
+				}
+			case selection.NotIn, selection.NotEquals:
+				c.excluded = c.excluded.Union(values)
+			case selection.Exists:
+				c.exists = true
+			case selection.DoesNotExist:
+				c.notExists = true
+			}
+		}
+	}
+	for _, c := range constraints {
+		if !c.satisfiable() {
+			return true
+		}
+	}
+	return false
+}
+
+// setTargetOverlapCondition sets the ScrapeTargetOverlap condition based on the monitorings
+// the resource overlaps with. The condition is only added once an overlap was detected.
+func setTargetOverlapCondition(status *monitoringv1.MonitoringStatus, gen int64, overlaps []string) (bool, error) {
+	cond := &monitoringv1.MonitoringCondition{
+		Type:   monitoringv1.ScrapeTargetOverlap,
+		Status: corev1.ConditionFalse,
+	}
+	if len(overlaps) > 0 {
+		cond.Status = corev1.ConditionTrue
+		cond.Reason = "TargetsOverlap"
+		cond.Message = fmt.Sprintf("endpoints may scrape the same targets as %s", strings.Join(overlaps, ", "))
+	} else if !slices.ContainsFunc(status.Conditions, func(c monitoringv1.MonitoringCondition) bool {
+		return c.Type == monitoringv1.ScrapeTargetOverlap
+	}) {
+		return false, nil
+	}
+	return status.SetMonitoringCondition(gen, metav1.Now(), cond)
+}
@@ -0,0 +1,243 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"testing"
+
+	monitoringv1 "github.com/GoogleCloudPlatform/prometheus-engine/pkg/operator/apis/monitoring/v1"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestFindTargetOverlaps(t *testing.T) {
+	podMonitoring := func(namespace, name string, selector metav1.LabelSelector, endpoints ...monitoringv1.ScrapeEndpoint) monitoringScope {
+		return podMonitoringScope(&monitoringv1.PodMonitoring{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Spec: monitoringv1.PodMonitoringSpec{
+				Selector:  selector,
+				Endpoints: endpoints,
+			},
+		})
+	}
+	clusterPodMonitoring := func(name string, excludeNamespaces *[]string, selector metav1.LabelSelector, endpoints ...monitoringv1.ScrapeEndpoint) monitoringScope {
+		return clusterPodMonitoringScope(&monitoringv1.ClusterPodMonitoring{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: monitoringv1.ClusterPodMonitoringSpec{
+				Selector:          selector,
+				Endpoints:         endpoints,
+				ExcludeNamespaces: excludeNamespaces,
+			},
+		})
+	}
+	matchLabels := func(kv ...string) metav1.LabelSelector {
+		s := metav1.LabelSelector{MatchLabels: map[string]string{}}
+		for i := 0; i < len(kv); i += 2 {
+			s.MatchLabels[kv[i]] = kv[i+1]
+		}
+		return s
+	}
+	metrics := monitoringv1.ScrapeEndpoint{Port: intstr.FromString("metrics")}
+
+	cases := []struct {
+		doc    string
+		scopes []monitoringScope
+		want   map[string][]string
+	}{
+		{
+			doc: "same selector and endpoint",
+			scopes: []monitoringScope{
+				podMonitoring("ns1", "a", matchLabels("app", "foo"), metrics),
+				podMonitoring("ns1", "b", matchLabels("app", "foo"), metrics),
+			},
+			want: map[string][]string{
+				"PodMonitoring/ns1/a": {"PodMonitoring/ns1/b"},
+				"PodMonitoring/ns1/b": {"PodMonitoring/ns1/a"},
+			},
+		},
+		{
+			doc: "default and explicit metrics path",
+			scopes: []monitoringScope{
+				podMonitoring("ns1", "a", matchLabels("app", "foo"), metrics),
+				podMonitoring("ns1", "b", matchLabels("app", "foo"), monitoringv1.ScrapeEndpoint{
+					Port: intstr.FromString("metrics"),
+					Path: "/metrics",
+				}),
+			},
+			want: map[string][]string{
+				"PodMonitoring/ns1/a": {"PodMonitoring/ns1/b"},
+				"PodMonitoring/ns1/b": {"PodMonitoring/ns1/a"},
+			},
+		},
+		{
+			doc: "different namespaces",
+			scopes: []monitoringScope{
+				podMonitoring("ns1", "a", matchLabels("app", "foo"), metrics),
+				podMonitoring("ns2", "a", matchLabels("app", "foo"), metrics),
+			},
+			want: map[string][]string{},
+		},
+		{
+			doc: "different ports and paths",
+			scopes: []monitoringScope{
+				podMonitoring("ns1", "a", matchLabels("app", "foo"), metrics),
+				podMonitoring("ns1", "b", matchLabels("app", "foo"), monitoringv1.ScrapeEndpoint{Port: intstr.FromInt(9090)}),
+				podMonitoring("ns1", "c", matchLabels("app", "foo"), monitoringv1.ScrapeEndpoint{
+					Port: intstr.FromString("metrics"),
+					Path: "/federate",
+				}),
+			},
+			want: map[string][]string{},
+		},
+		{
+			doc: "port list containing the port",
+			scopes: []monitoringScope{
+				podMonitoring("ns1", "a", matchLabels("app", "foo"), metrics),
+				podMonitoring("ns1", "b", matchLabels("app", "foo"), monitoringv1.ScrapeEndpoint{Port: intstr.FromString("web, metrics")}),
+				podMonitoring("ns1", "c", matchLabels("app", "foo"), monitoringv1.ScrapeEndpoint{Port: intstr.FromString("web-0,9090")}),
+			},
+			want: map[string][]string{
+				"PodMonitoring/ns1/a": {"PodMonitoring/ns1/b"},
+				"PodMonitoring/ns1/b": {"PodMonitoring/ns1/a"},
+			},
+		},
+		{
+			doc: "conflicting label values",
+			scopes: []monitoringScope{
+				podMonitoring("ns1", "a", matchLabels("app", "foo"), metrics),
+				podMonitoring("ns1", "b", matchLabels("app", "bar"), metrics),
+			},
+			want: map[string][]string{},
+		},
+		{
+			doc: "subset selector",
+			scopes: []monitoringScope{
+				podMonitoring("ns1", "a", matchLabels("app", "foo"), metrics),
+				podMonitoring("ns1", "b", matchLabels("app", "foo", "tier", "web"), metrics),
+			},
+			want: map[string][]string{
+				"PodMonitoring/ns1/a": {"PodMonitoring/ns1/b"},
+				"PodMonitoring/ns1/b": {"PodMonitoring/ns1/a"},
+			},
+		},
+		{
+			doc: "disjoint expressions",
+			scopes: []monitoringScope{
+				podMonitoring("ns1", "a", metav1.LabelSelector{
+					MatchExpressions: []metav1.LabelSelectorRequirement{
+						{Key: "app", Operator: metav1.LabelSelectorOpIn, Values: []string{"foo", "bar"}},
+					},
+				}, metrics),
+				podMonitoring("ns1", "b", metav1.LabelSelector{
+					MatchExpressions: []metav1.LabelSelectorRequirement{
+						{Key: "app", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"foo", "bar"}},
+					},
+				}, metrics),
+				podMonitoring("ns1", "c", metav1.LabelSelector{
+					MatchExpressions: []metav1.LabelSelectorRequirement{
+						{Key: "app", Operator: metav1.LabelSelectorOpDoesNotExist},
+					},
+				}, metrics),
+			},
+			want: map[string][]string{
+				// A pod without an app label or any other app label value matches both.
+				"PodMonitoring/ns1/b": {"PodMonitoring/ns1/c"},
+				"PodMonitoring/ns1/c": {"PodMonitoring/ns1/b"},
+			},
+		},
+		{
+			doc: "cluster pod monitoring",
+			scopes: []monitoringScope{
+				podMonitoring("ns1", "a", matchLabels("app", "foo"), metrics),
+				podMonitoring("kube-system", "a", matchLabels("app", "foo"), metrics),
+				clusterPodMonitoring("all", nil, metav1.LabelSelector{}, metrics),
+			},
+			want: map[string][]string{
+				"PodMonitoring/ns1/a":      {"ClusterPodMonitoring/all"},
+				"ClusterPodMonitoring/all": {"PodMonitoring/ns1/a"},
+			},
+		},
+		{
+			doc: "cluster pod monitorings",
+			scopes: []monitoringScope{
+				clusterPodMonitoring("a", &[]string{}, matchLabels("app", "foo"), metrics),
+				clusterPodMonitoring("b", &[]string{}, matchLabels("tier", "web"), metrics),
+				clusterPodMonitoring("c", &[]string{}, matchLabels("app", "bar"), metrics),
+			},
+			want: map[string][]string{
+				"ClusterPodMonitoring/a": {"ClusterPodMonitoring/b"},
+				"ClusterPodMonitoring/b": {"ClusterPodMonitoring/a", "ClusterPodMonitoring/c"},
+				"ClusterPodMonitoring/c": {"ClusterPodMonitoring/b"},
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.doc, func(t *testing.T) {
+			got := findTargetOverlaps(c.scopes)
+			if diff := cmp.Diff(c.want, got); diff != "" {
+				t.Errorf("unexpected overlaps (-want, +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestSetTargetOverlapCondition(t *testing.T) {
+	var status monitoringv1.MonitoringStatus
+
+	// No condition is added without an overlap.
+	change, err := setTargetOverlapCondition(&status, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if change || len(status.Conditions) > 0 {
+		t.Fatalf("unexpected condition change: %v", status.Conditions)
+	}
+
+	getCondition := func() *monitoringv1.MonitoringCondition {
+		t.Helper()
+		for _, c := range status.Conditions {
+			if c.Type == monitoringv1.ScrapeTargetOverlap {
+				return &c
+			}
+		}
+		t.Fatal("condition not found")
+		return nil
+	}
+
+	change, err = setTargetOverlapCondition(&status, 1, []string{"PodMonitoring/ns1/b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !change {
+		t.Error("expected condition change")
+	}
+	if cond := getCondition(); cond.Status != corev1.ConditionTrue || cond.Message != "endpoints may scrape the same targets as PodMonitoring/ns1/b" {
+		t.Errorf("unexpected condition: %+v", cond)
+	}
+
+	// The condition is cleared once the overlap is gone.
+	change, err = setTargetOverlapCondition(&status, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !change {
+		t.Error("expected condition change")
+	}
+	if cond := getCondition(); cond.Status != corev1.ConditionFalse {
+		t.Errorf("unexpected condition: %+v", cond)
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	monitoringv1 "github.com/GoogleCloudPlatform/prometheus-engine/pkg/operator/apis/monitoring/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+)
+
+const (
+	// Name of the collector volume holding the requested service account tokens.
+	serviceAccountTokensVolumeName = "service-account-tokens"
+	// Validity of the requested service account tokens. The kubelet refreshes them once
+	// 80% of it has passed.
+	serviceAccountTokenExpirationSeconds = 3600
+)
+
+// serviceAccountTokenAudiences returns the sorted, distinct audiences of the service account
+// tokens referenced by all PodMonitorings and ClusterPodMonitorings.
+func (r *collectionReconciler) serviceAccountTokenAudiences(ctx context.Context) ([]string, error) {
+	var (
+		podMons        monitoringv1.PodMonitoringList
+		clusterPodMons monitoringv1.ClusterPodMonitoringList
+	)
+	if err := r.client.List(ctx, &podMons); err != nil {
+		return nil, fmt.Errorf("failed to list PodMonitorings: %w", err)
+	}
+	if err := r.client.List(ctx, &clusterPodMons); err != nil {
+		return nil, fmt.Errorf("failed to list ClusterPodMonitorings: %w", err)
+	}
+	var audiences []string
+	add := func(endpoints []monitoringv1.ScrapeEndpoint) {
+		for _, ep := range endpoints {
+			auth := ep.HTTPClientConfig.Authorization
+			if auth == nil || auth.ServiceAccountToken == nil || auth.ServiceAccountToken.Audience == "" {
+				continue
+			}
+			audiences = append(audiences, auth.ServiceAccountToken.Audience)
+		}
+	}
+	for _, pm := range podMons.Items {
+		add(pm.Spec.Endpoints)
+	}
+	for _, cm := range clusterPodMons.Items {
+		add(cm.Spec.Endpoints)
+	}
+	slices.Sort(audiences)
+	return slices.Compact(audiences), nil
+}
+
+// setServiceAccountTokensVolume configures a projected volume with a service account token
+// for each audience in the pod spec and mounts it into the prometheus container. The volume
+// is removed if there are no audiences.
+func setServiceAccountTokensVolume(spec *corev1.PodSpec, audiences []string) {
+	spec.Volumes = slices.DeleteFunc(spec.Volumes, func(v corev1.Volume) bool {
+		return v.Name == serviceAccountTokensVolumeName
+	})
+	for i, c := range spec.Containers {
+		if c.Name != CollectorPrometheusContainerName {
+			continue
+		}
+		mounts := slices.DeleteFunc(c.VolumeMounts, func(m corev1.VolumeMount) bool {
+			return m.Name == serviceAccountTokensVolumeName
+		})
+		if len(audiences) > 0 {
+			mounts = append(mounts, corev1.VolumeMount{
+				Name:      serviceAccountTokensVolumeName,
+				MountPath: monitoringv1.CollectorServiceAccountTokensDir,
+				ReadOnly:  true,
+			})
+		}
+		spec.Containers[i].VolumeMounts = mounts
+	}
+	if len(audiences) == 0 {
+		return
+	}
+	var sources []corev1.VolumeProjection
+	for _, audience := range audiences {
+		sources = append(sources, corev1.VolumeProjection{
+			ServiceAccountToken: &corev1.ServiceAccountTokenProjection{
+				Audience:          audience,
+				ExpirationSeconds: ptr.To[int64](serviceAccountTokenExpirationSeconds),
+				Path:              monitoringv1.CollectorServiceAccountTokenFile(audience),
+			},
+		})
+	}
+	spec.Volumes = append(spec.Volumes, corev1.Volume{
+		Name: serviceAccountTokensVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			Projected: &corev1.ProjectedVolumeSource{Sources: sources},
+		},
+	})
+}
@@ -0,0 +1,105 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	monitoringv1 "github.com/GoogleCloudPlatform/prometheus-engine/pkg/operator/apis/monitoring/v1"
+)
+
+// targetEstimate is the approximate number of targets of a ClusterPodMonitoring.
+type targetEstimate struct {
+	targets    int
+	pods       int
+	namespaces int
+}
+
+// estimateClusterPodMonitoringTargets lists the pods that the ClusterPodMonitoring selects
+// and returns the approximate number of targets it scrapes. Every selected pod is assumed
+// to expose all endpoints. The node selector and the ports of the pods are not considered,
+// so the estimate is an upper bound.
+func estimateClusterPodMonitoringTargets(ctx context.Context, c client.Reader, cm *monitoringv1.ClusterPodMonitoring) (targetEstimate, error) {
+	selector, err := metav1.LabelSelectorAsSelector(&cm.Spec.Selector)
+	if err != nil {
+		return targetEstimate{}, fmt.Errorf("invalid selector: %w", err)
+	}
+	var pods corev1.PodList
+	if err := c.List(ctx, &pods, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return targetEstimate{}, fmt.Errorf("failed to list pods: %w", err)
+	}
+	excluded := map[string]bool{}
+	for _, ns := range cm.ExcludedNamespaces() {
+		excluded[ns] = true
+	}
+	var included map[string]bool
+	if cm.Spec.NamespaceSelector != nil {
+		namespaces, err := selectedNamespaces(ctx, c, cm)
+		if err != nil {
+			return targetEstimate{}, err
+		}
+		if namespaces != nil {
+			included = map[string]bool{}
+			for _, ns := range namespaces {
+				included[ns] = true
+			}
+		}
+	}
+	filterRunning := cm.Spec.FilterRunning == nil || *cm.Spec.FilterRunning
+
+	var targetsPerPod int
+	for _, ep := range cm.Spec.Endpoints {
+		if len(ep.ProbeTargets) > 0 {
+			targetsPerPod += len(ep.ProbeTargets)
+		} else {
+			targetsPerPod++
+		}
+	}
+	var est targetEstimate
+	namespaces := map[string]bool{}
+	for _, pod := range pods.Items {
+		if excluded[pod.Namespace] || (included != nil && !included[pod.Namespace]) {
+			continue
+		}
+		if filterRunning && (pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed) {
+			continue
+		}
+		est.pods++
+		namespaces[pod.Namespace] = true
+	}
+	est.namespaces = len(namespaces)
+	est.targets = est.pods * targetsPerPod
+	return est, nil
+}
+
+// targetEstimateWarning returns an admission warning with the approximate number of
+// targets of the ClusterPodMonitoring. Failing to estimate them does not fail the
+// admission and is reported as a warning instead.
+func targetEstimateWarning(ctx context.Context, c client.Reader, cm *monitoringv1.ClusterPodMonitoring) string {
+	est, err := estimateClusterPodMonitoringTargets(ctx, c, cm)
+	if apierrors.IsForbidden(err) {
+		return "unable to estimate the number of matched targets: the operator is not permitted to list pods in all namespaces"
+	}
+	if err != nil {
+		return fmt.Sprintf("unable to estimate the number of matched targets: %s", err)
+	}
+	return fmt.Sprintf("ClusterPodMonitoring matches approximately %d targets on %d pods in %d namespaces", est.targets, est.pods, est.namespaces)
+}
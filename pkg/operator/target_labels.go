@@ -0,0 +1,141 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	monitoringv1 "github.com/GoogleCloudPlatform/prometheus-engine/pkg/operator/apis/monitoring/v1"
+	"github.com/go-logr/logr"
+	prometheusv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// TargetLabels is the final label set of an active target of a monitoring resource.
+type TargetLabels struct {
+	// The endpoint of the monitoring resource the target belongs to, e.g. the port.
+	Endpoint  string            `json:"endpoint"`
+	ScrapeURL string            `json:"scrapeUrl"`
+	Health    string            `json:"health"`
+	Labels    map[string]string `json:"labels"`
+}
+
+// targetLabelsHandler serves the active targets of a PodMonitoring or ClusterPodMonitoring
+// with their label sets after relabeling.
+type targetLabelsHandler struct {
+	logger     logr.Logger
+	opts       Options
+	getTarget  getTargetFn
+	httpClient *http.Client
+	kubeClient client.Client
+}
+
+// TargetLabelsHandler returns a handler that serves the active targets of a monitoring
+// resource and their final label sets as JSON. The resource is selected through the
+// `kind` (PodMonitoring or ClusterPodMonitoring), `namespace`, and `name` query parameters.
+// Targets are fetched from the targets API of all collectors on every request.
+func (o *Operator) TargetLabelsHandler() http.Handler {
+	return &targetLabelsHandler{
+		logger:     o.logger,
+		opts:       o.opts,
+		getTarget:  getTarget,
+		httpClient: o.opts.CollectorHTTPClient,
+		kubeClient: o.manager.GetClient(),
+	}
+}
+
+func (h *targetLabelsHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	key, err := monitoringKeyFromQuery(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	targets, err := fetchTargets(req.Context(), h.logger, h.opts, h.httpClient, h.getTarget, h.kubeClient)
+	if err != nil {
+		h.logger.Error(err, "fetching targets failed")
+		http.Error(w, fmt.Sprintf("fetch targets: %s", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(selectTargetLabels(key, targets)); err != nil {
+		h.logger.Error(err, "writing target labels failed")
+	}
+}
+
+// monitoringKeyFromQuery returns the scrape job key of the monitoring resource selected
+// by the request.
+func monitoringKeyFromQuery(req *http.Request) (string, error) {
+	q := req.URL.Query()
+	kind, namespace, name := q.Get("kind"), q.Get("namespace"), q.Get("name")
+	if name == "" {
+		return "", errors.New("name must be set")
+	}
+	switch kind {
+	case "PodMonitoring":
+		if namespace == "" {
+			return "", errors.New("namespace must be set for PodMonitoring")
+		}
+		pm := &monitoringv1.PodMonitoring{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+		return pm.GetKey(), nil
+	case "ClusterPodMonitoring":
+		if namespace != "" {
+			return "", errors.New("namespace must not be set for ClusterPodMonitoring")
+		}
+		cm := &monitoringv1.ClusterPodMonitoring{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		return cm.GetKey(), nil
+	default:
+		return "", fmt.Errorf("kind must be one of PodMonitoring or ClusterPodMonitoring, got %q", kind)
+	}
+}
+
+// selectTargetLabels returns the active targets of the monitoring resource with the given
+// key, sorted by endpoint and scrape URL. Results of unreachable collectors are skipped.
+func selectTargetLabels(key string, targets []*prometheusv1.TargetsResult) []TargetLabels {
+	result := []TargetLabels{}
+	for _, target := range targets {
+		if target == nil {
+			continue
+		}
+		for _, activeTarget := range target.Active {
+			pool, err := parseScrapePool(activeTarget.ScrapePool)
+			if err != nil || pool.key != key {
+				continue
+			}
+			labels := make(map[string]string, len(activeTarget.Labels))
+			for k, v := range activeTarget.Labels {
+				labels[string(k)] = string(v)
+			}
+			result = append(result, TargetLabels{
+				Endpoint:  strings.TrimPrefix(pool.group, "/"),
+				ScrapeURL: activeTarget.ScrapeURL,
+				Health:    string(activeTarget.Health),
+				Labels:    labels,
+			})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Endpoint != result[j].Endpoint {
+			return result[i].Endpoint < result[j].Endpoint
+		}
+		return result[i].ScrapeURL < result[j].ScrapeURL
+	})
+	return result
+}
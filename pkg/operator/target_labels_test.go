@@ -0,0 +1,230 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/go-logr/logr/testr"
+	"github.com/google/go-cmp/cmp"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Response of the targets API of a collector scraping a PodMonitoring with two endpoints
+// and a ClusterPodMonitoring.
+const collectorTargetsResponse = `{
+  "status": "success",
+  "data": {
+    "activeTargets": [
+      {
+        "scrapePool": "PodMonitoring/gmp-test/example/metrics",
+        "scrapeUrl": "http://10.0.0.2:8080/metrics",
+        "health": "up",
+        "discoveredLabels": {"__address__": "10.0.0.2:8080", "__meta_kubernetes_pod_name": "example-2"},
+        "labels": {"instance": "node-a:metrics", "job": "example", "namespace": "gmp-test", "pod": "example-2"}
+      },
+      {
+        "scrapePool": "PodMonitoring/gmp-test/example/metrics",
+        "scrapeUrl": "http://10.0.0.1:8080/metrics",
+        "health": "down",
+        "discoveredLabels": {"__address__": "10.0.0.1:8080", "__meta_kubernetes_pod_name": "example-1"},
+        "labels": {"instance": "node-a:metrics", "job": "example", "namespace": "gmp-test", "pod": "example-1"}
+      },
+      {
+        "scrapePool": "PodMonitoring/gmp-test/example/admin",
+        "scrapeUrl": "http://10.0.0.1:9090/metrics",
+        "health": "up",
+        "discoveredLabels": {"__address__": "10.0.0.1:9090"},
+        "labels": {"instance": "node-a:admin", "job": "example", "namespace": "gmp-test", "pod": "example-1"}
+      },
+      {
+        "scrapePool": "ClusterPodMonitoring/example/metrics",
+        "scrapeUrl": "http://10.0.0.3:8080/metrics",
+        "health": "up",
+        "discoveredLabels": {"__address__": "10.0.0.3:8080"},
+        "labels": {"instance": "node-a:metrics", "job": "example", "namespace": "other", "pod": "other-1"}
+      }
+    ],
+    "droppedTargets": []
+  }
+}`
+
+// newTestTargetLabelsHandler returns a handler that fetches targets from a single fake
+// collector serving collectorTargetsResponse.
+func newTestTargetLabelsHandler(t *testing.T) *targetLabelsHandler {
+	t.Helper()
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/targets" {
+			t.Errorf("unexpected request path %q", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := w.Write([]byte(collectorTargetsResponse)); err != nil {
+			t.Error(err)
+		}
+	}))
+	t.Cleanup(collector.Close)
+
+	host, portStr, err := net.SplitHostPort(collector.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := testr.New(t)
+	opts := Options{
+		ProjectID: "test-proj",
+		Location:  "test-loc",
+		Cluster:   "test-cluster",
+	}
+	if err := opts.defaultAndValidate(logger); err != nil {
+		t.Fatal(err)
+	}
+	kubeClient := newFakeClientBuilder().WithObjects(
+		&appsv1.DaemonSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      NameCollector,
+				Namespace: opts.OperatorNamespace,
+			},
+			Spec: appsv1.DaemonSetSpec{
+				Selector: &metav1.LabelSelector{},
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{
+							Name: CollectorPrometheusContainerName,
+							Ports: []corev1.ContainerPort{{
+								Name:          CollectorPrometheusContainerPortName,
+								ContainerPort: int32(port),
+							}},
+						}},
+					},
+				},
+			},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "collector-a",
+				Namespace: opts.OperatorNamespace,
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: CollectorPrometheusContainerName}},
+			},
+			Status: corev1.PodStatus{PodIP: host},
+		},
+	).Build()
+
+	return &targetLabelsHandler{
+		logger:     logger,
+		opts:       opts,
+		getTarget:  getTarget,
+		httpClient: collector.Client(),
+		kubeClient: kubeClient,
+	}
+}
+
+func TestTargetLabelsHandler(t *testing.T) {
+	handler := newTestTargetLabelsHandler(t)
+
+	cases := []struct {
+		doc    string
+		query  string
+		status int
+		want   []TargetLabels
+	}{
+		{
+			doc:    "pod monitoring",
+			query:  "kind=PodMonitoring&namespace=gmp-test&name=example",
+			status: http.StatusOK,
+			want: []TargetLabels{
+				{
+					Endpoint:  "admin",
+					ScrapeURL: "http://10.0.0.1:9090/metrics",
+					Health:    "up",
+					Labels:    map[string]string{"instance": "node-a:admin", "job": "example", "namespace": "gmp-test", "pod": "example-1"},
+				},
+				{
+					Endpoint:  "metrics",
+					ScrapeURL: "http://10.0.0.1:8080/metrics",
+					Health:    "down",
+					Labels:    map[string]string{"instance": "node-a:metrics", "job": "example", "namespace": "gmp-test", "pod": "example-1"},
+				},
+				{
+					Endpoint:  "metrics",
+					ScrapeURL: "http://10.0.0.2:8080/metrics",
+					Health:    "up",
+					Labels:    map[string]string{"instance": "node-a:metrics", "job": "example", "namespace": "gmp-test", "pod": "example-2"},
+				},
+			},
+		},
+		{
+			doc:    "cluster pod monitoring",
+			query:  "kind=ClusterPodMonitoring&name=example",
+			status: http.StatusOK,
+			want: []TargetLabels{
+				{
+					Endpoint:  "metrics",
+					ScrapeURL: "http://10.0.0.3:8080/metrics",
+					Health:    "up",
+					Labels:    map[string]string{"instance": "node-a:metrics", "job": "example", "namespace": "other", "pod": "other-1"},
+				},
+			},
+		},
+		{
+			doc:    "no targets",
+			query:  "kind=PodMonitoring&namespace=other&name=example",
+			status: http.StatusOK,
+			want:   []TargetLabels{},
+		},
+		{
+			doc:    "missing namespace",
+			query:  "kind=PodMonitoring&name=example",
+			status: http.StatusBadRequest,
+		},
+		{
+			doc:    "unknown kind",
+			query:  "kind=ClusterNodeMonitoring&name=example",
+			status: http.StatusBadRequest,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.doc, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/target-labels?"+c.query, nil))
+
+			if rec.Code != c.status {
+				t.Fatalf("expected status %d, got %d: %s", c.status, rec.Code, rec.Body)
+			}
+			if c.status != http.StatusOK {
+				return
+			}
+			var got []TargetLabels
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(c.want, got); diff != "" {
+				t.Errorf("unexpected target labels (-want, +got): %s", diff)
+			}
+		})
+	}
+}
@@ -128,33 +128,34 @@ func setupTargetStatusPoller(op *Operator, registry prometheus.Registerer, httpC
 	return nil
 }
 
-// shouldPoll verifies if polling collectors is configured or necessary.
-func shouldPoll(ctx context.Context, cfgNamespacedName types.NamespacedName, kubeClient client.Client) (bool, error) {
+// shouldPoll verifies if polling collectors is configured or necessary. It returns the
+// target status configuration if so and nil otherwise.
+func shouldPoll(ctx context.Context, cfgNamespacedName types.NamespacedName, kubeClient client.Client) (*monitoringv1.TargetStatusSpec, error) {
 	// Check if target status is enabled.
 	var config monitoringv1.OperatorConfig
 	if err := kubeClient.Get(ctx, cfgNamespacedName, &config); err != nil {
 		if apierrors.IsNotFound(err) {
-			return false, nil
+			return nil, nil
 		}
-		return false, err
+		return nil, err
 	}
 	if !config.Features.TargetStatus.Enabled {
-		return false, nil
+		return nil, nil
 	}
 
 	// No need to poll if there's no PodMonitorings.
 	var podMonitoringList monitoringv1.PodMonitoringList
 	if err := kubeClient.List(ctx, &podMonitoringList); err != nil {
-		return false, err
+		return nil, err
 	} else if len(podMonitoringList.Items) == 0 {
 		var clusterPodMonitoringList monitoringv1.ClusterPodMonitoringList
 		if err := kubeClient.List(ctx, &clusterPodMonitoringList); err != nil {
-			return false, err
+			return nil, err
 		} else if len(clusterPodMonitoringList.Items) == 0 {
-			return false, nil
+			return nil, nil
 		}
 	}
-	return true, nil
+	return &config.Features.TargetStatus, nil
 }
 
 // Reconcile polls the collector pods, fetches and aggregates target status and
@@ -169,10 +170,10 @@ func (r *targetStatusReconciler) Reconcile(ctx context.Context, _ reconcile.Requ
 		Namespace: r.opts.PublicNamespace,
 	}
 
-	if should, err := shouldPoll(ctx, cfgNamespacedName, r.kubeClient); err != nil {
+	if spec, err := shouldPoll(ctx, cfgNamespacedName, r.kubeClient); err != nil {
 		r.logger.Error(err, "should poll")
-	} else if should {
-		if err := pollAndUpdate(ctx, r.logger, r.opts, r.httpClient, r.getTarget, r.kubeClient); err != nil {
+	} else if spec != nil {
+		if err := pollAndUpdate(ctx, r.logger, r.opts, r.httpClient, r.getTarget, r.kubeClient, spec); err != nil {
 			r.logger.Error(err, "poll and update")
 		} else {
 			// Only log metrics if target polling was successful.
@@ -195,13 +196,13 @@ func (r *targetStatusReconciler) Reconcile(ctx context.Context, _ reconcile.Requ
 }
 
 // pollAndUpdate fetches and updates the target status in each collector pod.
-func pollAndUpdate(ctx context.Context, logger logr.Logger, opts Options, httpClient *http.Client, getTarget getTargetFn, kubeClient client.Client) error {
+func pollAndUpdate(ctx context.Context, logger logr.Logger, opts Options, httpClient *http.Client, getTarget getTargetFn, kubeClient client.Client, spec *monitoringv1.TargetStatusSpec) error {
 	targets, err := fetchTargets(ctx, logger, opts, httpClient, getTarget, kubeClient)
 	if err != nil {
 		return err
 	}
 
-	return updateTargetStatus(ctx, logger, kubeClient, targets)
+	return updateTargetStatus(ctx, logger, kubeClient, targets, spec)
 }
 
 // fetchTargets retrieves the Prometheus targets using the given target function
@@ -307,8 +308,8 @@ func patchPodMonitoringStatus(ctx context.Context, kubeClient client.Client, obj
 
 // updateTargetStatus populates the status object of each pod using the given
 // Prometheus targets.
-func updateTargetStatus(ctx context.Context, logger logr.Logger, kubeClient client.Client, targets []*prometheusv1.TargetsResult) error {
-	endpointMap, err := buildEndpointStatuses(targets)
+func updateTargetStatus(ctx context.Context, logger logr.Logger, kubeClient client.Client, targets []*prometheusv1.TargetsResult, spec *monitoringv1.TargetStatusSpec) error {
+	endpointMap, err := buildEndpointStatuses(targets, spec.DegradedOnTimeout)
 	if err != nil {
 		return err
 	}
@@ -324,9 +325,13 @@ func updateTargetStatus(ctx context.Context, logger logr.Logger, kubeClient clie
 			// Skip hard-coded jobs which we do not patch.
 			continue
 		}
-		pm.GetPodMonitoringStatus().EndpointStatuses = endpointStatuses
-
-		if err := patchPodMonitoringStatus(ctx, kubeClient, pm, pm.GetPodMonitoringStatus()); err != nil {
+		if spec.ReadyThreshold != nil {
+			err = updateTargetsReady(ctx, kubeClient, pm, endpointStatuses, *spec.ReadyThreshold)
+		} else {
+			pm.GetPodMonitoringStatus().EndpointStatuses = endpointStatuses
+			err = patchPodMonitoringStatus(ctx, kubeClient, pm, pm.GetPodMonitoringStatus())
+		}
+		if err != nil {
 			// Save and log any error encountered while patching the status.
 			// We don't want to prematurely return if the error was transient
 			// as we should continue patching all statuses before exiting.
@@ -18,7 +18,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"net/url"
 	"sort"
 	"strconv"
 	"strings"
@@ -229,7 +231,8 @@ func TestUpdateTargetStatus(t *testing.T) {
 												LastScrapeDurationSeconds: "1.2",
 											},
 										},
-										Count: ptr.To(int32(1)),
+										Count:                    ptr.To(int32(1)),
+										MaxScrapeDurationSeconds: "1.2",
 									},
 								},
 								CollectorsFraction: "1",
@@ -295,7 +298,8 @@ func TestUpdateTargetStatus(t *testing.T) {
 												LastScrapeDurationSeconds: "1.2",
 											},
 										},
-										Count: ptr.To(int32(1)),
+										Count:                    ptr.To(int32(1)),
+										MaxScrapeDurationSeconds: "1.2",
 									},
 								},
 								CollectorsFraction: "0.4",
@@ -328,7 +332,8 @@ func TestUpdateTargetStatus(t *testing.T) {
 												LastScrapeDurationSeconds: "2.4",
 											},
 										},
-										Count: ptr.To(int32(1)),
+										Count:                    ptr.To(int32(1)),
+										MaxScrapeDurationSeconds: "2.4",
 									},
 								},
 								CollectorsFraction: "0.4",
@@ -418,7 +423,8 @@ func TestUpdateTargetStatus(t *testing.T) {
 												LastScrapeDurationSeconds: "1.2",
 											},
 										},
-										Count: ptr.To(int32(1)),
+										Count:                    ptr.To(int32(1)),
+										MaxScrapeDurationSeconds: "1.2",
 									},
 								},
 								CollectorsFraction: "1",
@@ -478,7 +484,8 @@ func TestUpdateTargetStatus(t *testing.T) {
 												LastScrapeDurationSeconds: "1.2",
 											},
 										},
-										Count: ptr.To(int32(1)),
+										Count:                    ptr.To(int32(1)),
+										MaxScrapeDurationSeconds: "1.2",
 									},
 								},
 								CollectorsFraction: "1",
@@ -522,15 +529,17 @@ func TestUpdateTargetStatus(t *testing.T) {
 									{
 										SampleTargets: []monitoringv1.SampleTarget{
 											{
-												Health:    "down",
-												LastError: ptr.To("err x"),
+												Health:        "down",
+												LastError:     ptr.To("err x"),
+												FailureReason: monitoringv1.ScrapeFailureUnknown,
 												Labels: map[model.LabelName]model.LabelValue{
 													"instance": "a",
 												},
 												LastScrapeDurationSeconds: "1.2",
 											},
 										},
-										Count: ptr.To(int32(1)),
+										Count:                    ptr.To(int32(1)),
+										MaxScrapeDurationSeconds: "1.2",
 									},
 								},
 								CollectorsFraction: "1",
@@ -581,15 +590,17 @@ func TestUpdateTargetStatus(t *testing.T) {
 									{
 										SampleTargets: []monitoringv1.SampleTarget{
 											{
-												Health:    "down",
-												LastError: ptr.To("err x"),
+												Health:        "down",
+												LastError:     ptr.To("err x"),
+												FailureReason: monitoringv1.ScrapeFailureUnknown,
 												Labels: map[model.LabelName]model.LabelValue{
 													"instance": "b",
 												},
 												LastScrapeDurationSeconds: "1.2",
 											},
 										},
-										Count: ptr.To(int32(1)),
+										Count:                    ptr.To(int32(1)),
+										MaxScrapeDurationSeconds: "1.2",
 									},
 									{
 										SampleTargets: []monitoringv1.SampleTarget{
@@ -601,7 +612,8 @@ func TestUpdateTargetStatus(t *testing.T) {
 												LastScrapeDurationSeconds: "4.3",
 											},
 										},
-										Count: ptr.To(int32(1)),
+										Count:                    ptr.To(int32(1)),
+										MaxScrapeDurationSeconds: "4.3",
 									},
 								},
 								CollectorsFraction: "1",
@@ -671,28 +683,32 @@ func TestUpdateTargetStatus(t *testing.T) {
 									{
 										SampleTargets: []monitoringv1.SampleTarget{
 											{
-												Health:    "down",
-												LastError: ptr.To("err x"),
+												Health:        "down",
+												LastError:     ptr.To("err x"),
+												FailureReason: monitoringv1.ScrapeFailureUnknown,
 												Labels: map[model.LabelName]model.LabelValue{
 													"instance": "a",
 												},
 												LastScrapeDurationSeconds: "5.3",
 											},
 										},
-										Count: ptr.To(int32(1)),
+										Count:                    ptr.To(int32(1)),
+										MaxScrapeDurationSeconds: "5.3",
 									},
 									{
 										SampleTargets: []monitoringv1.SampleTarget{
 											{
-												Health:    "down",
-												LastError: ptr.To("err y"),
+												Health:        "down",
+												LastError:     ptr.To("err y"),
+												FailureReason: monitoringv1.ScrapeFailureUnknown,
 												Labels: map[model.LabelName]model.LabelValue{
 													"instance": "b",
 												},
 												LastScrapeDurationSeconds: "7",
 											},
 										},
-										Count: ptr.To(int32(1)),
+										Count:                    ptr.To(int32(1)),
+										MaxScrapeDurationSeconds: "7",
 									},
 								},
 								CollectorsFraction: "1",
@@ -706,23 +722,26 @@ func TestUpdateTargetStatus(t *testing.T) {
 									{
 										SampleTargets: []monitoringv1.SampleTarget{
 											{
-												Health:    "down",
-												LastError: ptr.To("err x"),
+												Health:        "down",
+												LastError:     ptr.To("err x"),
+												FailureReason: monitoringv1.ScrapeFailureUnknown,
 												Labels: map[model.LabelName]model.LabelValue{
 													"instance": "c",
 												},
 												LastScrapeDurationSeconds: "1.2",
 											},
 											{
-												Health:    "down",
-												LastError: ptr.To("err x"),
+												Health:        "down",
+												LastError:     ptr.To("err x"),
+												FailureReason: monitoringv1.ScrapeFailureUnknown,
 												Labels: map[model.LabelName]model.LabelValue{
 													"instance": "d",
 												},
 												LastScrapeDurationSeconds: "3.6",
 											},
 										},
-										Count: ptr.To(int32(2)),
+										Count:                    ptr.To(int32(2)),
+										MaxScrapeDurationSeconds: "3.6",
 									},
 								},
 								CollectorsFraction: "1",
@@ -806,65 +825,74 @@ func TestUpdateTargetStatus(t *testing.T) {
 									{
 										SampleTargets: []monitoringv1.SampleTarget{
 											{
-												Health:    "down",
-												LastError: ptr.To("err x"),
+												Health:        "down",
+												LastError:     ptr.To("err x"),
+												FailureReason: monitoringv1.ScrapeFailureUnknown,
 												Labels: map[model.LabelName]model.LabelValue{
 													"instance": "b",
 												},
 												LastScrapeDurationSeconds: "6.8",
 											},
 											{
-												Health:    "down",
-												LastError: ptr.To("err x"),
+												Health:        "down",
+												LastError:     ptr.To("err x"),
+												FailureReason: monitoringv1.ScrapeFailureUnknown,
 												Labels: map[model.LabelName]model.LabelValue{
 													"instance": "e",
 												},
 												LastScrapeDurationSeconds: "3.6",
 											},
 											{
-												Health:    "down",
-												LastError: ptr.To("err x"),
+												Health:        "down",
+												LastError:     ptr.To("err x"),
+												FailureReason: monitoringv1.ScrapeFailureUnknown,
 												Labels: map[model.LabelName]model.LabelValue{
 													"instance": "f",
 												},
 												LastScrapeDurationSeconds: "1.2",
 											},
 										},
-										Count: ptr.To(int32(3)),
+										Count:                    ptr.To(int32(3)),
+										MaxScrapeDurationSeconds: "6.8",
 									},
 									{
 										SampleTargets: []monitoringv1.SampleTarget{
 											{
-												Health:    "down",
-												LastError: ptr.To("err y"),
+												Health:        "down",
+												LastError:     ptr.To("err y"),
+												FailureReason: monitoringv1.ScrapeFailureUnknown,
 												Labels: map[model.LabelName]model.LabelValue{
 													"instance": "c",
 												},
 												LastScrapeDurationSeconds: "2.4",
 											},
 										},
-										Count: ptr.To(int32(1)),
+										Count:                    ptr.To(int32(1)),
+										MaxScrapeDurationSeconds: "2.4",
 									},
 									{
 										SampleTargets: []monitoringv1.SampleTarget{
 											{
-												Health:    "down",
-												LastError: ptr.To("err z"),
+												Health:        "down",
+												LastError:     ptr.To("err z"),
+												FailureReason: monitoringv1.ScrapeFailureUnknown,
 												Labels: map[model.LabelName]model.LabelValue{
 													"instance": "a",
 												},
 												LastScrapeDurationSeconds: "5",
 											},
 											{
-												Health:    "down",
-												LastError: ptr.To("err z"),
+												Health:        "down",
+												LastError:     ptr.To("err z"),
+												FailureReason: monitoringv1.ScrapeFailureUnknown,
 												Labels: map[model.LabelName]model.LabelValue{
 													"instance": "d",
 												},
 												LastScrapeDurationSeconds: "4.7",
 											},
 										},
-										Count: ptr.To(int32(2)),
+										Count:                    ptr.To(int32(2)),
+										MaxScrapeDurationSeconds: "5",
 									},
 								},
 								CollectorsFraction: "1",
@@ -980,81 +1008,92 @@ func TestUpdateTargetStatus(t *testing.T) {
 									{
 										SampleTargets: []monitoringv1.SampleTarget{
 											{
-												Health:    "down",
-												LastError: ptr.To("err x"),
+												Health:        "down",
+												LastError:     ptr.To("err x"),
+												FailureReason: monitoringv1.ScrapeFailureUnknown,
 												Labels: map[model.LabelName]model.LabelValue{
 													"instance": "a",
 												},
 												LastScrapeDurationSeconds: "3.6",
 											},
 											{
-												Health:    "down",
-												LastError: ptr.To("err x"),
+												Health:        "down",
+												LastError:     ptr.To("err x"),
+												FailureReason: monitoringv1.ScrapeFailureUnknown,
 												Labels: map[model.LabelName]model.LabelValue{
 													"instance": "b",
 												},
 												LastScrapeDurationSeconds: "6.8",
 											},
 											{
-												Health:    "down",
-												LastError: ptr.To("err x"),
+												Health:        "down",
+												LastError:     ptr.To("err x"),
+												FailureReason: monitoringv1.ScrapeFailureUnknown,
 												Labels: map[model.LabelName]model.LabelValue{
 													"instance": "c",
 												},
 												LastScrapeDurationSeconds: "2.7",
 											},
 											{
-												Health:    "down",
-												LastError: ptr.To("err x"),
+												Health:        "down",
+												LastError:     ptr.To("err x"),
+												FailureReason: monitoringv1.ScrapeFailureUnknown,
 												Labels: map[model.LabelName]model.LabelValue{
 													"instance": "d",
 												},
 												LastScrapeDurationSeconds: "9.5",
 											},
 											{
-												Health:    "down",
-												LastError: ptr.To("err x"),
+												Health:        "down",
+												LastError:     ptr.To("err x"),
+												FailureReason: monitoringv1.ScrapeFailureUnknown,
 												Labels: map[model.LabelName]model.LabelValue{
 													"instance": "e",
 												},
 												LastScrapeDurationSeconds: "4.1",
 											},
 										},
-										Count: ptr.To(int32(7)),
+										Count:                    ptr.To(int32(7)),
+										MaxScrapeDurationSeconds: "9.5",
 									},
 									{
 										SampleTargets: []monitoringv1.SampleTarget{
 											{
-												Health:    "down",
-												LastError: ptr.To("err y"),
+												Health:        "down",
+												LastError:     ptr.To("err y"),
+												FailureReason: monitoringv1.ScrapeFailureUnknown,
 												Labels: map[model.LabelName]model.LabelValue{
 													"instance": "c",
 												},
 												LastScrapeDurationSeconds: "2.4",
 											},
 										},
-										Count: ptr.To(int32(1)),
+										Count:                    ptr.To(int32(1)),
+										MaxScrapeDurationSeconds: "2.4",
 									},
 									{
 										SampleTargets: []monitoringv1.SampleTarget{
 											{
-												Health:    "down",
-												LastError: ptr.To("err z"),
+												Health:        "down",
+												LastError:     ptr.To("err z"),
+												FailureReason: monitoringv1.ScrapeFailureUnknown,
 												Labels: map[model.LabelName]model.LabelValue{
 													"instance": "a",
 												},
 												LastScrapeDurationSeconds: "5",
 											},
 											{
-												Health:    "down",
-												LastError: ptr.To("err z"),
+												Health:        "down",
+												LastError:     ptr.To("err z"),
+												FailureReason: monitoringv1.ScrapeFailureUnknown,
 												Labels: map[model.LabelName]model.LabelValue{
 													"instance": "d",
 												},
 												LastScrapeDurationSeconds: "4.7",
 											},
 										},
-										Count: ptr.To(int32(2)),
+										Count:                    ptr.To(int32(2)),
+										MaxScrapeDurationSeconds: "5",
 									},
 								},
 								CollectorsFraction: "1",
@@ -1127,7 +1166,8 @@ func TestUpdateTargetStatus(t *testing.T) {
 												LastScrapeDurationSeconds: "1.2",
 											},
 										},
-										Count: ptr.To[int32](1),
+										Count:                    ptr.To[int32](1),
+										MaxScrapeDurationSeconds: "1.2",
 									},
 								},
 								CollectorsFraction: "1",
@@ -1226,7 +1266,7 @@ func TestUpdateTargetStatus(t *testing.T) {
 
 			kubeClient := clientBuilder.Build()
 
-			err := updateTargetStatus(context.Background(), testr.New(t), kubeClient, testCase.targets)
+			err := updateTargetStatus(context.Background(), testr.New(t), kubeClient, testCase.targets, &monitoringv1.TargetStatusSpec{Enabled: true})
 			if err != nil && (testCase.expErr == nil || !testCase.expErr(err)) {
 				t.Fatalf("unexpected error updating target status: %s", err)
 			}
@@ -1441,7 +1481,8 @@ func TestPolling(t *testing.T) {
 							LastScrapeDurationSeconds: "1.2",
 						},
 					},
-					Count: ptr.To(int32(1)),
+					Count:                    ptr.To(int32(1)),
+					MaxScrapeDurationSeconds: "1.2",
 				},
 			},
 			CollectorsFraction: "1",
@@ -1472,10 +1513,12 @@ func TestPolling(t *testing.T) {
 								"instance": "a",
 							},
 							LastError:                 ptr.To("err y"),
+							FailureReason:             monitoringv1.ScrapeFailureUnknown,
 							LastScrapeDurationSeconds: "5.4",
 						},
 					},
-					Count: ptr.To(int32(1)),
+					Count:                    ptr.To(int32(1)),
+					MaxScrapeDurationSeconds: "5.4",
 				},
 			},
 			CollectorsFraction: "1",
@@ -1508,7 +1551,8 @@ func TestPolling(t *testing.T) {
 							LastScrapeDurationSeconds: "8.3",
 						},
 					},
-					Count: ptr.To(int32(1)),
+					Count:                    ptr.To(int32(1)),
+					MaxScrapeDurationSeconds: "8.3",
 				},
 			},
 			CollectorsFraction: "1",
@@ -1636,11 +1680,11 @@ func TestShouldPoll(t *testing.T) {
 		}
 		kubeClient := newFakeClientBuilder().WithObjects(tc.objs...).Build()
 		t.Run(tc.desc, func(t *testing.T) {
-			should, err := shouldPoll(ctx, nn, kubeClient)
+			spec, err := shouldPoll(ctx, nn, kubeClient)
 			if err != nil && !tc.expErr {
 				t.Errorf("unexpected shouldPoll error: %s", err)
 			}
-			if should != tc.should {
+			if should := spec != nil; should != tc.should {
 				t.Errorf("got %t, want %t", should, tc.should)
 			}
 		})
@@ -1768,3 +1812,566 @@ func TestFetchTargets(t *testing.T) {
 		})
 	}
 }
+
+func TestScrapeFailureReason(t *testing.T) {
+	cases := []struct {
+		err  string
+		want monitoringv1.ScrapeFailureReason
+	}{
+		{
+			err:  `Get "http://10.0.0.1:8080/metrics": dial tcp 10.0.0.1:8080: connect: connection refused`,
+			want: monitoringv1.ScrapeFailureConnectionRefused,
+		},
+		{
+			err:  `Get "http://10.0.0.1:8080/metrics": context deadline exceeded`,
+			want: monitoringv1.ScrapeFailureTimeout,
+		},
+		{
+			err:  `Get "http://10.0.0.1:8080/metrics": dial tcp 10.0.0.1:8080: i/o timeout`,
+			want: monitoringv1.ScrapeFailureTimeout,
+		},
+		{
+			err:  `Get "http://foo.bar:8080/metrics": dial tcp: lookup foo.bar on 10.0.0.10:53: no such host`,
+			want: monitoringv1.ScrapeFailureDNSLookup,
+		},
+		{
+			err:  `Get "http://foo.bar:8080/metrics": dial tcp: lookup foo.bar on 10.0.0.10:53: read udp 10.0.0.1:41234->10.0.0.10:53: i/o timeout`,
+			want: monitoringv1.ScrapeFailureDNSLookup,
+		},
+		{
+			err:  `Get "http://foo.bar:8080/metrics": dial tcp: lookup foo.bar on 10.0.0.10:53: read udp 10.0.0.1:41234->10.0.0.10:53: read: connection refused`,
+			want: monitoringv1.ScrapeFailureDNSLookup,
+		},
+		{
+			err:  `Get "http://foo.bar:8080/metrics": dial tcp: lookup foo.bar on 10.0.0.10:53: server misbehaving`,
+			want: monitoringv1.ScrapeFailureDNSLookup,
+		},
+		{
+			err:  `Get "http://foo.bar:8080/metrics": dial tcp: lookup foo.bar: no such host`,
+			want: monitoringv1.ScrapeFailureDNSLookup,
+		},
+		{
+			err:  `Get "https://10.0.0.1:8443/metrics": tls: failed to verify certificate: x509: certificate signed by unknown authority`,
+			want: monitoringv1.ScrapeFailureTLSHandshake,
+		},
+		{
+			err:  `Get "https://10.0.0.1:8443/metrics": remote error: tls: handshake failure`,
+			want: monitoringv1.ScrapeFailureTLSHandshake,
+		},
+		{
+			err:  "server returned HTTP status 401 Unauthorized",
+			want: monitoringv1.ScrapeFailureHTTPUnauthorized,
+		},
+		{
+			err:  "server returned HTTP status 403 Forbidden",
+			want: monitoringv1.ScrapeFailureHTTPForbidden,
+		},
+		{
+			err:  "server returned HTTP status 404 Not Found",
+			want: monitoringv1.ScrapeFailureHTTPNotFound,
+		},
+		{
+			err:  "server returned HTTP status 503 Service Unavailable",
+			want: monitoringv1.ScrapeFailureHTTPError,
+		},
+		{
+			err:  "sample limit exceeded",
+			want: monitoringv1.ScrapeFailureLimitExceeded,
+		},
+		{
+			err:  `label_limit exceeded (metric: example_requests_total, number of labels: 6, limit: 5)`,
+			want: monitoringv1.ScrapeFailureLimitExceeded,
+		},
+		{
+			err:  `label_name_length_limit exceeded (metric: example_requests_total, label name: method, length: 6, limit: 4)`,
+			want: monitoringv1.ScrapeFailureLimitExceeded,
+		},
+		{
+			err:  `label_value_length_limit exceeded (metric: example_requests_total, label name: method, value: "POST", length: 4, limit: 2)`,
+			want: monitoringv1.ScrapeFailureLimitExceeded,
+		},
+		{
+			err:  "body size limit exceeded",
+			want: monitoringv1.ScrapeFailureLimitExceeded,
+		},
+		{
+			err:  "Get \"http://10.0.0.1:8080/metrics\": oauth2: cannot fetch token: 401 Unauthorized\nResponse: unauthorized client",
+			want: monitoringv1.ScrapeFailureOAuth2Token,
+		},
+		{
+			err:  `Get "http://10.0.0.1:8080/metrics": oauth2: "invalid_client" "client authentication failed"`,
+			want: monitoringv1.ScrapeFailureOAuth2Token,
+		},
+		{
+			err:  `Get "http://10.0.0.1:8080/metrics": oauth2: cannot fetch token: Post "https://auth.example.com/token": tls: failed to verify certificate: x509: certificate signed by unknown authority`,
+			want: monitoringv1.ScrapeFailureOAuth2Token,
+		},
+		{
+			// The token was fetched successfully but the target rejected it.
+			err:  "server returned HTTP status 401 Unauthorized",
+			want: monitoringv1.ScrapeFailureHTTPUnauthorized,
+		},
+		{
+			err:  `"INVALID" is not a valid start token`,
+			want: monitoringv1.ScrapeFailureUnknown,
+		},
+	}
+	for _, c := range cases {
+		t.Run(string(c.want), func(t *testing.T) {
+			if got := scrapeFailureReason(c.err); got != c.want {
+				t.Errorf("expected reason %q for error %q, got %q", c.want, c.err, got)
+			}
+		})
+	}
+}
+
+func TestScrapeFailureReasonDNSError(t *testing.T) {
+	// DNS errors as returned by the resolver, wrapped like scrape errors of Prometheus.
+	for _, dnsErr := range []*net.DNSError{
+		{Err: "no such host", Name: "foo.bar", Server: "10.0.0.10:53", IsNotFound: true},
+		{Err: "no such host", Name: "foo.bar", IsNotFound: true},
+		{Err: "server misbehaving", Name: "foo.bar", Server: "10.0.0.10:53", IsTemporary: true},
+		{Err: "read udp 10.0.0.1:41234->10.0.0.10:53: i/o timeout", Name: "foo.bar", Server: "10.0.0.10:53", IsTimeout: true},
+	} {
+		err := &url.Error{
+			Op:  "Get",
+			URL: "http://foo.bar:8080/metrics",
+			Err: &net.OpError{Op: "dial", Net: "tcp", Err: dnsErr},
+		}
+		if got := scrapeFailureReason(err.Error()); got != monitoringv1.ScrapeFailureDNSLookup {
+			t.Errorf("expected reason %q for error %q, got %q", monitoringv1.ScrapeFailureDNSLookup, err, got)
+		}
+	}
+}
+
+func TestBuildEndpointStatusesDegradedOnTimeout(t *testing.T) {
+	targets := []*prometheusv1.TargetsResult{
+		{
+			Active: []prometheusv1.ActiveTarget{
+				{
+					Health:     "up",
+					ScrapePool: "PodMonitoring/gmp-test/prom-example-1/metrics",
+					Labels:     model.LabelSet{"instance": "a"},
+				},
+				{
+					Health:     "down",
+					LastError:  `Get "http://10.0.0.2:8080/metrics": context deadline exceeded`,
+					ScrapePool: "PodMonitoring/gmp-test/prom-example-1/metrics",
+					Labels:     model.LabelSet{"instance": "b"},
+				},
+				{
+					Health:     "down",
+					LastError:  `Get "http://10.0.0.3:8080/metrics": dial tcp 10.0.0.3:8080: connect: connection refused`,
+					ScrapePool: "PodMonitoring/gmp-test/prom-example-1/metrics",
+					Labels:     model.LabelSet{"instance": "c"},
+				},
+			},
+		},
+	}
+	cases := []struct {
+		desc              string
+		degradedOnTimeout bool
+		wantUnhealthy     int64
+		wantDegraded      int64
+		wantTimeoutHealth string
+	}{
+		{
+			desc:              "timeouts unhealthy",
+			wantUnhealthy:     2,
+			wantTimeoutHealth: "down",
+		},
+		{
+			desc:              "timeouts degraded",
+			degradedOnTimeout: true,
+			wantUnhealthy:     1,
+			wantDegraded:      1,
+			wantTimeoutHealth: monitoringv1.TargetHealthDegraded,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			endpointMap, err := buildEndpointStatuses(targets, c.degradedOnTimeout)
+			if err != nil {
+				t.Fatal(err)
+			}
+			statuses := endpointMap["PodMonitoring/gmp-test/prom-example-1"]
+			if len(statuses) != 1 {
+				t.Fatalf("expected 1 endpoint status, got %v", endpointMap)
+			}
+			status := statuses[0]
+			if status.ActiveTargets != 3 {
+				t.Errorf("expected 3 active targets, got %d", status.ActiveTargets)
+			}
+			if status.UnhealthyTargets != c.wantUnhealthy {
+				t.Errorf("expected %d unhealthy targets, got %d", c.wantUnhealthy, status.UnhealthyTargets)
+			}
+			if status.DegradedTargets != c.wantDegraded {
+				t.Errorf("expected %d degraded targets, got %d", c.wantDegraded, status.DegradedTargets)
+			}
+			found := false
+			for _, group := range status.SampleGroups {
+				for _, target := range group.SampleTargets {
+					if target.FailureReason != monitoringv1.ScrapeFailureTimeout {
+						continue
+					}
+					found = true
+					if target.Health != c.wantTimeoutHealth {
+						t.Errorf("expected health %q for timed out target, got %q", c.wantTimeoutHealth, target.Health)
+					}
+				}
+			}
+			if !found {
+				t.Errorf("no sample target with failure reason %q", monitoringv1.ScrapeFailureTimeout)
+			}
+		})
+	}
+}
+
+func TestSetTargetsReadyCondition(t *testing.T) {
+	cases := []struct {
+		desc         string
+		statuses     []monitoringv1.ScrapeEndpointStatus
+		threshold    int32
+		wantFraction string
+		wantStatus   corev1.ConditionStatus
+	}{
+		{
+			desc: "all up",
+			statuses: []monitoringv1.ScrapeEndpointStatus{
+				{Name: "a", ActiveTargets: 10},
+			},
+			threshold:    100,
+			wantFraction: "1",
+			wantStatus:   corev1.ConditionTrue,
+		},
+		{
+			desc: "above threshold across endpoints",
+			statuses: []monitoringv1.ScrapeEndpointStatus{
+				{Name: "a", ActiveTargets: 10, UnhealthyTargets: 1},
+				{Name: "b", ActiveTargets: 10},
+			},
+			threshold:    90,
+			wantFraction: "0.95",
+			wantStatus:   corev1.ConditionTrue,
+		},
+		{
+			desc: "at threshold",
+			statuses: []monitoringv1.ScrapeEndpointStatus{
+				{Name: "a", ActiveTargets: 10, UnhealthyTargets: 1},
+			},
+			threshold:    90,
+			wantFraction: "0.9",
+			wantStatus:   corev1.ConditionTrue,
+		},
+		{
+			desc: "below threshold",
+			statuses: []monitoringv1.ScrapeEndpointStatus{
+				{Name: "a", ActiveTargets: 10, UnhealthyTargets: 1},
+			},
+			threshold:    95,
+			wantFraction: "0.9",
+			wantStatus:   corev1.ConditionFalse,
+		},
+		{
+			desc: "degraded targets are not up",
+			statuses: []monitoringv1.ScrapeEndpointStatus{
+				{Name: "a", ActiveTargets: 4, UnhealthyTargets: 1, DegradedTargets: 1},
+			},
+			threshold:    75,
+			wantFraction: "0.5",
+			wantStatus:   corev1.ConditionFalse,
+		},
+		{
+			desc: "zero threshold",
+			statuses: []monitoringv1.ScrapeEndpointStatus{
+				{Name: "a", ActiveTargets: 2, UnhealthyTargets: 2},
+			},
+			threshold:    0,
+			wantFraction: "0",
+			wantStatus:   corev1.ConditionTrue,
+		},
+		{
+			desc:         "no targets",
+			threshold:    0,
+			wantFraction: "0",
+			wantStatus:   corev1.ConditionFalse,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			status := monitoringv1.PodMonitoringStatus{EndpointStatuses: c.statuses}
+			if _, err := setTargetsReadyCondition(&status, 1, c.threshold); err != nil {
+				t.Fatal(err)
+			}
+			if status.HealthyTargetsFraction != c.wantFraction {
+				t.Errorf("expected healthy targets fraction %q, got %q", c.wantFraction, status.HealthyTargetsFraction)
+			}
+			var cond *monitoringv1.MonitoringCondition
+			for i := range status.Conditions {
+				if status.Conditions[i].Type == monitoringv1.TargetsReady {
+					cond = &status.Conditions[i]
+				}
+			}
+			if cond == nil {
+				t.Fatalf("missing condition %q in %v", monitoringv1.TargetsReady, status.Conditions)
+			}
+			if cond.Status != c.wantStatus {
+				t.Errorf("expected condition status %q, got %q: %s", c.wantStatus, cond.Status, cond.Message)
+			}
+		})
+	}
+}
+
+func TestUpdateTargetStatusReadyThreshold(t *testing.T) {
+	now := metav1.Now()
+	pm := &monitoringv1.PodMonitoring{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "prom-example-1",
+			Namespace:  "gmp-test",
+			Generation: 2,
+		},
+		Status: monitoringv1.PodMonitoringStatus{
+			MonitoringStatus: monitoringv1.MonitoringStatus{
+				ObservedGeneration: 2,
+				Conditions: []monitoringv1.MonitoringCondition{{
+					Type:               monitoringv1.ConfigurationCreateSuccess,
+					Status:             corev1.ConditionTrue,
+					LastUpdateTime:     now,
+					LastTransitionTime: now,
+				}},
+			},
+		},
+	}
+	kubeClient := newFakeClientBuilder().WithObjects(pm).Build()
+
+	targets := []*prometheusv1.TargetsResult{{
+		Active: []prometheusv1.ActiveTarget{
+			{Health: "up", ScrapePool: "PodMonitoring/gmp-test/prom-example-1/metrics", Labels: model.LabelSet{"instance": "a"}},
+			{Health: "up", ScrapePool: "PodMonitoring/gmp-test/prom-example-1/metrics", Labels: model.LabelSet{"instance": "b"}},
+			{Health: "up", ScrapePool: "PodMonitoring/gmp-test/prom-example-1/metrics", Labels: model.LabelSet{"instance": "c"}},
+			{Health: "down", ScrapePool: "PodMonitoring/gmp-test/prom-example-1/metrics", Labels: model.LabelSet{"instance": "d"}, LastError: "err"},
+		},
+	}}
+	spec := &monitoringv1.TargetStatusSpec{Enabled: true, ReadyThreshold: ptr.To[int32](75)}
+	if err := updateTargetStatus(context.Background(), testr.New(t), kubeClient, targets, spec); err != nil {
+		t.Fatal(err)
+	}
+
+	var after monitoringv1.PodMonitoring
+	if err := kubeClient.Get(context.Background(), client.ObjectKeyFromObject(pm), &after); err != nil {
+		t.Fatal(err)
+	}
+	if len(after.Status.EndpointStatuses) != 1 || after.Status.EndpointStatuses[0].ActiveTargets != 4 {
+		t.Errorf("unexpected endpoint statuses: %v", after.Status.EndpointStatuses)
+	}
+	if after.Status.HealthyTargetsFraction != "0.75" {
+		t.Errorf("expected healthy targets fraction 0.75, got %q", after.Status.HealthyTargetsFraction)
+	}
+	got := map[monitoringv1.MonitoringConditionType]corev1.ConditionStatus{}
+	for _, c := range after.Status.Conditions {
+		got[c.Type] = c.Status
+	}
+	want := map[monitoringv1.MonitoringConditionType]corev1.ConditionStatus{
+		monitoringv1.ConfigurationCreateSuccess: corev1.ConditionTrue,
+		monitoringv1.TargetsReady:               corev1.ConditionTrue,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected conditions (-want, +got): %s", diff)
+	}
+}
+
+func TestParseScrapePool(t *testing.T) {
+	cases := []struct {
+		pool string
+		want scrapePool
+		err  bool
+	}{
+		{
+			pool: "PodMonitoring/gmp-test/example/metrics",
+			want: scrapePool{key: "PodMonitoring/gmp-test/example", group: "/metrics"},
+		},
+		{
+			// Probe targets are reported as part of their endpoint.
+			pool: "PodMonitoring/gmp-test/blackbox/http/probe-1",
+			want: scrapePool{key: "PodMonitoring/gmp-test/blackbox", group: "/http"},
+		},
+		{
+			pool: "PodMonitoring/gmp-test/probe-example/probe-port",
+			want: scrapePool{key: "PodMonitoring/gmp-test/probe-example", group: "/probe-port"},
+		},
+		{
+			pool: "ClusterPodMonitoring/blackbox/http/probe-0",
+			want: scrapePool{key: "ClusterPodMonitoring/blackbox", group: "/http"},
+		},
+		{
+			pool: "ClusterNodeMonitoring/example/metrics",
+			want: scrapePool{key: "ClusterNodeMonitoring/example", group: "/metrics"},
+		},
+		{
+			pool: "PodMonitoring/gmp-test/example/metrics/other",
+			err:  true,
+		},
+		{
+			pool: "ClusterNodeMonitoring/example/metrics/probe-0",
+			err:  true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.pool, func(t *testing.T) {
+			got, err := parseScrapePool(c.pool)
+			if c.err {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(c.want, got, cmp.AllowUnexported(scrapePool{})); diff != "" {
+				t.Errorf("unexpected scrape pool (-want, +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestBuildEndpointStatusesMaxScrapeDuration(t *testing.T) {
+	var active []prometheusv1.ActiveTarget
+	// More healthy targets than are sampled, with the slowest one sorting last.
+	for i, d := range []float64{0.5, 1.5, 0.2, 0.7, 0.3, 0.1, 4.2} {
+		active = append(active, prometheusv1.ActiveTarget{
+			Health:             "up",
+			ScrapePool:         "PodMonitoring/gmp-test/prom-example-1/metrics",
+			Labels:             model.LabelSet{"instance": model.LabelValue(fmt.Sprintf("target-%d", i))},
+			LastScrapeDuration: d,
+		})
+	}
+	active = append(active, prometheusv1.ActiveTarget{
+		Health:             "down",
+		LastError:          "server returned HTTP status 503 Service Unavailable",
+		ScrapePool:         "PodMonitoring/gmp-test/prom-example-1/metrics",
+		Labels:             model.LabelSet{"instance": "down"},
+		LastScrapeDuration: 0.05,
+	})
+
+	endpointMap, err := buildEndpointStatuses([]*prometheusv1.TargetsResult{{Active: active}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := endpointMap["PodMonitoring/gmp-test/prom-example-1"]
+	if len(statuses) != 1 {
+		t.Fatalf("expected 1 endpoint status, got %v", endpointMap)
+	}
+	var got []string
+	for _, group := range statuses[0].SampleGroups {
+		got = append(got, group.MaxScrapeDurationSeconds)
+	}
+	// The healthy group reports the slowest of all its targets, not only the sampled ones.
+	if diff := cmp.Diff([]string{"0.05", "4.2"}, got); diff != "" {
+		t.Errorf("unexpected max scrape durations (-want, +got): %s", diff)
+	}
+	if n := len(statuses[0].SampleGroups[1].SampleTargets); n != maxSampleTargetSize {
+		t.Errorf("expected %d sampled targets, got %d", maxSampleTargetSize, n)
+	}
+}
+
+func TestBuildEndpointStatusesLastScrapeTime(t *testing.T) {
+	lastScrape := time.Date(2024, 3, 1, 12, 0, 5, 0, time.UTC)
+	targets := []*prometheusv1.TargetsResult{
+		{
+			Active: []prometheusv1.ActiveTarget{
+				{
+					Health:     "up",
+					ScrapePool: "PodMonitoring/gmp-test/prom-example-1/metrics",
+					Labels:     model.LabelSet{"instance": "a"},
+					LastScrape: lastScrape,
+				},
+				{
+					// Not scraped yet.
+					Health:     "unknown",
+					ScrapePool: "PodMonitoring/gmp-test/prom-example-1/metrics",
+					Labels:     model.LabelSet{"instance": "b"},
+				},
+			},
+		},
+	}
+	endpointMap, err := buildEndpointStatuses(targets, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := endpointMap["PodMonitoring/gmp-test/prom-example-1"]
+	if len(statuses) != 1 {
+		t.Fatalf("expected 1 endpoint status, got %v", endpointMap)
+	}
+	got := map[model.LabelValue]*metav1.Time{}
+	for _, group := range statuses[0].SampleGroups {
+		for _, target := range group.SampleTargets {
+			got[target.Labels["instance"]] = target.LastScrapeTime
+		}
+	}
+	want := map[model.LabelValue]*metav1.Time{
+		"a": ptr.To(metav1.NewTime(lastScrape)),
+		"b": nil,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected last scrape times (-want, +got): %s", diff)
+	}
+}
+
+func TestBuildEndpointStatusesAggregatedLastScrapeTime(t *testing.T) {
+	earlier := time.Date(2024, 3, 1, 12, 0, 5, 0, time.UTC)
+	later := earlier.Add(10 * time.Second)
+	// Each collector reports the targets on its node.
+	targets := []*prometheusv1.TargetsResult{
+		{
+			Active: []prometheusv1.ActiveTarget{
+				{
+					Health:     "up",
+					ScrapePool: "PodMonitoring/gmp-test/prom-example-1/metrics",
+					Labels:     model.LabelSet{"instance": "a"},
+					LastScrape: earlier,
+				},
+				{
+					Health:     "down",
+					ScrapePool: "PodMonitoring/gmp-test/prom-example-1/metrics",
+					Labels:     model.LabelSet{"instance": "b"},
+					LastError:  "connection refused",
+					LastScrape: later.Add(time.Minute),
+				},
+			},
+		},
+		{
+			Active: []prometheusv1.ActiveTarget{
+				{
+					Health:     "up",
+					ScrapePool: "PodMonitoring/gmp-test/prom-example-1/metrics",
+					Labels:     model.LabelSet{"instance": "c"},
+					LastScrape: later,
+				},
+				{
+					Health:     "down",
+					ScrapePool: "PodMonitoring/gmp-test/prom-example-1/other",
+					Labels:     model.LabelSet{"instance": "d"},
+					LastError:  "connection refused",
+					LastScrape: later,
+				},
+			},
+		},
+	}
+	endpointMap, err := buildEndpointStatuses(targets, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]*metav1.Time{}
+	for _, status := range endpointMap["PodMonitoring/gmp-test/prom-example-1"] {
+		got[status.Name] = status.LastScrapeTime
+	}
+	// Failed scrapes are not taken into account.
+	want := map[string]*metav1.Time{
+		"PodMonitoring/gmp-test/prom-example-1/metrics": ptr.To(metav1.NewTime(later)),
+		"PodMonitoring/gmp-test/prom-example-1/other":   nil,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected last scrape times (-want, +got): %s", diff)
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	monitoringv1 "github.com/GoogleCloudPlatform/prometheus-engine/pkg/operator/apis/monitoring/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// setTargetsReadyCondition sets the healthy targets fraction and the TargetsReady condition
+// based on the endpoint statuses and the ready threshold in percent. It returns whether
+// the conditions changed.
+func setTargetsReadyCondition(status *monitoringv1.PodMonitoringStatus, gen int64, threshold int32) (bool, error) {
+	var active, up int64
+	for _, s := range status.EndpointStatuses {
+		active += s.ActiveTargets
+		up += s.ActiveTargets - s.UnhealthyTargets - s.DegradedTargets
+	}
+	fraction := 0.0
+	if active > 0 {
+		fraction = float64(up) / float64(active)
+	}
+	status.HealthyTargetsFraction = strconv.FormatFloat(fraction, 'f', -1, 64)
+
+	cond := &monitoringv1.MonitoringCondition{
+		Type:    monitoringv1.TargetsReady,
+		Status:  corev1.ConditionTrue,
+		Reason:  "ThresholdReached",
+		Message: fmt.Sprintf("%d of %d active targets are up, at least %d%% are required", up, active, threshold),
+	}
+	if active == 0 || up*100 < int64(threshold)*active {
+		cond.Status = corev1.ConditionFalse
+		cond.Reason = "BelowThreshold"
+	}
+	return status.SetMonitoringCondition(gen, metav1.Now(), cond)
+}
+
+// updateTargetsReady updates the endpoint statuses of the monitoring resource together with
+// its healthy targets fraction and TargetsReady condition.
+func updateTargetsReady(ctx context.Context, kubeClient client.Client, pm monitoringv1.PodMonitoringCRD, endpointStatuses []monitoringv1.ScrapeEndpointStatus, threshold int32) error {
+	if err := kubeClient.Get(ctx, client.ObjectKeyFromObject(pm), pm); err != nil {
+		return fmt.Errorf("unable to get %s: %w", pm.GetKey(), err)
+	}
+	status := pm.GetPodMonitoringStatus()
+	status.EndpointStatuses = endpointStatuses
+	if _, err := setTargetsReadyCondition(status, pm.GetGeneration(), threshold); err != nil {
+		return err
+	}
+	// The conditions are replaced as a whole and may be updated concurrently by the
+	// collection reconciler. The resource version makes the patch fail in that case.
+	patchObject := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"resourceVersion": pm.GetResourceVersion(),
+		},
+		"status": map[string]interface{}{
+			"endpointStatuses":       status.EndpointStatuses,
+			"healthyTargetsFraction": status.HealthyTargetsFraction,
+			"observedGeneration":     status.ObservedGeneration,
+			"conditions":             status.Conditions,
+		},
+	}
+	patchBytes, err := json.Marshal(patchObject)
+	if err != nil {
+		return fmt.Errorf("unable to marshall status: %w", err)
+	}
+	if err := kubeClient.Status().Patch(ctx, pm, client.RawPatch(types.MergePatchType, patchBytes)); err != nil {
+		return fmt.Errorf("unable to patch status: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	monitoringv1 "github.com/GoogleCloudPlatform/prometheus-engine/pkg/operator/apis/monitoring/v1"
+)
+
+var existingResourcesInvalid = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "gmp_existing_resources_invalid",
+	Help: "Number of existing resources found at startup that the admission webhooks would reject.",
+}, []string{"kind"})
+
+// invalidResource is an existing resource that fails admission validation.
+type invalidResource struct {
+	kind string
+	key  string
+	err  error
+}
+
+// setupExistingResourceValidation validates all existing PodMonitorings and ClusterPodMonitorings
+// once the caches are synced and reports the ones that fail validation. Invalid resources
+// are not modified and keep being processed as before.
+func setupExistingResourceValidation(op *Operator, registry prometheus.Registerer) error {
+	if err := registry.Register(existingResourcesInvalid); err != nil {
+		return err
+	}
+	namePattern, err := op.opts.namePatternRegexp()
+	if err != nil {
+		return err
+	}
+	validator := &podMonitoringValidator{namePattern: namePattern}
+
+	return op.manager.Add(manager.RunnableFunc(func(ctx context.Context) error {
+		invalid, err := validateExistingResources(ctx, op.manager.GetClient(), validator)
+		if err != nil {
+			// Validation is informational only and must not stop the operator.
+			op.logger.Error(err, "validating existing resources failed")
+			return nil
+		}
+		reportInvalidResources(op.logger, invalid)
+		return nil
+	}))
+}
+
+// validateExistingResources returns the existing resources that the validator rejects on
+// creation, i.e. that could not be created again as they are.
+func validateExistingResources(ctx context.Context, c client.Reader, validator *podMonitoringValidator) ([]invalidResource, error) {
+	var invalid []invalidResource
+
+	var pmList monitoringv1.PodMonitoringList
+	if err := c.List(ctx, &pmList); err != nil {
+		return nil, fmt.Errorf("list PodMonitorings: %w", err)
+	}
+	for i := range pmList.Items {
+		pm := &pmList.Items[i]
+		if _, err := validator.ValidateCreate(ctx, pm); err != nil {
+			invalid = append(invalid, invalidResource{kind: "PodMonitoring", key: pm.GetKey(), err: err})
+		}
+	}
+	var cmList monitoringv1.ClusterPodMonitoringList
+	if err := c.List(ctx, &cmList); err != nil {
+		return nil, fmt.Errorf("list ClusterPodMonitorings: %w", err)
+	}
+	for i := range cmList.Items {
+		cm := &cmList.Items[i]
+		if _, err := validator.ValidateCreate(ctx, cm); err != nil {
+			invalid = append(invalid, invalidResource{kind: "ClusterPodMonitoring", key: cm.GetKey(), err: err})
+		}
+	}
+	return invalid, nil
+}
+
+func reportInvalidResources(logger logr.Logger, invalid []invalidResource) {
+	counts := map[string]float64{"PodMonitoring": 0, "ClusterPodMonitoring": 0}
+	for _, r := range invalid {
+		logger.Info("existing resource fails validation", "kind", r.kind, "resource", r.key, "err", r.err.Error())
+		counts[r.kind]++
+	}
+	for kind, n := range counts {
+		existingResourcesInvalid.WithLabelValues(kind).Set(n)
+	}
+	logger.Info("validated existing resources", "invalid", len(invalid))
+}
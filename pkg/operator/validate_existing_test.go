@@ -0,0 +1,89 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/go-logr/logr/testr"
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	monitoringv1 "github.com/GoogleCloudPlatform/prometheus-engine/pkg/operator/apis/monitoring/v1"
+)
+
+func TestValidateExistingResources(t *testing.T) {
+	ctx := context.Background()
+	endpoints := func(interval, timeout string) []monitoringv1.ScrapeEndpoint {
+		return []monitoringv1.ScrapeEndpoint{{
+			Port:     intstr.FromString("metrics"),
+			Interval: interval,
+			Timeout:  timeout,
+		}}
+	}
+	kubeClient := newFakeClientBuilder().WithObjects(
+		&monitoringv1.PodMonitoring{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "team-a-valid"},
+			Spec:       monitoringv1.PodMonitoringSpec{Endpoints: endpoints("10s", "")},
+		},
+		&monitoringv1.PodMonitoring{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "team-a-timeout"},
+			Spec:       monitoringv1.PodMonitoringSpec{Endpoints: endpoints("10s", "20s")},
+		},
+		&monitoringv1.PodMonitoring{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns2", Name: "unprefixed"},
+			Spec:       monitoringv1.PodMonitoringSpec{Endpoints: endpoints("10s", "")},
+		},
+		&monitoringv1.ClusterPodMonitoring{
+			ObjectMeta: metav1.ObjectMeta{Name: "team-b-valid"},
+			Spec:       monitoringv1.ClusterPodMonitoringSpec{Endpoints: endpoints("30s", "")},
+		},
+		&monitoringv1.ClusterPodMonitoring{
+			ObjectMeta: metav1.ObjectMeta{Name: "unprefixed"},
+			Spec:       monitoringv1.ClusterPodMonitoringSpec{Endpoints: endpoints("30s", "")},
+		},
+	).Build()
+
+	validator := &podMonitoringValidator{namePattern: regexp.MustCompile("^(?:team-[a-z]+-.+)$")}
+	invalid, err := validateExistingResources(ctx, kubeClient, validator)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, r := range invalid {
+		got = append(got, r.key)
+	}
+	want := []string{
+		"PodMonitoring/ns1/team-a-timeout",
+		"PodMonitoring/ns2/unprefixed",
+		"ClusterPodMonitoring/unprefixed",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("unexpected invalid resources (-want, +got): %s", diff)
+	}
+
+	reportInvalidResources(testr.New(t), invalid)
+	if n := testutil.ToFloat64(existingResourcesInvalid.WithLabelValues("PodMonitoring")); n != 2 {
+		t.Errorf("expected 2 invalid PodMonitorings, got %v", n)
+	}
+	if n := testutil.ToFloat64(existingResourcesInvalid.WithLabelValues("ClusterPodMonitoring")); n != 1 {
+		t.Errorf("expected 1 invalid ClusterPodMonitoring, got %v", n)
+	}
+}
@@ -16,6 +16,7 @@ package rules
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/labels"
@@ -77,6 +78,35 @@ func FromAPIRules(groups []monitoringv1.RuleGroup) (result rulefmt.RuleGroups, e
 	return result, nil
 }
 
+// AddLabels sets the given labels on the results of all rules in the given groups.
+// Labels that are already set on a rule are kept.
+// An error is returned if a label name is invalid.
+func AddLabels(groups *rulefmt.RuleGroups, lset map[string]string) error {
+	for name := range lset {
+		if !model.LabelName(name).IsValid() || strings.HasPrefix(name, model.ReservedLabelPrefix) {
+			return fmt.Errorf("invalid label name %q", name)
+		}
+	}
+	if len(lset) == 0 {
+		return nil
+	}
+	for _, g := range groups.Groups {
+		for i, r := range g.Rules {
+			// Copy as the labels may be shared with the resource the rules were converted from.
+			ruleLabels := make(map[string]string, len(r.Labels)+len(lset))
+			for name, value := range lset {
+				ruleLabels[name] = value
+			}
+			for name, value := range r.Labels {
+				ruleLabels[name] = value
+			}
+			r.Labels = ruleLabels
+			g.Rules[i] = r
+		}
+	}
+	return nil
+}
+
 // Scope all rules in the given groups to the given labels. All metric selectors
 // check for equality on the labels and all rule results are annotated with them again.
 // This ensures that the scope is preserved in output data, even if the given label keys
@@ -73,3 +73,53 @@ func TestScope(t *testing.T) {
 		t.Fatalf("unexpected result (-want, +got):\n %s", diff)
 	}
 }
+
+func TestAddLabels(t *testing.T) {
+	input := `groups:
+- name: test
+  rules:
+  - record: rule:1
+    expr: vector(1)
+  - alert: Bar
+    expr: my_metric > 0
+    labels:
+      severity: critical
+`
+	groups, errs := rulefmt.Parse([]byte(input))
+	if len(errs) > 0 {
+		t.Fatalf("Unexpected input errors: %s", errs)
+	}
+	if err := AddLabels(groups, map[string]string{
+		"team":     "a",
+		"severity": "warning",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want := `groups:
+    - name: test
+      rules:
+        - record: rule:1
+          expr: vector(1)
+          labels:
+            severity: warning
+            team: a
+        - alert: Bar
+          expr: my_metric > 0
+          labels:
+            severity: critical
+            team: a
+`
+	got, err := yaml.Marshal(groups)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Fatalf("unexpected result (-want, +got):\n %s", diff)
+	}
+
+	for _, name := range []string{"__name__", "team-name", ""} {
+		if err := AddLabels(groups, map[string]string{name: "a"}); err == nil {
+			t.Errorf("expected error for label name %q", name)
+		}
+	}
+}
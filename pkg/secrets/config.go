@@ -16,6 +16,7 @@ package secrets
 
 import (
 	"context"
+	"time"
 
 	"github.com/go-kit/log"
 )
@@ -37,4 +38,8 @@ func (fn *SecretFn) Fetch(ctx context.Context) (string, error) {
 // ProviderOptions provides options for a Provider.
 type ProviderOptions struct {
 	Logger log.Logger
+	// DeletionGracePeriod is how long the last-known value of a deleted secret keeps being
+	// served. This avoids failing requests while a secret is briefly deleted, e.g. during
+	// rotation. Zero disables the grace period.
+	DeletionGracePeriod time.Duration
 }
@@ -533,7 +533,7 @@ func TestProvider(t *testing.T) {
 
 		t.Run(tc.description, func(t *testing.T) {
 			ctx := context.Background()
-			provider := newWatchProvider(ctx, log.NewNopLogger(), c)
+			provider := newWatchProvider(ctx, log.NewNopLogger(), c, 0)
 
 			tc.test(ctx, c, provider)
 			require.True(t, provider.isClean())
@@ -549,7 +549,7 @@ func TestProvider(t *testing.T) {
 
 		parentCtx := context.Background()
 		ctx, cancel := context.WithCancel(parentCtx)
-		provider := newWatchProvider(ctx, log.NewNopLogger(), c)
+		provider := newWatchProvider(ctx, log.NewNopLogger(), c, 0)
 
 		key := typeBinary.entries[0].key
 		s, err := provider.Add(toSecretConfig(typeBinary.secret, key))
@@ -588,7 +588,7 @@ func TestProvider(t *testing.T) {
 		})
 
 		ctx := context.Background()
-		provider := newWatchProvider(ctx, log.NewNopLogger(), proxyClient)
+		provider := newWatchProvider(ctx, log.NewNopLogger(), proxyClient, 0)
 
 		key := typeBinary.entries[0].key
 		s, err := provider.Add(toSecretConfig(typeBinary.secret, key))
@@ -621,6 +621,76 @@ func TestProvider(t *testing.T) {
 	})
 }
 
+func TestProviderDeletionGracePeriod(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns1",
+			Name:      "s1",
+		},
+		Data: map[string][]byte{
+			"k1": []byte("Hello world!"),
+		},
+	}
+	notFoundErr := fmt.Errorf("secret %s/%s not found", secret.Namespace, secret.Name)
+
+	t.Run("delete and recreate within grace period", func(t *testing.T) {
+		c := fake.NewSimpleClientset(secret.DeepCopy())
+		ctx := context.Background()
+		provider := newWatchProvider(ctx, log.NewNopLogger(), c, time.Hour)
+
+		s, err := provider.Add(toSecretConfig(secret, "k1"))
+		require.NoError(t, err)
+		requireFetchEquals(ctx, t, s, "Hello world!")
+
+		err = c.CoreV1().Secrets(secret.Namespace).Delete(ctx, secret.Name, metav1.DeleteOptions{})
+		require.NoError(t, err)
+
+		// Wait for the deletion to be observed before checking the stale value is served.
+		w := provider.secretKeyToWatcher[toSecretConfig(secret, "k1").objectKey().String()]
+		require.NoError(t, wait.PollUntilContextTimeout(ctx, time.Millisecond, 10*time.Second, true, func(context.Context) (bool, error) {
+			w.mu.Lock()
+			defer w.mu.Unlock()
+			return w.s == nil, nil
+		}))
+		requireFetchEquals(ctx, t, s, "Hello world!")
+
+		recreated := secret.DeepCopy()
+		updateKey(recreated, "k1", "Goodbye")
+		_, err = c.CoreV1().Secrets(secret.Namespace).Create(ctx, recreated, metav1.CreateOptions{})
+		require.NoError(t, err)
+
+		requireFetchEquals(ctx, t, s, "Goodbye")
+
+		provider.Remove(toSecretConfig(secret, "k1"))
+		requireFetchFail(ctx, t, s, notFoundErr)
+		require.True(t, provider.isClean())
+	})
+	t.Run("delete and grace period expires", func(t *testing.T) {
+		c := fake.NewSimpleClientset(secret.DeepCopy())
+		ctx := context.Background()
+		provider := newWatchProvider(ctx, log.NewNopLogger(), c, 100*time.Millisecond)
+
+		s, err := provider.Add(toSecretConfig(secret, "k1"))
+		require.NoError(t, err)
+		requireFetchEquals(ctx, t, s, "Hello world!")
+
+		err = c.CoreV1().Secrets(secret.Namespace).Delete(ctx, secret.Name, metav1.DeleteOptions{})
+		require.NoError(t, err)
+
+		requireFetchFail(ctx, t, s, notFoundErr)
+
+		recreated := secret.DeepCopy()
+		updateKey(recreated, "k1", "Goodbye")
+		_, err = c.CoreV1().Secrets(secret.Namespace).Create(ctx, recreated, metav1.CreateOptions{})
+		require.NoError(t, err)
+
+		requireFetchEquals(ctx, t, s, "Goodbye")
+
+		provider.Remove(toSecretConfig(secret, "k1"))
+		require.True(t, provider.isClean())
+	})
+}
+
 func (p *watchProvider) isClean() bool {
 	return len(p.secretKeyToWatcher) == 0
 }
@@ -22,6 +22,7 @@ import (
 	"time"
 
 	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -41,7 +42,7 @@ func (c *WatchSPConfig) newProvider(ctx context.Context, opts ProviderOptions) (
 	if err != nil {
 		return nil, err
 	}
-	return newWatchProvider(ctx, opts.Logger, client), nil
+	return newWatchProvider(ctx, opts.Logger, client, opts.DeletionGracePeriod), nil
 }
 
 type secretWatcher struct {
@@ -51,12 +52,19 @@ type secretWatcher struct {
 	s        *corev1.Secret
 	refCount uint
 	done     bool
+
+	// The last-known secret and when it was deleted, used to keep serving the secret value
+	// for the duration of the grace period.
+	gracePeriod time.Duration
+	stale       *corev1.Secret
+	deletedAt   time.Time
 }
 
-func newWatcher(ctx context.Context, logger log.Logger, client kubernetes.Interface, config *KubernetesSecretConfig) (*secretWatcher, error) {
+func newWatcher(ctx context.Context, logger log.Logger, client kubernetes.Interface, config *KubernetesSecretConfig, gracePeriod time.Duration) (*secretWatcher, error) {
 	watcher := &secretWatcher{
-		refCount: 1,
-		done:     false,
+		refCount:    1,
+		done:        false,
+		gracePeriod: gracePeriod,
 	}
 
 	if err := watcher.start(ctx, client, config); err != nil {
@@ -77,6 +85,7 @@ func newWatcher(ctx context.Context, logger log.Logger, client kubernetes.Interf
 					watcher.mu.Lock()
 					defer watcher.mu.Lock()
 					watcher.s = nil
+					watcher.stale = nil
 					return
 				}
 				// If closed unintentionally (i.e. network issues), try and restart it.
@@ -110,7 +119,14 @@ func (w *secretWatcher) update(logger log.Logger, e watch.Event) {
 	case watch.Modified, watch.Added:
 		secret := e.Object.(*corev1.Secret)
 		w.s = secret
+		w.stale = nil
 	case watch.Deleted:
+		if w.gracePeriod > 0 && w.s != nil {
+			//nolint:errcheck
+			level.Warn(logger).Log("msg", "secret deleted, serving last-known value during grace period", "namespace", w.s.Namespace, "name", w.s.Name, "grace_period", w.gracePeriod)
+			w.stale = w.s
+			w.deletedAt = time.Now()
+		}
 		w.s = nil
 	case watch.Bookmark:
 		// Disabled explicitly when creating the watch interface.
@@ -125,6 +141,9 @@ func (w *secretWatcher) secret(config *KubernetesSecretConfig) Secret {
 		w.mu.Lock()
 		defer w.mu.Unlock()
 		if w.s == nil {
+			if w.stale != nil && time.Since(w.deletedAt) < w.gracePeriod {
+				return getValue(w.stale, config.Key)
+			}
 			return "", fmt.Errorf("secret %s/%s not found", config.Namespace, config.Name)
 		}
 		return getValue(w.s, config.Key)
@@ -158,6 +177,7 @@ func (w *secretWatcher) restart(ctx context.Context, client kubernetes.Interface
 		w.mu.Lock()
 		defer w.mu.Unlock()
 		w.s = nil
+		w.stale = nil
 		return true, nil
 	}
 
@@ -172,6 +192,7 @@ func (w *secretWatcher) restart(ctx context.Context, client kubernetes.Interface
 	// Check again in case the watcher cancelled while we were waiting for the mutex.
 	if w.done {
 		w.s = nil
+		w.stale = nil
 		return true, nil
 	}
 
@@ -187,6 +208,7 @@ func (w *secretWatcher) close() {
 	defer w.mu.Unlock()
 	w.w.Stop()
 	w.s = nil
+	w.stale = nil
 }
 
 type watchProvider struct {
@@ -194,14 +216,16 @@ type watchProvider struct {
 	client             kubernetes.Interface
 	secretKeyToWatcher map[string]*secretWatcher
 	logger             log.Logger
+	gracePeriod        time.Duration
 }
 
-func newWatchProvider(ctx context.Context, logger log.Logger, client kubernetes.Interface) *watchProvider {
+func newWatchProvider(ctx context.Context, logger log.Logger, client kubernetes.Interface, gracePeriod time.Duration) *watchProvider {
 	return &watchProvider{
 		ctx:                ctx,
 		client:             client,
 		secretKeyToWatcher: map[string]*secretWatcher{},
 		logger:             logger,
+		gracePeriod:        gracePeriod,
 	}
 }
 
@@ -215,7 +239,7 @@ func (p *watchProvider) Add(config *KubernetesSecretConfig) (Secret, error) {
 	}
 
 	var err error
-	val, err = newWatcher(p.ctx, p.logger, p.client, config)
+	val, err = newWatcher(p.ctx, p.logger, p.client, config, p.gracePeriod)
 	if err != nil {
 		return nil, err
 	}
@@ -257,5 +281,6 @@ func (p *watchProvider) Remove(config *KubernetesSecretConfig) {
 	val.mu.Lock()
 	defer val.mu.Unlock()
 	val.done = true
+	val.stale = nil
 	val.w.Stop()
 }